@@ -0,0 +1,41 @@
+package snip
+
+import (
+	"github.com/ryanfrishkorn/snip/database"
+	"strings"
+)
+
+// Append is a wrapper around Store.Append using the default store
+func (s *Snip) Append(data string) error {
+	return defaultStore().Append(s, data)
+}
+
+// Append adds data to the end of the snip on a new line, then saves and reindexes it in a single transaction. The
+// uuid and attachments are unchanged, and the previous data is kept as a revision. The combined data is subject to
+// MaxDataSize.
+func (st *Store) Append(s *Snip, data string) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	updated := *s
+	if updated.Data != "" && !strings.HasSuffix(updated.Data, "\n") {
+		updated.Data += "\n"
+	}
+	updated.Data += data
+	if err := CheckDataSize(len(updated.Data)); err != nil {
+		return err
+	}
+
+	err := database.WithTx(st.Conn, func() error {
+		err := st.Update(&updated)
+		if err != nil {
+			return err
+		}
+		return st.Index(&updated)
+	})
+	if err != nil {
+		return err
+	}
+	s.Data = updated.Data
+	return nil
+}
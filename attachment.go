@@ -1,30 +1,138 @@
 package snip
 
 import (
+	"encoding/base64"
 	"fmt"
 	"github.com/bvinc/go-sqlite-lite/sqlite3"
 	"github.com/google/uuid"
 	"github.com/ryanfrishkorn/snip/database"
+	"io"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// AttachmentStreamThreshold is the size in bytes at which attachment data is streamed instead of held in memory
+var AttachmentStreamThreshold = 16 * 1024 * 1024
+
+// attachmentChunkSize is the buffer size used when streaming attachment data
+const attachmentChunkSize = 64 * 1024
+
 // Attachment represents data (binary safe) associated with a specific snip
 type Attachment struct {
-	UUID      uuid.UUID
-	Data      []byte
-	Size      int
-	SnipUUID  uuid.UUID
-	Timestamp time.Time
-	Name      string
+	UUID      uuid.UUID `json:"uuid"`
+	Data      []byte    `json:"data"`
+	Size      int       `json:"size"`
+	SnipUUID  uuid.UUID `json:"snip_uuid"`
+	Timestamp time.Time `json:"timestamp"`
+	Name      string    `json:"name"`
+	MIME      string    `json:"mime"`
 }
 
-// GetAttachmentMetadata returns all fields except Data for analysis without large memory use
+// base64LineLength is the number of encoded characters per line, matching MIME
+const base64LineLength = 76
+
+// EncodeBase64 writes data to w as standard base64, wrapped into lines that are safe to paste through text channels
+func EncodeBase64(w io.Writer, data []byte) error {
+	enc := NewBase64Writer(w)
+	_, err := enc.Write(data)
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// NewBase64Writer returns a writer encoding the data written to it as EncodeBase64 does, so that data can be streamed
+// to w. Close must be called to write the final line.
+func NewBase64Writer(w io.Writer) io.WriteCloser {
+	lines := &base64LineWriter{w: w}
+	return &base64Writer{lines: lines, encoder: base64.NewEncoder(base64.StdEncoding, lines)}
+}
+
+// base64Writer encodes data as base64 through encoder, which writes it wrapped by lines
+type base64Writer struct {
+	lines   *base64LineWriter
+	encoder io.WriteCloser
+}
+
+func (b *base64Writer) Write(p []byte) (int, error) {
+	return b.encoder.Write(p)
+}
+
+// Close flushes the encoder and ends a partial final line
+func (b *base64Writer) Close() error {
+	err := b.encoder.Close()
+	if err != nil {
+		return err
+	}
+	if b.lines.column > 0 {
+		_, err = io.WriteString(b.lines.w, "\n")
+	}
+	return err
+}
+
+// base64LineWriter writes encoded characters to w, ending a line every base64LineLength characters
+type base64LineWriter struct {
+	w      io.Writer
+	column int
+}
+
+func (l *base64LineWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := base64LineLength - l.column
+		if n > len(p) {
+			n = len(p)
+		}
+		_, err := l.w.Write(p[:n])
+		if err != nil {
+			return written, err
+		}
+		written += n
+		l.column += n
+		p = p[n:]
+		if l.column == base64LineLength {
+			_, err = io.WriteString(l.w, "\n")
+			if err != nil {
+				return written, err
+			}
+			l.column = 0
+		}
+	}
+	return written, nil
+}
+
+// DecodeBase64 returns the data encoded as standard base64, ignoring any whitespace such as line breaks
+func DecodeBase64(encoded []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(encoded)), ""))
+}
+
+// DetectMIME returns the MIME type of data, considering at most the first 512 bytes
+func DetectMIME(data []byte) string {
+	if len(data) > 512 {
+		data = data[:512]
+	}
+	return http.DetectContentType(data)
+}
+
+// MediaType returns the MIME type of the attachment without any parameters such as charset
+func (a *Attachment) MediaType() string {
+	mediaType, _, _ := strings.Cut(a.MIME, ";")
+	return strings.TrimSpace(mediaType)
+}
+
+// GetAttachmentMetadata is a wrapper around Store.GetAttachmentMetadata using the default store
 func GetAttachmentMetadata(searchUUID uuid.UUID) (Attachment, error) {
+	return defaultStore().GetAttachmentMetadata(searchUUID)
+}
+
+// GetAttachmentMetadata returns all fields except Data for analysis without large memory use
+func (st *Store) GetAttachmentMetadata(searchUUID uuid.UUID) (Attachment, error) {
 	a := Attachment{}
 
 	var stmt *sqlite3.Stmt
-	stmt, err := database.Conn.Prepare(`SELECT size, snip_uuid, timestamp, name FROM snip_attachment WHERE uuid = ?`, searchUUID.String())
+	stmt, err := st.Conn.Prepare(`SELECT size, snip_uuid, timestamp, name, mime FROM snip_attachment WHERE uuid = ?`, searchUUID.String())
 	if err != nil {
 		return a, err
 	}
@@ -55,12 +163,14 @@ func GetAttachmentMetadata(searchUUID uuid.UUID) (Attachment, error) {
 			snipUUID  string
 			timestamp string
 			name      string
+			mime      string
 		)
-		err = stmt.Scan(&size, &snipUUID, &timestamp, &name)
+		err = stmt.Scan(&size, &snipUUID, &timestamp, &name, &mime)
 		if err != nil {
 			return a, err
 		}
 		a.UUID = searchUUID
+		a.SnipUUID, err = uuid.Parse(snipUUID)
 		if err != nil {
 			return a, fmt.Errorf("error parsing uuid string into struct")
 		}
@@ -73,6 +183,7 @@ func GetAttachmentMetadata(searchUUID uuid.UUID) (Attachment, error) {
 			return a, err
 		}
 		a.Name = name
+		a.MIME = mime
 	}
 	if resultCount == 0 {
 		return a, fmt.Errorf("database search returned zero results")
@@ -80,12 +191,134 @@ func GetAttachmentMetadata(searchUUID uuid.UUID) (Attachment, error) {
 	return a, nil
 }
 
+// ResolveAttachmentUUID is a wrapper around Store.ResolveAttachmentUUID using the default store
+func ResolveAttachmentUUID(searchUUID string) (uuid.UUID, error) {
+	return defaultStore().ResolveAttachmentUUID(searchUUID)
+}
+
+// ResolveAttachmentUUID returns the uuid of the attachment matching a full or partial uuid without reading its data
+func (st *Store) ResolveAttachmentUUID(searchUUID string) (uuid.UUID, error) {
+	if searchUUID == "" {
+		return uuid.UUID{}, fmt.Errorf("refusing to search for empty string")
+	}
+	stmt, err := st.Conn.Prepare(`SELECT uuid FROM snip_attachment WHERE uuid LIKE ?`, "%"+searchUUID+"%")
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	defer stmt.Close()
+
+	var ids []string
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+		if !hasRow {
+			break
+		}
+		var idStr string
+		err = stmt.Scan(&idStr)
+		if err != nil {
+			return uuid.UUID{}, err
+		}
+		ids = append(ids, idStr)
+	}
+	if len(ids) == 0 {
+		return uuid.UUID{}, fmt.Errorf("database search returned zero results")
+	}
+	// enforce only one result to avoid ambiguous behavior
+	if len(ids) > 1 {
+		return uuid.UUID{}, fmt.Errorf("database search returned multiple results")
+	}
+	return uuid.Parse(ids[0])
+}
+
+// OrphanedAttachmentError indicates that the snip an attachment belongs to does not exist
+type OrphanedAttachmentError struct {
+	AttachmentUUID uuid.UUID
+	SnipUUID       uuid.UUID
+}
+
+func (e *OrphanedAttachmentError) Error() string {
+	return fmt.Sprintf("attachment %s belongs to snip %s, which does not exist", e.AttachmentUUID, e.SnipUUID)
+}
+
+// GetAttachmentMetadataFromUUID is a wrapper around Store.GetAttachmentMetadataFromUUID using the default store
+func GetAttachmentMetadataFromUUID(searchUUID string) (Attachment, error) {
+	return defaultStore().GetAttachmentMetadataFromUUID(searchUUID)
+}
+
+// GetAttachmentMetadataFromUUID returns all fields except Data of the attachment matching a full or partial uuid
+func (st *Store) GetAttachmentMetadataFromUUID(searchUUID string) (Attachment, error) {
+	id, err := st.ResolveAttachmentUUID(searchUUID)
+	if err != nil {
+		return Attachment{}, err
+	}
+	return st.GetAttachmentMetadata(id)
+}
+
+// GetAttachmentOwner is a wrapper around Store.GetAttachmentOwner using the default store
+func GetAttachmentOwner(id uuid.UUID) (Snip, error) {
+	return defaultStore().GetAttachmentOwner(id)
+}
+
+// GetAttachmentOwner returns the snip the attachment belongs to. If that snip does not exist, an
+// OrphanedAttachmentError is returned.
+func (st *Store) GetAttachmentOwner(id uuid.UUID) (Snip, error) {
+	a, err := st.GetAttachmentMetadata(id)
+	if err != nil {
+		return Snip{}, err
+	}
+	exists, err := st.SnipExists(a.SnipUUID)
+	if err != nil {
+		return Snip{}, err
+	}
+	if !exists {
+		return Snip{}, &OrphanedAttachmentError{AttachmentUUID: id, SnipUUID: a.SnipUUID}
+	}
+	return st.GetFromUUID(a.SnipUUID.String())
+}
+
+// AttachmentsTotalSize is a wrapper around Store.AttachmentsTotalSize using the default store
+func AttachmentsTotalSize() (int, error) {
+	return defaultStore().AttachmentsTotalSize()
+}
+
+// AttachmentsTotalSize returns the sum of the recorded sizes of all attachments without reading their data
+func (st *Store) AttachmentsTotalSize() (int, error) {
+	var total int
+	stmt, err := st.Conn.Prepare(`SELECT coalesce(sum(size), 0) FROM snip_attachment`)
+	if err != nil {
+		return total, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return total, err
+	}
+	if !hasRow {
+		return total, nil
+	}
+	err = stmt.Scan(&total)
+	if err != nil {
+		return total, err
+	}
+	return total, nil
+}
+
+// GetAttachmentFromUUID is a wrapper around Store.GetAttachmentFromUUID using the default store
 func GetAttachmentFromUUID(searchUUID string) (Attachment, error) {
+	return defaultStore().GetAttachmentFromUUID(searchUUID)
+}
+
+// GetAttachmentFromUUID returns the attachment matching a full or partial uuid
+func (st *Store) GetAttachmentFromUUID(searchUUID string) (Attachment, error) {
 	a := Attachment{}
 
 	searchUUIDFuzzy := "%" + searchUUID + "%"
 	var stmt *sqlite3.Stmt
-	stmt, err := database.Conn.Prepare(`SELECT uuid, data, name, size, snip_uuid, timestamp FROM snip_attachment WHERE uuid LIKE ?`, searchUUIDFuzzy)
+	stmt, err := st.Conn.Prepare(`SELECT uuid, data, name, size, snip_uuid, timestamp, mime FROM snip_attachment WHERE uuid LIKE ?`, searchUUIDFuzzy)
 	if err != nil {
 		return a, err
 	}
@@ -117,8 +350,9 @@ func GetAttachmentFromUUID(searchUUID string) (Attachment, error) {
 			size      string
 			snipUUID  string
 			timestamp string
+			mime      string
 		)
-		err = stmt.Scan(&id, &data, &name, &size, &snipUUID, &timestamp)
+		err = stmt.Scan(&id, &data, &name, &size, &snipUUID, &timestamp, &mime)
 		if err != nil {
 			return a, err
 		}
@@ -126,6 +360,10 @@ func GetAttachmentFromUUID(searchUUID string) (Attachment, error) {
 		if err != nil {
 			return a, fmt.Errorf("error parsing uuid string into uuid type")
 		}
+		a.SnipUUID, err = uuid.Parse(snipUUID)
+		if err != nil {
+			return a, fmt.Errorf("error parsing uuid string into uuid type")
+		}
 		a.Data = []byte(data)
 		a.Size, err = strconv.Atoi(size)
 		if err != nil {
@@ -136,6 +374,7 @@ func GetAttachmentFromUUID(searchUUID string) (Attachment, error) {
 			return a, err
 		}
 		a.Name = name
+		a.MIME = mime
 	}
 	if resultCount == 0 {
 		return a, fmt.Errorf("database search returned zero results")
@@ -143,6 +382,138 @@ func GetAttachmentFromUUID(searchUUID string) (Attachment, error) {
 	return a, nil
 }
 
+// getAttachmentRowID returns the rowid of an attachment, which is required for incremental blob I/O
+func (st *Store) getAttachmentRowID(id uuid.UUID) (int64, error) {
+	var rowID int64
+	stmt, err := st.Conn.Prepare(`SELECT rowid FROM snip_attachment WHERE uuid = ?`, id.String())
+	if err != nil {
+		return rowID, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return rowID, err
+	}
+	if !hasRow {
+		return rowID, fmt.Errorf("database search returned zero results")
+	}
+	err = stmt.Scan(&rowID)
+	if err != nil {
+		return rowID, err
+	}
+	return rowID, nil
+}
+
+// StreamAttachment is a wrapper around Store.StreamAttachment using the default store
+func StreamAttachment(id uuid.UUID, w io.Writer) (int64, error) {
+	return defaultStore().StreamAttachment(id, w)
+}
+
+// StreamAttachment copies attachment data to w in chunks without loading the entire blob
+func (st *Store) StreamAttachment(id uuid.UUID, w io.Writer) (int64, error) {
+	rowID, err := st.getAttachmentRowID(id)
+	if err != nil {
+		return 0, err
+	}
+	blob, err := st.Conn.BlobIO("main", "snip_attachment", "data", rowID, false)
+	if err != nil {
+		return 0, err
+	}
+	defer blob.Close()
+
+	return io.CopyBuffer(w, blob, make([]byte, attachmentChunkSize))
+}
+
+// InsertAttachment is a wrapper around Store.InsertAttachment using the default store
+func InsertAttachment(a Attachment) error {
+	return defaultStore().InsertAttachment(a)
+}
+
+// InsertAttachment adds an Attachment to the database, preserving its uuid and timestamp
+func (st *Store) InsertAttachment(a Attachment) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	stmt, err := st.Conn.Prepare(`INSERT INTO snip_attachment (uuid, snip_uuid, timestamp, name, data, size, mime) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	err = database.ExecStmt(st.Conn, stmt, a.UUID.String(), a.SnipUUID.String(), a.Timestamp.Format(time.RFC3339Nano), a.Name, a.Data, len(a.Data), a.MIME)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// MoveAttachment is a wrapper around Store.MoveAttachment using the default store
+func MoveAttachment(attachmentID uuid.UUID, destSnipID uuid.UUID) error {
+	return defaultStore().MoveAttachment(attachmentID, destSnipID)
+}
+
+// MoveAttachment associates an attachment with a different snip
+func (st *Store) MoveAttachment(attachmentID uuid.UUID, destSnipID uuid.UUID) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	// validate both sides before modifying anything
+	_, err := st.GetAttachmentMetadata(attachmentID)
+	if err != nil {
+		return fmt.Errorf("could not locate attachment %s: %v", attachmentID, err)
+	}
+	exists, err := st.SnipExists(destSnipID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("could not locate snip %s", destSnipID)
+	}
+
+	return st.Conn.Exec(`UPDATE snip_attachment SET snip_uuid = ? WHERE uuid = ?`, destSnipID.String(), attachmentID.String())
+}
+
+// RenameAttachment is a wrapper around Store.RenameAttachment using the default store
+func RenameAttachment(id uuid.UUID, newName string) error {
+	return defaultStore().RenameAttachment(id, newName)
+}
+
+// RenameAttachment updates the name of an attachment
+func (st *Store) RenameAttachment(id uuid.UUID, newName string) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	if newName == "" {
+		return fmt.Errorf("attachment name cannot be empty")
+	}
+	_, err := st.GetAttachmentMetadata(id)
+	if err != nil {
+		return fmt.Errorf("could not locate attachment %s: %v", id, err)
+	}
+
+	return st.Conn.Exec(`UPDATE snip_attachment SET name = ? WHERE uuid = ?`, newName, id.String())
+}
+
+// ReplaceAttachment is a wrapper around Store.ReplaceAttachment using the default store
+func ReplaceAttachment(id uuid.UUID, data []byte) error {
+	return defaultStore().ReplaceAttachment(id, data)
+}
+
+// ReplaceAttachment replaces the data of an attachment, updating its size and MIME type. The uuid, name, and snip of
+// the attachment are kept.
+func (st *Store) ReplaceAttachment(id uuid.UUID, data []byte) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	_, err := st.GetAttachmentMetadata(id)
+	if err != nil {
+		return fmt.Errorf("could not locate attachment %s: %v", id, err)
+	}
+
+	return st.Conn.Exec(`UPDATE snip_attachment SET data = ?, size = ?, mime = ? WHERE uuid = ?`, data, len(data), DetectMIME(data), id.String())
+}
+
 // NewAttachment returns a new attachment struct with current defaults
 func NewAttachment() Attachment {
 	return Attachment{
@@ -154,10 +525,31 @@ func NewAttachment() Attachment {
 	}
 }
 
-// RemoveAttachment deletes an attachment from the database
+// ReassignAttachments is a wrapper around Store.ReassignAttachments using the default store
+func ReassignAttachments(fromSnipID uuid.UUID, toSnipID uuid.UUID) error {
+	return defaultStore().ReassignAttachments(fromSnipID, toSnipID)
+}
+
+// ReassignAttachments associates all attachments of one snip with another snip
+func (st *Store) ReassignAttachments(fromSnipID uuid.UUID, toSnipID uuid.UUID) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	return st.Conn.Exec(`UPDATE snip_attachment SET snip_uuid = ? WHERE snip_uuid = ?`, toSnipID.String(), fromSnipID.String())
+}
+
+// RemoveAttachment is a wrapper around Store.RemoveAttachment using the default store
 func RemoveAttachment(id uuid.UUID) error {
+	return defaultStore().RemoveAttachment(id)
+}
+
+// RemoveAttachment deletes an attachment from the database
+func (st *Store) RemoveAttachment(id uuid.UUID) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
 	// see if it exists first
-	stmt, err := database.Conn.Prepare(`SELECT uuid FROM snip_attachment where uuid = ? LIMIT 2`, id.String())
+	stmt, err := st.Conn.Prepare(`SELECT uuid FROM snip_attachment where uuid = ? LIMIT 2`, id.String())
 	if err != nil {
 		return err
 	}
@@ -185,7 +577,7 @@ func RemoveAttachment(id uuid.UUID) error {
 	}
 
 	// remove
-	stmt, err = database.Conn.Prepare(`DELETE FROM snip_attachment WHERE uuid = ?`, id.String())
+	stmt, err = st.Conn.Prepare(`DELETE FROM snip_attachment WHERE uuid = ?`, id.String())
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,105 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListBetween is a wrapper around Store.ListBetween using the default store
+func ListBetween(start time.Time, end time.Time) ([]Snip, error) {
+	return defaultStore().ListBetween(start, end)
+}
+
+// ListBetween returns Snips created at or after start and before end, ordered by creation. A zero start or end leaves
+// that side of the range open. Timestamps are compared as RFC3339Nano text, which sorts by time for snips created in
+// the local time zone, so the bounds are given in that zone.
+func (st *Store) ListBetween(start time.Time, end time.Time) ([]Snip, error) {
+	startStr, endStr := betweenBounds(start, end)
+	stmt, err := st.Conn.Prepare(`SELECT uuid, timestamp, name, data, favorite FROM snip
+		WHERE (?1 = '' OR timestamp >= ?1) AND (?2 = '' OR timestamp < ?2) ORDER BY timestamp`, startStr, endStr)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	return scanSnips(stmt)
+}
+
+// UUIDsBetween is a wrapper around Store.UUIDsBetween using the default store
+func UUIDsBetween(start time.Time, end time.Time) ([]uuid.UUID, error) {
+	return defaultStore().UUIDsBetween(start, end)
+}
+
+// UUIDsBetween returns the uuids of the snips ListBetween would return, without reading their data
+func (st *Store) UUIDsBetween(start time.Time, end time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	startStr, endStr := betweenBounds(start, end)
+	stmt, err := st.Conn.Prepare(`SELECT uuid FROM snip
+		WHERE (?1 = '' OR timestamp >= ?1) AND (?2 = '' OR timestamp < ?2) ORDER BY timestamp`, startStr, endStr)
+	if err != nil {
+		return ids, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return ids, err
+		}
+		if !hasRow {
+			break
+		}
+		var idStr string
+		err = stmt.Scan(&idStr)
+		if err != nil {
+			return ids, err
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// betweenBounds formats start and end in the local time zone for comparison with timestamps, leaving zero bounds empty
+func betweenBounds(start time.Time, end time.Time) (string, string) {
+	var startStr, endStr string
+	if !start.IsZero() {
+		startStr = start.In(time.Local).Format(time.RFC3339Nano)
+	}
+	if !end.IsZero() {
+		endStr = end.In(time.Local).Format(time.RFC3339Nano)
+	}
+	return startStr, endStr
+}
+
+// ParseTimeBound parses value as an RFC3339 time, a local date such as 2006-01-02, or a time relative to now such as
+// 30m, 12h, 7d, or 2w
+func ParseTimeBound(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return t, nil
+	}
+
+	// days and weeks are not units of time.ParseDuration
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if !strings.HasSuffix(value, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(value, suffix))
+		if err == nil && n >= 0 {
+			return now.Add(-time.Duration(n) * unit), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("time %q must be RFC3339, a date such as 2006-01-02, or relative such as 12h, 7d, or 2w", value)
+}
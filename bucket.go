@@ -0,0 +1,37 @@
+package snip
+
+// DefaultBucketHigh is the default lowest score of the high band of BucketScores
+const DefaultBucketHigh = 0.6
+
+// DefaultBucketMedium is the default lowest score of the medium band of BucketScores
+const DefaultBucketMedium = 0.3
+
+// ScoreBucket is a band of search scores, holding the scores at or above Min and below the band before it
+type ScoreBucket struct {
+	Name   string
+	Min    float64
+	Scores []SearchScore
+}
+
+// BucketScores groups scores sorted from highest to lowest into high, medium, and low bands, where high and medium
+// are the lowest scores of their bands. The order of scores is kept, and empty bands are omitted.
+func BucketScores(scores []SearchScore, high float64, medium float64) []ScoreBucket {
+	bands := []ScoreBucket{
+		{Name: "high", Min: high},
+		{Name: "medium", Min: medium},
+		{Name: "low", Min: 0},
+	}
+	var buckets []ScoreBucket
+	band := 0
+	for idx, score := range scores {
+		// a new bucket begins with the first score below the band before it
+		if idx == 0 || score.Score < bands[band].Min {
+			for band < len(bands)-1 && score.Score < bands[band].Min {
+				band++
+			}
+			buckets = append(buckets, bands[band])
+		}
+		buckets[len(buckets)-1].Scores = append(buckets[len(buckets)-1].Scores, score)
+	}
+	return buckets
+}
@@ -0,0 +1,57 @@
+package snip
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommand is an external tool that reads data for the clipboard from standard input
+type clipboardCommand struct {
+	Name string
+	Args []string
+}
+
+// clipboardCommands lists candidate clipboard tools in order of preference for each platform
+var clipboardCommands = map[string][]clipboardCommand{
+	"darwin": {
+		{Name: "pbcopy"},
+	},
+	"linux": {
+		{Name: "xclip", Args: []string{"-selection", "clipboard"}},
+		{Name: "wl-copy"},
+	},
+	"windows": {
+		{Name: "clip"},
+	},
+}
+
+// CopyToClipboard writes data to the system clipboard using the first available platform tool
+func CopyToClipboard(data []byte) error {
+	return copyWith(clipboardCommands[runtime.GOOS], data)
+}
+
+// copyWith pipes data to the first candidate command found in the path
+func copyWith(candidates []clipboardCommand, data []byte) error {
+	var names []string
+	for _, c := range candidates {
+		names = append(names, c.Name)
+		path, err := exec.LookPath(c.Name)
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, c.Args...)
+		cmd.Stdin = bytes.NewReader(data)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %v: %s", c.Name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("clipboard is not supported on %s", runtime.GOOS)
+	}
+	return fmt.Errorf("no clipboard tool found, looked for: %s", strings.Join(names, ", "))
+}
@@ -0,0 +1,71 @@
+package snip
+
+import (
+	"github.com/google/uuid"
+	"github.com/ryanfrishkorn/snip/database"
+	"time"
+)
+
+// CloneSuffix is appended to the name of a cloned snip
+const CloneSuffix = " (copy)"
+
+// Clone is a wrapper around Store.Clone using the default store
+func (s *Snip) Clone() (Snip, error) {
+	return defaultStore().Clone(s)
+}
+
+// Clone inserts a copy of the snip with a new uuid, the current time, and CloneSuffix appended to its name.
+// Attachments are copied with new uuids, metadata is copied, and the copy is indexed in the same stemming mode as the
+// original.
+func (st *Store) Clone(s *Snip) (Snip, error) {
+	if err := st.checkWritable(); err != nil {
+		return Snip{}, err
+	}
+	clone := New()
+	clone.Name = s.Name + CloneSuffix
+	clone.Data = s.Data
+
+	stemmed, err := st.Stemmed(s)
+	if err != nil {
+		return Snip{}, err
+	}
+	ids, err := st.GetAttachmentsUUID(s.UUID)
+	if err != nil {
+		return Snip{}, err
+	}
+
+	err = database.WithTx(st.Conn, func() error {
+		err := st.InsertSnip(clone)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			// attachment data is copied within the database rather than read into memory
+			err = st.Conn.Exec(`INSERT INTO snip_attachment (uuid, snip_uuid, timestamp, name, data, size, mime)
+				SELECT ?, ?, ?, name, data, size, mime FROM snip_attachment WHERE uuid = ?`,
+				uuid.New().String(), clone.UUID.String(), time.Now().Format(time.RFC3339Nano), id.String())
+			if err != nil {
+				return err
+			}
+		}
+		err = st.Conn.Exec(`INSERT INTO snip_meta (snip_uuid, key, value) SELECT ?, key, value FROM snip_meta WHERE snip_uuid = ?`,
+			clone.UUID.String(), s.UUID.String())
+		if err != nil {
+			return err
+		}
+		termsPositions, err := clone.analyzeTerms(stemmed)
+		if err != nil {
+			return err
+		}
+		return st.writeIndex(&clone, termsPositions, stemmed)
+	})
+	if err != nil {
+		return Snip{}, err
+	}
+
+	clone.Attachments, err = st.GetAttachments(clone.UUID)
+	if err != nil {
+		return clone, err
+	}
+	return clone, nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ryanfrishkorn/snip"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errFinderCancelled indicates that the finder was closed without selecting a candidate
+var errFinderCancelled = errors.New("selection cancelled")
+
+// finderAction is the outcome of a key pressed in a finder
+type finderAction int
+
+const (
+	finderContinue finderAction = iota // the selection continues
+	finderAccept                       // the selected candidate was chosen
+	finderCancel                       // the finder was closed without a selection
+)
+
+// finder is the state of an interactive selection from candidates filtered by snip.FuzzyMatch as the query is typed
+type finder struct {
+	Candidates []string
+	Query      []rune
+	Matches    []int // indexes of the candidates matching the query, best first
+	Selected   int   // position within Matches of the highlighted candidate
+}
+
+// newFinder returns a finder of candidates filtered by the initial query
+func newFinder(candidates []string, query string) *finder {
+	f := &finder{Candidates: candidates, Query: []rune(query)}
+	f.filter()
+	return f
+}
+
+// filter matches the candidates against the query, highlighting the best match
+func (f *finder) filter() {
+	f.Matches = snip.FuzzyMatch(string(f.Query), f.Candidates)
+	f.Selected = 0
+}
+
+// Choice returns the index within Candidates of the highlighted candidate, or -1 if nothing matches
+func (f *finder) Choice() int {
+	if len(f.Matches) == 0 {
+		return -1
+	}
+	return f.Matches[f.Selected]
+}
+
+// Key applies a key read from the terminal, such as a rune or an arrow key escape sequence
+func (f *finder) Key(key string) finderAction {
+	switch key {
+	case "\r", "\n":
+		if len(f.Matches) == 0 {
+			return finderContinue
+		}
+		return finderAccept
+	case "\x1b", "\x03", "\x04", "\x07": // escape, ctrl-c, ctrl-d, ctrl-g
+		return finderCancel
+	case "\x7f", "\x08": // backspace
+		if len(f.Query) > 0 {
+			f.Query = f.Query[:len(f.Query)-1]
+			f.filter()
+		}
+	case "\x15": // ctrl-u
+		if len(f.Query) > 0 {
+			f.Query = nil
+			f.filter()
+		}
+	case "\x1b[A", "\x1bOA", "\x10": // up, ctrl-p
+		if f.Selected > 0 {
+			f.Selected--
+		}
+	case "\x1b[B", "\x1bOB", "\x0e": // down, ctrl-n
+		if f.Selected < len(f.Matches)-1 {
+			f.Selected++
+		}
+	default:
+		r, size := utf8.DecodeRuneInString(key)
+		if size == len(key) && r != utf8.RuneError && unicode.IsPrint(r) {
+			f.Query = append(f.Query, r)
+			f.filter()
+		}
+	}
+	return finderContinue
+}
+
+// Render draws the query and as many matches as fit within rows and cols to w, scrolling to keep the highlighted
+// match visible. Lines end with a carriage return, as the terminal does not translate newlines in raw mode.
+func (f *finder) Render(w io.Writer, rows int, cols int) {
+	// clear the screen and move to the top
+	fmt.Fprint(w, "\x1b[H\x1b[2J")
+	visible := rows - 1
+	top := 0
+	if f.Selected >= visible {
+		top = f.Selected - visible + 1
+	}
+	for i := top; i < len(f.Matches) && i < top+visible; i++ {
+		marker := "  "
+		if i == f.Selected {
+			marker = "> "
+		}
+		fmt.Fprintf(w, "%s%s\r\n", marker, truncateRunes(snip.FlattenString(f.Candidates[f.Matches[i]]), cols-len(marker)))
+	}
+	// the prompt is placed on the last row, leaving the cursor after the query
+	prompt := fmt.Sprintf("%d/%d > %s", len(f.Matches), len(f.Candidates), string(f.Query))
+	fmt.Fprintf(w, "\x1b[%d;1H%s", rows, truncateRunes(prompt, cols-1))
+}
+
+// truncateRunes returns s shortened to at most n runes
+func truncateRunes(s string, n int) string {
+	if n < 0 {
+		n = 0
+	}
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// splitKeys splits input read from a terminal into keys, each an escape sequence such as an arrow key or a single rune
+func splitKeys(input []byte) []string {
+	var keys []string
+	for i := 0; i < len(input); {
+		if input[i] == 0x1b && i+1 < len(input) && (input[i+1] == '[' || input[i+1] == 'O') {
+			end := i + 2
+			if input[i+1] == '[' {
+				// parameter bytes precede the final byte of a control sequence
+				for end < len(input) && input[end] >= 0x30 && input[end] <= 0x3f {
+					end++
+				}
+			}
+			if end < len(input) {
+				end++
+			}
+			keys = append(keys, string(input[i:end]))
+			i = end
+			continue
+		}
+		_, size := utf8.DecodeRune(input[i:])
+		keys = append(keys, string(input[i:i+size]))
+		i += size
+	}
+	return keys
+}
+
+// runFinder selects one of the candidates interactively on the terminal tty, returning its index. The terminal is
+// placed in raw mode with stty for the duration, using the alternate screen so that the display is restored
+// afterwards. errFinderCancelled is returned if the finder is closed without a selection.
+func runFinder(tty *os.File, candidates []string, query string) (int, error) {
+	state, err := stty(tty, "-g")
+	if err != nil {
+		return -1, err
+	}
+	if _, err = stty(tty, "raw", "-echo"); err != nil {
+		return -1, err
+	}
+	defer stty(tty, state)
+
+	rows, cols := 24, 80
+	if size, err := stty(tty, "size"); err == nil {
+		fields := strings.Fields(size)
+		if len(fields) == 2 {
+			r, errRows := strconv.Atoi(fields[0])
+			c, errCols := strconv.Atoi(fields[1])
+			if errRows == nil && errCols == nil && r > 1 && c > 0 {
+				rows, cols = r, c
+			}
+		}
+	}
+
+	fmt.Fprint(tty, "\x1b[?1049h")
+	defer fmt.Fprint(tty, "\x1b[?1049l")
+
+	f := newFinder(candidates, query)
+	buf := make([]byte, 256)
+	for {
+		f.Render(tty, rows, cols)
+		n, err := tty.Read(buf)
+		if err != nil {
+			return -1, err
+		}
+		for _, key := range splitKeys(buf[:n]) {
+			switch f.Key(key) {
+			case finderAccept:
+				return f.Choice(), nil
+			case finderCancel:
+				return -1, errFinderCancelled
+			}
+		}
+	}
+}
+
+// stty runs stty with args on the terminal tty, returning its output
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
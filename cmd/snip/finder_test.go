@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFinderKeys(t *testing.T) {
+	f := newFinder([]string{"alpha", "beta", "gamma"}, "")
+	for _, key := range splitKeys([]byte("am\x1b[B")) {
+		if action := f.Key(key); action != finderContinue {
+			t.Fatalf("expected key %q to continue, got %v", key, action)
+		}
+	}
+	if string(f.Query) != "am" || !reflect.DeepEqual(f.Matches, []int{2}) {
+		t.Fatalf("expected query am to match only gamma, got %q %v", string(f.Query), f.Matches)
+	}
+
+	f.Key("\x7f")
+	f.Key("\x1b[B")
+	// gamma matches a earlier than beta
+	if f.Choice() != 2 {
+		t.Errorf("expected gamma selected after moving down, got %d", f.Choice())
+	}
+	if action := f.Key("\r"); action != finderAccept {
+		t.Errorf("expected enter to accept, got %v", action)
+	}
+	if action := f.Key("\x1b"); action != finderCancel {
+		t.Errorf("expected escape to cancel, got %v", action)
+	}
+
+	if keys := splitKeys([]byte("\x1bOAé\x1b")); !reflect.DeepEqual(keys, []string{"\x1bOA", "é", "\x1b"}) {
+		t.Errorf("unexpected keys %q", keys)
+	}
+}
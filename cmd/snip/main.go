@@ -20,26 +20,39 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/bvinc/go-sqlite-lite/sqlite3"
 	"github.com/fatih/color"
 	"github.com/google/uuid"
+	"github.com/mattn/go-isatty"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/ryanfrishkorn/snip"
 	"github.com/ryanfrishkorn/snip/database"
 	"io"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"os"
-	"path"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+	"unicode"
 	"unicode/utf8"
 )
 
+// quiet suppresses informational messages, leaving data, warnings, and errors
+var quiet bool
+
 func main() {
 	// configure logging
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
@@ -49,180 +62,797 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
 
-	// check env for explicit database path
-	dbFilePath := os.Getenv("SNIP_DB")
-	if dbFilePath == "" {
-		homePath := os.Getenv("HOME")
-		dbFilename := ".snip.sqlite3"
-		if homePath == "" {
-			fmt.Fprintf(os.Stderr, "please $HOME env to your home directory for database save location")
-			log.Debug().Msg("could not retrieve $HOME environment variable")
+	// check env for maximum data size
+	maxSizeStr := os.Getenv("SNIP_MAX_SIZE")
+	if maxSizeStr != "" {
+		maxSize, err := strconv.Atoi(maxSizeStr)
+		if err != nil || maxSize < 0 {
+			fmt.Fprintf(os.Stderr, "The SNIP_MAX_SIZE value %s must be a non-negative number of bytes.\n", maxSizeStr)
+			log.Debug().Err(err).Str("SNIP_MAX_SIZE", maxSizeStr).Msg("error parsing maximum size")
 			os.Exit(1)
 		}
-		dbFilePath = homePath + "/" + dbFilename
+		snip.MaxDataSize = maxSize
+	}
+
+	// check env for number of revisions to retain
+	maxRevisionsStr := os.Getenv("SNIP_MAX_REVISIONS")
+	if maxRevisionsStr != "" {
+		maxRevisions, err := strconv.Atoi(maxRevisionsStr)
+		if err != nil || maxRevisions < 0 {
+			fmt.Fprintf(os.Stderr, "The SNIP_MAX_REVISIONS value %s must be a non-negative number.\n", maxRevisionsStr)
+			log.Debug().Err(err).Str("SNIP_MAX_REVISIONS", maxRevisionsStr).Msg("error parsing maximum revisions")
+			os.Exit(1)
+		}
+		snip.MaxRevisions = maxRevisions
+	}
+
+	// check env for retaining symbols within words
+	wordSymbolsStr := os.Getenv("SNIP_WORD_SYMBOLS")
+	if wordSymbolsStr != "" {
+		keep, err := strconv.ParseBool(wordSymbolsStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The SNIP_WORD_SYMBOLS value %s must be true or false.\n", wordSymbolsStr)
+			log.Debug().Err(err).Str("SNIP_WORD_SYMBOLS", wordSymbolsStr).Msg("error parsing word symbols setting")
+			os.Exit(1)
+		}
+		snip.KeepWordSymbols = keep
+	}
+
+	// check env for moving removed snips to the trash rather than deleting them
+	softDeleteStr := os.Getenv("SNIP_SOFT_DELETE")
+	if softDeleteStr != "" {
+		softDelete, err := strconv.ParseBool(softDeleteStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The SNIP_SOFT_DELETE value %s must be true or false.\n", softDeleteStr)
+			log.Debug().Err(err).Str("SNIP_SOFT_DELETE", softDeleteStr).Msg("error parsing soft delete setting")
+			os.Exit(1)
+		}
+		snip.SoftDelete = softDelete
+	}
+
+	// check env for indexing new snips without stemming
+	noStemStr := os.Getenv("SNIP_NO_STEM")
+	if noStemStr != "" {
+		noStem, err := strconv.ParseBool(noStemStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The SNIP_NO_STEM value %s must be true or false.\n", noStemStr)
+			log.Debug().Err(err).Str("SNIP_NO_STEM", noStemStr).Msg("error parsing no stem setting")
+			os.Exit(1)
+		}
+		snip.NoStem = noStem
 	}
 
 	helpMessage :=
 		`usage:
+snip [options] <command>
+       --color <auto|always|never>
+                                colorize output (default: auto, honors NO_COLOR)
+       --db <file>              database file, - to read a database from stdin (default: $SNIP_DB, or ~/.snip.sqlite3)
+       --db-url <url>           download the database from an http or https URL, opening it read-only
+       --lang <language>        stemming language (default: $SNIP_LANG, or english)
+       --read-only              open the database without allowing changes
+       -q, --quiet              suppress informational messages, keeping data, warnings, and errors
+       -v, --verbose            log debug messages, as with DEBUG=1
+
 snip add                        add a new snip from standard input
-       -f <file>                data from file instead of stdin default
+       -append <uuid>           append data to an existing snip on a new line instead
+       -f <file ...>            data from files instead of stdin default, one snip per file named after the file
+       -join                    concatenate multiple files into a single snip
        -n <name>                use specified name
+       -name-words <n>          number of words from data used to generate a name (default: 5)
+       -no-index                skip indexing for faster bulk adds, search misses the snip until indexed
+       -no-stem                 index words verbatim instead of stems, suited to code (default: $SNIP_NO_STEM)
+       -max-size <bytes>        maximum data size, 0 for no limit (default: 10485760)
 
 snip attach                     attach a file to specified snip
        add <uuid> <file ...>    add attachment files to snip
-       get <uuid>               display attachment metadata and info
-       list                     list all attachments in database
+         -base64                decode base64 files, removing a .b64 or .base64 extension from the name
+         -stdin                 attach standard input, also given as a file named -
+         -name <name>           name of the attachment read from standard input
+       get <uuid>               display attachment metadata without its data
+       ls [uuid]                list all attachments in database, or only those of snip
+         -mime <type>           list only attachments of MIME type (ex: image/png)
          -sort <size|name>      sort by attachment field (default: name)
+         -total                 print only the total size of attachments
+       mv <uuid> <snip_uuid>    move attachment to another snip
+       owner <uuid>             print the uuid and name of the snip the attachment belongs to
+       promote <uuid>           create an indexed snip named after the attachment from its text data
+         -force                 promote data that is not valid UTF-8
+         -rm                    remove the attachment once promoted
+       rename <uuid> <name>     rename attachment
+       replace <uuid> <file>    replace attachment data with file, keeping its uuid, name, and snip
        rm <uuid ...>            remove attachment
        stdout <uuid>            write data to stdout
+         -base64                encode data as base64 for text-safe output
+       verify [uuid]            check that recorded sizes match data for one or all attachments
        write <file>             write data to file
+         -force                 overwrite existing file
+       write-all <uuid> <dir>   write each attachment of snip to dir using its stored name, creating dir
+         -force                 overwrite existing files
+
+snip backup <file>              write a consistent copy of the database to file
+       -force                   replace an existing file once the backup is complete
+
+snip cat <uuid ...>             print the data of each snip in order
+       -delimiter <string>      separator between snips, escapes such as \n are interpreted (default: \n)
+       -header                  print each snip name as a comment line before its data
+
+snip clone <uuid>               copy snip and its attachments to a new snip named with " (copy)"
+
+snip dedup                      list groups of snips with identical data
+       -delete-newer            remove all but the oldest snip of each group
+       -delete-older            remove all but the newest snip of each group
+       -dry-run                 show what would be removed without removing anything
+       -reattach                move attachments to the remaining snip (default: true)
+
+snip diff <uuid> <uuid|@revision>
+                                show changes between the data of two snips, or from a revision of snip
+
+snip export                     export all snips and attachments as JSON
+       -o <file>                write to file instead of stdout
+
+snip export <uuid> <dir>        write snip data to <dir>/<name>.txt and each attachment to <dir>, creating <dir>
+
+snip export-jsonl               export each snip and its attachments as a line of JSON, streaming large databases
+       -o <file>                write to file instead of stdout
+
+snip fav <uuid ...>             mark snips as favorites
+
+snip find [query]               select a snip interactively by name, filtering as you type, and print its data
+       -print-uuid              print the uuid of the selected snip instead
+                                lists matching snips by fuzzy name when output is not a terminal
 
 snip get <uuid>                 retrieve snip with specified uuid
+       -copy                    copy raw data to the clipboard
+       -expand <key=value>      expand placeholders such as {{.key}} in data as a Go text/template, may be repeated
+       -expand-missing-ok       expand placeholders without a value to an empty string instead of failing
+       -format <text|md|gist>   output format (default: text), gist is plain text for pasting
+       -head <n>                print only the first n lines of data, followed by -tail lines if given
+       -highlight <term>        highlight words matching term in data, may be repeated
+       -inline-max <bytes>      inline text attachments up to bytes with -format gist (default: 0, list only)
+       -random [term ...]       retrieve a random snip, optionally matching terms
        -raw                     output only raw data from snip
+       -tail <n>                print only the last n lines of data
+       -wrap <n>                wrap lines of data at n columns on word boundaries (default: 0, no wrap)
+
+snip history <uuid>             list stored revisions of snip
+
+snip import <file>              import snips from an export file (default: stdin)
+       -no-index                skip indexing for faster imports, run index -missing afterwards
+       -dir <path>              import each text file in a directory as a snip instead
+       -ext <.md,.txt>          import only files with listed extensions with -dir
+       -recursive               descend into subdirectories with -dir
+
+snip import-jsonl <file>        import snips from an export-jsonl file (default: stdin)
+       -no-index                skip indexing for faster imports, run index -missing afterwards
+
+snip index                      rebuild the search index of all snips
+       -incremental             only reindex snips whose data changed since last indexed
+       -workers <n>             number of concurrent workers (default: number of CPUs)
+       -no-stem <uuid ...>      reindex specified snips by words verbatim instead of stems
+       -stem <uuid ...>         reindex specified snips by stems
+       -status                  list snips without any indexed terms
+       -missing                 index only the snips listed by -status
 
 snip ls                         list all snips
+       -dupe-names              list names shared by more than one snip
+       -fav                     list only favorite snips
+       -l                       list with full uuid
+       -names-only              print only the name of each snip, without a header, for piping
+       -random <count>          list this number of randomly chosen snips, after any other filters
+       -uuids-only              print only the full uuid of each snip, without a header, for piping
+       -since <time>            list only snips created at or after time (RFC3339, 2006-01-02, or relative: 12h, 7d, 2w)
+       -until <time>            list only snips created before time
+       -template <template>     format each snip with a Go text/template (ex: '{{.UUID}}\t{{.Name}}')
+       -template-name <name>    format each snip with a named template (short|long)
+
+snip meta                       attach freeform key=value metadata to a snip
+       set <uuid> <key=value ...>
+                                set metadata keys of snip, replacing previous values
+       get <uuid> <key>         print the value of a metadata key of snip
+       ls <uuid>                list the metadata of snip as key=value
+       rm <uuid> <key ...>      remove metadata keys from snip
+
+snip mv <uuid> <new_uuid>       change the uuid of snip, including its attachments, index, and metadata
+
+snip open <attachment_uuid>     open attachment with the default application of the system
+
+snip recent [n]                 list the n snips most recently viewed with get (default: 10, 0 for all)
+       -l                       list with full uuid
+
+snip related <uuid> [n]         list the n snips sharing the most of the top indexed terms of snip (default: 10, 0 for all)
        -l                       list with full uuid
 
+snip restore <uuid> <revision>  restore snip data and name from a revision
+
 snip search <term ...>          return snips whose data contains given term
-       -type <data|index>       specify search source (data uses a singular term only)
-       -f <field>               search snip field
+       -type <data|index|regex> specify search source (data uses a singular term, regex a single pattern)
+       -any                     return snips containing any of the terms instead of all
+       -f <data|name|uuid>      search snip field with data search type (default: data)
+       -fields <field,...>      search several fields with data search type, ranking snips matching in the name highest
+       -count                   print only the number of matching snips
+       -exit-zero               exit with status 0 when nothing is found (default: status 1, as with grep)
+       -context <n>             number of words shown on each side of a match (default: 6)
+       -merge                   merge overlapping contexts into one, highlighting each match
+       -lines                   print only the lines containing a matched term, matched by stem, instead of context
+       -template <template>     format each result with a Go text/template, including .Score and .SearchCounts
+       -template-name <name>    format each result with a named template (short|long)
+       -json                    print each result as a JSON object per line, including match context
+       -json-array              print all results as a single JSON array
+       -names-only              print only the name of each result, for piping
+       -uuids-only              print only the full uuid of each result, for piping
+       -limit <n>               limit number of results, 0 for no limit
+       -offset <n>              skip the first n results
+       -since <time>            return only snips created at or after time (RFC3339, 2006-01-02, or relative: 12h, 7d, 2w)
+       -until <time>            return only snips created before time
+       -meta <key=value>        return only snips with metadata key set to value, may be repeated
+       -fold                    ignore case and accents for data search type (default: ascii case only), case for regex
+       -fuzzy                   match similar indexed terms within two edits for misspelled terms
+       -weight-coverage <n>     score weight of the ratio of terms matched (default: 1)
+       -weight-prominence <n>   score weight of term prominence within the snip (default: 1)
+       -no-idf                  weigh all terms equally in coverage instead of favoring terms found in few snips
+       -buckets                 group index results under high, medium, and low score headers
+       -bucket-high <n>         lowest score of the high band (default: 0.6)
+       -bucket-medium <n>       lowest score of the medium band (default: 0.3)
+       -watch                   run the search repeatedly, clearing the screen between results, until ctrl-c
+       -interval <duration>     time between runs with -watch (default: 2s)
 
 snip rename <uuid> <new_name>   rename snip
+       -all                     rename all snips matching -pattern instead, previewing changes by default
+       -pattern <regex>         regular expression matched against names
+       -replace <replacement>   replacement for each match, which may refer to groups as $1 or ${name}
+       -confirm                 apply the previewed changes
+       -regenerate              generate the name from the current data instead of giving a new name
+       -name-words <n>          number of words from data used by -regenerate (default: 5)
 
 snip rm <uuid ...>              remove snip <uuid> ...
+       -dry-run                 show what would be removed without removing anything
+       -search <terms>          remove all snips matching an index search instead, previewing them by default
+       -confirm                 remove the snips previewed by -search
+
+snip trash                      manage snips removed by rm, kept unless $SNIP_SOFT_DELETE is false
+       ls                       list removed snips, most recent first
+       empty                    permanently delete all removed snips
+
+snip undo                       restore the snip most recently removed, with its attachments and metadata
+       -n <count>               restore this number of the most recently removed snips (default: 1)
+
+snip unfav <uuid ...>           remove snips from favorites
+
+snip terms <uuid>               list indexed terms of snip by frequency
+       -all                     list terms of all snips instead, with total counts
+       -n <count>               limit to the most frequent terms
+       -prefix <prefix>         list only terms beginning with prefix, for completion
+
+snip verify                     check the database for missing references, attachment sizes, and timestamps
+
+snip wc <uuid ...>              print the number of lines, words, and bytes of snip data, as with wc
+       -all                     print the totals of all snips instead
+
+cat, clone, diff, export, fav, get, history, index, mv, related, rename, restore, rm, unfav, and wc accept a full uuid, partial uuid, or name:<name>
 `
 	Usage := func() {
 		fmt.Fprintf(os.Stderr, "%s", helpMessage)
 	}
 
+	globalCmd := flag.NewFlagSet("snip", flag.ExitOnError)
+	globalCmdColor := globalCmd.String("color", "auto", "colorize output (auto|always|never)")
+	globalCmdDB := globalCmd.String("db", "", "path of the database file, overriding SNIP_DB")
+	globalCmdDBURL := globalCmd.String("db-url", "", "http or https URL of a database to download and open read-only")
+	globalCmdLang := globalCmd.String("lang", "", "stemming language, overriding SNIP_LANG")
+	globalCmdReadOnly := globalCmd.Bool("read-only", false, "open the database without allowing changes")
+	globalCmd.BoolVar(&quiet, "q", false, "suppress informational messages")
+	globalCmd.BoolVar(&quiet, "quiet", false, "suppress informational messages")
+	globalCmdVerbose := globalCmd.Bool("v", false, "log debug messages")
+	globalCmd.BoolVar(globalCmdVerbose, "verbose", false, "log debug messages")
+
 	addCmd := flag.NewFlagSet("add", flag.ExitOnError)
-	addCmdFile := addCmd.String("f", "", "use data from specified file")
+	addCmdAppend := addCmd.String("append", "", "append data to the existing snip with uuid")
+	addCmdFile := addCmd.String("f", "", "use data from specified file, additional files may follow the options")
+	addCmdJoin := addCmd.Bool("join", false, "concatenate multiple files into a single snip")
+	addCmdMaxSize := addCmd.Int("max-size", snip.MaxDataSize, "maximum data size in bytes, 0 for no limit")
 	addCmdName := addCmd.String("n", "", "specify name")
+	addCmdNameWords := addCmd.Int("name-words", snip.DefaultNameWords, "number of words from data used to generate a name")
+	addCmdNoIndex := addCmd.Bool("no-index", false, "skip indexing, leaving the snip unsearchable until indexed")
+	addCmdNoStem := addCmd.Bool("no-stem", false, "index words verbatim instead of stems")
 	addCmdUUID := addCmd.String("u", "", "specify uuid")
 
 	attachCmd := flag.NewFlagSet("attach", flag.ExitOnError)
 	attachCmdGet := flag.NewFlagSet("get", flag.ExitOnError)
 	attachCmdAdd := flag.NewFlagSet("add", flag.ExitOnError)
+	attachCmdAddBase64 := attachCmdAdd.Bool("base64", false, "decode base64 files before attaching")
+	attachCmdAddName := attachCmdAdd.String("name", "", "name of the attachment read from standard input")
+	attachCmdAddStdin := attachCmdAdd.Bool("stdin", false, "attach data read from standard input, as with a file named -")
 	attachCmdList := flag.NewFlagSet("ls", flag.ExitOnError)
+	attachCmdListMIME := attachCmdList.String("mime", "", "list only attachments of MIME type")
 	attachCmdListSort := attachCmdList.String("sort", "name", "field to sort attachment list by")
+	attachCmdListTotal := attachCmdList.Bool("total", false, "print only the total size of listed attachments")
+	attachCmdMove := flag.NewFlagSet("mv", flag.ExitOnError)
+	attachCmdOwner := flag.NewFlagSet("owner", flag.ExitOnError)
+	attachCmdPromote := flag.NewFlagSet("promote", flag.ExitOnError)
+	attachCmdPromoteForce := attachCmdPromote.Bool("force", false, "promote data that is not valid UTF-8")
+	attachCmdPromoteRemove := attachCmdPromote.Bool("rm", false, "remove the attachment after promoting it")
 	attachCmdRemove := flag.NewFlagSet("rm", flag.ExitOnError)
+	attachCmdRename := flag.NewFlagSet("rename", flag.ExitOnError)
+	attachCmdReplace := flag.NewFlagSet("replace", flag.ExitOnError)
+	attachCmdStdout := flag.NewFlagSet("stdout", flag.ExitOnError)
+	attachCmdStdoutBase64 := attachCmdStdout.Bool("base64", false, "encode data as base64")
+	attachCmdVerify := flag.NewFlagSet("verify", flag.ExitOnError)
 	attachCmdWrite := flag.NewFlagSet("write", flag.ExitOnError)
 	attachCmdWriteForce := attachCmdWrite.Bool("force", false, "force local file overwrite")
+	attachCmdWriteAll := flag.NewFlagSet("write-all", flag.ExitOnError)
+	attachCmdWriteAllForce := attachCmdWriteAll.Bool("force", false, "force local file overwrite")
+
+	backupCmd := flag.NewFlagSet("backup", flag.ExitOnError)
+	backupCmdForce := backupCmd.Bool("force", false, "force local file overwrite")
+
+	catCmd := flag.NewFlagSet("cat", flag.ExitOnError)
+	catCmdDelimiter := catCmd.String("delimiter", `\n`, "separator printed between snips, escape sequences are interpreted")
+	catCmdHeader := catCmd.Bool("header", false, "print the name of each snip as a comment line before its data")
+
+	cloneCmd := flag.NewFlagSet("clone", flag.ExitOnError)
+
+	dedupCmd := flag.NewFlagSet("dedup", flag.ExitOnError)
+	dedupCmdDeleteNewer := dedupCmd.Bool("delete-newer", false, "remove all but the oldest snip of each group")
+	dedupCmdDeleteOlder := dedupCmd.Bool("delete-older", false, "remove all but the newest snip of each group")
+	dedupCmdDryRun := dedupCmd.Bool("dry-run", false, "show what would be removed without removing anything")
+	dedupCmdReattach := dedupCmd.Bool("reattach", true, "move attachments of removed snips to the remaining snip")
+
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+
+	exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+	exportCmdOutput := exportCmd.String("o", "", "write export to file")
+	exportCmdForce := exportCmd.Bool("force", false, "force local file overwrite")
+
+	exportJSONLCmd := flag.NewFlagSet("export-jsonl", flag.ExitOnError)
+	exportJSONLCmdOutput := exportJSONLCmd.String("o", "", "write export to file")
+	exportJSONLCmdForce := exportJSONLCmd.Bool("force", false, "force local file overwrite")
+
+	favCmd := flag.NewFlagSet("fav", flag.ExitOnError)
+	unfavCmd := flag.NewFlagSet("unfav", flag.ExitOnError)
+
+	findCmd := flag.NewFlagSet("find", flag.ExitOnError)
+	findCmdPrintUUID := findCmd.Bool("print-uuid", false, "print the uuid of the selected snip instead of its data")
 
 	getCmd := flag.NewFlagSet("get", flag.ExitOnError)
+	getCmdCopy := getCmd.Bool("copy", false, "copy raw data to the clipboard")
+	getCmdExpand := make(expandVars)
+	getCmd.Var(getCmdExpand, "expand", "expand the placeholder {{.key}} in data to value, may be repeated (key=value)")
+	getCmdExpandMissingOK := getCmd.Bool("expand-missing-ok", false, "expand placeholders without a value to an empty string")
+	var getCmdHighlight termList
+	getCmd.Var(&getCmdHighlight, "highlight", "highlight words matching term, may be repeated")
+	getCmdFormat := getCmd.String("format", "text", "output format (text|md|gist)")
+	getCmdInlineMax := getCmd.Int("inline-max", 0, "inline text attachments up to this number of bytes with -format gist")
+	getCmdHead := getCmd.Int("head", 0, "print only the first number of lines of data")
 	getCmdRaw := getCmd.Bool("raw", false, "output only raw data")
 	getCmdRandom := getCmd.Bool("random", false, "view a random snip")
+	getCmdTail := getCmd.Int("tail", 0, "print only the last number of lines of data")
+	getCmdWrap := getCmd.Int("wrap", 0, "wrap lines of data at the number of columns on word boundaries, 0 for no wrap")
+
+	historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+
+	indexCmd := flag.NewFlagSet("index", flag.ExitOnError)
+	indexCmdIncremental := indexCmd.Bool("incremental", false, "only reindex snips whose data changed since last indexed")
+	indexCmdMissing := indexCmd.Bool("missing", false, "index only snips without any indexed terms")
+	indexCmdStatus := indexCmd.Bool("status", false, "list snips without any indexed terms")
+	indexCmdNoStem := indexCmd.Bool("no-stem", false, "reindex specified snips by words verbatim instead of stems")
+	indexCmdStem := indexCmd.Bool("stem", false, "reindex specified snips by stems")
+	indexCmdWorkers := indexCmd.Int("workers", runtime.NumCPU(), "number of concurrent workers analyzing snips")
+
+	importCmd := flag.NewFlagSet("import", flag.ExitOnError)
+	importCmdDir := importCmd.String("dir", "", "import each text file in the directory as a snip")
+	importCmdExt := importCmd.String("ext", "", "comma separated list of file extensions to import with -dir")
+	importCmdNoIndex := importCmd.Bool("no-index", false, "skip indexing, leaving the snips unsearchable until indexed")
+	importCmdRecursive := importCmd.Bool("recursive", false, "descend into subdirectories with -dir")
+
+	importJSONLCmd := flag.NewFlagSet("import-jsonl", flag.ExitOnError)
+	importJSONLCmdNoIndex := importJSONLCmd.Bool("no-index", false, "skip indexing, leaving the snips unsearchable until indexed")
+
+	metaCmd := flag.NewFlagSet("meta", flag.ExitOnError)
 
 	listCmd := flag.NewFlagSet("ls", flag.ExitOnError)
+	listCmdDupeNames := listCmd.Bool("dupe-names", false, "list only names shared by more than one snip")
+	listCmdFav := listCmd.Bool("fav", false, "list only favorite snips")
 	listCmdLong := listCmd.Bool("l", false, "list full uuid instead of short")
+	listCmdNamesOnly := listCmd.Bool("names-only", false, "print only the name of each snip, without a header")
+	listCmdRandom := listCmd.Int("random", 0, "list this number of randomly chosen snips")
+	listCmdUUIDsOnly := listCmd.Bool("uuids-only", false, "print only the full uuid of each snip, without a header")
+	listCmdSince := listCmd.String("since", "", "list only snips created at or after time")
+	listCmdUntil := listCmd.String("until", "", "list only snips created before time")
+	listCmdTemplate := listCmd.String("template", "", "format each snip with a Go text/template")
+	listCmdTemplateName := listCmd.String("template-name", "", "format each snip with a named template (short|long)")
+
+	mvCmd := flag.NewFlagSet("mv", flag.ExitOnError)
+
+	openCmd := flag.NewFlagSet("open", flag.ExitOnError)
+
+	recentCmd := flag.NewFlagSet("recent", flag.ExitOnError)
+	recentCmdLong := recentCmd.Bool("l", false, "list full uuid instead of short")
+
+	relatedCmd := flag.NewFlagSet("related", flag.ExitOnError)
+	relatedCmdLong := relatedCmd.Bool("l", false, "list full uuid instead of short")
 
 	renameCmd := flag.NewFlagSet("rename", flag.ExitOnError)
+	renameCmdAll := renameCmd.Bool("all", false, "rename all snips with names matching -pattern")
+	renameCmdConfirm := renameCmd.Bool("confirm", false, "apply the changes previewed by -all")
+	renameCmdPattern := renameCmd.String("pattern", "", "regular expression matched against names with -all")
+	renameCmdReplace := renameCmd.String("replace", "", "replacement for matches of -pattern, which may refer to groups as $1")
+	renameCmdRegenerate := renameCmd.Bool("regenerate", false, "generate the name from the current data")
+	renameCmdNameWords := renameCmd.Int("name-words", snip.DefaultNameWords, "number of words from data used by -regenerate")
+
+	restoreCmd := flag.NewFlagSet("restore", flag.ExitOnError)
 
 	searchCmd := flag.NewFlagSet("search", flag.ExitOnError)
-	searchCmdContextWords := searchCmd.Int("context", 6, "number of context words to display")
-	searchCmdField := searchCmd.String("f", "data", "field to search (data|uuid)")
+	searchCmdAny := searchCmd.Bool("any", false, "return snips containing any of the terms rather than all of them")
+	searchCmdBuckets := searchCmd.Bool("buckets", false, "group index results into high, medium, and low score bands")
+	searchCmdBucketHigh := searchCmd.Float64("bucket-high", snip.DefaultBucketHigh, "lowest score of the high band with -buckets")
+	searchCmdBucketMedium := searchCmd.Float64("bucket-medium", snip.DefaultBucketMedium, "lowest score of the medium band with -buckets")
+	searchCmdContextWords := searchCmd.Int("context", 6, "number of words to display on each side of a match, 0 displays only the term")
+	searchCmdCount := searchCmd.Bool("count", false, "print only the number of matching snips")
+	searchCmdExitZero := searchCmd.Bool("exit-zero", false, "exit with status 0 when nothing is found")
+	searchCmdTemplate := searchCmd.String("template", "", "format each result with a Go text/template")
+	searchCmdTemplateName := searchCmd.String("template-name", "", "format each result with a named template (short|long)")
+	searchCmdFold := searchCmd.Bool("fold", false, "ignore case and accents in data search")
+	searchCmdFuzzy := searchCmd.Bool("fuzzy", false, "match similar terms when a term is not indexed")
+	searchCmdJSON := searchCmd.Bool("json", false, "print each result as a JSON object on its own line")
+	searchCmdJSONArray := searchCmd.Bool("json-array", false, "print all results as a single JSON array")
+	searchCmdField := searchCmd.String("f", "data", "field to search (data|name|uuid)")
+	searchCmdFields := searchCmd.String("fields", "", "comma separated fields to search together, ranking name matches highest")
 	searchCmdLimit := searchCmd.Int("limit", 0, "limit search results")
+	searchCmdLines := searchCmd.Bool("lines", false, "print only the lines of data containing a matched term instead of context")
+	searchCmdMerge := searchCmd.Bool("merge", false, "merge overlapping contexts into a single window marking each match")
+	searchCmdMeta := make(expandVars)
+	searchCmd.Var(searchCmdMeta, "meta", "return only snips with metadata key=value, may be repeated")
+	searchCmdOffset := searchCmd.Int("offset", 0, "skip the first number of search results")
+	searchCmdSince := searchCmd.String("since", "", "return only snips created at or after time")
+	searchCmdUntil := searchCmd.String("until", "", "return only snips created before time")
 	searchCmdLongUUID := searchCmd.Bool("l", false, "list full uuid instead of short")
-	searchCmdType := searchCmd.String("type", "index", "search type (data|index)")
+	searchCmdNamesOnly := searchCmd.Bool("names-only", false, "print only the name of each result")
+	searchCmdUUIDsOnly := searchCmd.Bool("uuids-only", false, "print only the full uuid of each result")
+	searchCmdType := searchCmd.String("type", "index", "search type (data|index|regex)")
+	searchCmdWatch := searchCmd.Bool("watch", false, "run the search repeatedly, redrawing the results until interrupted")
+	searchCmdInterval := searchCmd.Duration("interval", 2*time.Second, "time between runs of the search with -watch")
+	searchCmdWeightCoverage := searchCmd.Float64("weight-coverage", snip.DefaultScoreWeights.Coverage, "score weight of the ratio of terms matched")
+	searchCmdWeightProminence := searchCmd.Float64("weight-prominence", snip.DefaultScoreWeights.Prominence, "score weight of term prominence within the snip")
+	searchCmdNoIDF := searchCmd.Bool("no-idf", false, "weigh all terms equally when scoring instead of favoring rare terms")
 
 	rmCmd := flag.NewFlagSet("rm", flag.ExitOnError)
+	rmCmdConfirm := rmCmd.Bool("confirm", false, "remove the snips previewed by -search")
+	rmCmdDryRun := rmCmd.Bool("dry-run", false, "show what would be removed without removing anything")
+	rmCmdSearch := rmCmd.String("search", "", "remove all snips matching the index search terms instead (space separated)")
+
+	trashCmd := flag.NewFlagSet("trash", flag.ExitOnError)
+	undoCmd := flag.NewFlagSet("undo", flag.ExitOnError)
+	undoCmdCount := undoCmd.Int("n", 1, "restore this number of the most recently removed snips")
+
+	termsCmd := flag.NewFlagSet("terms", flag.ExitOnError)
+	termsCmdAll := termsCmd.Bool("all", false, "list terms of all snips with their total counts")
+	termsCmdCount := termsCmd.Int("n", 0, "limit to the most frequent terms")
+	termsCmdPrefix := termsCmd.String("prefix", "", "list only terms beginning with prefix")
+
+	verifyCmd := flag.NewFlagSet("verify", flag.ExitOnError)
+
+	wcCmd := flag.NewFlagSet("wc", flag.ExitOnError)
+	wcCmdAll := wcCmd.Bool("all", false, "count the data of all snips")
+
+	// global options precede the action
+	globalCmd.Usage = Usage
+	if err := globalCmd.Parse(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "The global arguments could not be parsed.\n")
+		log.Debug().Err(err).Msg("error parsing global arguments")
+		os.Exit(1)
+	}
+	if *globalCmdVerbose {
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	}
+	if err := configureColor(*globalCmdColor); err != nil {
+		fmt.Fprintf(os.Stderr, "The color option %s is not valid, use auto, always, or never.\n", *globalCmdColor)
+		log.Debug().Err(err).Msg("error configuring color")
+		os.Exit(1)
+	}
+
+	// the --lang option takes precedence over the env
+	lang := *globalCmdLang
+	if lang == "" {
+		lang = os.Getenv("SNIP_LANG")
+	}
+	if lang != "" {
+		if err := snip.CheckLanguage(lang); err != nil {
+			if reason, ok := snip.UnavailableLanguages[lang]; ok {
+				fmt.Fprintf(os.Stderr, "The language %s is not supported, %s. Use one of: %s\n", lang, reason, strings.Join(snip.Languages, ", "))
+				log.Debug().Err(err).Msg("error configuring language")
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "The language %s is not supported, use one of: %s\n", lang, strings.Join(snip.Languages, ", "))
+			log.Debug().Err(err).Msg("error configuring language")
+			os.Exit(1)
+		}
+		snip.Language = lang
+	}
+
+	// the --db option takes precedence over the env, then the home directory
+	if *globalCmdDBURL != "" {
+		if *globalCmdDB != "" {
+			fmt.Fprintf(os.Stderr, "The --db and --db-url options cannot be used together.\n")
+			os.Exit(1)
+		}
+		if !isDBURL(*globalCmdDBURL) {
+			fmt.Fprintf(os.Stderr, "The database URL %s must use http or https.\n", *globalCmdDBURL)
+			os.Exit(1)
+		}
+	}
+	dbFilePath := *globalCmdDB
+	if *globalCmdDBURL != "" {
+		dbFilePath = *globalCmdDBURL
+	}
+	if dbFilePath == "" {
+		dbFilePath = os.Getenv("SNIP_DB")
+	}
+	if dbFilePath == "" {
+		homePath := os.Getenv("HOME")
+		dbFilename := ".snip.sqlite3"
+		if homePath == "" {
+			fmt.Fprintf(os.Stderr, "please $HOME env to your home directory for database save location")
+			log.Debug().Msg("could not retrieve $HOME environment variable")
+			os.Exit(1)
+		}
+		dbFilePath = homePath + "/" + dbFilename
+	}
 
 	// establish action
-	if len(os.Args) < 2 {
+	if globalCmd.NArg() < 1 {
 		Usage()
 		os.Exit(1)
 	}
-	action := os.Args[1]
+	action := globalCmd.Arg(0)
+	args := globalCmd.Args()[1:]
 
-	var err error
-	database.Conn, err = sqlite3.Open(dbFilePath)
+	// databases from a URL or standard input are temporary copies, which are never changed
+	remoteDB := dbFilePath == "-" || isDBURL(dbFilePath)
+	dbPath, cleanupDB, err := resolveDB(dbFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "The database could not be read from %s: %v\n", dbFilePath, err)
+		log.Debug().Err(err).Str("source", dbFilePath).Msg("error resolving database")
+		os.Exit(1)
+	}
+	if remoteDB {
+		// a downloaded or piped file may be anything, so it is checked before use, and brought up to date while the
+		// copy can still be written
+		if err := prepareCopyDB(dbPath); err != nil {
+			cleanupDB()
+			fmt.Fprintf(os.Stderr, "The data read from %s is not a snip database.\n", dbFilePath)
+			log.Debug().Err(err).Str("source", dbFilePath).Msg("error preparing database copy")
+			os.Exit(1)
+		}
+		database.ReadOnly = true
+		// immutable copies are read without creating journal or shared memory files beside them
+		uri := url.URL{Scheme: "file", Path: dbPath, RawQuery: "immutable=1"}
+		database.Conn, err = sqlite3.Open(uri.String(), sqlite3.OPEN_READONLY|sqlite3.OPEN_URI)
+		// the open connection keeps the data of the copy, which is removed now so that no exit leaves it behind
+		cleanupDB()
+	} else if *globalCmdReadOnly {
+		database.ReadOnly = true
+		database.Conn, err = sqlite3.Open(dbPath, sqlite3.OPEN_READONLY)
+	} else {
+		database.Conn, err = sqlite3.Open(dbPath)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "The database could not be opened at this location: %s\n", dbFilePath)
-		log.Debug().Err(err).Str("path", dbFilePath).Msg("error opening database")
+		log.Debug().Err(err).Str("path", dbPath).Msg("error opening database")
 		os.Exit(1)
 	}
 	defer database.Conn.Close()
 
-	// ensure database is present
-	err = snip.CreateNewDatabase()
+	err = database.Configure()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "There was a problem creating the new database structure.\n")
-		log.Debug().Err(err).Msg("error creating database schema")
+		fmt.Fprintf(os.Stderr, "There was a problem configuring the database connection.\n")
+		log.Debug().Err(err).Msg("error configuring database connection")
 		os.Exit(1)
 	}
 
+	// ensure database is present and up to date, which a read-only database must already be
+	if !database.ReadOnly {
+		err = snip.Migrate()
+		if err != nil {
+			var versionErr *snip.SchemaVersionError
+			if errors.As(err, &versionErr) {
+				fmt.Fprintf(os.Stderr, "The database was created by a newer version of snip and cannot be used by this one.\n")
+			} else {
+				fmt.Fprintf(os.Stderr, "There was a problem creating or upgrading the database structure.\n")
+			}
+			log.Debug().Err(err).Msg("error migrating database schema")
+			os.Exit(1)
+		}
+	} else if !remoteDB {
+		// a database opened with --read-only cannot be upgraded, and an older schema lacks what this version reads
+		version, err := snip.SchemaVersion()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The schema version of the database could not be read.\n")
+			log.Debug().Err(err).Msg("error reading database schema version")
+			os.Exit(1)
+		}
+		if version < snip.LatestSchemaVersion() {
+			fmt.Fprintf(os.Stderr, "The database was created by an older version of snip. Open it once without --read-only to upgrade it.\n")
+			log.Debug().Int("version", version).Int("latest", snip.LatestSchemaVersion()).Msg("read-only database needs migration")
+			os.Exit(1)
+		}
+	}
+
 	log.Debug().Str("action", action).Msg("action invoked")
 	log.Debug().Str("args", strings.Join(os.Args, " ")).Msg("action invoked")
 
 	switch action {
 	case "add":
-		if err := addCmd.Parse(os.Args[2:]); err != nil {
+		if err := addCmd.Parse(args); err != nil {
 			fmt.Fprintf(os.Stderr, "The add arguments could not be parsed.\n")
 			log.Debug().Err(err).Msg("error parsing add arguments")
 			os.Exit(1)
 		}
 
-		// create simple object
-		s := snip.New()
+		if *addCmdMaxSize < 0 {
+			fmt.Fprintf(os.Stderr, "The maximum size must not be negative.\n")
+			os.Exit(1)
+		}
+		snip.MaxDataSize = *addCmdMaxSize
+		if *addCmdNameWords < 1 {
+			fmt.Fprintf(os.Stderr, "The number of name words must be at least 1.\n")
+			os.Exit(1)
+		}
 
-		// file input takes precedence, but default to standard input
+		// additional files follow the first file supplied with -f
+		var files []string
 		if *addCmdFile != "" {
-			data, err := readFromFile(*addCmdFile)
+			files = append([]string{*addCmdFile}, addCmd.Args()...)
+		}
+		// appended files are always joined
+		separate := len(files) > 1 && !*addCmdJoin && *addCmdAppend == ""
+		var target snip.Snip
+		if *addCmdAppend != "" {
+			if *addCmdName != "" || *addCmdUUID != "" || *addCmdNoStem || *addCmdNoIndex {
+				fmt.Fprintf(os.Stderr, "The -n, -u, -no-index, and -no-stem options do not apply when appending to a snip.\n")
+				os.Exit(1)
+			}
+			// validate before reading any data
+			target, err = snip.ResolveSnip(*addCmdAppend)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "There was a problem reading from the file %s\n", *addCmdFile)
-				log.Debug().Err(err).Str("file", *addCmdFile).Msg("error reading from file")
+				fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", *addCmdAppend)
+				printMatches(err)
+				log.Debug().Err(err).Str("uuid", *addCmdAppend).Msg("error retrieving snip with uuid")
 				os.Exit(1)
 			}
-			s.Data = string(data)
-		} else {
-			data, err := readFromStdin()
+		}
+		if separate && (*addCmdName != "" || *addCmdUUID != "") {
+			fmt.Fprintf(os.Stderr, "The -n and -u options apply to a single snip and require -join with multiple files.\n")
+			os.Exit(1)
+		}
+
+		var snips []snip.Snip
+		switch {
+		case separate:
+			// one snip per file, named after the file
+			for _, filename := range files {
+				data := readAddFile(filename)
+				s := snip.New()
+				s.Data = string(data)
+				s.Name = snip.NameFromFilename(filename)
+				snips = append(snips, s)
+			}
+		case len(files) > 0:
+			// file input takes precedence, concatenated in order when joined
+			var joined []byte
+			for _, filename := range files {
+				data := readAddFile(filename)
+				// keep contents of adjacent files on separate lines
+				if len(joined) > 0 && joined[len(joined)-1] != '\n' {
+					joined = append(joined, '\n')
+				}
+				joined = append(joined, data...)
+			}
+			if err := snip.CheckDataSize(len(joined)); err != nil {
+				fmt.Fprintf(os.Stderr, "The joined files could not be added: %v\n", err)
+				os.Exit(1)
+			}
+			s := snip.New()
+			s.Data = string(joined)
+			snips = append(snips, s)
+		default:
+			data, err := readFromStdin(snip.MaxDataSize)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "The standard input could not be read.\n")
+				var sizeErr *snip.DataSizeError
+				if errors.As(err, &sizeErr) {
+					fmt.Fprintf(os.Stderr, "The standard input could not be added: %v\n", err)
+				} else {
+					fmt.Fprintf(os.Stderr, "The standard input could not be read.\n")
+				}
 				log.Debug().Err(err).Msg("error reading from standard input")
 				os.Exit(1)
 			}
+			s := snip.New()
 			s.Data = string(data)
-		}
-		s.Name = *addCmdName
-		// generate name if empty
-		if s.Name == "" {
-			s.Name = s.GenerateName(5)
+			snips = append(snips, s)
 		}
 
-		// modify uuid if it was specified as an argument
-		if *addCmdUUID != "" {
-			id, err := uuid.Parse(*addCmdUUID)
+		if *addCmdAppend != "" {
+			err = target.Append(snips[0].Data)
+			exitIfReadOnly(err)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "There was a problem parsing the supplied uuid %s which may be malformed.\n", *addCmdUUID)
-				log.Debug().Err(err).Msg("error parsing uuid from arguments")
+				var sizeErr *snip.DataSizeError
+				if errors.As(err, &sizeErr) {
+					fmt.Fprintf(os.Stderr, "The data could not be appended: %v\n", err)
+				} else {
+					fmt.Fprintf(os.Stderr, "There was a problem appending to snip %s\n", target.UUID)
+					printIndexMismatch(err)
+				}
+				log.Debug().Err(err).Str("uuid", target.UUID.String()).Msg("error appending to snip")
 				os.Exit(1)
 			}
-			s.UUID = id
+			informOut("appended %d bytes to %s %s, total %d bytes\n", len(snips[0].Data), target.UUID, target.Name, len(target.Data))
+			break
 		}
 
-		log.Debug().
-			Str("UUID", s.UUID.String()).
-			Str("timestamp", s.Timestamp.String()).
-			Str("name", s.Name).
-			Str("Data", s.Data).
-			Msg("first snip object")
-		err = snip.InsertSnip(s)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "There was a problem inserting the new snip into the database.\n")
-			log.Debug().Err(err).Msg("error inserting Snip into database")
-			os.Exit(1)
+		if !separate {
+			s := &snips[0]
+			s.Name = *addCmdName
+			// generate name if empty
+			if s.Name == "" {
+				s.Name = s.GenerateName(*addCmdNameWords)
+			}
+
+			// modify uuid if it was specified as an argument
+			if *addCmdUUID != "" {
+				id, err := uuid.Parse(*addCmdUUID)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem parsing the supplied uuid %s which may be malformed.\n", *addCmdUUID)
+					log.Debug().Err(err).Msg("error parsing uuid from arguments")
+					os.Exit(1)
+				}
+				s.UUID = id
+			}
 		}
-		fmt.Printf("added snip uuid: %s\n", s.UUID)
-		// index for searching
-		err = s.Index()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "There was a problem indexing the new snip item.\n")
-			log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error indexing new snip %s")
-			os.Exit(1)
+
+		if *addCmdNoStem {
+			snip.NoStem = true
+		}
+		for _, s := range snips {
+			log.Debug().
+				Str("UUID", s.UUID.String()).
+				Str("timestamp", s.Timestamp.String()).
+				Str("name", s.Name).
+				Str("Data", s.Data).
+				Msg("first snip object")
+			warnDuplicateName(s.Name, s.UUID)
+			err = snip.InsertSnip(s)
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem inserting the new snip into the database.\n")
+				log.Debug().Err(err).Msg("error inserting Snip into database")
+				os.Exit(1)
+			}
+			// the uuid is the result of adding, so quiet output keeps it alone
+			if quiet {
+				fmt.Println(s.UUID)
+			} else {
+				fmt.Printf("added snip uuid: %s\n", s.UUID)
+			}
+			if *addCmdNoIndex {
+				continue
+			}
+			// index for searching
+			err = s.Index()
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem indexing the new snip item.\n")
+				printIndexMismatch(err)
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error indexing new snip %s")
+				os.Exit(1)
+			}
+		}
+		if *addCmdNoIndex {
+			warnNotIndexed(len(snips))
 		}
 
 	case "attach":
-		if err := attachCmd.Parse(os.Args[2:]); err != nil {
+		if err := attachCmd.Parse(args); err != nil {
 			fmt.Fprintf(os.Stderr, "The attach arguments could not be parsed.\n")
 			log.Debug().Err(err).Msg("error parsing attach arguments")
 			attachCmd.Usage()
@@ -238,42 +868,121 @@ snip rm <uuid ...>              remove snip <uuid> ...
 				os.Exit(1)
 			}
 
+			// standard input is attached as a file named -
+			files := attachCmdAdd.Args()
+			if *attachCmdAddStdin {
+				files = append(files, "-")
+			}
 			// should always have at least two arguments, uuid and at least one file
-			if len(attachCmdAdd.Args()) < 2 {
+			if len(files) < 2 {
 				fmt.Fprintf(os.Stderr, "The attach add command requires at least two arguments, the snip uuid and the local file to attach.\n")
 				log.Debug().Int("length", len(attachCmdAdd.Args())).Str("args", strings.Join(attachCmdAdd.Args(), " ")).Msg("arguments")
 				attachCmdAdd.Usage()
 				os.Exit(1)
 			}
+			stdinFiles := 0
+			for _, filename := range files[1:] {
+				if filename == "-" {
+					stdinFiles++
+				}
+			}
+			if stdinFiles > 1 {
+				fmt.Fprintf(os.Stderr, "Standard input can only be attached once.\n")
+				os.Exit(1)
+			}
+			if stdinFiles == 1 && *attachCmdAddName == "" {
+				fmt.Fprintf(os.Stderr, "The attachment read from standard input requires a name given with -name.\n")
+				attachCmdAdd.Usage()
+				os.Exit(1)
+			}
 			// INSERT new attachments
-			id := attachCmdAdd.Args()[0]
+			id := files[0]
 			// validate UUID
 			s, err := snip.GetFromUUID(id)
 			if err != nil {
 				log.Debug().Str("uuid", id).Msg("error locating snip uuid")
 				os.Exit(1)
 			}
-			fmt.Printf("attaching files to snip %s %s\n", s.UUID.String(), s.Name)
+			informOut("attaching files to snip %s %s\n", s.UUID.String(), s.Name)
 			// TODO: Do not allow duplicate attachments by calculating checksums at this point.
 
-			for _, filename := range attachCmdAdd.Args()[1:] {
+			for _, filename := range files[1:] {
+				if filename == "-" {
+					// the limit applies to the data as read, before any base64 decoding
+					data, err := readFromStdin(snip.MaxDataSize)
+					if err != nil {
+						var sizeErr *snip.DataSizeError
+						if errors.As(err, &sizeErr) {
+							fmt.Fprintf(os.Stderr, "The standard input could not be attached: %v\n", err)
+						} else {
+							fmt.Fprintf(os.Stderr, "The standard input could not be read.\n")
+						}
+						log.Debug().Err(err).Msg("error reading attachment from standard input")
+						os.Exit(1)
+					}
+					if *attachCmdAddBase64 {
+						data, err = snip.DecodeBase64(data)
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "The standard input does not contain valid base64 data.\n")
+							log.Debug().Err(err).Msg("error decoding base64 attachment data")
+							continue
+						}
+					}
+					err = s.Attach(*attachCmdAddName, data)
+					exitIfReadOnly(err)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "The attach operation of the standard input had a problem.\n")
+						log.Debug().Err(err).Str("name", *attachCmdAddName).Msg("error attaching standard input")
+						continue
+					}
+					informOut("attached %s %d bytes\n", *attachCmdAddName, len(data))
+					continue
+				}
 				// attempt to insert file
-				data, err := os.ReadFile(filename)
+				_, err := os.Stat(filename)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "The file %s could not be read.\n", filename)
 					log.Debug().Err(err).Str("file", filename).Msg("error reading attachment file data")
 					os.Exit(1)
 				}
-				basename := path.Base(filename)
-				// name is filename if not supplied
-				err = s.Attach(basename, data)
+				if *attachCmdAddBase64 {
+					encoded, err := os.ReadFile(filename)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "The file %s could not be read.\n", filename)
+						log.Debug().Err(err).Str("file", filename).Msg("error reading attachment file data")
+						os.Exit(1)
+					}
+					data, err := snip.DecodeBase64(encoded)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "The file %s does not contain valid base64 data.\n", filename)
+						log.Debug().Err(err).Str("file", filename).Msg("error decoding base64 attachment data")
+						continue
+					}
+					// name is filename without the encoding extension
+					name := filepath.Base(filename)
+					for _, ext := range []string{".b64", ".base64"} {
+						name = strings.TrimSuffix(name, ext)
+					}
+					err = s.Attach(name, data)
+					exitIfReadOnly(err)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "The attach operation of the file %s had a problem.\n", filename)
+						log.Debug().Err(err).Str("filename", filename).Msg("error attaching file")
+						continue
+					}
+					informOut("attached %s %d bytes\n", name, len(data))
+					continue
+				}
+				// name is filename, large files are streamed in chunks
+				size, err := s.AttachFile(filename)
+				exitIfReadOnly(err)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "The attach operation of the file %s had a problem.\n", filename)
 					log.Debug().Err(err).Str("filename", filename).Msg("error attaching file")
 					// at least attach partial
 					continue
 				}
-				fmt.Printf("attached %s %d bytes\n", filename, len(data))
+				informOut("attached %s %d bytes\n", filename, size)
 			}
 
 		case "ls":
@@ -283,12 +992,52 @@ snip rm <uuid ...>              remove snip <uuid> ...
 				os.Exit(1)
 			}
 
-			list, err := snip.GetAttachmentsAll()
+			if attachCmdList.NArg() > 1 {
+				fmt.Fprintf(os.Stderr, "The attach ls command accepts at most one snip uuid.\n")
+				attachCmdList.Usage()
+				os.Exit(1)
+			}
+			// listing is limited to the attachments of a snip when one is given
+			var owner *snip.Snip
+			if attachCmdList.NArg() == 1 {
+				idStr := attachCmdList.Arg(0)
+				s, err := snip.ResolveSnip(idStr)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "could not retrieve snip with id: %s\n", idStr)
+					printMatches(err)
+					log.Debug().Err(err).Str("uuid", idStr).Msg("retrieving snip from uuid")
+					os.Exit(1)
+				}
+				owner = &s
+			}
+
+			// the total of all attachments does not require listing them
+			if *attachCmdListTotal && *attachCmdListMIME == "" && owner == nil {
+				total, err := snip.AttachmentsTotalSize()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem while totaling the size of attachments.\n")
+					log.Debug().Err(err).Msg("could not total attachment sizes")
+					os.Exit(1)
+				}
+				fmt.Printf("total: %d bytes\n", total)
+				break
+			}
+
+			var list []uuid.UUID
+			if owner != nil {
+				list, err = snip.GetAttachmentsUUID(owner.UUID)
+			} else {
+				list, err = snip.GetAttachmentsAll()
+			}
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "There was a problem while gathering the list of attachments.\n")
 				log.Debug().Err(err).Msg("could not list all attachments")
 				os.Exit(1)
 			}
+			if owner != nil && len(list) == 0 {
+				inform("no attachments for snip %s %s\n", owner.UUID, owner.Name)
+				break
+			}
 			// build list
 			// use this function to not load overhead of Data field since it will not be used
 			var attachments []snip.Attachment
@@ -299,9 +1048,22 @@ snip rm <uuid ...>              remove snip <uuid> ...
 					log.Debug().Err(err).Str("uuid", id.String()).Msg("error getting attachment metadata")
 					os.Exit(1)
 				}
+				// compare without parameters such as charset
+				if *attachCmdListMIME != "" && a.MediaType() != *attachCmdListMIME {
+					continue
+				}
 				attachments = append(attachments, a)
 			}
 
+			if *attachCmdListTotal {
+				var total int
+				for _, a := range attachments {
+					total += a.Size
+				}
+				fmt.Printf("total: %d bytes\n", total)
+				break
+			}
+
 			switch *attachCmdListSort {
 			case "size":
 				sort.Slice(attachments, func(i, j int) bool {
@@ -322,70 +1084,387 @@ snip rm <uuid ...>              remove snip <uuid> ...
 				// do not print header if no results
 				if idx == 0 {
 					// print to stderr to easily pipe output
-					fmt.Fprintf(os.Stderr, "%s %42s %s\n", "uuid", "size", "name")
+					inform("%s %42s %-24s %s\n", "uuid", "size", "mime", "name")
 				}
-				fmt.Printf("%s %10d %s\n", a.UUID, a.Size, a.Name)
+				mimeType := a.MediaType()
+				if mimeType == "" {
+					mimeType = "-"
+				}
+				fmt.Printf("%s %10d %-24s %s\n", a.UUID, a.Size, mimeType, a.Name)
 			}
 
-		// REMOVE attachments by uuid
-		case "rm":
-			if err := attachCmdRemove.Parse(attachCmd.Args()[1:]); err != nil {
-				fmt.Fprintf(os.Stderr, "The arguments to the rm command could not be parsed.\n")
-				log.Debug().Err(err).Msg("error parsing attach remove arguments")
-				attachCmdRemove.Usage()
+		// MOVE attachment to another snip
+		case "mv":
+			if err := attachCmdMove.Parse(attachCmd.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "The arguments to the mv command could not be parsed.\n")
+				log.Debug().Err(err).Msg("error parsing attach move arguments")
+				attachCmdMove.Usage()
+				os.Exit(1)
+			}
+			if len(attachCmdMove.Args()) != 2 {
+				fmt.Fprintf(os.Stderr, "The attach mv command requires two arguments, the attachment uuid and the destination snip uuid.\n")
+				attachCmdMove.Usage()
 				os.Exit(1)
 			}
-			// TODO: Check this behavior, don't we need [1:] or something?
-			for _, idStr := range attachCmdRemove.Args() {
-				// id, err := uuid.Parse(idStr)
-				attachment, err := snip.GetAttachmentFromUUID(idStr)
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "The supplied id %s could not be located.\n", idStr)
-					log.Debug().Err(err).Str("uuid", "idStr").Msg("error locating attachment")
-					continue
-				}
 
-				// confirm before deletion
-				if !confirmAction(fmt.Sprintf("REMOVE attachment %s %s", attachment.UUID, attachment.Name)) {
-					fmt.Println("skipped")
-					continue
-				}
-				err = snip.RemoveAttachment(attachment.UUID)
+			idStr := attachCmdMove.Arg(0)
+			a, err := snip.GetAttachmentMetadataFromUUID(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The supplied id %s could not be located.\n", idStr)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error locating attachment")
+				os.Exit(1)
+			}
+			destStr := attachCmdMove.Arg(1)
+			dest, err := snip.GetFromUUID(destStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", destStr)
+				log.Debug().Err(err).Str("uuid", destStr).Msg("error retrieving snip with uuid")
+				os.Exit(1)
+			}
+
+			err = snip.MoveAttachment(a.UUID, dest.UUID)
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem moving attachment %s to snip %s\n", a.UUID, dest.UUID)
+				log.Debug().Err(err).Str("uuid", a.UUID.String()).Msg("error moving attachment")
+				os.Exit(1)
+			}
+			informOut("moved %s %s %s -> %s\n", a.UUID, a.Name, a.SnipUUID, dest.UUID)
+
+		// RENAME attachment
+		case "rename":
+			if err := attachCmdRename.Parse(attachCmd.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "The arguments to the rename command could not be parsed.\n")
+				log.Debug().Err(err).Msg("error parsing attach rename arguments")
+				attachCmdRename.Usage()
+				os.Exit(1)
+			}
+			if len(attachCmdRename.Args()) != 2 {
+				fmt.Fprintf(os.Stderr, "The attach rename command requires two arguments, the attachment uuid and the new name.\n")
+				attachCmdRename.Usage()
+				os.Exit(1)
+			}
+
+			idStr := attachCmdRename.Arg(0)
+			newName := attachCmdRename.Arg(1)
+			// no empty strings allowed
+			if newName == "" {
+				fmt.Fprintf(os.Stderr, "The new name cannot be an empty string.\n")
+				os.Exit(1)
+			}
+			a, err := snip.GetAttachmentMetadataFromUUID(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The supplied id %s could not be located.\n", idStr)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error locating attachment")
+				os.Exit(1)
+			}
+
+			err = snip.RenameAttachment(a.UUID, newName)
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem renaming attachment %s\n", a.UUID)
+				log.Debug().Err(err).Str("uuid", a.UUID.String()).Msg("error renaming attachment")
+				os.Exit(1)
+			}
+			informOut("renamed %s %s -> %s\n", a.UUID, a.Name, newName)
+
+		// REPLACE attachment data from a file
+		case "replace":
+			if err := attachCmdReplace.Parse(attachCmd.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "The arguments to the replace command could not be parsed.\n")
+				log.Debug().Err(err).Msg("error parsing attach replace arguments")
+				attachCmdReplace.Usage()
+				os.Exit(1)
+			}
+			if len(attachCmdReplace.Args()) != 2 {
+				fmt.Fprintf(os.Stderr, "The attach replace command requires two arguments, the attachment uuid and the local file.\n")
+				attachCmdReplace.Usage()
+				os.Exit(1)
+			}
+			idStr := attachCmdReplace.Arg(0)
+			filename := attachCmdReplace.Arg(1)
+			id, err := snip.ResolveAttachmentUUID(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not locate attachment with id %s\n", idStr)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error locating attachment")
+				os.Exit(1)
+			}
+			a, err := snip.GetAttachmentMetadata(id)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem retrieving the metadata of attachment %s\n", id)
+				log.Debug().Err(err).Str("uuid", id.String()).Msg("error retrieving attachment metadata")
+				os.Exit(1)
+			}
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The file %s could not be read.\n", filename)
+				log.Debug().Err(err).Str("file", filename).Msg("error reading replacement file data")
+				os.Exit(1)
+			}
+			err = snip.ReplaceAttachment(id, data)
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem replacing the data of attachment %s\n", id)
+				log.Debug().Err(err).Str("uuid", id.String()).Msg("error replacing attachment")
+				os.Exit(1)
+			}
+			informOut("replaced %s %s %d bytes -> %d bytes\n", a.UUID, a.Name, a.Size, len(data))
+
+		// REMOVE attachments by uuid
+		case "rm":
+			if err := attachCmdRemove.Parse(attachCmd.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "The arguments to the rm command could not be parsed.\n")
+				log.Debug().Err(err).Msg("error parsing attach remove arguments")
+				attachCmdRemove.Usage()
+				os.Exit(1)
+			}
+			// TODO: Check this behavior, don't we need [1:] or something?
+			for _, idStr := range attachCmdRemove.Args() {
+				// id, err := uuid.Parse(idStr)
+				attachment, err := snip.GetAttachmentMetadataFromUUID(idStr)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "The supplied id %s could not be located.\n", idStr)
+					log.Debug().Err(err).Str("uuid", "idStr").Msg("error locating attachment")
+					continue
+				}
+
+				// confirm before deletion
+				if !confirmAction(fmt.Sprintf("REMOVE attachment %s %s", attachment.UUID, attachment.Name)) {
+					informOut("skipped\n")
+					continue
+				}
+				err = snip.RemoveAttachment(attachment.UUID)
+				exitIfReadOnly(err)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "There was a problem while trying to delete attachment %s %s\n", idStr, err)
 					log.Debug().Err(err).Str("uuid", idStr).Msg("error removing attachment")
 				} else {
-					fmt.Println("removed attachment")
+					informOut("removed attachment\n")
 				}
 			}
 
 		// STANDARD OUTPUT
+		case "get":
+			if err := attachCmdGet.Parse(attachCmd.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "The attach get arguments could not be parsed.\n")
+				log.Debug().Err(err).Msg("error parsing attach get arguments")
+				attachCmdGet.Usage()
+				os.Exit(1)
+			}
+			if len(attachCmdGet.Args()) != 1 {
+				fmt.Fprintf(os.Stderr, "The attach get command requires one attachment uuid.\n")
+				attachCmdGet.Usage()
+				os.Exit(1)
+			}
+
+			idStr := attachCmdGet.Arg(0)
+			// metadata only, as the data may be large
+			id, err := snip.ResolveAttachmentUUID(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not locate attachment with id %s\n", idStr)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error locating attachment")
+				os.Exit(1)
+			}
+			a, err := snip.GetAttachmentMetadata(id)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem retrieving the metadata of attachment %s\n", id)
+				log.Debug().Err(err).Str("uuid", id.String()).Msg("error retrieving attachment metadata")
+				os.Exit(1)
+			}
+			fmt.Printf("uuid: %s\n", a.UUID)
+			fmt.Printf("snip_uuid: %s\n", a.SnipUUID)
+			fmt.Printf("name: %s\n", a.Name)
+			fmt.Printf("size: %d\n", a.Size)
+			fmt.Printf("mime: %s\n", a.MIME)
+			fmt.Printf("timestamp: %s\n", a.Timestamp.Format(time.RFC3339Nano))
+
+		case "owner":
+			if err := attachCmdOwner.Parse(attachCmd.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "The attach owner arguments could not be parsed.\n")
+				log.Debug().Err(err).Msg("error parsing attach owner arguments")
+				attachCmdOwner.Usage()
+				os.Exit(1)
+			}
+			if len(attachCmdOwner.Args()) != 1 {
+				fmt.Fprintf(os.Stderr, "The attach owner command requires one attachment uuid.\n")
+				attachCmdOwner.Usage()
+				os.Exit(1)
+			}
+
+			idStr := attachCmdOwner.Arg(0)
+			id, err := snip.ResolveAttachmentUUID(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not locate attachment with id %s\n", idStr)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error locating attachment")
+				os.Exit(1)
+			}
+			s, err := snip.GetAttachmentOwner(id)
+			var orphaned *snip.OrphanedAttachmentError
+			if errors.As(err, &orphaned) {
+				fmt.Fprintf(os.Stderr, "The attachment %s is orphaned, its snip %s does not exist. Move it to another snip with attach mv.\n", id, orphaned.SnipUUID)
+				database.Conn.Close()
+				os.Exit(1)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem retrieving the snip of attachment %s\n", id)
+				log.Debug().Err(err).Str("uuid", id.String()).Msg("error retrieving attachment owner")
+				os.Exit(1)
+			}
+			fmt.Printf("%s %s\n", s.UUID, s.Name)
+
+		case "promote":
+			if err := attachCmdPromote.Parse(attachCmd.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "The attach promote arguments could not be parsed.\n")
+				log.Debug().Err(err).Msg("error parsing attach promote arguments")
+				attachCmdPromote.Usage()
+				os.Exit(1)
+			}
+			if len(attachCmdPromote.Args()) != 1 {
+				fmt.Fprintf(os.Stderr, "The attach promote command requires one attachment uuid.\n")
+				attachCmdPromote.Usage()
+				os.Exit(1)
+			}
+
+			idStr := attachCmdPromote.Arg(0)
+			id, err := snip.ResolveAttachmentUUID(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not locate attachment with id %s\n", idStr)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error locating attachment")
+				os.Exit(1)
+			}
+			var s snip.Snip
+			if *attachCmdPromoteForce {
+				s, err = snip.PromoteAttachmentForce(id, *attachCmdPromoteRemove)
+			} else {
+				s, err = snip.PromoteAttachment(id, *attachCmdPromoteRemove)
+				exitIfReadOnly(err)
+			}
+			var sizeErr *snip.DataSizeError
+			switch {
+			case errors.Is(err, snip.ErrNotUTF8):
+				fmt.Fprintf(os.Stderr, "The attachment %s is not valid UTF-8 text, use -force to promote it anyway.\n", id)
+				os.Exit(1)
+			case errors.As(err, &sizeErr):
+				fmt.Fprintf(os.Stderr, "The attachment %s is too large to promote: %v\n", id, err)
+				os.Exit(1)
+			case err != nil:
+				fmt.Fprintf(os.Stderr, "There was a problem promoting attachment %s\n", id)
+				printIndexMismatch(err)
+				log.Debug().Err(err).Str("uuid", id.String()).Msg("error promoting attachment")
+				os.Exit(1)
+			}
+			warnDuplicateName(s.Name, s.UUID)
+			informOut("promoted attachment %s -> snip %s %s\n", id, s.UUID, s.Name)
+			if *attachCmdPromoteRemove {
+				informOut("removed attachment %s\n", id)
+			}
+
 		case "stdout":
 			// output raw data to stdout for piping or analysis
-			if err := attachCmdGet.Parse(attachCmd.Args()[1:]); err != nil {
+			if err := attachCmdStdout.Parse(attachCmd.Args()[1:]); err != nil {
 				log.Debug().Err(err).Msg("error parsing attach list arguments")
-				attachCmdGet.Usage()
+				attachCmdStdout.Usage()
 				os.Exit(1)
 			}
 
-			if len(attachCmdGet.Args()) != 1 {
+			if len(attachCmdStdout.Args()) != 1 {
 				Usage()
 				os.Exit(1)
 			}
 
-			id, err := uuid.Parse(attachCmdGet.Arg(0))
+			id, err := uuid.Parse(attachCmdStdout.Arg(0))
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "The provided id could not be parsed and may be malformed.\n")
 				os.Exit(1)
 			}
-			a, err := snip.GetAttachmentFromUUID(id.String())
+			_, err = snip.GetAttachmentMetadata(id)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Could not locate attachment with id %s\n", id)
 				log.Debug().Err(err).Str("uuid", id.String()).Msg("could not create attachment from uuid")
 				os.Exit(0)
 			}
-			// output
-			fmt.Printf("%s", a.Data)
+			// output, streamed so that large attachments are not held in memory
+			if *attachCmdStdoutBase64 {
+				enc := snip.NewBase64Writer(os.Stdout)
+				_, err = snip.StreamAttachment(id, enc)
+				if err == nil {
+					err = enc.Close()
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem writing attachment %s as base64.\n", id)
+					log.Debug().Err(err).Str("uuid", id.String()).Msg("error encoding attachment data")
+					os.Exit(1)
+				}
+				break
+			}
+			// data is written unchanged, as binary attachments are not text
+			_, err = snip.StreamAttachment(id, os.Stdout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem writing attachment %s.\n", id)
+				log.Debug().Err(err).Str("uuid", id.String()).Msg("error writing attachment data")
+				os.Exit(1)
+			}
+
+		// VERIFY attachment sizes against their data
+		case "verify":
+			if err := attachCmdVerify.Parse(attachCmd.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "The attach verify arguments could not be parsed.\n")
+				log.Debug().Err(err).Msg("error parsing attach verify arguments")
+				attachCmdVerify.Usage()
+				os.Exit(1)
+			}
+			if len(attachCmdVerify.Args()) > 1 {
+				fmt.Fprintf(os.Stderr, "The attach verify command accepts at most one attachment uuid.\n")
+				attachCmdVerify.Usage()
+				os.Exit(1)
+			}
+
+			var ids []uuid.UUID
+			if len(attachCmdVerify.Args()) == 1 {
+				idStr := attachCmdVerify.Arg(0)
+				id, err := snip.ResolveAttachmentUUID(idStr)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "The supplied id %s could not be located.\n", idStr)
+					log.Debug().Err(err).Str("uuid", idStr).Msg("error locating attachment")
+					os.Exit(1)
+				}
+				ids = append(ids, id)
+			} else {
+				ids, err = snip.GetAttachmentsAll()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem while gathering the list of attachments.\n")
+					log.Debug().Err(err).Msg("could not list all attachments")
+					os.Exit(1)
+				}
+			}
+
+			mismatched := 0
+			for _, id := range ids {
+				ok, err := snip.VerifyAttachment(id)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem verifying attachment %s\n", id)
+					log.Debug().Err(err).Str("uuid", id.String()).Msg("error verifying attachment")
+					os.Exit(1)
+				}
+				if ok {
+					continue
+				}
+				a, err := snip.GetAttachmentMetadata(id)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem when attempting to read metadata of attachment %s\n", id)
+					log.Debug().Err(err).Str("uuid", id.String()).Msg("error getting attachment metadata")
+					os.Exit(1)
+				}
+				fmt.Printf("size mismatch %s %s (%d bytes recorded)\n", a.UUID, a.Name, a.Size)
+				mismatched++
+			}
+			if mismatched > 0 {
+				fmt.Fprintf(os.Stderr, "%d of %d attachments do not match their recorded size\n", mismatched, len(ids))
+				// exiting skips deferred functions, and this is an expected outcome rather than a failure
+				database.Conn.Close()
+				os.Exit(1)
+			}
+			inform("%d attachments verified\n", len(ids))
 
 		// WRITE attachment to file
 		case "write":
@@ -415,7 +1494,7 @@ snip rm <uuid ...>              remove snip <uuid> ...
 					os.Exit(1)
 				}
 			*/
-			a, err := snip.GetAttachmentFromUUID(idStr)
+			a, err := snip.GetAttachmentMetadataFromUUID(idStr)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "There was a problem locating the attachment with id %s\n", idStr)
 				log.Debug().Err(err).Str("id", idStr).Msg("could not get attachment")
@@ -440,405 +1519,2395 @@ snip rm <uuid ...>              remove snip <uuid> ...
 				log.Debug().Err(err).Msg("error writing attachment to file")
 				os.Exit(1)
 			}
-			fmt.Printf("%s written -> %s %d bytes\n", a.Name, outfile, bytesWritten)
+			informOut("%s written -> %s %d bytes\n", a.Name, outfile, bytesWritten)
+
+		case "write-all":
+			if err := attachCmdWriteAll.Parse(attachCmd.Args()[1:]); err != nil {
+				fmt.Fprintf(os.Stderr, "The attach write-all arguments could not be parsed.\n")
+				log.Debug().Err(err).Msg("error parsing attach write-all arguments")
+				attachCmdWriteAll.Usage()
+				os.Exit(1)
+			}
+			if len(attachCmdWriteAll.Args()) != 2 {
+				fmt.Fprintf(os.Stderr, "The attach write-all command requires the snip uuid and an output directory.\n")
+				attachCmdWriteAll.Usage()
+				os.Exit(1)
+			}
+			idStr := attachCmdWriteAll.Args()[0]
+			dir := attachCmdWriteAll.Args()[1]
+			s, err := snip.ResolveSnip(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+				printMatches(err)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+				os.Exit(1)
+			}
+
+			written, err := snip.WriteAllAttachmentsReport(s.UUID, dir, *attachCmdWriteAllForce, func(a snip.Attachment, outfile string, bytesWritten int, err error) {
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s could not be written -> %s: %v\n", a.Name, outfile, err)
+					return
+				}
+				informOut("%s written -> %s %d bytes\n", a.Name, outfile, bytesWritten)
+			})
+			inform("%d attachments written\n", written)
+			if err != nil {
+				log.Debug().Err(err).Str("dir", dir).Msg("error writing attachments")
+				database.Conn.Close()
+				os.Exit(1)
+			}
+
 		default:
 			Usage()
 			os.Exit(1)
 		}
 
-	case "get":
-		if err := getCmd.Parse(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "The get arguments could not be parsed.\n")
-			log.Debug().Err(err).Msg("error parsing get arguments")
+	case "backup":
+		if err := backupCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The backup arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing backup arguments")
+			backupCmd.Usage()
+			os.Exit(1)
+		}
+		if len(backupCmd.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "The backup command requires one argument, the destination file.\n")
+			backupCmd.Usage()
 			os.Exit(1)
 		}
-		var idStr string
 
-		// random from all snips
-		if *getCmdRandom {
-			// get list
-			// TODO: verify that this does not load everything in memory everywhere immediately
-			allSnips, err := snip.List(0)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "There was a problem building the list of all snips in the database.\n")
-				log.Debug().Err(err).Msg("error retrieving all snips")
+		dest := backupCmd.Arg(0)
+		_, err = os.Stat(dest)
+		if err == nil {
+			// ESCAPE HATCH never overwrite data unless the issue is forced
+			if !*backupCmdForce {
+				fmt.Fprintf(os.Stderr, "The file %s already exists, refusing to overwrite.\n", dest)
+				log.Debug().Str("file", dest).Msg("stat returned no errors, refusing to overwrite file")
 				os.Exit(1)
 			}
-
-			// get random within range
-			src := rand.NewSource(time.Now().UnixNano())
-			r := rand.New(src)
-			index := r.Intn(len(allSnips))
-			log.Debug().Int("random index", index).Msg("generated random integer")
-			// assign to outside world
-			idStr = allSnips[index].UUID.String()
 		}
-
-		// obtain uuid specified from argument
-		if len(getCmd.Args()) != 1 {
-			Usage()
+		// an existing file is replaced only once the backup is complete
+		err = database.BackupDatabase(dest)
+		if errors.Is(err, database.ErrBackupIsDatabase) {
+			fmt.Fprintf(os.Stderr, "The file %s is the open database, refusing to overwrite it with a backup.\n", dest)
 			os.Exit(1)
 		}
-		idStr = getCmd.Args()[0]
-
-		// If this has not been set by anything above, use the command line.
-		if idStr == "" {
-			idStr = getCmd.Args()[0]
-		}
-
-		// There is no reason to parse this since it may be a fuzzy term. Rely on the errors.
-		// TODO handle both cases explicitly and derive functions for full and partial uuid
-		s, err := snip.GetFromUUID(idStr)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
-			log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+			fmt.Fprintf(os.Stderr, "There was a problem backing up the database to %s\n", dest)
+			log.Debug().Err(err).Str("file", dest).Msg("error backing up database")
 			os.Exit(1)
 		}
+		informOut("%s backup -> %s\n", dbFilePath, dest)
 
-		if *getCmdRaw {
-			fmt.Printf("%s", s.Data)
-		} else {
-			fmt.Printf("uuid: %s\n", s.UUID.String())
-			fmt.Printf("name: %s\n", s.Name)
-			fmt.Printf("timestamp: %s\n", s.Timestamp.Format(time.RFC3339Nano))
-			fmt.Printf("----\n")
-			fmt.Printf("%s", s.Data)
-			// add an extra newline if the data does not end with one
-			// no one likes their prompt hijacked. This will not affect raw output.
-			if !strings.HasSuffix(s.Data, "\n") {
-				fmt.Println()
-			}
-			fmt.Printf("----\n")
-			for idx, a := range s.Attachments {
-				// print attachments if present
-				if idx == 0 {
-					fmt.Printf("attachments:\n")
-					fmt.Printf("%s %42s %s\n", "uuid", "bytes", "name")
-				}
-				fmt.Printf("%s %10d %s\n", a.UUID.String(), a.Size, a.Name)
-			}
+	case "cat":
+		if err := catCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The cat arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing cat arguments")
+			catCmd.Usage()
+			os.Exit(1)
 		}
-
-	case "ls":
-		if err := listCmd.Parse(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "The ls arguments could not be parsed.\n")
-			log.Debug().Err(err).Msg("error parsing ls arguments")
-			listCmd.Usage()
+		if len(catCmd.Args()) < 1 {
+			fmt.Fprintf(os.Stderr, "The cat command requires at least one uuid.\n")
+			catCmd.Usage()
 			os.Exit(1)
 		}
-		results, err := snip.GetAllSnipIDs()
+		// allow escape sequences such as \n and \t to be supplied literally from the shell
+		delimiter, err := strconv.Unquote(`"` + *catCmdDelimiter + `"`)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "There was a problem while attempting to obtain the metadata of all snips.\n")
-			log.Debug().Err(err).Msg("error listing items metadata")
+			fmt.Fprintf(os.Stderr, "The delimiter %s could not be interpreted.\n", *catCmdDelimiter)
+			log.Debug().Err(err).Str("delimiter", *catCmdDelimiter).Msg("error unquoting delimiter")
 			os.Exit(1)
 		}
-		for idx, id := range results {
-			s, err := snip.GetFromUUID(id.String())
+
+		// resolve all snips before writing anything
+		var snips []snip.Snip
+		for _, idStr := range catCmd.Args() {
+			s, err := snip.ResolveSnip(idStr)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "The snip with uuid: %s could not be obtained from the database.\n", id.String())
-				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error obtaining snip from uuid")
+				fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+				printMatches(err)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
 				os.Exit(1)
 			}
-			if idx == 0 {
-				if *listCmdLong {
-					// long
-					fmt.Fprintf(os.Stderr, "%s %36s\n", "uuid", "name")
-				} else {
-					// short
-					fmt.Fprintf(os.Stderr, "%s %8s\n", "uuid", "name")
-				}
+			snips = append(snips, s)
+		}
+
+		for idx, s := range snips {
+			if idx > 0 {
+				io.WriteString(os.Stdout, delimiter)
 			}
-			if *listCmdLong {
-				fmt.Printf("%s %s\n", s.UUID, s.Name)
-			} else {
-				fmt.Printf("%s %s\n", snip.ShortenUUID(s.UUID)[0], s.Name)
+			if *catCmdHeader {
+				fmt.Printf("# %s\n", s.Name)
 			}
+			io.WriteString(os.Stdout, s.Data)
 		}
 
-	case "rename":
-		if err := renameCmd.Parse(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "The rename arguments could not be parsed.\n")
-			log.Debug().Err(err).Msg("error parsing rename arguments")
-			renameCmd.Usage()
+	case "clone":
+		if err := cloneCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The clone arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing clone arguments")
+			cloneCmd.Usage()
 			os.Exit(1)
 		}
-		// require one argument
-		if len(renameCmd.Args()) != 2 {
-			fmt.Fprintf(os.Stderr, "The rename command requires two arguments.\n")
-			log.Debug().Err(err).Msg("error parsing rename arguments")
+		if cloneCmd.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "The clone command requires one uuid argument.\n")
+			cloneCmd.Usage()
 			os.Exit(1)
 		}
 
-		idStr := renameCmd.Args()[0]
-		newName := renameCmd.Args()[1]
-		// no empty strings allowed
-		if newName == "" {
-			fmt.Fprintf(os.Stderr, "The new name cannot be an empty string.\n")
-			log.Debug().Err(err).Msg("no empty string allowed for renaming")
+		idStr := cloneCmd.Arg(0)
+		s, err := snip.ResolveSnip(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+			printMatches(err)
+			log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
 			os.Exit(1)
 		}
-		s, err := snip.GetFromUUID(idStr)
+		clone, err := s.Clone()
+		exitIfReadOnly(err)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "could not retrieve snip with id: %s\n", idStr)
-			log.Debug().Err(err).Str("uuid", idStr).Msg("retrieving snip from uuid")
+			fmt.Fprintf(os.Stderr, "There was a problem cloning snip %s\n", s.UUID)
+			printIndexMismatch(err)
+			log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error cloning snip")
 			os.Exit(1)
 		}
-		oldName := s.Name
-		s.Name = newName
-		err = s.Update()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "There was a problem updating snip with id %s\n", idStr)
-			log.Debug().Err(err).Msg("could not update snip")
+		informOut("cloned %s -> %s %s\n", s.UUID, clone.UUID, clone.Name)
+
+	case "dedup":
+		if err := dedupCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The dedup arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing dedup arguments")
+			dedupCmd.Usage()
+			os.Exit(1)
+		}
+		if *dedupCmdDeleteNewer && *dedupCmdDeleteOlder {
+			fmt.Fprintf(os.Stderr, "The -delete-newer and -delete-older options cannot be used together.\n")
 			os.Exit(1)
 		}
-		fmt.Printf("renamed %s %s -> %s\n", s.UUID.String(), oldName, newName)
 
-	case "rm":
-		if err := rmCmd.Parse(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "The rm arguments could not be parsed.\n")
-			log.Debug().Err(err).Msg("error parsing rm arguments")
-			rmCmd.Usage()
+		duplicates, err := snip.FindDuplicateSnips()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem searching for duplicate snips.\n")
+			log.Debug().Err(err).Msg("error finding duplicate snips")
 			os.Exit(1)
 		}
-		for idx, arg := range rmCmd.Args() {
-			// parse to uuid because it seems proper
-			s, err := snip.GetFromUUID(arg)
-			// id, err := uuid.Parse(arg)
+		if len(duplicates) == 0 {
+			inform("no duplicate snips found\n")
+			os.Exit(0)
+		}
+
+		// stable output order
+		var hashes []string
+		for hash := range duplicates {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+
+		for _, hash := range hashes {
+			// groups are ordered oldest to newest
+			ids := duplicates[hash]
+			fmt.Printf("%s (%d snips)\n", hash[:12], len(ids))
+			attachmentCounts := make(map[uuid.UUID]int)
+			for _, id := range ids {
+				s, err := snip.GetFromUUID(id.String())
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "The snip with uuid: %s could not be obtained from the database.\n", id)
+					log.Debug().Err(err).Str("uuid", id.String()).Msg("error obtaining snip from uuid")
+					os.Exit(1)
+				}
+				fmt.Printf("  %s %s %s\n", s.UUID, s.Timestamp.Format(time.RFC3339Nano), s.Name)
+				attachmentCounts[s.UUID] = len(s.Attachments)
+			}
+
+			if !*dedupCmdDeleteNewer && !*dedupCmdDeleteOlder {
+				continue
+			}
+			survivor := ids[0]
+			remove := ids[1:]
+			if *dedupCmdDeleteOlder {
+				survivor = ids[len(ids)-1]
+				remove = ids[:len(ids)-1]
+			}
+			for _, id := range remove {
+				if *dedupCmdDryRun {
+					if *dedupCmdReattach {
+						fmt.Printf("would remove %s keeping %s (%d attachments moved)\n", id, survivor, attachmentCounts[id])
+					} else {
+						fmt.Printf("would remove %s keeping %s (%d attachments removed)\n", id, survivor, attachmentCounts[id])
+					}
+					continue
+				}
+				if !confirmAction(fmt.Sprintf("REMOVE snip %s keeping %s", id, survivor)) {
+					informOut("skipped\n")
+					continue
+				}
+				if *dedupCmdReattach {
+					err = snip.ReassignAttachments(id, survivor)
+					exitIfReadOnly(err)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "The attachments of %s could not be moved to %s, skipping removal.\n", id, survivor)
+						log.Debug().Err(err).Str("uuid", id.String()).Msg("error reassigning attachments")
+						continue
+					}
+				}
+				err = snip.Remove(id)
+				exitIfReadOnly(err)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Could not remove %s\n", id)
+					log.Debug().Err(err).Str("uuid", id.String()).Msg("error while attempting to delete snip")
+					continue
+				}
+				informOut("removed %s\n", id)
+			}
+		}
+
+	case "diff":
+		if err := diffCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The diff arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing diff arguments")
+			diffCmd.Usage()
+			os.Exit(1)
+		}
+		if len(diffCmd.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "The diff command requires two uuids, or a uuid and a revision number such as @1.\n")
+			diffCmd.Usage()
+			os.Exit(1)
+		}
+
+		idStr := diffCmd.Arg(0)
+		s, err := snip.ResolveSnip(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+			printMatches(err)
+			log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+			os.Exit(1)
+		}
+
+		// @ and a number refers to a revision of the first snip, which is shown as the older side, while a bare number
+		// is a partial uuid
+		var output string
+		if revisionStr, ok := strings.CutPrefix(diffCmd.Arg(1), "@"); ok {
+			revision, err := strconv.Atoi(revisionStr)
+			if err != nil || revision < 1 {
+				fmt.Fprintf(os.Stderr, "The revision %s is not valid, use @ followed by a revision number.\n", diffCmd.Arg(1))
+				os.Exit(1)
+			}
+			r, err := snip.GetRevision(s.UUID, revision)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Could not locate id %d/%d %s\n", idx+1, len(rmCmd.Args()), arg)
+				fmt.Fprintf(os.Stderr, "The revision %d of snip %s could not be retrieved.\n", revision, s.UUID)
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Int("revision", revision).Msg("error retrieving revision")
+				os.Exit(1)
+			}
+			output = snip.Diff(fmt.Sprintf("%s %s (revision %d)", r.SnipUUID, r.Name, r.Revision), r.Data,
+				fmt.Sprintf("%s %s", s.UUID, s.Name), s.Data)
+		} else {
+			otherStr := diffCmd.Arg(1)
+			other, err := snip.ResolveSnip(otherStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", otherStr)
+				printMatches(err)
+				log.Debug().Err(err).Str("uuid", otherStr).Msg("error retrieving snip with uuid")
+				os.Exit(1)
+			}
+			output = snip.DiffSnips(s, other)
+		}
+		fmt.Print(colorDiff(output))
+
+	case "export", "export-jsonl":
+		exportFlags, exportOutput, exportForce := exportCmd, exportCmdOutput, exportCmdForce
+		if action == "export-jsonl" {
+			exportFlags, exportOutput, exportForce = exportJSONLCmd, exportJSONLCmdOutput, exportJSONLCmdForce
+		}
+		if err := exportFlags.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The %s arguments could not be parsed.\n", action)
+			log.Debug().Err(err).Msgf("error parsing %s arguments", action)
+			exportFlags.Usage()
+			os.Exit(1)
+		}
+
+		// EXPORT a single snip to a directory
+		if action == "export" && exportCmd.NArg() > 0 {
+			if exportCmd.NArg() != 2 || *exportCmdOutput != "" {
+				fmt.Fprintf(os.Stderr, "The export command requires two arguments to export one snip, the snip uuid and the directory.\n")
+				exportCmd.Usage()
+				os.Exit(1)
+			}
+			idStr, dir := exportCmd.Arg(0), exportCmd.Arg(1)
+			s, err := snip.ResolveSnip(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+				printMatches(err)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+				os.Exit(1)
+			}
+			err = snip.ExportSnip(s.UUID, dir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem exporting snip %s to %s\n", s.UUID, dir)
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Str("dir", dir).Msg("error exporting snip")
+				os.Exit(1)
+			}
+			informOut("exported %s %s -> %s (%d attachments)\n", s.UUID, s.Name, dir, len(s.Attachments))
+			break
+		}
+
+		// default to standard output
+		var w io.Writer = os.Stdout
+		if *exportOutput != "" {
+			_, err = os.Stat(*exportOutput)
+			if err == nil && !*exportForce {
+				fmt.Fprintf(os.Stderr, "The file %s already exists, refusing to overwrite.\n", *exportOutput)
+				log.Debug().Str("file", *exportOutput).Msg("stat returned no errors, refusing to overwrite file")
+				os.Exit(1)
+			}
+			f, err := os.Create(*exportOutput)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The file %s could not be opened for writing.\n", *exportOutput)
+				log.Debug().Err(err).Str("file", *exportOutput).Msg("error creating export file")
+				os.Exit(1)
+			}
+			defer f.Close()
+			w = f
+		}
+
+		if action == "export-jsonl" {
+			// buffered, as each snip is written separately
+			bw := bufio.NewWriter(w)
+			ctx, stop := interruptContext()
+			err = snip.ExportJSONL(ctx, bw)
+			stop()
+			// snips exported before an interruption are complete lines
+			if err == nil || errors.Is(err, context.Canceled) {
+				if flushErr := bw.Flush(); flushErr != nil {
+					err = flushErr
+				}
+			}
+		} else {
+			err = snip.ExportAll(w)
+		}
+		if errors.Is(err, context.Canceled) {
+			fmt.Fprintf(os.Stderr, "The export was interrupted and contains only the snips written before.\n")
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem exporting the database.\n")
+			log.Debug().Err(err).Msg("error exporting database")
+			os.Exit(1)
+		}
+		if *exportOutput != "" {
+			inform("exported -> %s\n", *exportOutput)
+		}
+
+	case "fav", "unfav":
+		favFlags := favCmd
+		if action == "unfav" {
+			favFlags = unfavCmd
+		}
+		if err := favFlags.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The %s arguments could not be parsed.\n", action)
+			log.Debug().Err(err).Msgf("error parsing %s arguments", action)
+			favFlags.Usage()
+			os.Exit(1)
+		}
+		if favFlags.NArg() < 1 {
+			fmt.Fprintf(os.Stderr, "The %s command requires at least one uuid argument.\n", action)
+			favFlags.Usage()
+			os.Exit(1)
+		}
+		fav := action == "fav"
+		for idx, arg := range favFlags.Args() {
+			s, err := snip.ResolveSnip(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not locate id %d/%d %s\n", idx+1, favFlags.NArg(), arg)
+				printMatches(err)
 				log.Debug().Str("uuid", arg).Err(err).Msg("error parsing uuid input")
 				// Do not exit as others may be valid.
 				continue
 			}
-			if !confirmAction(fmt.Sprintf("REMOVE snip %s", s.UUID)) {
-				fmt.Println("skipped")
+			err = snip.SetFavorite(s.UUID, fav)
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not update favorite %d/%d %s\n", idx+1, favFlags.NArg(), s.UUID)
+				log.Debug().Str("uuid", s.UUID.String()).Err(err).Msg("error setting favorite")
 				continue
 			}
-			err = snip.Remove(s.UUID)
-			if err != nil {
-				fmt.Printf("Could not remove %d/%d %s\n", idx+1, len(rmCmd.Args()), s.UUID)
-				log.Debug().Str("uuid", s.UUID.String()).Err(err).Msg("error while attempting to delete snip")
+			if fav {
+				informOut("favorite %d/%d %s %s\n", idx+1, favFlags.NArg(), s.UUID, s.Name)
 			} else {
-				// must else because we don't break
-				fmt.Printf("removed %d/%d %s\n", idx+1, len(rmCmd.Args()), s.UUID)
+				informOut("unfavorite %d/%d %s %s\n", idx+1, favFlags.NArg(), s.UUID, s.Name)
 			}
 		}
 
-	case "search":
-		if err := searchCmd.Parse(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "The search arguments could not be parsed.\n")
-			log.Debug().Err(err).Str("args", strings.Join(searchCmd.Args(), " ")).Msg("error parsing search arguments")
-			searchCmd.Usage()
+	case "find":
+		if err := findCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The find arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing find arguments")
+			findCmd.Usage()
 			os.Exit(1)
 		}
-		if len(searchCmd.Args()) < 1 {
-			fmt.Fprintf(os.Stderr, "Must supply at least one search term.\n")
-			searchCmd.Usage()
+		query := strings.Join(findCmd.Args(), " ")
+
+		snips, err := snip.ListNames()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem while attempting to list snips.\n")
+			log.Debug().Err(err).Msg("error listing snips")
 			os.Exit(1)
 		}
+		sort.SliceStable(snips, func(i int, j int) bool {
+			return strings.ToLower(snips[i].Name) < strings.ToLower(snips[j].Name)
+		})
+		var names []string
+		for _, s := range snips {
+			names = append(names, s.Name)
+		}
 
-		var snipResults []snip.Snip
-
-		switch *searchCmdType {
-		case "index":
-			terms := searchCmd.Args()
-
-			searchResults, err := snip.SearchIndexTerm(terms, true)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "There was a problem searching the index for term %s\n", terms)
-				log.Debug().Err(err).Msg("error while searching for term")
+		if !isatty.IsTerminal(os.Stdout.Fd()) {
+			matches := snip.FuzzyMatch(query, names)
+			for _, idx := range matches {
+				if *findCmdPrintUUID {
+					fmt.Printf("%s\n", snips[idx].UUID)
+				} else {
+					fmt.Printf("%s %s\n", snips[idx].UUID, snip.FlattenString(snips[idx].Name))
+				}
+			}
+			if len(matches) == 0 {
+				database.Conn.Close()
 				os.Exit(1)
 			}
+			break
+		}
+
+		if len(snips) == 0 {
+			fmt.Fprintf(os.Stderr, "There are no snips to find.\n")
+			database.Conn.Close()
+			os.Exit(1)
+		}
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The terminal could not be opened for interactive selection.\n")
+			log.Debug().Err(err).Msg("error opening terminal")
+			os.Exit(1)
+		}
+		idx, err := runFinder(tty, names, query)
+		tty.Close()
+		if errors.Is(err, errFinderCancelled) {
+			database.Conn.Close()
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem with interactive selection.\n")
+			log.Debug().Err(err).Msg("error running finder")
+			os.Exit(1)
+		}
+		if *findCmdPrintUUID {
+			fmt.Printf("%s\n", snips[idx].UUID)
+			break
+		}
+		s, err := snip.GetFromUUID(snips[idx].UUID.String())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem retrieving the selected snip.\n")
+			log.Debug().Err(err).Msg("error retrieving snip")
+			os.Exit(1)
+		}
+		io.WriteString(os.Stdout, s.Data)
+
+	case "get":
+		if err := getCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The get arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing get arguments")
+			os.Exit(1)
+		}
+		switch *getCmdFormat {
+		case "text", "md", "gist":
+		default:
+			fmt.Fprintf(os.Stderr, "The format %s is not supported, use text, md, or gist.\n", *getCmdFormat)
+			os.Exit(1)
+		}
+		if *getCmdInlineMax < 0 {
+			fmt.Fprintf(os.Stderr, "The maximum inline attachment size must not be negative.\n")
+			os.Exit(1)
+		}
+		if *getCmdHead < 0 || *getCmdTail < 0 {
+			fmt.Fprintf(os.Stderr, "The number of head and tail lines must not be negative.\n")
+			os.Exit(1)
+		}
+		if *getCmdWrap < 0 {
+			fmt.Fprintf(os.Stderr, "The wrap width must not be negative.\n")
+			os.Exit(1)
+		}
+		var idStr string
 
-			var scores []snip.SearchScore
-			for key, result := range searchResults {
-				score, err := snip.ScoreCounts(key, terms, result)
+		if *getCmdRandom {
+			// random from all snips, or from those matching supplied search terms
+			var candidates []uuid.UUID
+			if len(getCmd.Args()) > 0 {
+				searchResults, err := snip.SearchIndexTerm(getCmd.Args(), true)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem searching the index for term %s\n", getCmd.Args())
+					printIndexMismatch(err)
+					log.Debug().Err(err).Msg("error searching for random candidates")
+					os.Exit(1)
+				}
+				for id := range searchResults {
+					candidates = append(candidates, id)
+				}
+			} else {
+				candidates, err = snip.GetAllSnipIDs()
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "There was a problem scoring the item with id %s\n", key)
-					log.Debug().Err(err).Str("uuid", key.String()).Msg("scoring the results")
+					fmt.Fprintf(os.Stderr, "There was a problem building the list of all snips in the database.\n")
+					log.Debug().Err(err).Msg("error retrieving all snip ids")
 					os.Exit(1)
 				}
-				// add to sortable slice
-				scores = append(scores, snip.SearchScore{UUID: key, Score: score, SearchCounts: result})
+			}
+			if len(candidates) == 0 {
+				inform("no snips available\n")
+				os.Exit(0)
 			}
 
-			// sorted output by highest score
-			sort.Slice(scores, func(i int, j int) bool {
-				return scores[i].Score > scores[j].Score
-			})
+			// get random within range
+			src := rand.NewSource(time.Now().UnixNano())
+			r := rand.New(src)
+			index := r.Intn(len(candidates))
+			log.Debug().Int("random index", index).Int("candidates", len(candidates)).Msg("generated random integer")
+			idStr = candidates[index].String()
+		} else {
+			// obtain uuid specified from argument, which only -random makes optional
+			if len(getCmd.Args()) != 1 {
+				fmt.Fprintf(os.Stderr, "The get command requires one uuid argument unless -random is used.\n")
+				getCmd.Usage()
+				os.Exit(1)
+			}
+			idStr = getCmd.Args()[0]
+		}
+
+		// There is no reason to parse this since it may be a fuzzy term or name. Rely on the errors.
+		s, err := snip.ResolveSnip(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+			printMatches(err)
+			log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+			os.Exit(1)
+		}
+		// only get records access for recent, sparing other reads a write
+		if !database.ReadOnly {
+			err = snip.TouchSnip(s.UUID)
+			if err != nil {
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error recording access")
+			}
+		}
+
+		if len(getCmdExpand) > 0 || *getCmdExpandMissingOK {
+			if *getCmdExpandMissingOK {
+				s.Data, err = s.ExpandMissingOK(getCmdExpand)
+			} else {
+				s.Data, err = s.Expand(getCmdExpand)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The placeholders of snip %s could not be expanded: %v\n", s.UUID, err)
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error expanding snip")
+				os.Exit(1)
+			}
+		}
+
+		// the clipboard always receives the complete data
+		if !*getCmdCopy {
+			s.Data = previewLines(s.Data, *getCmdHead, *getCmdTail)
+			s.Data = snip.WrapText(s.Data, *getCmdWrap)
+		}
 
-			// enforce limit after sort
-			if *searchCmdLimit != 0 && len(scores) > *searchCmdLimit {
-				scores = scores[:*searchCmdLimit]
+		switch {
+		case *getCmdCopy:
+			err = snip.CopyToClipboard([]byte(s.Data))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The data could not be copied to the clipboard: %v\n", err)
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error copying to clipboard")
+				os.Exit(1)
+			}
+			inform("copied %s %d bytes to clipboard\n", s.UUID, len(s.Data))
+		case *getCmdRaw:
+			io.WriteString(os.Stdout, s.Data)
+		case *getCmdFormat == "md":
+			md, err := s.RenderMarkdown()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem rendering the snip as markdown.\n")
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error rendering markdown")
+				os.Exit(1)
+			}
+			io.WriteString(os.Stdout, md)
+		case *getCmdFormat == "gist":
+			gist, err := s.RenderGist(*getCmdInlineMax)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem rendering the snip as a gist.\n")
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error rendering gist")
+				os.Exit(1)
 			}
-			for _, score := range scores {
-				// get full snip to display name
-				s, err := snip.GetFromUUID(score.UUID.String())
+			io.WriteString(os.Stdout, gist)
+		default:
+			fmt.Printf("uuid: %s\n", s.UUID.String())
+			fmt.Printf("name: %s\n", s.Name)
+			fmt.Printf("timestamp: %s\n", s.Timestamp.Format(time.RFC3339Nano))
+			if s.Favorite {
+				fmt.Printf("favorite: yes\n")
+			}
+			meta, err := s.Meta()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem retrieving the metadata of snip %s\n", s.UUID)
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error retrieving metadata")
+				os.Exit(1)
+			}
+			for _, line := range metaLines(meta) {
+				fmt.Printf("meta: %s\n", line)
+			}
+			fmt.Printf("----\n")
+			data := s.Data
+			if len(getCmdHighlight) > 0 {
+				data, err = snip.HighlightTerms(s.Data, getCmdHighlight, highlight)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "There was a problem getting the snip to display its name.\n")
-					log.Debug().Err(err).Msg("building snip to display name")
+					fmt.Fprintf(os.Stderr, "There was a problem highlighting terms %s\n", getCmdHighlight.String())
+					log.Debug().Err(err).Msg("error highlighting terms")
 					os.Exit(1)
 				}
-				fmt.Printf("%s\n", s.Name)
-				if *searchCmdLongUUID {
-					fmt.Printf("  %s ", s.UUID)
-				} else {
-					fmt.Printf("  %s ", snip.ShortenUUID(s.UUID)[0])
+			}
+			io.WriteString(os.Stdout, data)
+			// add an extra newline if the data does not end with one
+			// no one likes their prompt hijacked. This will not affect raw output.
+			if !strings.HasSuffix(s.Data, "\n") {
+				fmt.Println()
+			}
+			fmt.Printf("----\n")
+			for idx, a := range s.Attachments {
+				// print attachments if present
+				if idx == 0 {
+					fmt.Printf("attachments:\n")
+					fmt.Printf("%s %42s %s\n", "uuid", "bytes", "name")
 				}
-				fmt.Printf("(score: %f, ", score.Score)
-				fmt.Printf("words: %d)", s.CountWords())
+				fmt.Printf("%s %10d %s\n", a.UUID.String(), a.Size, a.Name)
+			}
+			if len(s.Attachments) > 0 {
+				fmt.Printf("total: %d bytes\n", s.AttachmentsTotalSize())
+			}
+		}
 
-				// display terms found in document
-				for idx, stat := range score.SearchCounts {
-					if idx == 0 {
-						fmt.Printf(" [")
-					} else {
-						fmt.Printf(", ")
-					}
-					fmt.Printf("%s: %d", stat.Stem, stat.Count)
-					if idx == len(score.SearchCounts)-1 {
-						fmt.Printf("]")
-						fmt.Printf("\n")
-					}
-				}
+	case "history":
+		if err := historyCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The history arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing history arguments")
+			historyCmd.Usage()
+			os.Exit(1)
+		}
+		if len(historyCmd.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "The history command requires one uuid argument.\n")
+			historyCmd.Usage()
+			os.Exit(1)
+		}
+
+		idStr := historyCmd.Arg(0)
+		s, err := snip.ResolveSnip(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+			printMatches(err)
+			log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+			os.Exit(1)
+		}
+		revisions, err := snip.GetRevisions(s.UUID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem retrieving the revisions of snip %s\n", s.UUID)
+			log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error retrieving revisions")
+			os.Exit(1)
+		}
+		if len(revisions) == 0 {
+			inform("no revisions stored for snip %s\n", s.UUID)
+			os.Exit(0)
+		}
+		for idx, r := range revisions {
+			if idx == 0 {
+				inform("%8s %-35s %s\n", "revision", "saved", "name")
+			}
+			fmt.Printf("%8d %-35s %s\n", r.Revision, r.Saved.Format(time.RFC3339Nano), r.Name)
+		}
 
-				// show context
-				s, err = snip.GetFromUUID(score.UUID.String())
+	case "import", "import-jsonl":
+		importFlags := importCmd
+		if action == "import-jsonl" {
+			importFlags = importJSONLCmd
+		}
+		if err := importFlags.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The %s arguments could not be parsed.\n", action)
+			log.Debug().Err(err).Msgf("error parsing %s arguments", action)
+			importFlags.Usage()
+			os.Exit(1)
+		}
+
+		if action == "import" && *importCmdDir == "" && (*importCmdExt != "" || *importCmdRecursive) {
+			fmt.Fprintf(os.Stderr, "The -ext and -recursive options apply only to -dir.\n")
+			os.Exit(1)
+		}
+		// skipping indexing leaves the snips unsearchable until indexed
+		noIndex := *importCmdNoIndex || *importJSONLCmdNoIndex
+		store := &snip.Store{Conn: database.Conn, ReadOnly: database.ReadOnly, NoIndex: noIndex}
+
+		// IMPORT text files from a directory
+		if action == "import" && *importCmdDir != "" {
+			if len(importCmd.Args()) != 0 {
+				fmt.Fprintf(os.Stderr, "The -dir option cannot be combined with an import file.\n")
+				importCmd.Usage()
+				os.Exit(1)
+			}
+			dir := *importCmdDir
+
+			var exts []string
+			if *importCmdExt != "" {
+				exts = strings.Split(*importCmdExt, ",")
+			}
+			files, err := snip.FindImportFiles(dir, *importCmdRecursive, exts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem reading the directory %s\n", dir)
+				log.Debug().Err(err).Str("path", dir).Msg("error finding import files")
+				os.Exit(1)
+			}
+
+			inform("importing...")
+			var skipped []string
+			var oversized []string
+			var sizeErr *snip.DataSizeError
+			numLength := 0
+			for idx, f := range files {
+				numLength = len(strconv.Itoa(idx+1)) + 1 + len(strconv.Itoa(len(files)))
+				inform("%d/%d", idx+1, len(files))
+				_, err := store.ImportFile(f)
+				exitIfReadOnly(err)
+				if errors.Is(err, snip.ErrNotText) {
+					log.Debug().Str("file", f).Msg("skipping binary file")
+					skipped = append(skipped, f)
+				} else if errors.As(err, &sizeErr) {
+					log.Debug().Str("file", f).Msg("skipping oversized file")
+					oversized = append(oversized, f)
+				} else if err != nil {
+					inform("error\n")
+					fmt.Fprintf(os.Stderr, "There was a problem importing the file %s: %v\n", f, err)
+					log.Debug().Err(err).Str("file", f).Msg("error importing file")
+					os.Exit(1)
+				}
+				for i := 0; i < numLength; i++ {
+					inform("\b \b")
+				}
+			}
+			inform("success\n")
+			for _, f := range skipped {
+				inform("skipped non-text file %s\n", f)
+			}
+			for _, f := range oversized {
+				fmt.Fprintf(os.Stderr, "warning: skipped %s, larger than the maximum data size of %d bytes\n", f, sizeErr.Max)
+			}
+			imported := len(files) - len(skipped) - len(oversized)
+			informOut("imported %d snips\n", imported)
+			if noIndex {
+				warnNotIndexed(imported)
+			}
+			break
+		}
+
+		if len(importFlags.Args()) > 1 {
+			fmt.Fprintf(os.Stderr, "The %s command accepts at most one file argument.\n", action)
+			os.Exit(1)
+		}
+
+		// file input takes precedence, but default to standard input
+		var r io.Reader = os.Stdin
+		if len(importFlags.Args()) == 1 {
+			f, err := os.Open(importFlags.Arg(0))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem reading from the file %s\n", importFlags.Arg(0))
+				log.Debug().Err(err).Str("file", importFlags.Arg(0)).Msg("error opening import file")
+				os.Exit(1)
+			}
+			defer f.Close()
+			r = f
+		}
+
+		var count int
+		if action == "import-jsonl" {
+			count, err = store.ImportJSONL(bufio.NewReader(r))
+			exitIfReadOnly(err)
+		} else {
+			count, err = store.ImportAll(r)
+			exitIfReadOnly(err)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem importing snips.\n")
+			if count > 0 {
+				fmt.Fprintf(os.Stderr, "%d snips were imported before the problem occurred.\n", count)
+			}
+			printIndexMismatch(err)
+			log.Debug().Err(err).Msg("error importing snips")
+			os.Exit(1)
+		}
+		informOut("imported %d snips\n", count)
+		if noIndex {
+			warnNotIndexed(count)
+		}
+
+	case "ls":
+		if err := listCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The ls arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing ls arguments")
+			listCmd.Usage()
+			os.Exit(1)
+		}
+
+		if *listCmdDupeNames {
+			duplicates, err := snip.FindDuplicateNames()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem searching for duplicate names.\n")
+				log.Debug().Err(err).Msg("error finding duplicate names")
+				os.Exit(1)
+			}
+			// stable output order
+			var names []string
+			for name := range duplicates {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("%s (%d snips)\n", name, len(duplicates[name]))
+				for _, id := range duplicates[name] {
+					if *listCmdLong {
+						fmt.Printf("  %s\n", id)
+					} else {
+						fmt.Printf("  %s\n", snip.ShortenUUID(id)[0])
+					}
+				}
+			}
+			break
+		}
+
+		tmpl, err := selectTemplate(*listCmdTemplate, *listCmdTemplateName)
+		if err == nil {
+			tmpl, err = columnTemplate(tmpl, *listCmdUUIDsOnly, *listCmdNamesOnly)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The template options are not valid: %v\n", err)
+			os.Exit(1)
+		}
+		if *listCmdRandom < 0 {
+			fmt.Fprintf(os.Stderr, "The number of random snips must not be negative.\n")
+			os.Exit(1)
+		}
+
+		results, err := snip.GetAllSnipIDs()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem while attempting to obtain the metadata of all snips.\n")
+			log.Debug().Err(err).Msg("error listing items metadata")
+			os.Exit(1)
+		}
+		if *listCmdRandom > 0 {
+			// snips are taken in random order until enough pass the other filters
+			r := rand.New(rand.NewSource(time.Now().UnixNano()))
+			r.Shuffle(len(results), func(i, j int) {
+				results[i], results[j] = results[j], results[i]
+			})
+		}
+		between := snipsBetween(*listCmdSince, *listCmdUntil)
+		listed := 0
+		chosen := 0
+		for _, id := range results {
+			if *listCmdRandom > 0 && chosen == *listCmdRandom {
+				break
+			}
+			if between != nil && !between[id] {
+				continue
+			}
+			s, err := snip.GetFromUUID(id.String())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The snip with uuid: %s could not be obtained from the database.\n", id.String())
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error obtaining snip from uuid")
+				os.Exit(1)
+			}
+			if *listCmdFav && !s.Favorite {
+				continue
+			}
+			chosen++
+			if tmpl != "" {
+				printTemplate(tmpl, s)
+				continue
+			}
+			listed++
+			if listed == 1 {
+				if *listCmdLong {
+					// long
+					inform("%s %36s\n", "uuid", "name")
+				} else {
+					// short
+					inform("%s %8s\n", "uuid", "name")
+				}
+			}
+			if *listCmdLong {
+				fmt.Printf("%s %s\n", s.UUID, s.Name)
+			} else {
+				fmt.Printf("%s %s\n", snip.ShortenUUID(s.UUID)[0], s.Name)
+			}
+		}
+
+	case "meta":
+		if err := metaCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The meta arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing meta arguments")
+			metaCmd.Usage()
+			os.Exit(1)
+		}
+		if metaCmd.NArg() < 2 {
+			fmt.Fprintf(os.Stderr, "The meta command requires a subcommand and a snip uuid.\n")
+			Usage()
+			os.Exit(1)
+		}
+		sub, idStr, rest := metaCmd.Arg(0), metaCmd.Arg(1), metaCmd.Args()[2:]
+		switch sub {
+		case "set", "rm":
+			if len(rest) == 0 {
+				fmt.Fprintf(os.Stderr, "The meta %s command requires at least one key.\n", sub)
+				os.Exit(1)
+			}
+		case "get":
+			if len(rest) != 1 {
+				fmt.Fprintf(os.Stderr, "The meta get command requires exactly one key.\n")
+				os.Exit(1)
+			}
+		case "ls":
+			if len(rest) != 0 {
+				fmt.Fprintf(os.Stderr, "The meta ls command accepts only a snip uuid.\n")
+				os.Exit(1)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "The meta subcommand %s is not supported, use set, get, ls, or rm.\n", sub)
+			os.Exit(1)
+		}
+
+		s, err := snip.ResolveSnip(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+			printMatches(err)
+			log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+			os.Exit(1)
+		}
+
+		switch sub {
+		case "set":
+			// validate every pair before setting any
+			pairs := make(expandVars)
+			var keys []string
+			for _, pair := range rest {
+				key, _, _ := strings.Cut(pair, "=")
+				if err := snip.ValidateMetaKey(key); err != nil {
+					fmt.Fprintf(os.Stderr, "The metadata key %s is not valid: %v\n", key, err)
+					os.Exit(1)
+				}
+				// a repeated key takes the last value
+				if _, seen := pairs[key]; !seen {
+					keys = append(keys, key)
+				}
+				if err := pairs.Set(pair); err != nil {
+					fmt.Fprintf(os.Stderr, "The metadata %s must be given as key=value.\n", pair)
+					os.Exit(1)
+				}
+			}
+			for _, key := range keys {
+				err = s.SetMeta(key, pairs[key])
+				exitIfReadOnly(err)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem setting metadata %s of snip %s\n", key, s.UUID)
+					log.Debug().Err(err).Str("uuid", s.UUID.String()).Str("key", key).Msg("error setting metadata")
+					os.Exit(1)
+				}
+				informOut("set %s %s=%s\n", s.UUID, key, pairs[key])
+			}
+		case "get":
+			value, err := s.GetMeta(rest[0])
+			if errors.Is(err, snip.ErrMetaNotFound) {
+				fmt.Fprintf(os.Stderr, "The snip %s has no metadata key %s\n", s.UUID, rest[0])
+				os.Exit(1)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem retrieving metadata %s of snip %s\n", rest[0], s.UUID)
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Str("key", rest[0]).Msg("error retrieving metadata")
+				os.Exit(1)
+			}
+			fmt.Println(value)
+		case "ls":
+			meta, err := s.Meta()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem retrieving the metadata of snip %s\n", s.UUID)
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error retrieving metadata")
+				os.Exit(1)
+			}
+			if len(meta) == 0 {
+				inform("no metadata for snip %s %s\n", s.UUID, s.Name)
+				break
+			}
+			for _, line := range metaLines(meta) {
+				fmt.Println(line)
+			}
+		case "rm":
+			for _, key := range rest {
+				err = s.RemoveMeta(key)
+				exitIfReadOnly(err)
+				if errors.Is(err, snip.ErrMetaNotFound) {
+					fmt.Fprintf(os.Stderr, "The snip %s has no metadata key %s\n", s.UUID, key)
+					os.Exit(1)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem removing metadata %s of snip %s\n", key, s.UUID)
+					log.Debug().Err(err).Str("uuid", s.UUID.String()).Str("key", key).Msg("error removing metadata")
+					os.Exit(1)
+				}
+				informOut("removed %s %s\n", s.UUID, key)
+			}
+		}
+
+	case "mv":
+		if err := mvCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The mv arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing mv arguments")
+			mvCmd.Usage()
+			os.Exit(1)
+		}
+		if len(mvCmd.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "The mv command requires two arguments, the snip uuid and the new uuid.\n")
+			mvCmd.Usage()
+			os.Exit(1)
+		}
+
+		idStr := mvCmd.Arg(0)
+		s, err := snip.ResolveSnip(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+			printMatches(err)
+			log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+			os.Exit(1)
+		}
+		newID, err := uuid.Parse(mvCmd.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem parsing the new uuid %s which may be malformed.\n", mvCmd.Arg(1))
+			log.Debug().Err(err).Msg("error parsing new uuid")
+			os.Exit(1)
+		}
+
+		err = snip.ChangeUUID(s.UUID, newID)
+		exitIfReadOnly(err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The uuid of snip %s could not be changed: %v\n", s.UUID, err)
+			log.Debug().Err(err).Str("uuid", s.UUID.String()).Str("new", newID.String()).Msg("error changing uuid")
+			os.Exit(1)
+		}
+		informOut("moved %s -> %s %s\n", s.UUID, newID, s.Name)
+
+	case "open":
+		if err := openCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The open arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing open arguments")
+			openCmd.Usage()
+			os.Exit(1)
+		}
+		if len(openCmd.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "The open command requires one attachment uuid.\n")
+			openCmd.Usage()
+			os.Exit(1)
+		}
+
+		idStr := openCmd.Arg(0)
+		a, err := snip.GetAttachmentMetadataFromUUID(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem locating the attachment with id %s\n", idStr)
+			log.Debug().Err(err).Str("id", idStr).Msg("could not get attachment")
+			os.Exit(1)
+		}
+
+		// the attachment name is kept so that the viewer can be chosen by extension
+		dir, err := os.MkdirTemp("", "snip-open-")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "A temporary directory for the attachment could not be created.\n")
+			log.Debug().Err(err).Msg("error creating temporary directory")
+			os.Exit(1)
+		}
+		name := filepath.Base(a.Name)
+		if name == "." || name == string(filepath.Separator) {
+			name = a.UUID.String()
+		}
+		tmpFile := filepath.Join(dir, name)
+		_, err = snip.WriteAttachment(a.UUID, tmpFile, false)
+		if err != nil {
+			os.RemoveAll(dir)
+			fmt.Fprintf(os.Stderr, "There was a problem while writing data for the temporary file %s\n", tmpFile)
+			log.Debug().Err(err).Msg("error writing attachment to temporary file")
+			os.Exit(1)
+		}
+
+		err = snip.OpenWithDefault(tmpFile)
+		if errors.Is(err, snip.ErrNoOpener) {
+			fmt.Fprintf(os.Stderr, "No application opener was found, the attachment was written to:\n")
+			fmt.Println(tmpFile)
+			break
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			fmt.Fprintf(os.Stderr, "The attachment could not be opened: %v\n", err)
+			log.Debug().Err(err).Str("file", tmpFile).Msg("error opening attachment")
+			os.Exit(1)
+		}
+		// an opener that detaches returns before the viewer has read the file
+		if snip.OpenerWaits() {
+			os.RemoveAll(dir)
+			break
+		}
+		inform("opened %s, the temporary file remains for the viewer: %s\n", a.Name, tmpFile)
+
+	case "recent":
+		if err := recentCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The recent arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing recent arguments")
+			recentCmd.Usage()
+			os.Exit(1)
+		}
+		if recentCmd.NArg() > 1 {
+			fmt.Fprintf(os.Stderr, "The recent command accepts at most one argument, the number of snips.\n")
+			recentCmd.Usage()
+			os.Exit(1)
+		}
+		limit := snip.DefaultRecent
+		if recentCmd.NArg() == 1 {
+			limit, err = strconv.Atoi(recentCmd.Arg(0))
+			if err != nil || limit < 0 {
+				fmt.Fprintf(os.Stderr, "The number of snips %s must be a non-negative integer.\n", recentCmd.Arg(0))
+				os.Exit(1)
+			}
+		}
+
+		recent, err := snip.Recent(limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem listing recently accessed snips.\n")
+			log.Debug().Err(err).Msg("error listing recent snips")
+			os.Exit(1)
+		}
+		for idx, r := range recent {
+			if idx == 0 {
+				if *recentCmdLong {
+					inform("%-36s %-19s %s\n", "uuid", "accessed", "name")
+				} else {
+					inform("%-8s %-19s %s\n", "uuid", "accessed", "name")
+				}
+			}
+			accessed := r.Accessed.Local().Format("2006-01-02 15:04:05")
+			if *recentCmdLong {
+				fmt.Printf("%s %s %s\n", r.UUID, accessed, r.Name)
+			} else {
+				fmt.Printf("%s %s %s\n", snip.ShortenUUID(r.UUID)[0], accessed, r.Name)
+			}
+		}
+
+	case "related":
+		if err := relatedCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The related arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing related arguments")
+			relatedCmd.Usage()
+			os.Exit(1)
+		}
+		if relatedCmd.NArg() < 1 || relatedCmd.NArg() > 2 {
+			fmt.Fprintf(os.Stderr, "The related command requires a snip uuid, optionally followed by the number of snips.\n")
+			relatedCmd.Usage()
+			os.Exit(1)
+		}
+		limit := snip.DefaultRelated
+		if relatedCmd.NArg() == 2 {
+			limit, err = strconv.Atoi(relatedCmd.Arg(1))
+			if err != nil || limit < 0 {
+				fmt.Fprintf(os.Stderr, "The number of snips %s must be a non-negative integer.\n", relatedCmd.Arg(1))
+				os.Exit(1)
+			}
+		}
+
+		idStr := relatedCmd.Arg(0)
+		s, err := snip.ResolveSnip(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+			printMatches(err)
+			log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+			os.Exit(1)
+		}
+		related, err := snip.RelatedSnips(s.UUID, limit)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem finding snips related to %s\n", s.UUID)
+			printIndexMismatch(err)
+			log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error finding related snips")
+			os.Exit(1)
+		}
+		for idx, r := range related {
+			match, err := snip.GetFromUUID(r.UUID.String())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem getting the snip to display its name.\n")
+				log.Debug().Err(err).Str("uuid", r.UUID.String()).Msg("building snip to display name")
+				os.Exit(1)
+			}
+			if idx == 0 {
+				if *relatedCmdLong {
+					inform("%-36s %-5s %s\n", "uuid", "score", "name")
+				} else {
+					inform("%-8s %-5s %s\n", "uuid", "score", "name")
+				}
+			}
+			if *relatedCmdLong {
+				fmt.Printf("%s %.3f %s\n", match.UUID, r.Score, match.Name)
+			} else {
+				fmt.Printf("%s %.3f %s\n", snip.ShortenUUID(match.UUID)[0], r.Score, match.Name)
+			}
+		}
+
+	case "rename":
+		if err := renameCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The rename arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing rename arguments")
+			renameCmd.Usage()
+			os.Exit(1)
+		}
+		if *renameCmdAll {
+			if renameCmd.NArg() != 0 || *renameCmdPattern == "" {
+				fmt.Fprintf(os.Stderr, "The rename -all option requires -pattern and no other arguments.\n")
+				renameCmd.Usage()
+				os.Exit(1)
+			}
+			results, err := snip.RenameByPattern(*renameCmdPattern, *renameCmdReplace, !*renameCmdConfirm)
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem renaming snips matching %s: %v\n", *renameCmdPattern, err)
+				log.Debug().Err(err).Str("pattern", *renameCmdPattern).Msg("error renaming by pattern")
+				os.Exit(1)
+			}
+			for _, r := range results {
+				if *renameCmdConfirm {
+					informOut("renamed %s %s -> %s\n", r.UUID, r.OldName, r.NewName)
+				} else {
+					fmt.Printf("would rename %s %s -> %s\n", r.UUID, r.OldName, r.NewName)
+				}
+			}
+			switch {
+			case len(results) == 0:
+				inform("no names match %s\n", *renameCmdPattern)
+			case !*renameCmdConfirm:
+				inform("%d snips would be renamed, add -confirm to apply\n", len(results))
+			}
+			break
+		}
+		if *renameCmdRegenerate {
+			if len(renameCmd.Args()) != 1 {
+				fmt.Fprintf(os.Stderr, "The rename -regenerate option requires one snip uuid and no new name.\n")
+				renameCmd.Usage()
+				os.Exit(1)
+			}
+			if *renameCmdNameWords < 1 {
+				fmt.Fprintf(os.Stderr, "The number of name words must be at least 1.\n")
+				os.Exit(1)
+			}
+		} else if len(renameCmd.Args()) != 2 {
+			// require the uuid and the new name
+			fmt.Fprintf(os.Stderr, "The rename command requires two arguments.\n")
+			log.Debug().Err(err).Msg("error parsing rename arguments")
+			os.Exit(1)
+		}
+
+		idStr := renameCmd.Args()[0]
+		s, err := snip.ResolveSnip(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not retrieve snip with id: %s\n", idStr)
+			printMatches(err)
+			log.Debug().Err(err).Str("uuid", idStr).Msg("retrieving snip from uuid")
+			os.Exit(1)
+		}
+		var newName string
+		if *renameCmdRegenerate {
+			newName = s.GenerateName(*renameCmdNameWords)
+			if newName == "" {
+				fmt.Fprintf(os.Stderr, "A name could not be generated because the data of snip %s contains no words.\n", s.UUID)
+				os.Exit(1)
+			}
+			if newName == s.Name {
+				fmt.Fprintf(os.Stderr, "The name of snip %s already matches its data.\n", s.UUID)
+				break
+			}
+		} else {
+			newName = renameCmd.Args()[1]
+		}
+		// no empty strings allowed
+		if newName == "" {
+			fmt.Fprintf(os.Stderr, "The new name cannot be an empty string.\n")
+			log.Debug().Err(err).Msg("no empty string allowed for renaming")
+			os.Exit(1)
+		}
+		oldName := s.Name
+		warnDuplicateName(newName, s.UUID)
+		s.Name = newName
+		err = s.Update()
+		exitIfReadOnly(err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem updating snip with id %s\n", idStr)
+			log.Debug().Err(err).Msg("could not update snip")
+			os.Exit(1)
+		}
+		informOut("renamed %s %s -> %s\n", s.UUID.String(), oldName, newName)
+
+	case "rm":
+		if err := rmCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The rm arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing rm arguments")
+			rmCmd.Usage()
+			os.Exit(1)
+		}
+		if *rmCmdSearch != "" {
+			if rmCmd.NArg() != 0 {
+				fmt.Fprintf(os.Stderr, "The rm -search option does not accept uuids.\n")
+				rmCmd.Usage()
+				os.Exit(1)
+			}
+			terms := strings.Fields(*rmCmdSearch)
+			confirmed := *rmCmdConfirm && !*rmCmdDryRun
+			removed, err := snip.RemoveBySearch(terms, !confirmed)
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem removing snips matching %s\n", terms)
+				printIndexMismatch(err)
+				log.Debug().Err(err).Strs("terms", terms).Msg("error removing by search")
+				os.Exit(1)
+			}
+			for _, s := range removed {
+				if confirmed {
+					informOut("removed %s %s\n", s.UUID, s.Name)
+				} else {
+					fmt.Printf("would remove %s %s (%d attachments)\n", s.UUID, s.Name, len(s.Attachments))
+				}
+			}
+			switch {
+			case len(removed) == 0:
+				inform("no snips match %s\n", *rmCmdSearch)
+			case confirmed && snip.SoftDelete:
+				inform("%d snips removed, restore them with: snip undo -n %d\n", len(removed), len(removed))
+			case confirmed:
+				inform("%d snips removed\n", len(removed))
+			default:
+				inform("%d snips would be removed, add -confirm to remove them\n", len(removed))
+			}
+			break
+		}
+		for idx, arg := range rmCmd.Args() {
+			// accept a uuid, partial uuid, or name reference
+			s, err := snip.ResolveSnip(arg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Could not locate id %d/%d %s\n", idx+1, len(rmCmd.Args()), arg)
+				printMatches(err)
+				log.Debug().Str("uuid", arg).Err(err).Msg("error parsing uuid input")
+				// Do not exit as others may be valid.
+				continue
+			}
+			if *rmCmdDryRun {
+				fmt.Printf("would remove %d/%d %s %s (%d attachments)\n", idx+1, len(rmCmd.Args()), s.UUID, s.Name, len(s.Attachments))
+				continue
+			}
+			if !confirmAction(fmt.Sprintf("REMOVE snip %s", s.UUID)) {
+				informOut("skipped\n")
+				continue
+			}
+			err = snip.Remove(s.UUID)
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Printf("Could not remove %d/%d %s\n", idx+1, len(rmCmd.Args()), s.UUID)
+				log.Debug().Str("uuid", s.UUID.String()).Err(err).Msg("error while attempting to delete snip")
+			} else {
+				// must else because we don't break
+				informOut("removed %d/%d %s\n", idx+1, len(rmCmd.Args()), s.UUID)
+			}
+		}
+
+	case "restore":
+		if err := restoreCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The restore arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing restore arguments")
+			restoreCmd.Usage()
+			os.Exit(1)
+		}
+		if len(restoreCmd.Args()) != 2 {
+			fmt.Fprintf(os.Stderr, "The restore command requires a uuid and a revision number.\n")
+			restoreCmd.Usage()
+			os.Exit(1)
+		}
+
+		idStr := restoreCmd.Arg(0)
+		revision, err := strconv.Atoi(restoreCmd.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The revision %s is not a valid number.\n", restoreCmd.Arg(1))
+			log.Debug().Err(err).Msg("error parsing revision number")
+			os.Exit(1)
+		}
+		s, err := snip.ResolveSnip(idStr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+			printMatches(err)
+			log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+			os.Exit(1)
+		}
+		err = s.Restore(revision)
+		exitIfReadOnly(err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem restoring snip %s to revision %d\n", s.UUID, revision)
+			printIndexMismatch(err)
+			log.Debug().Err(err).Str("uuid", s.UUID.String()).Int("revision", revision).Msg("error restoring revision")
+			os.Exit(1)
+		}
+		informOut("restored %s to revision %d\n", s.UUID, revision)
+
+	case "search":
+		if err := searchCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The search arguments could not be parsed.\n")
+			log.Debug().Err(err).Str("args", strings.Join(searchCmd.Args(), " ")).Msg("error parsing search arguments")
+			searchCmd.Usage()
+			os.Exit(1)
+		}
+		if len(searchCmd.Args()) < 1 {
+			fmt.Fprintf(os.Stderr, "Must supply at least one search term.\n")
+			searchCmd.Usage()
+			os.Exit(1)
+		}
+		if *searchCmdLimit < 0 || *searchCmdOffset < 0 {
+			fmt.Fprintf(os.Stderr, "The limit and offset must not be negative.\n")
+			os.Exit(1)
+		}
+		tmpl, err := selectTemplate(*searchCmdTemplate, *searchCmdTemplateName)
+		if err == nil {
+			tmpl, err = columnTemplate(tmpl, *searchCmdUUIDsOnly, *searchCmdNamesOnly)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The template options are not valid: %v\n", err)
+			os.Exit(1)
+		}
+		if *searchCmdContextWords < 0 {
+			fmt.Fprintf(os.Stderr, "The number of context words must not be negative.\n")
+			os.Exit(1)
+		}
+		jsonOutput := *searchCmdJSON || *searchCmdJSONArray
+		if jsonOutput && (tmpl != "" || *searchCmdCount) {
+			fmt.Fprintf(os.Stderr, "The JSON options cannot be combined with -count or a template.\n")
+			os.Exit(1)
+		}
+		weights := snip.ScoreWeights{Coverage: *searchCmdWeightCoverage, Prominence: *searchCmdWeightProminence}
+		if weights.Coverage < 0 || weights.Prominence < 0 || weights.Coverage+weights.Prominence == 0 {
+			fmt.Fprintf(os.Stderr, "The score weights must not be negative, and at least one must be greater than zero.\n")
+			os.Exit(1)
+		}
+
+		if *searchCmdAny && *searchCmdType != "index" {
+			fmt.Fprintf(os.Stderr, "The -any option applies only to the index search type.\n")
+			os.Exit(1)
+		}
+		if *searchCmdFields != "" {
+			if *searchCmdType != "data" {
+				fmt.Fprintf(os.Stderr, "The -fields option applies only to the data search type.\n")
+				os.Exit(1)
+			}
+			if *searchCmdFold {
+				fmt.Fprintf(os.Stderr, "The -fields option cannot be combined with -fold.\n")
+				os.Exit(1)
+			}
+		}
+		if *searchCmdLines {
+			if *searchCmdType != "index" {
+				fmt.Fprintf(os.Stderr, "The -lines option applies only to the index search type.\n")
+				os.Exit(1)
+			}
+			if tmpl != "" || *searchCmdCount {
+				fmt.Fprintf(os.Stderr, "The -lines option cannot be combined with -count or a template.\n")
+				os.Exit(1)
+			}
+		}
+		if *searchCmdBuckets {
+			if *searchCmdType != "index" {
+				fmt.Fprintf(os.Stderr, "The -buckets option applies only to the index search type.\n")
+				os.Exit(1)
+			}
+			if jsonOutput || tmpl != "" || *searchCmdCount {
+				fmt.Fprintf(os.Stderr, "The -buckets option cannot be combined with -count, JSON, or a template.\n")
+				os.Exit(1)
+			}
+			if *searchCmdBucketMedium < 0 || *searchCmdBucketHigh < *searchCmdBucketMedium {
+				fmt.Fprintf(os.Stderr, "The bucket thresholds must not be negative, and -bucket-high must not be below -bucket-medium.\n")
+				os.Exit(1)
+			}
+		}
+
+		// search runs the search once, returning the number of results after offset and limit
+		search := func() int {
+			var snipResults []snip.Snip
+			var jsonResults []searchResultJSON
+			var err error
+			between := snipsBetween(*searchCmdSince, *searchCmdUntil)
+			// metadata narrows the results in the same way as the time range
+			if len(searchCmdMeta) > 0 {
+				ids, err := snip.SnipsWithMeta(searchCmdMeta)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem finding snips with metadata %s\n", searchCmdMeta)
+					log.Debug().Err(err).Msg("error finding snips with metadata")
+					os.Exit(1)
+				}
+				withMeta := make(map[uuid.UUID]bool)
+				for _, id := range ids {
+					if between == nil || between[id] {
+						withMeta[id] = true
+					}
+				}
+				between = withMeta
+			}
+			// number of results after offset and limit, which sets the exit status
+			found := 0
+
+			switch *searchCmdType {
+			case "index":
+				terms := searchCmd.Args()
+
+				var searchResults map[uuid.UUID][]snip.SearchCount
+				requireAll := !*searchCmdAny
+				if *searchCmdFuzzy {
+					searchResults, err = snip.SearchIndexTermFuzzy(terms, requireAll, snip.DefaultFuzzyDistance)
+				} else {
+					searchResults, err = snip.SearchIndexTerm(terms, requireAll)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem searching the index for term %s\n", terms)
+					printIndexMismatch(err)
+					log.Debug().Err(err).Msg("error while searching for term")
+					os.Exit(1)
+				}
+				if between != nil {
+					for id := range searchResults {
+						if !between[id] {
+							delete(searchResults, id)
+						}
+					}
+				}
+
+				var idf map[string]float64
+				if !*searchCmdNoIDF {
+					idf, err = snip.TermIDF(terms)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "There was a problem calculating the document frequency of terms %s\n", terms)
+						log.Debug().Err(err).Msg("error calculating term idf")
+						os.Exit(1)
+					}
+				}
+				var scores []snip.SearchScore
+				for key, result := range searchResults {
+					var score float64
+					if *searchCmdNoIDF {
+						score, err = snip.ScoreCountsWeighted(key, terms, result, weights)
+					} else {
+						score, err = snip.ScoreCountsIDF(key, terms, result, weights, idf)
+					}
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "There was a problem scoring the item with id %s\n", key)
+						log.Debug().Err(err).Str("uuid", key.String()).Msg("scoring the results")
+						os.Exit(1)
+					}
+					// add to sortable slice
+					scores = append(scores, snip.SearchScore{UUID: key, Score: score, SearchCounts: result})
+				}
+
+				// sorted output by highest score
+				sort.Slice(scores, func(i int, j int) bool {
+					// order equal scores consistently so pages do not overlap
+					if scores[i].Score == scores[j].Score {
+						return scores[i].UUID.String() < scores[j].UUID.String()
+					}
+					return scores[i].Score > scores[j].Score
+				})
+
+				// enforce offset and limit after sort
+				start, end := pageBounds(len(scores), *searchCmdOffset, *searchCmdLimit)
+				scores = scores[start:end]
+				found = len(scores)
+				if *searchCmdCount {
+					fmt.Printf("%d\n", len(scores))
+					break
+				}
+				// headers of the score bands, keyed by the position of the first result of each
+				bandHeaders := make(map[int]string)
+				if *searchCmdBuckets {
+					position := 0
+					for _, b := range snip.BucketScores(scores, *searchCmdBucketHigh, *searchCmdBucketMedium) {
+						switch b.Name {
+						case "low":
+							bandHeaders[position] = fmt.Sprintf("== %s (score < %g) ==", b.Name, *searchCmdBucketMedium)
+						default:
+							bandHeaders[position] = fmt.Sprintf("== %s (score >= %g) ==", b.Name, b.Min)
+						}
+						position += len(b.Scores)
+					}
+				}
+				for idx, score := range scores {
+					if header, ok := bandHeaders[idx]; ok {
+						fmt.Printf("%s\n\n", header)
+					}
+					// get full snip to display name
+					s, err := snip.GetFromUUID(score.UUID.String())
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "There was a problem getting the snip to display its name.\n")
+						log.Debug().Err(err).Msg("building snip to display name")
+						os.Exit(1)
+					}
+					if tmpl != "" {
+						printTemplate(tmpl, snip.ScoredSnip{Snip: s, Score: score.Score, SearchCounts: score.SearchCounts})
+						continue
+					}
+					// similar terms are located by their indexed stem
+					contextTerms := append([]string{}, terms...)
+					var similar []string
+					for _, stat := range score.SearchCounts {
+						if stat.Distance > 0 {
+							contextTerms = append(contextTerms, stat.Stem)
+							similar = append(similar, stat.Stem)
+						}
+					}
+					ctxAll, err := gatherSearchContext(s, terms, similar, *searchCmdContextWords)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "There was a problem gathering search context for item %s: %v\n", score.UUID, err)
+						log.Debug().Err(err).Str("uuid", score.UUID.String()).Msg("gathering context")
+						os.Exit(1)
+					}
+					var lines []snip.LineMatch
+					if *searchCmdLines {
+						lines, err = s.MatchTermLines(contextTerms)
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "There was a problem finding matched lines for item %s: %v\n", score.UUID, err)
+							log.Debug().Err(err).Str("uuid", score.UUID.String()).Msg("matching lines")
+							os.Exit(1)
+						}
+					}
+					if jsonOutput {
+						r := searchResultJSON{UUID: s.UUID, Name: s.Name, Score: score.Score, SearchCounts: score.SearchCounts, Context: ctxAll, Lines: lines}
+						if *searchCmdMerge {
+							r.Windows = snip.ContextWindows(ctxAll, true)
+						}
+						jsonResults = append(jsonResults, r)
+						continue
+					}
+					// lines are printed grep-style under a single header, which suits piping to other tools
+					if *searchCmdLines {
+						if *searchCmdLongUUID {
+							fmt.Printf("%s %s\n", s.UUID, s.Name)
+						} else {
+							fmt.Printf("%s %s\n", snip.ShortenUUID(s.UUID)[0], s.Name)
+						}
+						for _, l := range lines {
+							fmt.Printf("%d:%s\n", l.Line, highlightLine(l))
+						}
+						continue
+					}
+
+					fmt.Printf("%s\n", s.Name)
+					if *searchCmdLongUUID {
+						fmt.Printf("  %s ", s.UUID)
+					} else {
+						fmt.Printf("  %s ", snip.ShortenUUID(s.UUID)[0])
+					}
+					fmt.Printf("(score: %f, ", score.Score)
+					fmt.Printf("words: %d)", s.CountWords())
+
+					// display terms found in document
+					for idx, stat := range score.SearchCounts {
+						if idx == 0 {
+							fmt.Printf(" [")
+						} else {
+							fmt.Printf(", ")
+						}
+						fmt.Printf("%s: %d", stat.Stem, stat.Count)
+						if idx == len(score.SearchCounts)-1 {
+							fmt.Printf("]")
+							fmt.Printf("\n")
+						}
+					}
+
+					// print each context, or each window of merged contexts, with matched terms highlighted
+					for _, w := range snip.ContextWindows(ctxAll, *searchCmdMerge) {
+						fmt.Printf("    [%d-%d] \"%s\"\n", w.Start, w.End, highlightWindow(w))
+					}
+					fmt.Printf("\n")
+				}
+				if jsonOutput {
+					printSearchJSON(jsonResults, *searchCmdJSONArray)
+				}
+
+				if len(searchResults) <= 0 {
+					fmt.Fprintf(os.Stderr, "No results for term \"%s\"\n", terms)
+				}
+
+			case "data":
+				term := searchCmd.Args()[0]
+
+				if *searchCmdFields != "" {
+					var fields []string
+					for _, field := range strings.Split(*searchCmdFields, ",") {
+						fields = append(fields, strings.TrimSpace(field))
+					}
+					if !*searchCmdCount {
+						fmt.Fprintf(os.Stderr, "Search type %s on fields %s for: \"%s\"\n", *searchCmdType, strings.Join(fields, ", "), term)
+					}
+					scores, err := snip.SearchMulti(term, fields)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "There was a problem searching fields %s for term %s: %v\n", strings.Join(fields, ", "), term, err)
+						log.Debug().Err(err).Msg("error while searching fields for term")
+						os.Exit(1)
+					}
+					if between != nil {
+						var kept []snip.SearchScore
+						for _, score := range scores {
+							if between[score.UUID] {
+								kept = append(kept, score)
+							}
+						}
+						scores = kept
+					}
+					start, end := pageBounds(len(scores), *searchCmdOffset, *searchCmdLimit)
+					scores = scores[start:end]
+					found = len(scores)
+					if *searchCmdCount {
+						fmt.Printf("%d\n", len(scores))
+						break
+					}
+					if len(scores) == 0 {
+						if jsonOutput {
+							printSearchJSON(nil, *searchCmdJSONArray)
+						}
+						fmt.Fprintf(os.Stderr, "No results for term \"%s\"\n", term)
+						break
+					}
+					searchesData := false
+					for _, field := range fields {
+						searchesData = searchesData || field == "data"
+					}
+
+					for idx, score := range scores {
+						s, err := snip.GetFromUUID(score.UUID.String())
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "There was a problem getting the snip to display its name.\n")
+							log.Debug().Err(err).Msg("building snip to display name")
+							os.Exit(1)
+						}
+						if tmpl != "" {
+							printTemplate(tmpl, snip.ScoredSnip{Snip: s, Score: score.Score})
+							continue
+						}
+						// the data is read directly, so terms that are not indexed are shown
+						var ctxAll []snip.TermContext
+						if searchesData {
+							ctxAll, err = s.GatherDataContext(term, *searchCmdContextWords)
+							if err != nil {
+								fmt.Fprintf(os.Stderr, "There was a problem gathering search context for item %s: %v\n", s.UUID, err)
+								log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("gathering context")
+								os.Exit(1)
+							}
+						}
+						if jsonOutput {
+							r := searchResultJSON{UUID: s.UUID, Name: s.Name, Score: score.Score, Context: ctxAll}
+							if *searchCmdMerge {
+								r.Windows = snip.ContextWindows(ctxAll, true)
+							}
+							jsonResults = append(jsonResults, r)
+							continue
+						}
+						if idx == 0 {
+							inform("%-36s %-5s %s\n", "uuid", "score", "name")
+						}
+						fmt.Printf("%s %.3f %s\n", s.UUID, score.Score, s.Name)
+						for _, w := range snip.ContextWindows(ctxAll, *searchCmdMerge) {
+							fmt.Printf("    [%d-%d] \"%s\"\n", w.Start, w.End, highlightWindow(w))
+						}
+					}
+					if jsonOutput {
+						printSearchJSON(jsonResults, *searchCmdJSONArray)
+					}
+					break
+				}
+
+				if !*searchCmdCount {
+					fmt.Fprintf(os.Stderr, "Search type %s on field %s for: \"%s\"\n", *searchCmdType, *searchCmdField, term)
+				}
+				log.Debug().Str("field", *searchCmdField)
+
+				// whether offset and limit were applied by the search
+				paged := false
+				switch *searchCmdField {
+				case "data":
+					switch {
+					case *searchCmdFold:
+						snipResults, err = snip.SearchDataTermFold(term)
+					case between == nil:
+						// only the requested page of snips is loaded
+						snipResults, err = snip.SearchDataTermLimit(term, *searchCmdOffset, *searchCmdLimit)
+						paged = true
+					default:
+						snipResults, err = snip.SearchDataTerm(term)
+					}
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "There was a problem searching %s field for term %s\n", *searchCmdField, term)
+						log.Debug().Err(err).Msg("error while searching for term")
+						os.Exit(1)
+					}
+
+				case "name":
+					snipResults, err = snip.SearchNameTerm(term)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "There was a problem searching %s field for term %s\n", *searchCmdField, term)
+						log.Debug().Err(err).Msg("error while searching for term")
+						os.Exit(1)
+					}
+
+				case "uuid":
+					snipResults, err = snip.SearchUUID(term)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "There was a problem searching %s field for term %s\n", *searchCmdField, term)
+						log.Debug().Err(err).Msg("error while searching for term")
+						os.Exit(1)
+					}
+
+				default:
+					fmt.Fprintf(os.Stderr, "The search field %s is not supported, use data, name, or uuid.\n", *searchCmdField)
+					os.Exit(1)
+				}
+
+				if !paged {
+					snipResults = filterBetween(snipResults, between)
+					start, end := pageBounds(len(snipResults), *searchCmdOffset, *searchCmdLimit)
+					snipResults = snipResults[start:end]
+				}
+				found = len(snipResults)
+				if *searchCmdCount {
+					fmt.Printf("%d\n", len(snipResults))
+					break
+				}
+				if len(snipResults) <= 0 {
+					if jsonOutput {
+						printSearchJSON(nil, *searchCmdJSONArray)
+					}
+					fmt.Fprintf(os.Stderr, "No results for term \"%s\"\n", term)
+					break
+				}
+
+				if jsonOutput {
+					for _, s := range snipResults {
+						var ctxAll []snip.TermContext
+						if *searchCmdField == "data" {
+							ctxAll, err = s.GatherDataContext(term, *searchCmdContextWords)
+						}
+						if err != nil {
+							fmt.Fprintf(os.Stderr, "There was a problem gathering search context for item %s: %v\n", s.UUID, err)
+							log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("gathering context")
+							os.Exit(1)
+						}
+						r := searchResultJSON{UUID: s.UUID, Name: s.Name, Context: ctxAll}
+						if *searchCmdMerge {
+							r.Windows = snip.ContextWindows(ctxAll, true)
+						}
+						jsonResults = append(jsonResults, r)
+					}
+					printSearchJSON(jsonResults, *searchCmdJSONArray)
+					break
+				}
+				if tmpl != "" {
+					for _, s := range snipResults {
+						printTemplate(tmpl, snip.ScoredSnip{Snip: s})
+					}
+					break
+				}
+				inform("%s %36s\n", "uuid", "name")
+				for _, s := range snipResults {
+					fmt.Printf("%s %s\n", s.UUID.String(), s.Name)
+					if *searchCmdField != "data" {
+						continue
+					}
+					// the data is read directly, so terms that are not indexed are shown
+					ctxAll, err := s.GatherDataContext(term, *searchCmdContextWords)
+					if err != nil {
+						fmt.Fprintf(os.Stderr, "There was a problem gathering search context for item %s: %v\n", s.UUID, err)
+						log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("gathering context")
+						os.Exit(1)
+					}
+					for _, w := range snip.ContextWindows(ctxAll, *searchCmdMerge) {
+						fmt.Printf("    [%d-%d] \"%s\"\n", w.Start, w.End, highlightWindow(w))
+					}
+				}
+
+			case "regex":
+				pattern := searchCmd.Args()[0]
+				if *searchCmdFold {
+					pattern = "(?i)" + pattern
+				}
+				re, err := snip.CompileRegex(pattern)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "The pattern %s is not a valid regular expression: %v\n", searchCmd.Args()[0], err)
+					log.Debug().Err(err).Str("pattern", pattern).Msg("error compiling pattern")
+					os.Exit(1)
+				}
+				if !*searchCmdCount {
+					fmt.Fprintf(os.Stderr, "Search type %s for: \"%s\"\n", *searchCmdType, pattern)
+				}
+
+				ctx, stop := interruptContext()
+				snipResults, err = snip.SearchDataRegex(ctx, pattern)
+				stop()
+				if errors.Is(err, context.Canceled) {
+					fmt.Fprintf(os.Stderr, "The search was interrupted.\n")
+					os.Exit(1)
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "There was a problem searching for pattern %s\n", pattern)
+					log.Debug().Err(err).Msg("error while searching for pattern")
+					os.Exit(1)
+				}
+
+				snipResults = filterBetween(snipResults, between)
+				start, end := pageBounds(len(snipResults), *searchCmdOffset, *searchCmdLimit)
+				snipResults = snipResults[start:end]
+				found = len(snipResults)
+				if *searchCmdCount {
+					fmt.Printf("%d\n", len(snipResults))
+					break
+				}
+				if len(snipResults) <= 0 {
+					if jsonOutput {
+						printSearchJSON(nil, *searchCmdJSONArray)
+					}
+					fmt.Fprintf(os.Stderr, "No results for pattern \"%s\"\n", pattern)
+					break
+				}
+				if tmpl != "" {
+					for _, s := range snipResults {
+						printTemplate(tmpl, snip.ScoredSnip{Snip: s})
+					}
+					break
+				}
+
+				for _, s := range snipResults {
+					lines := snip.MatchLines(re, s.Data)
+					if jsonOutput {
+						jsonResults = append(jsonResults, searchResultJSON{UUID: s.UUID, Name: s.Name, Lines: lines})
+						continue
+					}
+
+					fmt.Printf("%s\n", s.Name)
+					if *searchCmdLongUUID {
+						fmt.Printf("  %s ", s.UUID)
+					} else {
+						fmt.Printf("  %s ", snip.ShortenUUID(s.UUID)[0])
+					}
+					fmt.Printf("(lines: %d)\n", len(lines))
+					for _, l := range lines {
+						fmt.Printf("    %d: %s\n", l.Line, re.ReplaceAllStringFunc(l.Text, func(m string) string {
+							return highlight(m)
+						}))
+					}
+					fmt.Printf("\n")
+				}
+				if jsonOutput {
+					printSearchJSON(jsonResults, *searchCmdJSONArray)
+				}
+
+			default:
+				fmt.Fprintf(os.Stderr, "The search type %s is not supported, use data, index, or regex.\n", *searchCmdType)
+				os.Exit(1)
+			}
+			return found
+		}
+
+		if *searchCmdWatch {
+			if *searchCmdInterval <= 0 {
+				fmt.Fprintf(os.Stderr, "The interval must be greater than zero.\n")
+				os.Exit(1)
+			}
+			watchSearch(search, *searchCmdInterval)
+			break
+		}
+		if search() == 0 && !*searchCmdExitZero {
+			// exiting skips deferred functions, and this is an expected outcome rather than a failure
+			database.Conn.Close()
+			os.Exit(1)
+		}
+
+	case "trash":
+		if err := trashCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The trash arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing trash arguments")
+			trashCmd.Usage()
+			os.Exit(1)
+		}
+		if trashCmd.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "The trash command requires one subcommand, ls or empty.\n")
+			os.Exit(1)
+		}
+		switch trashCmd.Arg(0) {
+		case "ls":
+			trashed, err := snip.ListTrash()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem listing the trash.\n")
+				log.Debug().Err(err).Msg("error listing trash")
+				os.Exit(1)
+			}
+			if len(trashed) == 0 {
+				inform("the trash is empty\n")
+				break
+			}
+			inform("%-35s %-36s %s\n", "removed", "uuid", "name")
+			for _, t := range trashed {
+				fmt.Printf("%-35s %s %s\n", t.Trashed.Format(time.RFC3339Nano), t.UUID, t.Name)
+			}
+		case "empty":
+			trashed, err := snip.ListTrash()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem listing the trash.\n")
+				log.Debug().Err(err).Msg("error listing trash")
+				os.Exit(1)
+			}
+			if len(trashed) == 0 {
+				inform("the trash is empty\n")
+				break
+			}
+			if !confirmAction(fmt.Sprintf("PERMANENTLY DELETE %d snips in the trash", len(trashed))) {
+				informOut("skipped\n")
+				break
+			}
+			count, err := snip.EmptyTrash()
+			exitIfReadOnly(err)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem emptying the trash.\n")
+				log.Debug().Err(err).Msg("error emptying trash")
+				os.Exit(1)
+			}
+			informOut("deleted %d snips from the trash\n", count)
+		default:
+			fmt.Fprintf(os.Stderr, "The trash subcommand %s is not supported, use ls or empty.\n", trashCmd.Arg(0))
+			os.Exit(1)
+		}
+
+	case "undo":
+		if err := undoCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The undo arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing undo arguments")
+			undoCmd.Usage()
+			os.Exit(1)
+		}
+		if undoCmd.NArg() != 0 {
+			fmt.Fprintf(os.Stderr, "The undo command does not accept arguments.\n")
+			os.Exit(1)
+		}
+		if *undoCmdCount < 1 {
+			fmt.Fprintf(os.Stderr, "The number of snips to restore must be at least 1.\n")
+			os.Exit(1)
+		}
+		restored, err := snip.UndoRemoves(*undoCmdCount)
+		exitIfReadOnly(err)
+		if errors.Is(err, snip.ErrTrashEmpty) {
+			fmt.Fprintf(os.Stderr, "There is no removed snip to restore.\n")
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem restoring the most recently removed snips: %v\n", err)
+			printIndexMismatch(err)
+			log.Debug().Err(err).Msg("error restoring removed snips")
+			os.Exit(1)
+		}
+		for _, s := range restored {
+			informOut("restored %s %s\n", s.UUID, s.Name)
+		}
+
+	case "terms":
+		if err := termsCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The terms arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing terms arguments")
+			termsCmd.Usage()
+			os.Exit(1)
+		}
+		if *termsCmdAll && len(termsCmd.Args()) != 0 {
+			fmt.Fprintf(os.Stderr, "The terms command does not accept a uuid with -all.\n")
+			termsCmd.Usage()
+			os.Exit(1)
+		}
+		if !*termsCmdAll && len(termsCmd.Args()) != 1 {
+			fmt.Fprintf(os.Stderr, "The terms command requires one argument.\n")
+			termsCmd.Usage()
+			os.Exit(1)
+		}
+		if *termsCmdCount < 0 {
+			fmt.Fprintf(os.Stderr, "The number of terms must not be negative.\n")
+			os.Exit(1)
+		}
+
+		var terms []snip.SearchCount
+		if *termsCmdAll {
+			terms, err = snip.IndexedTermsPrefix(*termsCmdPrefix, *termsCmdCount)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem reading the indexed terms.\n")
+				log.Debug().Err(err).Msg("error reading indexed terms")
+				os.Exit(1)
+			}
+		} else {
+			idStr := termsCmd.Arg(0)
+			s, err := snip.GetFromUUID(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+				os.Exit(1)
+			}
+			// the prefix is applied before the limit
+			limit := *termsCmdCount
+			if *termsCmdPrefix != "" {
+				limit = 0
+			}
+			terms, err = s.TopTerms(limit)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem reading the indexed terms of snip %s\n", s.UUID)
+				log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error reading top terms")
+				os.Exit(1)
+			}
+			if *termsCmdPrefix != "" {
+				var matched []snip.SearchCount
+				for _, t := range terms {
+					if strings.HasPrefix(t.Stem, strings.ToLower(*termsCmdPrefix)) {
+						matched = append(matched, t)
+					}
+				}
+				start, end := pageBounds(len(matched), 0, *termsCmdCount)
+				terms = matched[start:end]
+			}
+		}
+		for idx, t := range terms {
+			if idx == 0 {
+				inform("%6s %s\n", "count", "term")
+			}
+			fmt.Printf("%6d %s\n", t.Count, t.Stem)
+		}
+
+	case "verify":
+		if err := verifyCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The verify arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing verify arguments")
+			verifyCmd.Usage()
+			os.Exit(1)
+		}
+
+		problems, err := snip.VerifyDatabase()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem verifying the database.\n")
+			log.Debug().Err(err).Msg("error verifying database")
+			os.Exit(1)
+		}
+		for idx, p := range problems {
+			if idx == 0 {
+				inform("%-15s %-36s %s\n", "table", "uuid", "problem")
+			}
+			fmt.Printf("%-15s %-36s %s\n", p.Table, p.UUID, p.Description)
+		}
+		if len(problems) > 0 {
+			inform("%d problems found\n", len(problems))
+			// exiting skips deferred functions, and this is an expected outcome rather than a failure
+			database.Conn.Close()
+			os.Exit(1)
+		}
+		inform("no problems found\n")
+
+	case "wc":
+		if err := wcCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The wc arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing wc arguments")
+			wcCmd.Usage()
+			os.Exit(1)
+		}
+
+		if *wcCmdAll {
+			if len(wcCmd.Args()) > 0 {
+				fmt.Fprintf(os.Stderr, "The wc -all option does not accept uuids.\n")
+				os.Exit(1)
+			}
+			total, counted, err := snip.CountAll()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem counting the data of all snips.\n")
+				log.Debug().Err(err).Msg("error counting all snips")
+				os.Exit(1)
+			}
+			fmt.Printf("%7d %7d %7d total\n", total.Lines, total.Words, total.Bytes)
+			inform("%d snips counted\n", counted)
+			break
+		}
+		if len(wcCmd.Args()) < 1 {
+			fmt.Fprintf(os.Stderr, "The wc command requires at least one uuid, or -all.\n")
+			wcCmd.Usage()
+			os.Exit(1)
+		}
+
+		var total snip.Counts
+		for _, idStr := range wcCmd.Args() {
+			s, err := snip.ResolveSnip(idStr)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "The snip with id %s could not be retrieved.\n", idStr)
+				printMatches(err)
+				log.Debug().Err(err).Str("uuid", idStr).Msg("error retrieving snip with uuid")
+				os.Exit(1)
+			}
+			c := s.Counts()
+			total.Add(c)
+			fmt.Printf("%7d %7d %7d %s\n", c.Lines, c.Words, c.Bytes, s.UUID)
+		}
+		// as with wc, a total follows more than one count
+		if len(wcCmd.Args()) > 1 {
+			fmt.Printf("%7d %7d %7d total\n", total.Lines, total.Words, total.Bytes)
+		}
+
+	case "index":
+		if err := indexCmd.Parse(args); err != nil {
+			fmt.Fprintf(os.Stderr, "The index arguments could not be parsed.\n")
+			log.Debug().Err(err).Msg("error parsing index arguments")
+			indexCmd.Usage()
+			os.Exit(1)
+		}
+		if *indexCmdWorkers < 1 {
+			fmt.Fprintf(os.Stderr, "The number of workers must be at least 1.\n")
+			os.Exit(1)
+		}
+
+		if *indexCmdNoStem || *indexCmdStem {
+			if *indexCmdNoStem && *indexCmdStem {
+				fmt.Fprintf(os.Stderr, "The -stem and -no-stem options cannot be used together.\n")
+				os.Exit(1)
+			}
+			if indexCmd.NArg() < 1 {
+				fmt.Fprintf(os.Stderr, "Specify the snips to reindex with -stem or -no-stem.\n")
+				os.Exit(1)
+			}
+			for idx, arg := range indexCmd.Args() {
+				s, err := snip.ResolveSnip(arg)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Could not locate id %d/%d %s\n", idx+1, indexCmd.NArg(), arg)
+					printMatches(err)
+					log.Debug().Str("uuid", arg).Err(err).Msg("error parsing uuid input")
+					continue
+				}
+				err = s.SetStemmed(*indexCmdStem)
+				exitIfReadOnly(err)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "There was a problem showing search context for item %s\n", score.UUID)
-					log.Debug().Err(err).Msg("building snip to obtain search context")
-					os.Exit(1)
+					fmt.Fprintf(os.Stderr, "There was a problem reindexing %d/%d %s\n", idx+1, indexCmd.NArg(), s.UUID)
+					printIndexMismatch(err)
+					log.Debug().Str("uuid", s.UUID.String()).Err(err).Msg("error setting stemming mode")
+					continue
 				}
-				for _, term := range terms {
-					ctxAll, err := s.GatherContext(term, *searchCmdContextWords)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "There was a problem gathering context for term %s: %v\n", term, err)
-						log.Debug().Str("term", term).Str("uuid", score.UUID.String()).Msg("gathering context")
-						log.Debug().Err(err).Msg("gathering context")
-						os.Exit(1)
-					}
-					if len(ctxAll) == 0 {
-						// in this case, there are no results (which is technically not an error)
-						// TODO: perhaps only matching terms should be iterated over instead of supplied terms
-						continue
-					}
-
-					// log.Debug().Any("ctx", ctxAll).Msg("term context")
-
-					// print each context
-					for _, ctx := range ctxAll {
-						// these will be printed if not empty
-						var before string
-						var after string
-
-						// print indexes for begin and end of context (to give more context)
-						fmt.Printf("    [%d-%d] ", ctx.BeforeStart, ctx.AfterEnd)
-						before = strings.Join(ctx.Before, " ")
-						after = strings.Join(ctx.After, " ")
-						// log.Debug().Int("ctx.Before", len(ctx.After)).Msg("join before length")
-						// log.Debug().Int("ctx.After", len(ctx.After)).Msg("join after length")
-
-						// if we don't check for empty line, it will produce padding
-						fmt.Printf(`"`) // quotes separate from before string output
-						if before != "" {
-							fmt.Printf("%s ", before)
-						}
-						c := color.New(color.FgRed)
-						_, err = c.Printf("%s", ctx.Term)
-						if err != nil {
-							fmt.Fprintf(os.Stderr, "Color output could not be displayed.\n")
-							log.Debug().Err(err).Msg("color print of context term")
-							os.Exit(1)
-						}
-						if after != "" {
-							fmt.Printf(" %s", after)
-						}
-						fmt.Printf(`"`) // quotes separate from after string output
-						fmt.Printf("\n")
-					}
+				mode := "verbatim"
+				if *indexCmdStem {
+					mode = "stemmed"
 				}
-				fmt.Printf("\n")
+				informOut("reindexed %d/%d %s %s\n", idx+1, indexCmd.NArg(), s.UUID, mode)
 			}
+			break
+		}
 
-			if len(searchResults) <= 0 {
-				fmt.Fprintf(os.Stderr, "No results for term \"%s\"\n", terms)
-				os.Exit(0)
+		if *indexCmdStatus || *indexCmdMissing {
+			if *indexCmdStatus && *indexCmdMissing {
+				fmt.Fprintf(os.Stderr, "The -status and -missing options cannot be used together.\n")
+				os.Exit(1)
 			}
-
-		case "data":
-			term := searchCmd.Args()[0]
-
-			fmt.Fprintf(os.Stderr, "Search type %s on field %s for: \"%s\"\n", *searchCmdType, *searchCmdField, term)
-			log.Debug().Str("field", *searchCmdField)
-
-			switch *searchCmdField {
-			case "data":
-				snipResults, err = snip.SearchDataTerm(term)
+			ids, err := snip.UnindexedSnips()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem finding snips that are not indexed.\n")
+				log.Debug().Err(err).Msg("error finding unindexed snips")
+				os.Exit(1)
+			}
+			if *indexCmdMissing {
+				if len(ids) == 0 {
+					inform("no snips are missing from the index\n")
+					break
+				}
+				progress, clearProgress := progressPrinter()
+				inform("indexing...")
+				ctx, stop := interruptContext()
+				indexed, err := snip.IndexSnips(ctx, ids, *indexCmdWorkers, false, progress)
+				exitIfReadOnly(err)
+				stop()
+				if errors.Is(err, context.Canceled) {
+					clearProgress()
+					inform("interrupted\n")
+					fmt.Fprintf(os.Stderr, "%d of %d snips were indexed. Run snip index -missing to index the rest.\n", indexed, len(ids))
+					os.Exit(1)
+				}
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "There was a problem searching %s field for term %s\n", *searchCmdField, term)
-					log.Debug().Err(err).Msg("error while searching for term")
+					fmt.Fprintf(os.Stderr, "error indexing items: %v\n", err)
+					printIndexMismatch(err)
 					os.Exit(1)
 				}
-
-			case "uuid":
-				snipResults, err = snip.SearchUUID(term)
+				clearProgress()
+				inform("success\n")
+				inform("%d indexed\n", indexed)
+				break
+			}
+			total, err := snip.GetAllSnipIDs()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "There was a problem counting snips.\n")
+				log.Debug().Err(err).Msg("error listing snip ids")
+				os.Exit(1)
+			}
+			for _, id := range ids {
+				s, err := snip.GetFromUUID(id.String())
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "There was a problem searching %s field for term %s\n", *searchCmdField, term)
-					log.Debug().Err(err).Msg("error while searching for term")
+					fmt.Fprintf(os.Stderr, "There was a problem retrieving snip %s\n", id)
+					log.Debug().Err(err).Str("uuid", id.String()).Msg("error retrieving unindexed snip")
 					os.Exit(1)
 				}
+				fmt.Printf("%s %s\n", s.UUID, s.Name)
 			}
+			inform("%d of %d snips are not indexed\n", len(ids), len(total))
+			break
+		}
 
-			if len(snipResults) <= 0 {
-				fmt.Fprintf(os.Stderr, "No results for term \"%s\"\n", term)
-				os.Exit(0)
+		progress, clearProgress := progressPrinter()
+		ctx, stop := interruptContext()
+		defer stop()
+		if !*indexCmdIncremental {
+			// rebuild index
+			inform("reindexing...")
+			snip.IndexWorkers = *indexCmdWorkers
+			err := snip.ReindexAll(ctx, progress)
+			exitIfReadOnly(err)
+			if errors.Is(err, context.Canceled) {
+				clearProgress()
+				inform("interrupted\n")
+				fmt.Fprintf(os.Stderr, "The index is incomplete. Run snip index -incremental to index the remaining snips.\n")
+				os.Exit(1)
 			}
-			fmt.Fprintf(os.Stderr, "%s %36s\n", "uuid", "name")
-			for _, s := range snipResults {
-				fmt.Printf("%s %s\n", s.UUID.String(), s.Name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error reindexing items: %v\n", err)
+				os.Exit(1)
 			}
+			clearProgress()
+			inform("success\n")
+			break
 		}
 
-	case "index":
-		// rebuild index
-		fmt.Fprintf(os.Stderr, "dropping index...")
-		err := snip.DropIndex()
+		inform("indexing...")
+		ids, err := snip.GetAllSnipIDs()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error")
-			fmt.Fprintf(os.Stderr, "%v\n", err)
+			inform("error")
 			os.Exit(1)
 		}
-		fmt.Fprintf(os.Stderr, "success\n")
-
-		fmt.Fprintf(os.Stderr, "indexing...")
-
-		ids, err := snip.GetAllSnipIDs()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error")
+		indexed, err := snip.IndexSnips(ctx, ids, *indexCmdWorkers, true, progress)
+		exitIfReadOnly(err)
+		if errors.Is(err, context.Canceled) {
+			clearProgress()
+			inform("interrupted\n")
+			fmt.Fprintf(os.Stderr, "%d snips were indexed. Run snip index -incremental to index the rest.\n", indexed)
 			os.Exit(1)
 		}
-		numLength := 0
-		for idx, id := range ids {
-			// assign for next time
-			numLength = len(strconv.Itoa(idx+1)) + 1 + len(strconv.Itoa(len(ids)))
-			progressStr := fmt.Sprintf("%d/%d", idx+1, len(ids))
-			fmt.Fprintf(os.Stderr, progressStr)
-			s, err := snip.GetFromUUID(id.String())
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "error")
-				os.Exit(1)
-			}
-			log.Debug().Str("uuid", s.UUID.String()).Msg("indexing snip")
-			err = s.Index()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "error indexing item %s\n", s.UUID)
-				os.Exit(1)
-			}
-			for i := 0; i < numLength; i++ {
-				fmt.Fprintf(os.Stderr, "\b \b")
-			}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error indexing items: %v\n", err)
+			os.Exit(1)
 		}
-		fmt.Fprintf(os.Stderr, "success\n")
+		clearProgress()
+		inform("success\n")
+		inform("%d indexed, %d unchanged\n", indexed, len(ids)-indexed)
 
 	default:
 		Usage()
@@ -848,12 +3917,124 @@ snip rm <uuid ...>              remove snip <uuid> ...
 	log.Debug().Msg("program execution complete")
 }
 
+// snipsBetween returns the ids of snips created within the range of since and until, or nil if neither is set.
+// It exits with a message if either time is not valid.
+func snipsBetween(since string, until string) map[uuid.UUID]bool {
+	if since == "" && until == "" {
+		return nil
+	}
+	now := time.Now()
+	var start, end time.Time
+	var err error
+	if since != "" {
+		start, err = snip.ParseTimeBound(since, now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The -since value is not valid: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if until != "" {
+		end, err = snip.ParseTimeBound(until, now)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "The -until value is not valid: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	ids, err := snip.UUIDsBetween(start, end)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "There was a problem listing snips created within the time range.\n")
+		log.Debug().Err(err).Msg("error listing snips between times")
+		os.Exit(1)
+	}
+	between := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		between[id] = true
+	}
+	return between
+}
+
+// filterBetween returns the snips whose ids are in between, or all snips if between is nil
+func filterBetween(snips []snip.Snip, between map[uuid.UUID]bool) []snip.Snip {
+	if between == nil {
+		return snips
+	}
+	var filtered []snip.Snip
+	for _, s := range snips {
+		if between[s.UUID] {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// progressPrinter returns a progress callback that renders done/total in place on stderr, and a function that erases it
+func progressPrinter() (func(done int, total int), func()) {
+	numLength := 0
+	erase := func() {
+		for i := 0; i < numLength; i++ {
+			inform("\b \b")
+		}
+		numLength = 0
+	}
+	progress := func(done int, total int) {
+		// replace the previous progress
+		erase()
+		progressStr := fmt.Sprintf("%d/%d", done, total)
+		numLength = len(progressStr)
+		inform("%s", progressStr)
+	}
+	return progress, erase
+}
+
+// configureColor enables or disables colored output, where auto disables color for NO_COLOR and non-terminal output
+func configureColor(mode string) error {
+	switch mode {
+	case "auto":
+		// the color package detects NO_COLOR and terminals on init
+	case "always":
+		color.NoColor = false
+	case "never":
+		color.NoColor = true
+	default:
+		return fmt.Errorf("unknown color mode %s", mode)
+	}
+	return nil
+}
+
+// highlight returns the arguments formatted in the highlight color if color is enabled
+func highlight(a ...interface{}) string {
+	return color.New(color.FgRed).Sprint(a...)
+}
+
+// colorDiff returns a unified diff with removed lines in red and added lines in green if color is enabled
+func colorDiff(diff string) string {
+	removed := color.New(color.FgRed)
+	added := color.New(color.FgGreen)
+	lines := strings.Split(diff, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			lines[i] = color.New(color.Bold).Sprint(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = color.New(color.FgCyan).Sprint(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = removed.Sprint(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = added.Sprint(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// confirmReader reads responses to confirmAction, shared so that answers piped together are not lost to buffering
+var confirmReader = bufio.NewReader(os.Stdin)
+
 // confirmAction prompts the user to confirm an action
 func confirmAction(message string) bool {
 	prompt := "[Y/n]"
-	r := bufio.NewReader(os.Stdin)
 	fmt.Printf("%s %s: ", message, prompt)
-	response, err := r.ReadString('\n')
+	response, err := confirmReader.ReadString('\n')
 	if err != nil {
 		return false
 	}
@@ -871,9 +4052,207 @@ func confirmAction(message string) bool {
 	return false
 }
 
-// readFromFile reads all data from specified file
-func readFromFile(path string) ([]byte, error) {
-	// TODO check file size for sanity to avoid polluting a database
+// inform writes an informational message, such as a header, progress, or summary, to stderr unless quiet
+func inform(format string, a ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, a...)
+}
+
+// informOut writes a message confirming a change to stdout unless quiet
+func informOut(format string, a ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+// warnDuplicateName notifies the user when a name is already used by a snip other than id
+func warnDuplicateName(name string, id uuid.UUID) {
+	ids, err := snip.GetUUIDsFromName(name)
+	if err != nil {
+		log.Debug().Err(err).Str("name", name).Msg("error checking for duplicate names")
+		return
+	}
+	for _, other := range ids {
+		if other != id {
+			fmt.Fprintf(os.Stderr, "warning: the name %s is also used by snip %s\n", name, other)
+		}
+	}
+}
+
+// warnNotIndexed notifies the user that count snips were added without indexing and are not yet searchable
+func warnNotIndexed(count int) {
+	if count == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "warning: %d snips were not indexed and will not be found by search until indexed with: snip index -missing\n", count)
+}
+
+// resolveDB returns the path of the database file named by source, which is a local path, an http or https URL, or -
+// for standard input. A database from a URL or standard input is copied to a temporary file, which cleanup removes.
+func resolveDB(source string) (string, func(), error) {
+	cleanup := func() {}
+	var r io.Reader
+	switch {
+	case source == "-":
+		r = os.Stdin
+	case isDBURL(source):
+		// an interrupted download is abandoned, removing the partial copy
+		ctx, stop := interruptContext()
+		defer stop()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return "", cleanup, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", cleanup, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return "", cleanup, fmt.Errorf("unexpected response %s", resp.Status)
+		}
+		r = resp.Body
+	default:
+		return source, cleanup, nil
+	}
+
+	f, err := os.CreateTemp("", "snip-*.sqlite3")
+	if err != nil {
+		return "", cleanup, err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", cleanup, err
+	}
+	return f.Name(), func() { os.Remove(f.Name()) }, nil
+}
+
+// errNotSnipDatabase indicates that a database copy has no snip table
+var errNotSnipDatabase = errors.New("no snip table")
+
+// prepareCopyDB checks that the database copy at p is a snip database, and applies migrations to a database created
+// by an older release so that it can be read. A database created by a newer release is left as it is.
+func prepareCopyDB(p string) error {
+	conn, err := sqlite3.Open(p)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	st := snip.NewStore(conn)
+	isSnip, err := st.IsSnipDatabase()
+	if err != nil {
+		return err
+	}
+	if !isSnip {
+		return errNotSnipDatabase
+	}
+	var versionErr *snip.SchemaVersionError
+	if err = st.Migrate(); err != nil && !errors.As(err, &versionErr) {
+		return err
+	}
+	return conn.Close()
+}
+
+// isDBURL reports whether source is an http or https URL of a database
+func isDBURL(source string) bool {
+	u, err := url.Parse(source)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// interruptContext returns a context cancelled by an interrupt or termination signal, allowing a long operation to stop
+// cleanly. Once the context is cancelled, another signal ends the program as usual.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// watchSearch runs search every interval until interrupted, clearing the screen before each run so that the results
+// are redrawn in place
+func watchSearch(search func() int, interval time.Duration) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// clear the screen and move to the top
+		fmt.Print("\x1b[H\x1b[2J")
+		inform("every %s: snip %s (%s)\n\n", interval, strings.Join(os.Args[1:], " "), time.Now().Format("15:04:05"))
+		search()
+		select {
+		case <-interrupt:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// printMatches lists the candidate snips of an ambiguous uuid or name error for disambiguation
+func printMatches(err error) {
+	var matches []snip.Snip
+	var ambiguousUUID *snip.AmbiguousUUIDError
+	var ambiguousName *snip.AmbiguousNameError
+	switch {
+	case errors.As(err, &ambiguousUUID):
+		matches = ambiguousUUID.Matches
+	case errors.As(err, &ambiguousName):
+		matches = ambiguousName.Matches
+	}
+	for _, m := range matches {
+		fmt.Fprintf(os.Stderr, "matches: %s %s\n", m.UUID, m.Name)
+	}
+}
+
+// printIndexMismatch explains how to resolve err if the index was built with another stemming language or word symbols
+// setting
+func printIndexMismatch(err error) {
+	var langErr *snip.IndexLanguageError
+	if errors.As(err, &langErr) {
+		fmt.Fprintf(os.Stderr, "The index was built with %s stemming. Use --lang %s, or rebuild the index with snip --lang %s index.\n",
+			langErr.Index, langErr.Index, langErr.Language)
+	}
+	var symbolsErr *snip.IndexWordSymbolsError
+	if errors.As(err, &symbolsErr) {
+		fmt.Fprintf(os.Stderr, "The index was built with SNIP_WORD_SYMBOLS=%t. Use that setting, or rebuild the index with SNIP_WORD_SYMBOLS=%t snip index.\n",
+			symbolsErr.Index, symbolsErr.Keep)
+	}
+}
+
+// pageBounds returns the slice bounds of a page of results, where a limit of zero means no limit
+func pageBounds(length int, offset int, limit int) (int, int) {
+	start := offset
+	if start > length {
+		start = length
+	}
+	end := length
+	if limit != 0 && start+limit < length {
+		end = start + limit
+	}
+	return start, end
+}
+
+// readFromFile reads all data from specified file, refusing files larger than maxSize
+func readFromFile(path string, maxSize int) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return []byte{}, err
+	}
+	if maxSize > 0 && info.Size() > int64(maxSize) {
+		return []byte{}, &snip.DataSizeError{Max: maxSize}
+	}
 	f, err := os.ReadFile(path)
 	if err != nil {
 		return []byte{}, err
@@ -881,12 +4260,229 @@ func readFromFile(path string) ([]byte, error) {
 	return f, nil
 }
 
-// readFromStdin reads all data from standard input
-func readFromStdin() ([]byte, error) {
-	data, err := io.ReadAll(os.Stdin)
+// selectTemplate returns the template string or named preset to format output with, or an empty string for the default format.
+// The escape sequences \t and \n are interpreted since they are difficult to enter in a shell.
+func selectTemplate(tmpl string, name string) (string, error) {
+	if tmpl != "" && name != "" {
+		return "", fmt.Errorf("-template and -template-name cannot be combined")
+	}
+	if name != "" {
+		preset, ok := snip.TemplatePresets[name]
+		if !ok {
+			return "", fmt.Errorf("unknown template name %s, use short or long", name)
+		}
+		return preset, nil
+	}
+	return strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n").Replace(tmpl), nil
+}
+
+// columnTemplate returns the template printing the single column selected by -uuids-only or -names-only, or tmpl
+// unchanged if neither is set. The options cannot be combined with each other or with a template.
+func columnTemplate(tmpl string, uuidsOnly bool, namesOnly bool) (string, error) {
+	if uuidsOnly && namesOnly {
+		return "", fmt.Errorf("-uuids-only and -names-only cannot be combined")
+	}
+	if !uuidsOnly && !namesOnly {
+		return tmpl, nil
+	}
+	if tmpl != "" {
+		return "", fmt.Errorf("-uuids-only and -names-only cannot be combined with a template")
+	}
+	if uuidsOnly {
+		return "{{.UUID}}", nil
+	}
+	return "{{.Name}}", nil
+}
+
+// previewLines returns the first head and last tail lines of data separated by an ellipsis, or data unchanged if
+// both are zero or together they cover every line
+func previewLines(data string, head int, tail int) string {
+	lines := snip.SplitLines(data)
+	if head+tail == 0 || head+tail >= len(lines) {
+		return data
+	}
+	var preview []string
+	if head > 0 {
+		preview = append(preview, lines[:head]...)
+	}
+	if head > 0 && tail > 0 {
+		preview = append(preview, "...")
+	}
+	if tail > 0 {
+		preview = append(preview, lines[len(lines)-tail:]...)
+	}
+	output := strings.Join(preview, "\n")
+	// the final newline of data is kept only when the final line is shown
+	if tail == 0 || strings.HasSuffix(data, "\n") {
+		output += "\n"
+	}
+	return output
+}
+
+// printTemplate writes data formatted by tmpl followed by a newline, exiting if the template cannot be rendered
+func printTemplate(tmpl string, data any) {
+	output, err := snip.RenderTemplate(tmpl, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "The template could not be rendered: %v\n", err)
+		log.Debug().Err(err).Str("template", tmpl).Msg("error rendering template")
+		os.Exit(1)
+	}
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	fmt.Print(output)
+}
+
+// searchResultJSON is a search result serialized by the JSON output options of search
+type searchResultJSON struct {
+	UUID         uuid.UUID            `json:"uuid"`
+	Name         string               `json:"name"`
+	Score        float64              `json:"score"`
+	SearchCounts []snip.SearchCount   `json:"counts"`
+	Context      []snip.TermContext   `json:"context"`
+	Lines        []snip.LineMatch     `json:"lines,omitempty"`
+	Windows      []snip.ContextWindow `json:"windows,omitempty"`
+	Meta         map[string]string    `json:"meta,omitempty"`
+}
+
+// gatherSearchContext returns the context of each search term within the snip, followed by the context of each term
+// exactly as indexed, such as the stems of similar terms matched by a fuzzy search
+func gatherSearchContext(s snip.Snip, terms []string, indexed []string, words int) ([]snip.TermContext, error) {
+	var ctxAll []snip.TermContext
+	for _, term := range terms {
+		ctx, err := s.GatherContext(term, words)
+		if err != nil {
+			return ctxAll, fmt.Errorf("term %s: %w", term, err)
+		}
+		ctxAll = append(ctxAll, ctx...)
+	}
+	for _, term := range indexed {
+		ctx, err := s.GatherIndexedContext(term, words)
+		if err != nil {
+			return ctxAll, fmt.Errorf("indexed term %s: %w", term, err)
+		}
+		ctxAll = append(ctxAll, ctx...)
+	}
+	return ctxAll, nil
+}
+
+// highlightWindow returns the words of the window separated by spaces, with each matched term highlighted
+func highlightWindow(w snip.ContextWindow) string {
+	words := append([]string{}, w.Words...)
+	for _, t := range w.Terms {
+		words[t] = highlight(words[t])
+	}
+	return strings.Join(words, " ")
+}
+
+// highlightLine returns the text of the line with each of its matched words highlighted. Matches are located in order
+// at word boundaries, so that a match is not highlighted within a longer word.
+func highlightLine(l snip.LineMatch) string {
+	var b strings.Builder
+	text := l.Text
+	for _, m := range l.Matches {
+		from := 0
+		for {
+			idx := strings.Index(text[from:], m)
+			if idx < 0 {
+				break
+			}
+			start, end := from+idx, from+idx+len(m)
+			before, _ := utf8.DecodeLastRuneInString(text[:start])
+			after, _ := utf8.DecodeRuneInString(text[end:])
+			if !isWordRune(before) && !isWordRune(after) {
+				b.WriteString(text[:start])
+				b.WriteString(highlight(m))
+				text = text[end:]
+				break
+			}
+			from = end
+		}
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// isWordRune reports whether r is part of a word, where utf8.RuneError marks the start or end of the text
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsNumber(r))
+}
+
+// printSearchJSON writes results as one JSON object per line, or as a single array, including the metadata of each
+func printSearchJSON(results []searchResultJSON, array bool) {
+	for i := range results {
+		s := snip.Snip{UUID: results[i].UUID}
+		meta, err := s.Meta()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "There was a problem retrieving the metadata of snip %s\n", s.UUID)
+			log.Debug().Err(err).Str("uuid", s.UUID.String()).Msg("error retrieving metadata")
+			os.Exit(1)
+		}
+		results[i].Meta = meta
+	}
+	// empty arrays are clearer to consumers than null
+	for _, r := range results {
+		for i := range r.Context {
+			if r.Context[i].Before == nil {
+				r.Context[i].Before = []string{}
+			}
+			if r.Context[i].After == nil {
+				r.Context[i].After = []string{}
+			}
+		}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	var err error
+	if array {
+		if results == nil {
+			results = []searchResultJSON{}
+		}
+		err = enc.Encode(results)
+	} else {
+		for _, r := range results {
+			err = enc.Encode(r)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "There was a problem encoding the search results as JSON.\n")
+		log.Debug().Err(err).Msg("error encoding search results")
+		os.Exit(1)
+	}
+}
+
+// readAddFile reads a file to be added as snip data, exiting with a message if it cannot be read or is too large
+func readAddFile(filename string) []byte {
+	data, err := readFromFile(filename, snip.MaxDataSize)
+	if err != nil {
+		var sizeErr *snip.DataSizeError
+		if errors.As(err, &sizeErr) {
+			fmt.Fprintf(os.Stderr, "The file %s could not be added: %v\n", filename, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "There was a problem reading from the file %s\n", filename)
+		}
+		log.Debug().Err(err).Str("file", filename).Msg("error reading from file")
+		os.Exit(1)
+	}
+	return data
+}
+
+// readFromStdin reads all data from standard input, refusing input larger than maxSize
+func readFromStdin(maxSize int) ([]byte, error) {
+	var r io.Reader = os.Stdin
+	if maxSize > 0 {
+		// read one byte past the limit to detect oversized input without reading all of it
+		r = io.LimitReader(os.Stdin, int64(maxSize)+1)
+	}
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return []byte{}, err
 	}
+	if maxSize > 0 && len(data) > maxSize {
+		return []byte{}, &snip.DataSizeError{Max: maxSize}
+	}
 	return data, nil
 }
 
@@ -913,3 +4509,59 @@ func truncateStr(text string, max int, suffix string) string {
 	}
 	return text
 }
+
+// exitIfReadOnly exits with a clear message if err was returned for a change to a database opened read-only
+func exitIfReadOnly(err error) {
+	if errors.Is(err, snip.ErrReadOnly) {
+		fmt.Fprintf(os.Stderr, "The database was opened read-only, so no changes can be made.\n")
+		log.Debug().Err(err).Msg("write refused")
+		os.Exit(1)
+	}
+}
+
+// metaLines returns the metadata as key=value lines sorted by key
+func metaLines(meta map[string]string) []string {
+	var lines []string
+	for key, value := range meta {
+		lines = append(lines, key+"="+value)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// expandVars collects the key=value pairs of repeated -expand flags
+type expandVars map[string]string
+
+func (v expandVars) String() string {
+	var pairs []string
+	for key, value := range v {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v expandVars) Set(pair string) error {
+	key, value, ok := strings.Cut(pair, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %s", pair)
+	}
+	v[key] = value
+	return nil
+}
+
+// termList collects the terms of a repeated flag, where a value may also hold several terms separated by spaces
+type termList []string
+
+func (l *termList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *termList) Set(terms string) error {
+	fields := strings.Fields(terms)
+	if len(fields) == 0 {
+		return fmt.Errorf("expected a term")
+	}
+	*l = append(*l, fields...)
+	return nil
+}
@@ -2,14 +2,20 @@ package main_test
 
 import (
 	"bufio"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -151,3 +157,1745 @@ func TestList(t *testing.T) {
 		}
 	}
 }
+
+func TestGetRandomEmpty(t *testing.T) {
+	// use an empty database instead of the shared test database
+	cmd := exec.Command(appPath, "get", "-random")
+	cmd.Env = append(os.Environ(), "SNIP_DB="+path.Join(t.TempDir(), "empty.sqlite"))
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		t.Fatalf("expected clean exit on empty database, got %v: %s", err, stderr.String())
+	}
+	if strings.Contains(stderr.String(), "panic") {
+		t.Errorf("expected no panic, got: %s", stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "no snips available") {
+		t.Errorf("expected no snips message, got: %s", stderr.String())
+	}
+}
+
+// runSnip runs the tool with args and returns its standard output
+func runSnip(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(appPath, args...)
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		t.Fatalf("snip %s: %v: %s", strings.Join(args, " "), err, stderr.String())
+	}
+	return stdout.String()
+}
+
+// resultLines returns the lines of data search output listing each result, omitting the indented context lines
+func resultLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if !strings.HasPrefix(line, " ") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestSearchOffset(t *testing.T) {
+	all := resultLines(runSnip(t, "search", "-type", "data", "the"))
+	if len(all) < 2 {
+		t.Fatalf("expected at least 2 results to page through, got %d", len(all))
+	}
+
+	paged := resultLines(runSnip(t, "search", "-type", "data", "-offset", "1", "-limit", "1", "the"))
+	if len(paged) != 1 || paged[0] != all[1] {
+		t.Errorf("expected page %v, got %v", all[1:2], paged)
+	}
+
+	beyond := runSnip(t, "search", "-exit-zero", "-type", "data", "-offset", "100", "the")
+	if beyond != "" {
+		t.Errorf("expected no output for offset beyond results, got %q", beyond)
+	}
+}
+
+func TestColorOption(t *testing.T) {
+	escape := "\x1b["
+	args := []string{"get", "-highlight", "lorem", "65f6930f-e970-4b6e-b10c-fca3dac21c1e"}
+
+	// output is not a terminal, so auto must not colorize
+	if output := runSnip(t, args...); strings.Contains(output, escape) {
+		t.Errorf("expected no color escapes for non-terminal output")
+	}
+	if output := runSnip(t, append([]string{"--color=never"}, args...)...); strings.Contains(output, escape) {
+		t.Errorf("expected no color escapes with --color=never")
+	}
+	if output := runSnip(t, append([]string{"--color=always"}, args...)...); !strings.Contains(output, escape) {
+		t.Errorf("expected color escapes with --color=always")
+	}
+}
+
+func TestGetHighlightRepeated(t *testing.T) {
+	escape := "\x1b["
+	id := "65f6930f-e970-4b6e-b10c-fca3dac21c1e"
+	one := runSnip(t, "--color=always", "get", "-highlight", "lorem", id)
+	two := runSnip(t, "--color=always", "get", "-highlight", "lorem", "-highlight", "ipsum", id)
+	if strings.Count(two, escape) <= strings.Count(one, escape) {
+		t.Errorf("expected each repeated -highlight term to be highlighted")
+	}
+	if spaced := runSnip(t, "--color=always", "get", "-highlight", "lorem ipsum", id); spaced != two {
+		t.Errorf("expected space separated terms to match repeated flags")
+	}
+}
+
+func TestRemoveDryRun(t *testing.T) {
+	output := runSnip(t, "rm", "-dry-run", "65f6930f")
+	expected := "would remove 1/1 65f6930f-e970-4b6e-b10c-fca3dac21c1e Lorem ipsum dolor sit amet (1 attachments)\n"
+	if output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+
+	// snip must still be present
+	output = runSnip(t, "get", "-raw", "65f6930f")
+	if output == "" {
+		t.Errorf("expected snip to remain after dry run")
+	}
+}
+
+func TestRemoveSearch(t *testing.T) {
+	db := path.Join(t.TempDir(), "rmsearch.sqlite")
+	for _, data := range []string{"obsolete draft one", "obsolete draft two", "keep this"} {
+		cmd := exec.Command(appPath, "--db", db, "add")
+		cmd.Stdin = strings.NewReader(data)
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// matches are only listed without -confirm
+	output := runSnip(t, "--db", db, "rm", "-search", "obsolete draft")
+	if strings.Count(output, "would remove ") != 2 {
+		t.Errorf("expected two snips previewed, got %q", output)
+	}
+	runSnip(t, "--db", db, "rm", "-search", "obsolete draft", "-confirm")
+	output = runSnip(t, "--db", db, "ls")
+	if strings.Contains(output, "obsolete") || !strings.Contains(output, "keep this") {
+		t.Errorf("expected only matching snips removed, got %q", output)
+	}
+}
+
+func TestGetByName(t *testing.T) {
+	output := runSnip(t, "get", "-raw", "name:Lorem ipsum dolor sit amet")
+	expected := runSnip(t, "get", "-raw", "65f6930f-e970-4b6e-b10c-fca3dac21c1e")
+	if output != expected {
+		t.Errorf("expected name reference to retrieve the same snip as its uuid")
+	}
+}
+
+func TestSearchCount(t *testing.T) {
+	all := resultLines(runSnip(t, "search", "-type", "data", "the"))
+	output := runSnip(t, "search", "-type", "data", "-count", "the")
+	if output != fmt.Sprintf("%d\n", len(all)) {
+		t.Errorf("expected data count %d, got %q", len(all), output)
+	}
+
+	output = runSnip(t, "search", "-exit-zero", "-count", "xyzzyplugh")
+	if output != "0\n" {
+		t.Errorf("expected index count 0, got %q", output)
+	}
+}
+
+func TestSearchContext(t *testing.T) {
+	err := exec.Command(appPath, "search", "-context", "-1", "lorem").Run()
+	if err == nil {
+		t.Errorf("expected error for negative context")
+	}
+
+	// the CSV test data is not indexed
+	runSnip(t, "index")
+
+	output := runSnip(t, "search", "-context", "0", "lorem")
+	var contexts int
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, "    [") {
+			continue
+		}
+		contexts++
+		var start, end int
+		var term string
+		_, err := fmt.Sscanf(strings.TrimSpace(line), "[%d-%d] %s", &start, &end, &term)
+		if err != nil {
+			t.Fatalf("could not parse context line %q: %v", line, err)
+		}
+		if start != end || !strings.EqualFold(strings.Trim(term, `"`), "lorem") {
+			t.Errorf("expected only the matched term, got %q", line)
+		}
+	}
+	if contexts == 0 {
+		t.Errorf("expected at least one context line, got %q", output)
+	}
+}
+
+func TestCat(t *testing.T) {
+	ids := []string{"65f6930f", "990a917e"}
+	var expected strings.Builder
+	for idx, id := range ids {
+		if idx > 0 {
+			expected.WriteString("\n--\n")
+		}
+		name := strings.TrimPrefix(strings.Split(runSnip(t, "get", id), "\n")[1], "name: ")
+		expected.WriteString("# " + name + "\n")
+		expected.WriteString(runSnip(t, "get", "-raw", id))
+	}
+
+	output := runSnip(t, "cat", "-header", "-delimiter", `\n--\n`, ids[0], ids[1])
+	if output != expected.String() {
+		t.Errorf("expected %q, got %q", expected.String(), output)
+	}
+}
+
+func TestAttachStdoutBase64(t *testing.T) {
+	id := "9cfc5a2d-2946-48ee-82e0-227ba4bcdbd5"
+	raw := runSnip(t, "attach", "stdout", id)
+	encoded := runSnip(t, "attach", "stdout", "-base64", id)
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(encoded, "\n", ""))
+	if err != nil {
+		t.Fatalf("could not decode output: %v", err)
+	}
+	if string(decoded) != raw {
+		t.Errorf("expected decoded output to match raw attachment data")
+	}
+}
+
+func TestAttachAddBase64(t *testing.T) {
+	db := path.Join(t.TempDir(), "base64.sqlite")
+	id := "99999999-9999-9999-9999-999999999999"
+	cmd := exec.Command(appPath, "--db", db, "add", "-u", id, "-n", "host")
+	cmd.Stdin = strings.NewReader("host")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	blob := []byte{0x00, 0xff, 0x10, 'b', 'i', 'n', 0x80}
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	file := path.Join(t.TempDir(), "blob.bin.b64")
+	// line breaks within the encoded data are ignored
+	if err := os.WriteFile(file, []byte(encoded[:4]+"\n"+encoded[4:]+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := runSnip(t, "--db", db, "attach", "add", "-base64", id, file); !strings.HasSuffix(output, "\nattached blob.bin 7 bytes\n") {
+		t.Errorf("expected the decoded attachment named without its encoding extension, got %q", output)
+	}
+	fields := strings.Fields(runSnip(t, "--db", db, "attach", "ls"))
+	if len(fields) < 4 || fields[3] != "blob.bin" {
+		t.Fatalf("expected attachment blob.bin to be listed, got %q", fields)
+	}
+	if data := runSnip(t, "--db", db, "attach", "stdout", fields[0]); data != string(blob) {
+		t.Errorf("expected decoded data %q, got %q", blob, data)
+	}
+
+	// standard input is decoded as well
+	cmd = exec.Command(appPath, "--db", db, "attach", "add", "-base64", "-stdin", "-name", "stdin.bin", id)
+	cmd.Stdin = strings.NewReader(encoded)
+	if output, err := cmd.Output(); err != nil || !strings.HasSuffix(string(output), "\nattached stdin.bin 7 bytes\n") {
+		t.Errorf("expected standard input to be decoded, got %q %v", output, err)
+	}
+
+	invalid := path.Join(t.TempDir(), "invalid.b64")
+	if err := os.WriteFile(invalid, []byte("not base64!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command(appPath, "--db", db, "attach", "add", "-base64", id, invalid)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	cmd.Run()
+	if !strings.Contains(stderr.String(), "does not contain valid base64 data") {
+		t.Errorf("expected invalid base64 to be reported, got %q", stderr.String())
+	}
+}
+
+func TestVerify(t *testing.T) {
+	// binary attachment data in the CSV test data is truncated on import, so sizes do not match
+	cmd := exec.Command(appPath, "verify")
+	var stdout strings.Builder
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	if err == nil {
+		t.Errorf("expected non-zero exit for problems found")
+	}
+	if !strings.Contains(stdout.String(), "snip_attachment 9cfc5a2d-2946-48ee-82e0-227ba4bcdbd5 size does not match data") {
+		t.Errorf("expected size problem of attachment 9cfc5a2d, got %q", stdout.String())
+	}
+
+	cmd = exec.Command(appPath, "verify")
+	cmd.Env = append(os.Environ(), "SNIP_DB="+path.Join(t.TempDir(), "empty.sqlite"))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Errorf("expected clean exit for empty database, got %v: %s", err, output)
+	}
+	if string(output) != "no problems found\n" {
+		t.Errorf("expected no problems, got %q", output)
+	}
+}
+
+func TestAddMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	env := append(os.Environ(), "SNIP_DB="+path.Join(dir, "add.sqlite"))
+	files := []string{path.Join(dir, "first.txt"), path.Join(dir, "second.md")}
+	contents := []string{"first file", "second file\n"}
+	for idx, f := range files {
+		err := os.WriteFile(f, []byte(contents[idx]), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(args ...string) string {
+		cmd := exec.Command(appPath, args...)
+		cmd.Env = env
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("snip %s: %v", strings.Join(args, " "), err)
+		}
+		return string(output)
+	}
+
+	output := run(append([]string{"add", "-f"}, files...)...)
+	if strings.Count(output, "added snip uuid: ") != 2 {
+		t.Errorf("expected two snips added, got %q", output)
+	}
+	if data := run("cat", "name:first"); data != contents[0] {
+		t.Errorf("expected snip named after first file with data %q, got %q", contents[0], data)
+	}
+	if data := run("cat", "name:second"); data != contents[1] {
+		t.Errorf("expected snip named after second file with data %q, got %q", contents[1], data)
+	}
+
+	output = run(append([]string{"add", "-join", "-n", "joined", "-f"}, files...)...)
+	if strings.Count(output, "added snip uuid: ") != 1 {
+		t.Errorf("expected one snip added, got %q", output)
+	}
+	if data := run("cat", "name:joined"); data != "first file\nsecond file\n" {
+		t.Errorf("expected joined data, got %q", data)
+	}
+}
+
+func TestDatabaseOption(t *testing.T) {
+	// SNIP_DB refers to the shared test database, which must be overridden
+	other := path.Join(t.TempDir(), "other.sqlite")
+	if output := runSnip(t, "--db", other, "ls"); output != "" {
+		t.Errorf("expected empty listing of new database, got %q", output)
+	}
+
+	cmd := exec.Command(appPath, "--db="+other, "add", "-n", "other")
+	cmd.Stdin = strings.NewReader("data in another database")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	output := runSnip(t, "--db", other, "ls")
+	if !strings.HasSuffix(output, " other\n") || strings.Count(output, "\n") != 1 {
+		t.Errorf("expected only the added snip, got %q", output)
+	}
+	if strings.Contains(runSnip(t, "ls"), " other\n") {
+		t.Errorf("expected snip to be absent from the database in SNIP_DB")
+	}
+}
+
+func TestListTemplate(t *testing.T) {
+	long := strings.Split(strings.TrimSpace(runSnip(t, "ls", "-l")), "\n")
+	output := runSnip(t, "ls", "-template", `{{.UUID}}\t{{.Name}}`)
+	var expected string
+	for _, line := range long {
+		id, name, _ := strings.Cut(line, " ")
+		expected += id + "\t" + name + "\n"
+	}
+	if output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+
+	short := runSnip(t, "ls")
+	if output := runSnip(t, "ls", "-template-name", "short"); output != short {
+		t.Errorf("expected short preset to match default listing %q, got %q", short, output)
+	}
+}
+
+func TestReadOnlyOption(t *testing.T) {
+	cmd := exec.Command(appPath, "--read-only", "add", "-n", "read-only")
+	cmd.Stdin = strings.NewReader("data that must not be added")
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Errorf("expected add to fail on a read-only database")
+	}
+	if !strings.Contains(string(output), "read-only") {
+		t.Errorf("expected read-only error message, got %q", output)
+	}
+
+	if output := runSnip(t, "--read-only", "ls"); output != runSnip(t, "ls") {
+		t.Errorf("expected read-only listing to match, got %q", output)
+	}
+
+	// a database from an older release must be upgraded before it can be read
+	legacy := path.Join(t.TempDir(), "legacy.sqlite")
+	cmd = exec.Command("sqlite3", legacy, `CREATE TABLE snip(uuid TEXT, timestamp TEXT, name TEXT, data TEXT);
+		CREATE TABLE snip_attachment(uuid TEXT, snip_uuid TEXT, timestamp TEXT, name TEXT, data BLOB, size INTEGER);
+		INSERT INTO snip VALUES ('`+uuid.New().String()+`', '2023-06-16T12:00:00Z', 'legacy notes', 'older data');`)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command(appPath, "--read-only", "--db", legacy, "ls")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil || !strings.Contains(stderr.String(), "Open it once without --read-only to upgrade it") {
+		t.Errorf("expected an older database refused with a hint to upgrade it, got %q: %v", stderr.String(), err)
+	}
+	runSnip(t, "--db", legacy, "ls")
+	if output := runSnip(t, "--read-only", "--db", legacy, "ls"); !strings.Contains(output, "legacy notes") {
+		t.Errorf("expected the upgraded database listed read-only, got %q", output)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	db := path.Join(t.TempDir(), "diff.sqlite")
+	add := func(id string, name string, data string) {
+		cmd := exec.Command(appPath, "--db", db, "add", "-u", id, "-n", name)
+		cmd.Stdin = strings.NewReader(data)
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first := "11111111-1111-1111-1111-111111111111"
+	second := "22222222-2222-2222-2222-222222222222"
+	add(first, "first", "one\ntwo\nthree\n")
+	add(second, "second", "one\n2\nthree\nfour\n")
+
+	// a renamed snip stores a revision with unchanged data
+	runSnip(t, "--db", db, "rename", first, "renamed")
+	cmd := exec.Command(appPath, "--db", db, "diff", first, "@2")
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected error for missing revision")
+	}
+	if output := runSnip(t, "--db", db, "diff", first, "@1"); output != "" {
+		t.Errorf("expected no difference from revision with the same data, got %q", output)
+	}
+
+	expected := `--- 11111111-1111-1111-1111-111111111111 renamed
++++ 22222222-2222-2222-2222-222222222222 second
+@@ -1,3 +1,4 @@
+ one
+-two
++2
+ three
++four
+`
+	if output := runSnip(t, "--db", db, "diff", "1111", "name:second"); output != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+	// a partial uuid of digits is not taken for a revision
+	if output := runSnip(t, "--db", db, "diff", "1111", "2222"); output != expected {
+		t.Errorf("expected all-digit partial uuids to be diffed, got:\n%s", output)
+	}
+}
+
+func TestGetRandom(t *testing.T) {
+	cmd := exec.Command(appPath, "get", "-raw")
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected error for get without uuid or -random")
+	}
+
+	ids := strings.Fields(runSnip(t, "ls", "-template", "{{.UUID}}"))
+	data := make(map[string]bool)
+	for _, id := range ids {
+		data[runSnip(t, "get", "-raw", id)] = true
+	}
+	// no uuid argument is required when choosing at random
+	for i := 0; i < 5; i++ {
+		output := runSnip(t, "get", "-random", "-raw")
+		if !data[output] {
+			t.Errorf("expected data of a snip in the database, got %q", output)
+		}
+	}
+}
+
+func TestGetRandomTerms(t *testing.T) {
+	db := path.Join(t.TempDir(), "random.sqlite")
+	for _, data := range []string{"the wren sings", "the heron waits"} {
+		cmd := exec.Command(appPath, "--db", db, "add", "-n", data)
+		cmd.Stdin = strings.NewReader(data)
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the snip matching every term is a candidate
+	for i := 0; i < 5; i++ {
+		if output := runSnip(t, "--db", db, "get", "-random", "-raw", "wren"); output != "the wren sings" {
+			t.Errorf("expected the snip matching wren, got %q", output)
+		}
+	}
+
+	cmd := exec.Command(appPath, "--db", db, "get", "-random", "-raw", "wren", "heron")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Errorf("expected status 0 without candidates, got %v", err)
+	}
+	if len(output) != 0 || !strings.Contains(stderr.String(), "no snips available") {
+		t.Errorf("expected no output and a notice without candidates, got %q %q", output, stderr.String())
+	}
+}
+
+func TestListRandom(t *testing.T) {
+	db := path.Join(t.TempDir(), "random.sqlite")
+	names := []string{"wren", "heron", "robin", "finch"}
+	for _, name := range names {
+		cmd := exec.Command(appPath, "--db", db, "add", "-n", name)
+		cmd.Stdin = strings.NewReader("the " + name + " sings")
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runSnip(t, "--db", db, "fav", "name:heron")
+
+	all := make(map[string]bool)
+	for _, name := range names {
+		all[name] = true
+	}
+	for i := 0; i < 5; i++ {
+		listed := strings.Fields(runSnip(t, "--db", db, "ls", "-random", "2", "-names-only"))
+		if len(listed) != 2 || listed[0] == listed[1] || !all[listed[0]] || !all[listed[1]] {
+			t.Errorf("expected two different snips, got %v", listed)
+		}
+	}
+	// other filters narrow the candidates first
+	if listed := runSnip(t, "--db", db, "ls", "-random", "3", "-fav", "-names-only"); listed != "heron\n" {
+		t.Errorf("expected only the favorite snip, got %q", listed)
+	}
+	if listed := strings.Fields(runSnip(t, "--db", db, "ls", "-random", "10", "-names-only")); len(listed) != len(names) {
+		t.Errorf("expected every snip when asking for more than exist, got %v", listed)
+	}
+
+	cmd := exec.Command(appPath, "--db", db, "ls", "-random", "-1")
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected error for a negative number of random snips")
+	}
+}
+
+func TestSearchJSON(t *testing.T) {
+	// relies on the index built by TestSearchContext
+	type result struct {
+		UUID    string
+		Name    string
+		Score   float64
+		Counts  []struct{ Stem string }
+		Context []struct {
+			Before      []string
+			BeforeStart int `json:"before_start"`
+			Term        string
+			AfterEnd    int `json:"after_end"`
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(runSnip(t, "search", "-json", "-context", "1", "lorem")), "\n")
+	for _, line := range lines {
+		var r result
+		err := json.Unmarshal([]byte(line), &r)
+		if err != nil {
+			t.Fatalf("could not decode result %q: %v", line, err)
+		}
+		if r.UUID == "" || r.Score <= 0 || len(r.Counts) == 0 || len(r.Context) == 0 {
+			t.Errorf("expected uuid, score, counts, and context, got %+v", r)
+		}
+		for _, ctx := range r.Context {
+			if !strings.EqualFold(ctx.Term, "lorem") || ctx.Before == nil || ctx.AfterEnd-ctx.BeforeStart > 2 {
+				t.Errorf("unexpected context %+v", ctx)
+			}
+		}
+	}
+
+	if output := runSnip(t, "search", "-exit-zero", "-json-array", "nonexistentterm"); output != "[]\n" {
+		t.Errorf("expected empty array, got %q", output)
+	}
+
+	var all []result
+	err := json.Unmarshal([]byte(runSnip(t, "search", "-json-array", "lorem")), &all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(lines) {
+		t.Errorf("expected %d results in array, got %d", len(lines), len(all))
+	}
+}
+
+func TestOpen(t *testing.T) {
+	id := "9cfc5a2d-2946-48ee-82e0-227ba4bcdbd5"
+	expected := runSnip(t, "attach", "stdout", id)
+
+	// without an opener in the path, the written file is reported instead
+	cmd := exec.Command(appPath, "open", id)
+	cmd.Env = append(os.Environ(), "PATH="+t.TempDir())
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpFile := strings.TrimSpace(string(output))
+	defer os.RemoveAll(path.Dir(tmpFile))
+	data, err := os.ReadFile(tmpFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Errorf("expected written file to hold attachment data")
+	}
+}
+
+func TestAttachGet(t *testing.T) {
+	output := runSnip(t, "attach", "get", "9cfc5a2d")
+	for _, line := range []string{
+		"uuid: 9cfc5a2d-2946-48ee-82e0-227ba4bcdbd5\n",
+		"snip_uuid: 65f6930f-e970-4b6e-b10c-fca3dac21c1e\n",
+		"name: Lorem ipsum - Wikipedia.pdf\n",
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected %q in output %q", line, output)
+		}
+	}
+}
+
+func TestAttachOwner(t *testing.T) {
+	expected := "65f6930f-e970-4b6e-b10c-fca3dac21c1e Lorem ipsum dolor sit amet\n"
+	if output := runSnip(t, "attach", "owner", "9cfc5a2d"); output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+}
+
+func TestAttachPromote(t *testing.T) {
+	db := path.Join(t.TempDir(), "promote.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-u", "55555555-5555-5555-5555-555555555555", "-n", "host")
+	cmd.Stdin = strings.NewReader("host")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	notes := path.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(notes, []byte("promoted attachment notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runSnip(t, "--db", db, "attach", "add", "55555555-5555-5555-5555-555555555555", notes)
+	// the header is written to stderr
+	id := strings.Fields(runSnip(t, "--db", db, "attach", "ls"))[0]
+
+	output := runSnip(t, "--db", db, "attach", "promote", "-rm", id)
+	if !strings.HasPrefix(output, "promoted attachment "+id+" -> snip ") {
+		t.Errorf("unexpected output %q", output)
+	}
+	if output := runSnip(t, "--db", db, "cat", "name:notes.txt"); output != "promoted attachment notes\n" {
+		t.Errorf("unexpected promoted data %q", output)
+	}
+}
+
+func TestAttachReplace(t *testing.T) {
+	db := path.Join(t.TempDir(), "replace.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-u", "66666666-6666-6666-6666-666666666666", "-n", "host")
+	cmd.Stdin = strings.NewReader("host")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	notes := path.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(notes, []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runSnip(t, "--db", db, "attach", "add", "66666666-6666-6666-6666-666666666666", notes)
+	// the header is written to stderr
+	id := strings.Fields(runSnip(t, "--db", db, "attach", "ls"))[0]
+
+	if err := os.WriteFile(notes, []byte("second version"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := runSnip(t, "--db", db, "attach", "replace", id, notes)
+	if output != "replaced "+id+" notes.txt 5 bytes -> 14 bytes\n" {
+		t.Errorf("unexpected output %q", output)
+	}
+	if data := runSnip(t, "--db", db, "attach", "stdout", id); data != "second version" {
+		t.Errorf("expected replaced data, got %q", data)
+	}
+}
+
+func TestAttachWriteAll(t *testing.T) {
+	dir := path.Join(t.TempDir(), "attachments")
+	output := runSnip(t, "attach", "write-all", "65f6930f", dir)
+	written := path.Join(dir, "Lorem ipsum - Wikipedia.pdf")
+	if !strings.HasPrefix(output, "Lorem ipsum - Wikipedia.pdf written -> "+written) {
+		t.Fatalf("expected attachment written to %s, got %q", written, output)
+	}
+	data, err := os.ReadFile(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != runSnip(t, "attach", "stdout", "9cfc5a2d-2946-48ee-82e0-227ba4bcdbd5") {
+		t.Errorf("expected written file to hold attachment data")
+	}
+
+	// existing files are not overwritten without -force
+	cmd := exec.Command(appPath, "attach", "write-all", "65f6930f", dir)
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected error writing over existing files")
+	}
+	runSnip(t, "attach", "write-all", "-force", "65f6930f", dir)
+}
+
+func TestWc(t *testing.T) {
+	db := path.Join(t.TempDir(), "wc.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-u", "44444444-4444-4444-4444-444444444444", "-n", "counted")
+	cmd.Stdin = strings.NewReader("one two\nthree\n")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "      2       3      14 44444444-4444-4444-4444-444444444444\n"
+	if output := runSnip(t, "--db", db, "wc", "name:counted"); output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+	if output := runSnip(t, "--db", db, "wc", "-all"); output != "      2       3      14 total\n" {
+		t.Errorf("unexpected total %q", output)
+	}
+}
+
+func TestGetExpand(t *testing.T) {
+	db := path.Join(t.TempDir(), "expand.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "ssh")
+	cmd.Stdin = strings.NewReader("ssh {{.user}}@{{.host}}\n")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd = exec.Command(appPath, "--db", db, "get", "-raw", "-expand", "host=server1", "name:ssh")
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected error for placeholder without value")
+	}
+	output := runSnip(t, "--db", db, "get", "-raw", "-expand", "host=server1", "-expand", "user=admin", "name:ssh")
+	if output != "ssh admin@server1\n" {
+		t.Errorf("unexpected expansion %q", output)
+	}
+}
+
+func TestGetWrap(t *testing.T) {
+	db := path.Join(t.TempDir(), "wrap.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "prose")
+	cmd.Stdin = strings.NewReader("the quick brown fox jumps\nover\n")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	output := runSnip(t, "--db", db, "get", "-raw", "-wrap", "10", "name:prose")
+	if output != "the quick\nbrown fox\njumps\nover\n" {
+		t.Errorf("unexpected wrapped output %q", output)
+	}
+}
+
+func TestGetHeadTail(t *testing.T) {
+	db := path.Join(t.TempDir(), "headtail.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-u", "33333333-3333-3333-3333-333333333333", "-n", "lines")
+	cmd.Stdin = strings.NewReader("1\n2\n3\n4\n5\n6\n")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-head", "2"}, "1\n2\n"},
+		{[]string{"-tail", "2"}, "5\n6\n"},
+		{[]string{"-head", "2", "-tail", "1"}, "1\n2\n...\n6\n"},
+		{[]string{"-head", "4", "-tail", "4"}, "1\n2\n3\n4\n5\n6\n"},
+	}
+	for _, tt := range tests {
+		args := append([]string{"--db", db, "get", "-raw"}, tt.args...)
+		if output := runSnip(t, append(args, "3333")...); output != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.expected, output)
+		}
+	}
+}
+
+func TestAttachListTotal(t *testing.T) {
+	var expected int
+	for _, line := range strings.Split(strings.TrimSpace(runSnip(t, "attach", "ls")), "\n") {
+		var size int
+		_, err := fmt.Sscanf(strings.Fields(line)[1], "%d", &size)
+		if err != nil {
+			t.Fatalf("could not parse size of %q: %v", line, err)
+		}
+		expected += size
+	}
+	if output := runSnip(t, "attach", "ls", "-total"); output != fmt.Sprintf("total: %d bytes\n", expected) {
+		t.Errorf("expected total of %d bytes, got %q", expected, output)
+	}
+}
+
+func TestSearchRegex(t *testing.T) {
+	count := strings.TrimSpace(runSnip(t, "search", "-type", "regex", "-count", `(?i)lorem\s+ipsum`))
+	var results []struct {
+		UUID  string
+		Lines []struct {
+			Line    int
+			Text    string
+			Matches []string
+		}
+	}
+	err := json.Unmarshal([]byte(runSnip(t, "search", "-type", "regex", "-json-array", `(?i)lorem\s+ipsum`)), &results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count == "0" || count != fmt.Sprintf("%d", len(results)) {
+		t.Errorf("expected count %s to equal %d results", count, len(results))
+	}
+	for _, r := range results {
+		if len(r.Lines) == 0 {
+			t.Errorf("expected matched lines for %s", r.UUID)
+		}
+		for _, l := range r.Lines {
+			if l.Line < 1 || len(l.Matches) == 0 || !strings.Contains(strings.ToLower(l.Text), "lorem") {
+				t.Errorf("unexpected line %+v", l)
+			}
+		}
+	}
+
+	if output := runSnip(t, "search", "-type", "regex", "-fold", "-count", `LOREM\s+IPSUM`); strings.TrimSpace(output) != count {
+		t.Errorf("expected folded pattern to match %s snips, got %s", count, output)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	id := "65f6930f"
+	runSnip(t, "get", id)
+	output := runSnip(t, "recent", "-l", "1")
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); len(lines) != 1 || !strings.HasPrefix(lines[0], id) {
+		t.Errorf("expected snip %s to be most recent, got %q", id, output)
+	}
+}
+
+func TestFavorite(t *testing.T) {
+	id := "65f6930f"
+	runSnip(t, "fav", id)
+	if output := runSnip(t, "ls", "-fav"); !strings.HasPrefix(output, id) || strings.Count(output, "\n") != 1 {
+		t.Errorf("expected only snip %s listed as favorite, got %q", id, output)
+	}
+	if output := runSnip(t, "get", id); !strings.Contains(output, "\nfavorite: yes\n") {
+		t.Errorf("expected favorite line in get output, got %q", output)
+	}
+
+	runSnip(t, "unfav", id)
+	if output := runSnip(t, "ls", "-fav"); output != "" {
+		t.Errorf("expected no favorites, got %q", output)
+	}
+}
+
+func TestListSinceUntil(t *testing.T) {
+	output, err := exec.Command(appPath, "ls", "-since", "last week").CombinedOutput()
+	if err == nil || !strings.Contains(string(output), "-since value is not valid") {
+		t.Errorf("expected error for invalid time, got %v: %q", err, output)
+	}
+
+	all := runSnip(t, "ls")
+	if output := runSnip(t, "ls", "-since", "2000-01-01"); output != all {
+		t.Errorf("expected all snips since 2000, got %q", output)
+	}
+	if output := runSnip(t, "ls", "-until", "2000-01-01"); output != "" {
+		t.Errorf("expected no snips until 2000, got %q", output)
+	}
+	if output := runSnip(t, "search", "-exit-zero", "-type", "data", "-count", "-until", "2000-01-01", "the"); output != "0\n" {
+		t.Errorf("expected no search results until 2000, got %q", output)
+	}
+}
+
+func TestRenameAll(t *testing.T) {
+	before := runSnip(t, "ls", "-l")
+	preview := strings.Split(strings.TrimSpace(runSnip(t, "rename", "-all", "-pattern", "^", "-replace", "renamed ")), "\n")
+	listed := strings.Split(strings.TrimSpace(before), "\n")
+	if len(preview) != len(listed) {
+		t.Fatalf("expected %d previewed renames, got %q", len(listed), preview)
+	}
+	for idx, line := range preview {
+		id, name, _ := strings.Cut(listed[idx], " ")
+		if line != fmt.Sprintf("would rename %s %s -> renamed %s", id, name, name) {
+			t.Errorf("unexpected preview %q", line)
+		}
+	}
+	if after := runSnip(t, "ls", "-l"); after != before {
+		t.Errorf("expected preview to leave names unchanged, got %q", after)
+	}
+}
+
+func TestRenameRegenerate(t *testing.T) {
+	db := path.Join(t.TempDir(), "regenerate.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "stale name")
+	cmd.Stdin = strings.NewReader("fresh words about birds, and more")
+	err := cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := runSnip(t, "--db", db, "rename", "-regenerate", "-name-words", "3", "name:stale name")
+	var id string
+	if _, err = fmt.Sscanf(output, "renamed %s", &id); err != nil || output != fmt.Sprintf("renamed %s stale name -> fresh words about\n", id) {
+		t.Errorf("unexpected rename output %q: %v", output, err)
+	}
+	if data := runSnip(t, "--db", db, "cat", "name:fresh words about"); data != "fresh words about birds, and more" {
+		t.Errorf("expected snip found by regenerated name, got %q", data)
+	}
+}
+
+func TestClone(t *testing.T) {
+	db := path.Join(t.TempDir(), "clone.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "template")
+	cmd.Stdin = strings.NewReader("starting point")
+	err := cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := runSnip(t, "--db", db, "clone", "name:template")
+	var from, to, name string
+	_, err = fmt.Sscanf(output, "cloned %s -> %s %s", &from, &to, &name)
+	if err != nil || from == to {
+		t.Fatalf("unexpected clone output %q: %v", output, err)
+	}
+	if data := runSnip(t, "--db", db, "cat", "name:template (copy)"); data != "starting point" {
+		t.Errorf("expected cloned data, got %q", data)
+	}
+}
+
+func TestSearchMerge(t *testing.T) {
+	// relies on the index built by TestSearchContext
+	var results []struct {
+		Context []struct{ Term string }
+		Windows []struct {
+			Start int
+			End   int
+			Words []string
+			Terms []int
+		}
+	}
+	err := json.Unmarshal([]byte(runSnip(t, "search", "-json-array", "-merge", "-context", "3", "lorem")), &results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		var terms int
+		for idx, w := range r.Windows {
+			if len(w.Words) != w.End-w.Start+1 || len(w.Terms) == 0 {
+				t.Errorf("unexpected window %+v", w)
+			}
+			if idx > 0 && w.Start <= r.Windows[idx-1].End+1 {
+				t.Errorf("expected window %+v to be merged with the previous window", w)
+			}
+			terms += len(w.Terms)
+		}
+		if terms != len(r.Context) {
+			t.Errorf("expected %d terms marked in windows, got %d", len(r.Context), terms)
+		}
+	}
+}
+
+func TestExportImportJSONL(t *testing.T) {
+	exported := runSnip(t, "export-jsonl")
+	ids := strings.Fields(runSnip(t, "ls", "-template", "{{.UUID}}"))
+	if lines := strings.Count(exported, "\n"); lines == 0 || lines != len(ids) {
+		t.Fatalf("expected one line for each of %d snips, got %d", len(ids), lines)
+	}
+
+	cmd := exec.Command(appPath, "--db", path.Join(t.TempDir(), "jsonl.sqlite"), "import-jsonl")
+	cmd.Stdin = strings.NewReader(exported)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("imported %d snips\n", strings.Count(exported, "\n")); string(output) != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+}
+
+func TestAttachVerify(t *testing.T) {
+	// binary attachment data in the CSV test data is truncated on import, so sizes do not match
+	cmd := exec.Command(appPath, "attach", "verify", "9cfc5a2d")
+	output, err := cmd.Output()
+	if err == nil {
+		t.Errorf("expected non-zero exit for size mismatch")
+	}
+	if !strings.HasPrefix(string(output), "size mismatch 9cfc5a2d-2946-48ee-82e0-227ba4bcdbd5 ") {
+		t.Errorf("expected size mismatch of attachment 9cfc5a2d, got %q", output)
+	}
+
+	cmd = exec.Command(appPath, "--db", path.Join(t.TempDir(), "empty.sqlite"), "attach", "verify")
+	combined, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Errorf("expected clean exit for empty database, got %v: %s", err, combined)
+	}
+	if string(combined) != "0 attachments verified\n" {
+		t.Errorf("unexpected output %q", combined)
+	}
+}
+
+func TestRelated(t *testing.T) {
+	db := path.Join(t.TempDir(), "related.sqlite")
+	for _, data := range []string{"wren sparrow finch", "wren sparrow nests", "compilers parse grammars"} {
+		cmd := exec.Command(appPath, "--db", db, "add", "-n", data)
+		cmd.Stdin = strings.NewReader(data)
+		err := cmd.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output := runSnip(t, "--db", db, "related", "name:wren sparrow finch")
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 1 || !strings.HasSuffix(lines[0], " 0.667 wren sparrow nests") {
+		t.Errorf("expected only the snip sharing terms, got %q", output)
+	}
+}
+
+func TestAddAppend(t *testing.T) {
+	db := path.Join(t.TempDir(), "append.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "notes")
+	cmd.Stdin = strings.NewReader("monday")
+	err := cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd = exec.Command(appPath, "--db", db, "add", "-append", "name:notes")
+	cmd.Stdin = strings.NewReader("tuesday")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(output), "appended 7 bytes to ") || !strings.HasSuffix(string(output), " notes, total 14 bytes\n") {
+		t.Errorf("unexpected output %q", output)
+	}
+	if data := runSnip(t, "--db", db, "cat", "name:notes"); data != "monday\ntuesday" {
+		t.Errorf("expected appended data, got %q", data)
+	}
+}
+
+func TestSearchExitStatus(t *testing.T) {
+	for _, args := range [][]string{
+		{"search", "xyzzyplugh"},
+		{"search", "-type", "data", "xyzzyplugh"},
+		{"search", "-type", "regex", "xyzzy+plugh"},
+		{"search", "-count", "xyzzyplugh"},
+	} {
+		cmd := exec.Command(appPath, args...)
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			t.Errorf("expected exit status 1 for %v, got %v", args, err)
+		}
+	}
+
+	runSnip(t, "search", "-type", "data", "lorem")
+	if output := runSnip(t, "search", "-exit-zero", "-type", "data", "xyzzyplugh"); output != "" {
+		t.Errorf("expected no output, got %q", output)
+	}
+}
+
+func TestSearchName(t *testing.T) {
+	output := runSnip(t, "search", "-type", "data", "-f", "name", "IPSUM DOLOR")
+	if !strings.Contains(output, "65f6930f-e970-4b6e-b10c-fca3dac21c1e Lorem ipsum dolor sit amet") {
+		t.Errorf("expected snip matched by name, got %q", output)
+	}
+}
+
+func TestFind(t *testing.T) {
+	// output is not a terminal, so matches are listed rather than selected
+	output := runSnip(t, "find", "-print-uuid", "lrmips")
+	if !strings.Contains(output, "65f6930f-e970-4b6e-b10c-fca3dac21c1e\n") {
+		t.Errorf("expected uuid of snip matched by name, got %q", output)
+	}
+}
+
+func TestSearchAny(t *testing.T) {
+	db := path.Join(t.TempDir(), "any.sqlite")
+	for _, data := range []string{"banana cherry smoothie recipe", "banana bread recipe with walnuts", "cherry pie recipe"} {
+		cmd := exec.Command(appPath, "--db", db, "add")
+		cmd.Stdin = strings.NewReader(data)
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(args ...string) string {
+		return strings.TrimSpace(runSnip(t, append([]string{"--db", db, "search", "-exit-zero", "-count"}, args...)...))
+	}
+	if n := count("banana", "pie"); n != "0" {
+		t.Errorf("expected no snips with all terms, got %s", n)
+	}
+	if n := count("-any", "banana", "pie"); n != "3" {
+		t.Errorf("expected 3 snips with any term, got %s", n)
+	}
+	// the snip matching both terms ranks first
+	output := runSnip(t, "--db", db, "search", "-any", "-limit", "1", "-template", "{{.Name}}", "banana", "cherry")
+	if output != "banana cherry smoothie recipe\n" {
+		t.Errorf("expected the snip with both terms, got %q", output)
+	}
+}
+
+func TestTermsAll(t *testing.T) {
+	all := strings.Split(strings.TrimSpace(runSnip(t, "terms", "-all")), "\n")
+	if len(all) == 0 {
+		t.Fatalf("expected indexed terms")
+	}
+	output := runSnip(t, "terms", "-all", "-prefix", "lor", "-n", "1")
+	if fields := strings.Fields(output); len(fields) != 2 || !strings.HasPrefix(fields[1], "lor") {
+		t.Errorf("expected one term beginning with lor, got %q", output)
+	}
+}
+
+func TestExportSnip(t *testing.T) {
+	dir := path.Join(t.TempDir(), "archive")
+	output := runSnip(t, "export", "65f6930f", dir)
+	if !strings.HasPrefix(output, "exported 65f6930f-e970-4b6e-b10c-fca3dac21c1e Lorem ipsum dolor sit amet -> ") {
+		t.Errorf("unexpected output %q", output)
+	}
+	data, err := os.ReadFile(path.Join(dir, "Lorem ipsum dolor sit amet.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != runSnip(t, "cat", "65f6930f") {
+		t.Errorf("expected exported file to contain snip data")
+	}
+}
+
+func TestSearchDataContext(t *testing.T) {
+	db := path.Join(t.TempDir(), "context.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "release")
+	cmd.Stdin = strings.NewReader("upgrade to v1.4.2 before migrating")
+	err := cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// version numbers are split into several indexed words, but are found in the data
+	output := runSnip(t, "--db", db, "search", "-type", "data", "-context", "1", "1.4")
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 || lines[1] != `    [2-4] "to v1.4.2 before"` {
+		t.Errorf("unexpected output %q", output)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	db := path.Join(t.TempDir(), "quiet.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "-q", "add", "-n", "quiet")
+	cmd.Stdin = strings.NewReader("quiet data")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimSpace(string(output))
+	if _, err = uuid.Parse(id); err != nil {
+		t.Fatalf("expected only the uuid from a quiet add, got %q", output)
+	}
+
+	cmd = exec.Command(appPath, "--db", db, "--quiet", "ls")
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if stderr.Len() != 0 {
+		t.Errorf("expected no header from a quiet ls, got %q", stderr.String())
+	}
+	if stdout.String() != fmt.Sprintf("%s quiet\n", id[:8]) {
+		t.Errorf("expected listing kept by quiet, got %q", stdout.String())
+	}
+
+	if output := runSnip(t, "--db", db, "-q", "rename", id, "renamed"); output != "" {
+		t.Errorf("expected no confirmation from a quiet rename, got %q", output)
+	}
+	if data := runSnip(t, "--db", db, "-q", "cat", "name:renamed"); data != "quiet data" {
+		t.Errorf("expected data kept by quiet, got %q", data)
+	}
+}
+
+func TestSearchWatch(t *testing.T) {
+	db := path.Join(t.TempDir(), "watch.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add")
+	cmd.Stdin = strings.NewReader("the wren sings")
+	err := cmd.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd = exec.Command(appPath, "--db", db, "search", "-watch", "-interval", "100ms", "wren")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// each run prints the context of the match, which is awaited rather than assuming runs within a time
+	found := make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if strings.Contains(scanner.Text(), "the wren sings") {
+				found <- struct{}{}
+			}
+		}
+		close(found)
+	}()
+	timeout := time.After(10 * time.Second)
+	for runs := 0; runs < 2; {
+		select {
+		case _, ok := <-found:
+			if !ok {
+				t.Fatalf("expected the search to run repeatedly, output ended after %d runs", runs)
+			}
+			runs++
+		case <-timeout:
+			cmd.Process.Kill()
+			t.Fatalf("expected the search to run repeatedly, got %d runs", runs)
+		}
+	}
+
+	if err = cmd.Process.Signal(os.Interrupt); err != nil {
+		t.Fatal(err)
+	}
+	// later runs are read until the output closes, so that the watch is not blocked writing
+	for range found {
+	}
+	if err = cmd.Wait(); err != nil {
+		t.Errorf("expected watch to exit cleanly when interrupted: %v", err)
+	}
+}
+
+func TestAttachAddStdin(t *testing.T) {
+	db := path.Join(t.TempDir(), "stdin.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "-q", "add", "-n", "piped")
+	cmd.Stdin = strings.NewReader("holds piped attachments")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimSpace(string(output))
+
+	cmd = exec.Command(appPath, "--db", db, "attach", "add", id, "-")
+	cmd.Stdin = strings.NewReader("unnamed")
+	if err = cmd.Run(); err == nil {
+		t.Errorf("expected standard input without -name to be refused")
+	}
+
+	cmd = exec.Command(appPath, "--db", db, "attach", "add", "-name", "piped.txt", id, "-")
+	cmd.Stdin = strings.NewReader("piped attachment data")
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "attached piped.txt 21 bytes\n") {
+		t.Errorf("unexpected attach output %q", output)
+	}
+
+	fields := strings.Fields(runSnip(t, "--db", db, "attach", "ls"))
+	if len(fields) != 4 || fields[3] != "piped.txt" {
+		t.Fatalf("expected the piped attachment listed, got %q", fields)
+	}
+	if data := runSnip(t, "--db", db, "attach", "stdout", fields[0]); data != "piped attachment data" {
+		t.Errorf("expected piped data attached, got %q", data)
+	}
+}
+
+func TestIndexStatus(t *testing.T) {
+	db := path.Join(t.TempDir(), "status.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add")
+	cmd.Stdin = strings.NewReader("indexed when added")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// the status only reads the index
+	if output := runSnip(t, "--db", db, "--read-only", "index", "-status"); output != "" {
+		t.Errorf("expected no unindexed snips, got %q", output)
+	}
+	if output := runSnip(t, "--db", db, "index", "-missing"); output != "" {
+		t.Errorf("expected nothing printed to stdout by -missing, got %q", output)
+	}
+}
+
+func TestSearchBuckets(t *testing.T) {
+	db := path.Join(t.TempDir(), "buckets.sqlite")
+	for _, data := range []string{"wren", "a wren among many other words that lower its score"} {
+		cmd := exec.Command(appPath, "--db", db, "add")
+		cmd.Stdin = strings.NewReader(data)
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var headers []string
+	for _, line := range strings.Split(runSnip(t, "--db", db, "search", "-buckets", "wren"), "\n") {
+		if strings.HasPrefix(line, "== ") {
+			headers = append(headers, line)
+		}
+	}
+	expected := []string{"== high (score >= 0.6) ==", "== medium (score >= 0.3) =="}
+	if strings.Join(headers, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected headers %q, got %q", expected, headers)
+	}
+}
+
+func TestAttachListSnip(t *testing.T) {
+	// the header is written to stderr
+	fields := strings.Fields(runSnip(t, "attach", "ls", "65f6930f"))
+	if len(fields) < 3 || fields[0] != "9cfc5a2d-2946-48ee-82e0-227ba4bcdbd5" {
+		t.Errorf("expected the attachment of the snip listed, got %q", fields)
+	}
+
+	db := path.Join(t.TempDir(), "ls.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "bare")
+	cmd.Stdin = strings.NewReader("no attachments here")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command(appPath, "--db", db, "attach", "ls", "name:bare")
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.Len() != 0 || !strings.HasPrefix(stderr.String(), "no attachments for snip ") {
+		t.Errorf("expected a message that the snip has no attachments, got %q %q", stdout.String(), stderr.String())
+	}
+}
+
+func TestRawOutputExact(t *testing.T) {
+	db := path.Join(t.TempDir(), "raw.sqlite")
+	data := "format verbs %s and %d, 100% %!v(MISSING) %%\n\tindented"
+	cmd := exec.Command(appPath, "--db", db, "-q", "add", "-n", "verbs")
+	cmd.Stdin = strings.NewReader(data)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimSpace(string(output))
+
+	if raw := runSnip(t, "--db", db, "get", "-raw", id); raw != data {
+		t.Errorf("expected get -raw to round-trip exactly, got %q", raw)
+	}
+	if raw := runSnip(t, "--db", db, "cat", id); raw != data {
+		t.Errorf("expected cat to round-trip exactly, got %q", raw)
+	}
+
+	binary := "%s\x00\xff\xfe%d"
+	cmd = exec.Command(appPath, "--db", db, "attach", "add", "-name", "binary.dat", id, "-")
+	cmd.Stdin = strings.NewReader(binary)
+	if err = cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// the header is written to stderr
+	attachment := strings.Fields(runSnip(t, "--db", db, "attach", "ls", id))[0]
+	if raw := runSnip(t, "--db", db, "attach", "stdout", attachment); raw != binary {
+		t.Errorf("expected attach stdout to round-trip exactly, got %q", raw)
+	}
+}
+
+func TestSearchFields(t *testing.T) {
+	db := path.Join(t.TempDir(), "fields.sqlite")
+	for _, s := range [][2]string{{"garden birds", "a wren at the feeder"}, {"wren notes", "nothing else"}} {
+		cmd := exec.Command(appPath, "--db", db, "add", "-n", s[0])
+		cmd.Stdin = strings.NewReader(s[1])
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var names []string
+	for _, line := range strings.Split(runSnip(t, "--db", db, "search", "-type", "data", "-fields", "name,data", "wren"), "\n") {
+		if fields := strings.Fields(line); len(fields) > 2 && !strings.HasPrefix(line, " ") {
+			names = append(names, strings.Join(fields[2:], " "))
+		}
+	}
+	if strings.Join(names, ",") != "wren notes,garden birds" {
+		t.Errorf("expected the name match ranked first, got %q", names)
+	}
+}
+
+func TestAddNoIndex(t *testing.T) {
+	db := path.Join(t.TempDir(), "noindex.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-no-index")
+	cmd.Stdin = strings.NewReader("skipped by the index")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimPrefix(strings.TrimSpace(string(output)), "added snip uuid: ")
+	if !strings.Contains(stderr.String(), "snip index -missing") {
+		t.Errorf("expected a warning to run index -missing, got %q", stderr.String())
+	}
+
+	status := runSnip(t, "--db", db, "index", "-status")
+	if !strings.HasPrefix(status, id+" ") {
+		t.Errorf("expected snip %s listed as not indexed, got %q", id, status)
+	}
+	runSnip(t, "--db", db, "index", "-missing")
+	if status := runSnip(t, "--db", db, "index", "-status"); status != "" {
+		t.Errorf("expected no unindexed snips after index -missing, got %q", status)
+	}
+}
+
+func TestSearchLines(t *testing.T) {
+	db := path.Join(t.TempDir(), "lines.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "training log")
+	cmd.Stdin = strings.NewReader("nothing here\nthe dog was running fast\nlast line\n")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(runSnip(t, "--db", db, "search", "-lines", "run")), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " training log") || lines[1] != "2:the dog was running fast" {
+		t.Errorf("expected a header and the matched line, got %q", lines)
+	}
+}
+
+func TestDBFromURLAndStdin(t *testing.T) {
+	db := path.Join(t.TempDir(), "shared.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "shared snapshot")
+	cmd.Stdin = strings.NewReader("a snapshot shared with others")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	if output := runSnip(t, "--db-url", server.URL+"/snips.sqlite3", "ls"); !strings.Contains(output, "shared snapshot") {
+		t.Errorf("expected the downloaded database listed, got %q", output)
+	}
+
+	cmd = exec.Command(appPath, "--db", "-", "ls")
+	cmd.Stdin = strings.NewReader(string(data))
+	output, err := cmd.Output()
+	if err != nil || !strings.Contains(string(output), "shared snapshot") {
+		t.Errorf("expected the piped database listed, got %q: %v", output, err)
+	}
+
+	// a database created before schema versions were recorded is migrated before it is read
+	legacy := path.Join(t.TempDir(), "legacy.sqlite")
+	cmd = exec.Command("sqlite3", legacy, `CREATE TABLE snip(uuid TEXT, timestamp TEXT, name TEXT, data TEXT);
+		INSERT INTO snip VALUES ('`+uuid.New().String()+`', '2023-06-16T12:00:00Z', 'legacy snapshot', 'older data');`)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	legacyData, err := os.ReadFile(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command(appPath, "--db", "-", "ls")
+	cmd.Stdin = strings.NewReader(string(legacyData))
+	output, err = cmd.Output()
+	if err != nil || !strings.Contains(string(output), "legacy snapshot") {
+		t.Errorf("expected the piped legacy database listed, got %q: %v", output, err)
+	}
+
+	// other databases are refused
+	other := path.Join(t.TempDir(), "other.sqlite")
+	cmd = exec.Command("sqlite3", other, `CREATE TABLE other(value TEXT);`)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	otherData, err := os.ReadFile(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd = exec.Command(appPath, "--db", "-", "ls")
+	cmd.Stdin = strings.NewReader(string(otherData))
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil || !strings.Contains(stderr.String(), "is not a snip database") {
+		t.Errorf("expected a database without snips refused, got %q: %v", stderr.String(), err)
+	}
+
+	// copies are always read-only
+	cmd = exec.Command(appPath, "--db-url", server.URL+"/snips.sqlite3", "add")
+	cmd.Stdin = strings.NewReader("refused")
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected a change to a downloaded database to fail")
+	}
+}
+
+func TestMeta(t *testing.T) {
+	db := path.Join(t.TempDir(), "meta.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "-q", "add", "-n", "go notes")
+	cmd.Stdin = strings.NewReader("channels and goroutines")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimSpace(string(output))
+
+	runSnip(t, "--db", db, "meta", "set", id, "source=stackoverflow", "lang=go")
+	if value := runSnip(t, "--db", db, "meta", "get", id, "lang"); value != "go\n" {
+		t.Errorf("expected lang go, got %q", value)
+	}
+	if listed := runSnip(t, "--db", db, "meta", "ls", id); listed != "lang=go\nsource=stackoverflow\n" {
+		t.Errorf("expected sorted key=value lines, got %q", listed)
+	}
+	if got := runSnip(t, "--db", db, "get", id); !strings.Contains(got, "meta: lang=go\n") {
+		t.Errorf("expected metadata in get output, got %q", got)
+	}
+	if found := runSnip(t, "--db", db, "search", "-meta", "lang=go", "channels"); !strings.Contains(found, "go notes") {
+		t.Errorf("expected the snip found with matching metadata, got %q", found)
+	}
+	cmd = exec.Command(appPath, "--db", db, "search", "-meta", "lang=rust", "channels")
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected no results with other metadata")
+	}
+}
+
+func TestUndo(t *testing.T) {
+	db := path.Join(t.TempDir(), "undo.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "-q", "add", "-n", "removed by accident")
+	cmd.Stdin = strings.NewReader("an important note")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strings.TrimSpace(string(output))
+
+	cmd = exec.Command(appPath, "--db", db, "rm", id)
+	cmd.Stdin = strings.NewReader("y\n")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if listed := runSnip(t, "--db", db, "trash", "ls"); !strings.Contains(listed, id+" removed by accident") {
+		t.Errorf("expected the snip listed in the trash, got %q", listed)
+	}
+	if restored := runSnip(t, "--db", db, "undo"); restored != "restored "+id+" removed by accident\n" {
+		t.Errorf("expected the snip restored, got %q", restored)
+	}
+	if data := runSnip(t, "--db", db, "get", "-raw", id); data != "an important note" {
+		t.Errorf("expected the data of the restored snip, got %q", data)
+	}
+
+	// snips removed by a search are restored together
+	for _, name := range []string{"stale draft", "stale notes"} {
+		cmd = exec.Command(appPath, "--db", db, "add", "-n", name)
+		cmd.Stdin = strings.NewReader("a stale " + name)
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd = exec.Command(appPath, "--db", db, "rm", "-search", "stale", "-confirm")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "snip undo -n 2") {
+		t.Errorf("expected a hint to restore both snips, got %q", stderr.String())
+	}
+	restored := runSnip(t, "--db", db, "undo", "-n", "2")
+	if strings.Count(restored, "restored ") != 2 || !strings.Contains(restored, "stale draft") || !strings.Contains(restored, "stale notes") {
+		t.Errorf("expected both snips restored, got %q", restored)
+	}
+}
+
+func TestGetGist(t *testing.T) {
+	db := path.Join(t.TempDir(), "gist.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-u", "77777777-7777-7777-7777-777777777777", "-n", "host")
+	cmd.Stdin = strings.NewReader("ssh host")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	notes := path.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(notes, []byte("small notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runSnip(t, "--db", db, "attach", "add", "77777777-7777-7777-7777-777777777777", notes)
+
+	output := runSnip(t, "--db", db, "get", "-format", "gist", "77777777")
+	if !strings.HasPrefix(output, "host\n====\n\n```\nssh host\n```\n") {
+		t.Errorf("unexpected gist %q", output)
+	}
+	if !strings.Contains(output, "notes.txt (12 bytes, not inlined)\n") {
+		t.Errorf("expected attachment to be listed, got %q", output)
+	}
+
+	output = runSnip(t, "--db", db, "get", "-format", "gist", "-inline-max", "64", "77777777")
+	if !strings.Contains(output, "notes.txt (12 bytes)\n```\nsmall notes\n```\n") {
+		t.Errorf("expected attachment to be inlined, got %q", output)
+	}
+}
+
+func TestListColumns(t *testing.T) {
+	db := path.Join(t.TempDir(), "columns.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-u", "88888888-8888-8888-8888-888888888888", "-n", "wren notes")
+	cmd.Stdin = strings.NewReader("the wren is a small bird")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if output := runSnip(t, "--db", db, "ls", "-uuids-only"); output != "88888888-8888-8888-8888-888888888888\n" {
+		t.Errorf("expected only the uuid, got %q", output)
+	}
+	if output := runSnip(t, "--db", db, "ls", "-names-only"); output != "wren notes\n" {
+		t.Errorf("expected only the name, got %q", output)
+	}
+	if output := runSnip(t, "--db", db, "search", "-uuids-only", "wren"); output != "88888888-8888-8888-8888-888888888888\n" {
+		t.Errorf("expected only the uuid of the result, got %q", output)
+	}
+	if output := runSnip(t, "--db", db, "search", "-type", "data", "-names-only", "small"); output != "wren notes\n" {
+		t.Errorf("expected only the name of the result, got %q", output)
+	}
+
+	cmd = exec.Command(appPath, "--db", db, "ls", "-uuids-only", "-names-only")
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected -uuids-only and -names-only to be rejected together")
+	}
+}
+
+func TestDedupDelete(t *testing.T) {
+	db := path.Join(t.TempDir(), "dedup.sqlite")
+	ids := []string{
+		"a1111111-1111-1111-1111-111111111111",
+		"a2222222-2222-2222-2222-222222222222",
+		"a3333333-3333-3333-3333-333333333333",
+	}
+	for _, id := range ids {
+		cmd := exec.Command(appPath, "--db", db, "add", "-u", id, "-n", "copy")
+		cmd.Stdin = strings.NewReader("identical data")
+		if err := cmd.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	notes := path.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(notes, []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runSnip(t, "--db", db, "attach", "add", ids[2], notes)
+
+	runSnip(t, "--db", db, "dedup", "-delete-newer", "-dry-run")
+	if listed := runSnip(t, "--db", db, "ls", "-uuids-only"); strings.Count(listed, "\n") != 3 {
+		t.Errorf("expected dry run to keep every snip, got %q", listed)
+	}
+
+	// each removal is confirmed, and the attachment moves to the oldest snip
+	cmd := exec.Command(appPath, "--db", db, "dedup", "-delete-newer")
+	cmd.Stdin = strings.NewReader("y\ny\n")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(output), "removed ") != 2 {
+		t.Errorf("expected two removals, got %q", output)
+	}
+	if listed := runSnip(t, "--db", db, "ls", "-uuids-only"); listed != ids[0]+"\n" {
+		t.Errorf("expected only the oldest snip to remain, got %q", listed)
+	}
+	if got := runSnip(t, "--db", db, "get", ids[0]); !strings.Contains(got, "notes.txt") {
+		t.Errorf("expected the attachment to be moved to the oldest snip, got %q", got)
+	}
+}
+
+func TestImportDir(t *testing.T) {
+	db := path.Join(t.TempDir(), "importdir.sqlite")
+	dir := t.TempDir()
+	files := map[string]string{
+		"small.txt": "small notes",
+		"large.txt": strings.Repeat("large notes ", 10),
+		"image.png": "\x89PNG\x00",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(path.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// an oversized file is skipped with a warning instead of ending the import
+	cmd := exec.Command(appPath, "--db", db, "import", "-dir", dir)
+	cmd.Env = append(os.Environ(), "SNIP_MAX_SIZE=50")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("import -dir: %v: %s", err, stderr.String())
+	}
+	if string(output) != "imported 1 snips\n" {
+		t.Errorf("expected one imported snip, got %q", output)
+	}
+	if !strings.Contains(stderr.String(), "warning: skipped "+path.Join(dir, "large.txt")) {
+		t.Errorf("expected a warning for the oversized file, got %q", stderr.String())
+	}
+	if listed := runSnip(t, "--db", db, "ls", "-names-only"); listed != "small\n" {
+		t.Errorf("expected only the small file as a snip, got %q", listed)
+	}
+
+	// an export file named dir is imported as usual
+	exportDir := t.TempDir()
+	runSnip(t, "--db", db, "export", "-o", path.Join(exportDir, "dir"))
+	other := path.Join(t.TempDir(), "other.sqlite")
+	cmd = exec.Command(appPath, "--db", other, "import", "dir")
+	cmd.Dir = exportDir
+	if output, err := cmd.Output(); err != nil || string(output) != "imported 1 snips\n" {
+		t.Errorf("expected the export file named dir to be imported, got %q %v", output, err)
+	}
+}
+
+func TestSearchFuzzyContext(t *testing.T) {
+	db := path.Join(t.TempDir(), "fuzzy.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "campus")
+	cmd.Stdin = strings.NewReader("the universities opened early")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the similar stem univers is located as indexed, since stemming it again gives univ
+	output := runSnip(t, "--db", db, "search", "-fuzzy", "univrsities")
+	if !strings.Contains(output, `"the universities opened early"`) {
+		t.Errorf("expected context of the similar term, got %q", output)
+	}
+}
+
+func TestSearchWordSymbolsMismatch(t *testing.T) {
+	db := path.Join(t.TempDir(), "symbols.sqlite")
+	cmd := exec.Command(appPath, "--db", db, "add", "-n", "paths")
+	cmd.Stdin = strings.NewReader("a/b/c/d/e/f/g/h/i/j target")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// searching with another word symbols setting than the index was built with is refused
+	symbols := append(os.Environ(), "SNIP_WORD_SYMBOLS=true")
+	cmd = exec.Command(appPath, "--db", db, "search", "target")
+	cmd.Env = symbols
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Errorf("expected search to fail with a mismatched word symbols setting")
+	}
+	if !strings.Contains(stderr.String(), "SNIP_WORD_SYMBOLS=true snip index") || strings.Contains(stderr.String(), "panic") {
+		t.Errorf("expected a hint to rebuild the index, got %q", stderr.String())
+	}
+
+	cmd = exec.Command(appPath, "--db", db, "index")
+	cmd.Env = symbols
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("index: %v: %s", err, output)
+	}
+	cmd = exec.Command(appPath, "--db", db, "search", "target")
+	cmd.Env = symbols
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "paths") || !strings.Contains(string(output), "a/b/c/d/e/f/g/h/i/j") {
+		t.Errorf("expected the snip found with its joined path as context, got %q", output)
+	}
+}
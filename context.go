@@ -0,0 +1,213 @@
+package snip
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ContextWindow is a span of consecutive words of the data containing one or more matched terms
+type ContextWindow struct {
+	Start int      `json:"start"` // word position of the first word, counting from one
+	End   int      `json:"end"`   // word position of the last word
+	Words []string `json:"words"`
+	Terms []int    `json:"terms"` // indexes within Words of each matched term, in order
+}
+
+// ContextWindows returns a window for each context. With mergeOverlap, contexts whose windows overlap or adjoin are
+// combined into a single window marking each of their terms, and windows are ordered by position.
+func ContextWindows(contexts []TermContext, mergeOverlap bool) []ContextWindow {
+	var windows []ContextWindow
+	for _, ctx := range contexts {
+		w := ContextWindow{
+			Start: ctx.BeforeStart,
+			End:   ctx.AfterEnd,
+			Words: append(append(append([]string{}, ctx.Before...), ctx.Term), ctx.After...),
+			Terms: []int{len(ctx.Before)},
+		}
+		windows = append(windows, w)
+	}
+	if !mergeOverlap || len(windows) < 2 {
+		return windows
+	}
+
+	sort.SliceStable(windows, func(i int, j int) bool {
+		return windows[i].Start < windows[j].Start
+	})
+	merged := []ContextWindow{windows[0]}
+	for _, w := range windows[1:] {
+		last := &merged[len(merged)-1]
+		if w.Start > last.End+1 {
+			merged = append(merged, w)
+			continue
+		}
+		// words beyond the end of the last window extend it
+		if w.End > last.End {
+			last.Words = append(last.Words, w.Words[last.End-w.Start+1:]...)
+			last.End = w.End
+		}
+		for _, t := range w.Terms {
+			last.Terms = appendTerm(last.Terms, w.Start-last.Start+t)
+		}
+	}
+	return merged
+}
+
+// appendTerm inserts the index into the ordered terms unless already present, as when terms share a word
+func appendTerm(terms []int, index int) []int {
+	i := sort.SearchInts(terms, index)
+	if i < len(terms) && terms[i] == index {
+		return terms
+	}
+	terms = append(terms, 0)
+	copy(terms[i+1:], terms[i:])
+	terms[i] = index
+	return terms
+}
+
+// GatherDataContext returns the surrounding words of each occurrence of term in the data, matched as a substring
+// ignoring ASCII case like SearchDataTerm. Unlike GatherContext it reads the data rather than the index, so it finds
+// terms that are not indexed, such as numbers or text containing punctuation. The Term of each context is the
+// whitespace delimited text containing the match, which counts as a single word position even when it spans several
+// words.
+func (s *Snip) GatherDataContext(term string, adjacent int) ([]TermContext, error) {
+	var ctxAll []TermContext
+	if adjacent < 0 {
+		return ctxAll, fmt.Errorf("number of adjacent words must not be negative")
+	}
+	if term == "" {
+		return ctxAll, fmt.Errorf("refusing to search for empty string")
+	}
+
+	// byte offsets of each word in the data
+	type wordSpan struct {
+		word  string
+		start int
+		end   int
+	}
+	var words []wordSpan
+	offset := 0
+	for _, segment := range wordSegments(s.Data) {
+		if IsWord(segment) {
+			words = append(words, wordSpan{word: segment, start: offset, end: offset + len(segment)})
+		}
+		offset += len(segment)
+	}
+
+	// ascii case is folded without changing byte offsets
+	data := asciiLower(s.Data)
+	term = asciiLower(term)
+	for from := 0; from < len(data); {
+		idx := strings.Index(data[from:], term)
+		if idx < 0 {
+			break
+		}
+		// the match is shown within the whitespace delimited text containing it, such as v1.4.2 for 1.4
+		termStart, termEnd := from+idx, from+idx+len(term)
+		for termStart > 0 {
+			r, size := utf8.DecodeLastRuneInString(s.Data[:termStart])
+			if unicode.IsSpace(r) {
+				break
+			}
+			termStart -= size
+		}
+		for termEnd < len(s.Data) {
+			r, size := utf8.DecodeRuneInString(s.Data[termEnd:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			termEnd += size
+		}
+		// words within the term, of which there are none when it contains only punctuation
+		first := sort.Search(len(words), func(i int) bool { return words[i].end > termStart })
+		last := sort.Search(len(words), func(i int) bool { return words[i].start >= termEnd }) - 1
+
+		var ctx TermContext
+		start := first - adjacent
+		if start < 0 {
+			start = 0
+		}
+		ctx.BeforeStart = start + 1 // add one to reflect word count, not element index
+		for _, w := range words[start:first] {
+			ctx.Before = append(ctx.Before, w.word)
+		}
+		ctx.Term = s.Data[termStart:termEnd]
+		end := last + 1 + adjacent
+		if end > len(words) {
+			end = len(words)
+		}
+		for _, w := range words[last+1 : end] {
+			ctx.After = append(ctx.After, w.word)
+		}
+		ctx.AfterEnd = ctx.BeforeStart + len(ctx.Before) + len(ctx.After)
+		ctxAll = append(ctxAll, ctx)
+
+		// further matches within the same text would repeat the context
+		from = termEnd
+	}
+	return ctxAll, nil
+}
+
+// MatchTermLines is a wrapper around Store.MatchTermLines using the default store
+func (s *Snip) MatchTermLines(terms []string) ([]LineMatch, error) {
+	return defaultStore().MatchTermLines(s, terms)
+}
+
+// MatchTermLines returns each line of the data of s containing a word that indexes to the same term as one of terms,
+// so that running matches a line containing run when the snip is stemmed. The Matches of each line are the words as
+// they appear in the data, in order.
+func (st *Store) MatchTermLines(s *Snip, terms []string) ([]LineMatch, error) {
+	var matches []LineMatch
+	stemmed, err := st.Stemmed(s)
+	if err != nil {
+		return matches, err
+	}
+	// words are reduced as analyzeTerms reduces them for the index
+	reduce := func(word string) (string, error) {
+		word = strings.ToLower(cleanTerm(word))
+		if !stemmed {
+			return word, nil
+		}
+		return stemTerm(word)
+	}
+	wanted := make(map[string]bool)
+	for _, term := range terms {
+		reduced, err := reduce(term)
+		if err != nil {
+			return matches, err
+		}
+		if reduced != "" {
+			wanted[reduced] = true
+		}
+	}
+
+	for idx, line := range SplitLines(s.Data) {
+		var words []string
+		for _, word := range SplitWords(line) {
+			reduced, err := reduce(word)
+			if err != nil {
+				return matches, err
+			}
+			if reduced != "" && wanted[reduced] {
+				words = append(words, word)
+			}
+		}
+		if len(words) > 0 {
+			matches = append(matches, LineMatch{Line: idx + 1, Text: line, Matches: words})
+		}
+	}
+	return matches, nil
+}
+
+// asciiLower returns s with ASCII letters lowercased, leaving all other bytes, even invalid UTF-8, and offsets unchanged
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,54 @@
+package snip
+
+// Counts holds the number of lines, words, and bytes of snip data, in the order printed by wc
+type Counts struct {
+	Lines int
+	Words int
+	Bytes int
+}
+
+// Counts returns the line, word, and byte counts of the snip data
+func (s *Snip) Counts() Counts {
+	return Counts{Lines: s.CountLines(), Words: s.CountWords(), Bytes: s.CountBytes()}
+}
+
+// Add adds the counts of other to c
+func (c *Counts) Add(other Counts) {
+	c.Lines += other.Lines
+	c.Words += other.Words
+	c.Bytes += other.Bytes
+}
+
+// CountAll is a wrapper around Store.CountAll using the default store
+func CountAll() (Counts, int, error) {
+	return defaultStore().CountAll()
+}
+
+// CountAll returns the sum of the counts of all snips and the number of snips counted. Snips are read one at a time,
+// so the whole database is never held in memory.
+func (st *Store) CountAll() (Counts, int, error) {
+	var total Counts
+	stmt, err := st.Conn.Prepare(`SELECT uuid, timestamp, name, data, favorite FROM snip`)
+	if err != nil {
+		return total, 0, err
+	}
+	defer stmt.Close()
+
+	counted := 0
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return total, counted, err
+		}
+		if !hasRow {
+			break
+		}
+		s, err := scanSnip(stmt)
+		if err != nil {
+			return total, counted, err
+		}
+		total.Add(s.Counts())
+		counted++
+	}
+	return total, counted, nil
+}
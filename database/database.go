@@ -1,9 +1,239 @@
 package database
 
 import (
+	"errors"
+	"fmt"
 	"github.com/bvinc/go-sqlite-lite/sqlite3"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
 )
 
 var (
 	Conn *sqlite3.Conn
+	// ReadOnly indicates that Conn was opened read-only and must not be modified
+	ReadOnly bool
 )
+
+// BusyTimeout is how long a statement waits for a lock held by another connection
+var BusyTimeout = 5 * time.Second
+
+// RetryAttempts is the number of times Retry attempts a write while the database remains busy or locked
+var RetryAttempts = 5
+
+// RetryDelay is how long Retry waits before the second attempt, doubling before each further attempt
+var RetryDelay = 50 * time.Millisecond
+
+const (
+	backupAttempts   = 50
+	backupRetryDelay = 100 * time.Millisecond
+)
+
+// Configure applies connection settings for concurrent access, and should be called after opening Conn
+func Configure() error {
+	Conn.BusyTimeout(BusyTimeout)
+
+	// write-ahead logging allows readers to proceed while another process writes, but changing it requires a write
+	if !ReadOnly {
+		err := Conn.Exec(`PRAGMA journal_mode=WAL`)
+		if err != nil {
+			return err
+		}
+	}
+	err := Conn.Exec(`PRAGMA foreign_keys=ON`)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// ErrBackupIsDatabase is returned when the backup destination is the open database itself
+var ErrBackupIsDatabase = errors.New("backup destination is the open database")
+
+// BackupDatabase writes a consistent copy of the open database to destPath using the online backup API. The copy is
+// written to a temporary file in the same directory and renamed over destPath once complete, so an existing file at
+// destPath is only replaced by a finished backup.
+func BackupDatabase(destPath string) error {
+	same, err := isOpenDatabase(destPath)
+	if err != nil {
+		return err
+	}
+	if same {
+		return ErrBackupIsDatabase
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(destPath), "."+filepath.Base(destPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := backupTo(tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// isOpenDatabase reports whether p is the file of the main database of Conn
+func isOpenDatabase(p string) (bool, error) {
+	destInfo, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	stmt, err := Conn.Prepare(`SELECT file FROM pragma_database_list WHERE name = 'main'`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	hasRow, err := stmt.Step()
+	if err != nil || !hasRow {
+		return false, err
+	}
+	var file string
+	if err := stmt.Scan(&file); err != nil {
+		return false, err
+	}
+	// in-memory and temporary databases have no file
+	if file == "" {
+		return false, nil
+	}
+	dbInfo, err := os.Stat(file)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(destInfo, dbInfo), nil
+}
+
+// backupTo copies all pages of the open database to the database at destPath
+func backupTo(destPath string) error {
+	dst, err := sqlite3.Open(destPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	b, err := Conn.Backup("main", dst, "main")
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	for attempt := 1; ; attempt++ {
+		// copy all pages at once so the result reflects a single point in time
+		err = b.Step(-1)
+		if err == io.EOF {
+			break
+		}
+		if IsBusy(err) && attempt < backupAttempts {
+			// another connection is writing, try again shortly
+			time.Sleep(backupRetryDelay)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := b.Close(); err != nil {
+		return err
+	}
+	return dst.Close()
+}
+
+// IsBusy reports whether err indicates that the database is busy or locked by another connection
+func IsBusy(err error) bool {
+	var sqliteErr *sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	// extended result codes carry the primary code in the lower byte
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.BUSY || code == sqlite3.LOCKED
+}
+
+// Retry calls fn until it returns an error other than busy or locked, waiting between attempts with exponential
+// backoff from RetryDelay. The last error is returned after RetryAttempts attempts. Bursts of writes by other
+// connections can outlast BusyTimeout, after which a statement fails even though the lock is soon released.
+func Retry(fn func() error) error {
+	delay := RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !IsBusy(err) || attempt >= RetryAttempts {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// ExecStmt executes stmt on conn with args using Retry. The statement is reset after each failed attempt, so it can
+// run again. Within a transaction the statement is executed once, as only rerunning the whole transaction with WithTx
+// can resolve a busy error there.
+func ExecStmt(conn *sqlite3.Conn, stmt *sqlite3.Stmt, args ...interface{}) error {
+	if !conn.AutoCommit() {
+		return stmt.Exec(args...)
+	}
+	return Retry(func() error {
+		return stmt.Exec(args...)
+	})
+}
+
+// WithTx calls fn within a transaction on conn, committing if fn succeeds and rolling back if it fails. The write lock
+// is taken when the transaction begins, and if the database is busy or locked at any point the transaction is rolled
+// back and run again using Retry, so fn must be safe to call more than once. When conn already has a transaction open,
+// fn runs within a savepoint of it instead, so that a failure of fn undoes only its own changes, leaving the outermost
+// WithTx to commit or retry.
+func WithTx(conn *sqlite3.Conn, fn func() error) error {
+	if !conn.AutoCommit() {
+		return withSavepoint(conn, fn)
+	}
+	return Retry(func() error {
+		err := conn.Exec(`BEGIN IMMEDIATE`)
+		if err != nil {
+			return err
+		}
+		err = fn()
+		if err == nil {
+			err = conn.Exec(`COMMIT`)
+		}
+		// a failed commit may leave the transaction open
+		if err != nil && !conn.AutoCommit() {
+			if rollbackErr := conn.Exec(`ROLLBACK`); rollbackErr != nil {
+				return fmt.Errorf("%w, additionally rolling back transaction failed: %v", err, rollbackErr)
+			}
+		}
+		return err
+	})
+}
+
+// withSavepoint calls fn within a savepoint of the open transaction of conn, releasing it if fn succeeds and rolling
+// back to it if fn fails
+func withSavepoint(conn *sqlite3.Conn, fn func() error) error {
+	err := conn.Exec(`SAVEPOINT snip_tx`)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if err != nil {
+		if rollbackErr := conn.Exec(`ROLLBACK TO snip_tx`); rollbackErr != nil {
+			return fmt.Errorf("%w, additionally rolling back savepoint failed: %v", err, rollbackErr)
+		}
+	}
+	// releasing after rolling back closes the savepoint, leaving the transaction open
+	if releaseErr := conn.Exec(`RELEASE snip_tx`); releaseErr != nil && err == nil {
+		return releaseErr
+	}
+	return err
+}
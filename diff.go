@@ -0,0 +1,158 @@
+package snip
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffContextLines is the number of unchanged lines shown around each change in a diff
+const DiffContextLines = 3
+
+// diffOp is a single line of an edit script, where kind is one of ' ', '-', or '+'
+type diffOp struct {
+	kind byte
+	line string
+	a    int // index of the line in a, valid for ' ' and '-'
+	b    int // index of the line in b, valid for ' ' and '+'
+}
+
+// DiffSnips returns a unified diff of the data of two snips, or an empty string if the data is identical
+func DiffSnips(a, b Snip) string {
+	return Diff(fmt.Sprintf("%s %s", a.UUID, a.Name), a.Data, fmt.Sprintf("%s %s", b.UUID, b.Name), b.Data)
+}
+
+// Diff returns a unified diff of the lines of aData and bData labeled with aLabel and bLabel, or an empty string if they are identical
+func Diff(aLabel string, aData string, bLabel string, bData string) string {
+	ops := diffLines(SplitLines(aData), SplitLines(bData))
+
+	var b strings.Builder
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		// extend the hunk until the unchanged lines between changes exceed twice the context
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*DiffContextLines {
+				break
+			}
+		}
+		first := start - DiffContextLines
+		if first < 0 {
+			first = 0
+		}
+		last := end + DiffContextLines
+		if last > len(ops) {
+			last = len(ops)
+		}
+
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", aLabel, bLabel)
+		}
+		writeHunk(&b, ops[first:last])
+		start = last
+	}
+	return b.String()
+}
+
+// writeHunk writes the header and lines of a hunk, where ranges follow the unified format of 1-based start and count
+func writeHunk(b *strings.Builder, ops []diffOp) {
+	aStart, aCount, bStart, bCount := -1, 0, -1, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			if aStart < 0 {
+				aStart = op.a
+			}
+			aCount++
+		}
+		if op.kind != '-' {
+			if bStart < 0 {
+				bStart = op.b
+			}
+			bCount++
+		}
+	}
+	// an empty range starts at the line preceding it
+	if aStart < 0 {
+		aStart = ops[0].a - 1
+	}
+	if bStart < 0 {
+		bStart = ops[0].b - 1
+	}
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(aStart+1, aCount), hunkRange(bStart+1, bCount))
+	for _, op := range ops {
+		fmt.Fprintf(b, "%c%s\n", op.kind, op.line)
+	}
+}
+
+// hunkRange formats a range of a hunk header, omitting a count of one
+func hunkRange(start int, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// diffLines returns an edit script transforming a into b using the longest common subsequence of lines
+func diffLines(a []string, b []string) []diffOp {
+	// common leading and trailing lines are excluded from the quadratic comparison
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+
+	// lcs[i][j] is the length of the longest common subsequence of midA[i:] and midB[j:]
+	lcs := make([][]int, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{kind: ' ', line: a[i], a: i, b: i})
+	}
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			ops = append(ops, diffOp{kind: ' ', line: midA[i], a: prefix + i, b: prefix + j})
+			i++
+			j++
+		case j == len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', line: midA[i], a: prefix + i, b: prefix + j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', line: midB[j], a: prefix + i, b: prefix + j})
+			j++
+		}
+	}
+	for k := 0; k < suffix; k++ {
+		ai := len(a) - suffix + k
+		bi := len(b) - suffix + k
+		ops = append(ops, diffOp{kind: ' ', line: a[ai], a: ai, b: bi})
+	}
+	return ops
+}
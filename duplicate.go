@@ -0,0 +1,118 @@
+package snip
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+)
+
+// HashData returns the hex encoded SHA-256 checksum of data
+func HashData(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// FindDuplicateSnips is a wrapper around Store.FindDuplicateSnips using the default store
+func FindDuplicateSnips() (map[string][]uuid.UUID, error) {
+	return defaultStore().FindDuplicateSnips()
+}
+
+// FindDuplicateSnips returns groups of snips with identical data keyed by content hash.
+// Each group is ordered from oldest to newest and only groups with more than one snip are returned.
+func (st *Store) FindDuplicateSnips() (map[string][]uuid.UUID, error) {
+	type entry struct {
+		id        uuid.UUID
+		timestamp time.Time
+	}
+	groups := make(map[string][]entry)
+	duplicates := make(map[string][]uuid.UUID)
+
+	stmt, err := st.Conn.Prepare(`SELECT uuid, timestamp, data FROM snip`)
+	if err != nil {
+		return duplicates, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return duplicates, err
+		}
+		if !hasRow {
+			break
+		}
+
+		var idStr string
+		var timestampStr string
+		var data string
+		err = stmt.Scan(&idStr, &timestampStr, &data)
+		if err != nil {
+			return duplicates, err
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return duplicates, err
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, timestampStr)
+		if err != nil {
+			return duplicates, err
+		}
+		hash := HashData([]byte(data))
+		groups[hash] = append(groups[hash], entry{id: id, timestamp: timestamp})
+	}
+
+	for hash, entries := range groups {
+		if len(entries) < 2 {
+			continue
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].timestamp.Before(entries[j].timestamp)
+		})
+		for _, e := range entries {
+			duplicates[hash] = append(duplicates[hash], e.id)
+		}
+	}
+	return duplicates, nil
+}
+
+// FindDuplicateNames is a wrapper around Store.FindDuplicateNames using the default store
+func FindDuplicateNames() (map[string][]uuid.UUID, error) {
+	return defaultStore().FindDuplicateNames()
+}
+
+// FindDuplicateNames returns the uuids of snips sharing a name, keyed by name.
+// Each group is ordered from oldest to newest and only names used by more than one snip are returned.
+func (st *Store) FindDuplicateNames() (map[string][]uuid.UUID, error) {
+	duplicates := make(map[string][]uuid.UUID)
+
+	stmt, err := st.Conn.Prepare(`SELECT name, uuid FROM snip WHERE name IN (SELECT name FROM snip GROUP BY name HAVING count() > 1) ORDER BY name, timestamp`)
+	if err != nil {
+		return duplicates, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return duplicates, err
+		}
+		if !hasRow {
+			break
+		}
+
+		var name string
+		var idStr string
+		err = stmt.Scan(&name, &idStr)
+		if err != nil {
+			return duplicates, err
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return duplicates, err
+		}
+		duplicates[name] = append(duplicates[name], id)
+	}
+	return duplicates, nil
+}
@@ -0,0 +1,414 @@
+package snip
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/ryanfrishkorn/snip/database"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExportVersion is the format version written to exported documents
+const ExportVersion = 1
+
+// Export represents a portable document containing all snips and attachments
+type Export struct {
+	Version   int       `json:"version"`
+	Timestamp time.Time `json:"timestamp"`
+	Snips     []Snip    `json:"snips"`
+}
+
+// ExportAll is a wrapper around Store.ExportAll using the default store
+func ExportAll(w io.Writer) error {
+	return defaultStore().ExportAll(w)
+}
+
+// ExportAll writes every snip with its attachments and metadata to w as a single JSON document
+func (st *Store) ExportAll(w io.Writer) error {
+	doc := Export{
+		Version:   ExportVersion,
+		Timestamp: time.Now(),
+	}
+
+	ids, err := st.GetAllSnipIDs()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s, err := st.GetFromUUID(id.String())
+		if err != nil {
+			return err
+		}
+		s.Metadata, err = st.Meta(&s)
+		if err != nil {
+			return err
+		}
+		doc.Snips = append(doc.Snips, s)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// ExportSnip is a wrapper around Store.ExportSnip using the default store
+func ExportSnip(id uuid.UUID, dir string) error {
+	return defaultStore().ExportSnip(id, dir)
+}
+
+// ExportSnip writes the data of the snip to <name>.txt in dir, and each attachment to dir using its stored name.
+// The directory is created if it does not exist. Existing files are never overwritten; a counter is appended to the
+// name instead.
+func (st *Store) ExportSnip(id uuid.UUID, dir string) error {
+	// attachments are streamed to their files rather than read with the snip
+	s, err := st.getFromUUID(id.String())
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	p, err := exportPath(dir, s.Name+".txt")
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(s.Data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	attachmentIDs, err := st.GetAttachmentsUUID(s.UUID)
+	if err != nil {
+		return err
+	}
+	for _, attachmentID := range attachmentIDs {
+		a, err := st.GetAttachmentMetadata(attachmentID)
+		if err != nil {
+			return err
+		}
+		p, err := exportPath(dir, a.Name)
+		if err != nil {
+			return err
+		}
+		_, err = st.WriteAttachment(a.UUID, p, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportPath returns a path in dir for a file named name that does not exist, appending a counter to the name before
+// its extension if it does. Path separators within name are replaced, and names that would refer outside of dir, such
+// as "..", are refused.
+func exportPath(dir string, name string) (string, error) {
+	name = strings.NewReplacer("/", "_", `\`, "_").Replace(name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 0; ; n++ {
+		candidate := name
+		if n > 0 {
+			candidate = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		p, err := dirPath(dir, candidate)
+		if err != nil {
+			return "", err
+		}
+		_, err = os.Lstat(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			return p, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// dirPath returns the path of a file named name in dir. Path separators within name are replaced, and names that would
+// refer outside of dir, such as "..", are refused.
+func dirPath(dir string, name string) (string, error) {
+	name = strings.NewReplacer("/", "_", `\`, "_").Replace(name)
+	p := filepath.Join(dir, name)
+	if filepath.Dir(p) != filepath.Clean(dir) || filepath.Base(p) != name {
+		return "", fmt.Errorf("refusing to write file %q outside of directory %s", name, dir)
+	}
+	return p, nil
+}
+
+// WriteAllAttachments is a wrapper around Store.WriteAllAttachments using the default store
+func WriteAllAttachments(snipID uuid.UUID, dir string, force bool) (int, error) {
+	return defaultStore().WriteAllAttachments(snipID, dir, force)
+}
+
+// WriteAllAttachments writes each attachment of the snip to dir using its stored name, returning the number written.
+// It behaves like WriteAllAttachmentsReport without reporting each file.
+func (st *Store) WriteAllAttachments(snipID uuid.UUID, dir string, force bool) (int, error) {
+	return st.WriteAllAttachmentsReport(snipID, dir, force, nil)
+}
+
+// WriteAllAttachmentsReport is a wrapper around Store.WriteAllAttachmentsReport using the default store
+func WriteAllAttachmentsReport(snipID uuid.UUID, dir string, force bool, report func(a Attachment, path string, bytesWritten int, err error)) (int, error) {
+	return defaultStore().WriteAllAttachmentsReport(snipID, dir, force, report)
+}
+
+// WriteAllAttachmentsReport writes each attachment of the snip to dir using its stored name, creating dir if needed,
+// and returns the number written. As with WriteAttachment, existing files are not overwritten unless force is set.
+// Attachments that cannot be written do not stop the others, but an error is returned after all are attempted. If
+// report is not nil, it is called with the result of each attachment.
+func (st *Store) WriteAllAttachmentsReport(snipID uuid.UUID, dir string, force bool, report func(a Attachment, path string, bytesWritten int, err error)) (int, error) {
+	exists, err := st.SnipExists(snipID)
+	if err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, fmt.Errorf("snip %s does not exist", snipID)
+	}
+	ids, err := st.GetAttachmentsUUID(snipID)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	failed := 0
+	// attachments sharing a name would otherwise overwrite each other when forced
+	paths := make(map[string]bool)
+	for _, id := range ids {
+		a, err := st.GetAttachmentMetadata(id)
+		if err != nil {
+			return written, err
+		}
+		p, err := dirPath(dir, a.Name)
+		bytesWritten := 0
+		if err == nil && paths[p] {
+			err = fmt.Errorf("another attachment of the snip has the same name")
+		}
+		if err == nil {
+			paths[p] = true
+			bytesWritten, err = st.WriteAttachment(a.UUID, p, force)
+		}
+		if err != nil {
+			log.Debug().Err(err).Str("uuid", a.UUID.String()).Str("path", p).Msg("error writing attachment")
+			failed++
+		} else {
+			written++
+		}
+		if report != nil {
+			report(a, p, bytesWritten, err)
+		}
+	}
+	if failed > 0 {
+		return written, fmt.Errorf("%d of %d attachments could not be written", failed, len(ids))
+	}
+	return written, nil
+}
+
+// ImportAll is a wrapper around Store.ImportAll using the default store
+func ImportAll(r io.Reader) (int, error) {
+	return defaultStore().ImportAll(r)
+}
+
+// ImportAll reads a document produced by ExportAll and inserts the snips it contains.
+// Snips already present in the database are skipped. The number of imported snips is returned.
+func (st *Store) ImportAll(r io.Reader) (int, error) {
+	if err := st.checkWritable(); err != nil {
+		return 0, err
+	}
+	var doc Export
+	err := json.NewDecoder(r).Decode(&doc)
+	if err != nil {
+		return 0, err
+	}
+	if doc.Version != ExportVersion {
+		return 0, fmt.Errorf("unsupported export version %d", doc.Version)
+	}
+
+	imported := 0
+	err = database.WithTx(st.Conn, func() error {
+		imported = 0
+		for _, s := range doc.Snips {
+			ok, err := st.importSnip(s)
+			if err != nil {
+				return err
+			}
+			if ok {
+				imported++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return imported, nil
+}
+
+// importSnip inserts the snip with its attachments and metadata, indexing it unless the store has NoIndex set,
+// returning false if the snip already exists
+func (st *Store) importSnip(s Snip) (bool, error) {
+	exists, err := st.SnipExists(s.UUID)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		log.Debug().Str("uuid", s.UUID.String()).Msg("snip already exists, skipping import")
+		return false, nil
+	}
+
+	err = st.InsertSnip(s)
+	if err != nil {
+		return false, err
+	}
+	for _, a := range s.Attachments {
+		// associate with the imported snip regardless of document contents
+		a.SnipUUID = s.UUID
+		err = st.InsertAttachment(a)
+		if err != nil {
+			return false, err
+		}
+	}
+	for key, value := range s.Metadata {
+		err = st.SetMeta(&s, key, value)
+		if err != nil {
+			return false, err
+		}
+	}
+	if st.NoIndex {
+		return true, nil
+	}
+	err = st.Index(&s)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ImportBatchSize is the number of snips inserted within each transaction by ImportJSONL
+var ImportBatchSize = 1000
+
+// ExportJSONL is a wrapper around Store.ExportJSONL using the default store
+func ExportJSONL(ctx context.Context, w io.Writer) error {
+	return defaultStore().ExportJSONL(ctx, w)
+}
+
+// ExportJSONL writes each snip with its attachments and metadata to w as a JSON object on its own line. Snips are read
+// and written one at a time, so the size of the database is not limited by memory. Cancelling ctx stops the export
+// between snips, returning the error of ctx, so that every line written is a complete snip.
+func (st *Store) ExportJSONL(ctx context.Context, w io.Writer) error {
+	stmt, err := st.Conn.Prepare(`SELECT uuid, timestamp, name, data, favorite FROM snip`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	enc := json.NewEncoder(w)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return err
+		}
+		if !hasRow {
+			break
+		}
+		s, err := scanSnip(stmt)
+		if err != nil {
+			return err
+		}
+		s.Attachments, err = st.GetAttachments(s.UUID)
+		if err != nil {
+			return err
+		}
+		s.Metadata, err = st.Meta(&s)
+		if err != nil {
+			return err
+		}
+		err = enc.Encode(s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportJSONL is a wrapper around Store.ImportJSONL using the default store
+func ImportJSONL(r io.Reader) (int, error) {
+	return defaultStore().ImportJSONL(r)
+}
+
+// ImportJSONL reads snips written by ExportJSONL in batches of ImportBatchSize and inserts them, committing each batch.
+// Snips already present in the database are skipped. If an error occurs, snips in committed batches remain, and
+// their number is returned with the error.
+func (st *Store) ImportJSONL(r io.Reader) (int, error) {
+	if err := st.checkWritable(); err != nil {
+		return 0, err
+	}
+	dec := json.NewDecoder(r)
+	imported := 0
+	read := 0 // snips decoded, to locate errors
+	for done := false; !done; {
+		// the batch is decoded first, as the transaction is run again if the database is busy
+		var snips []Snip
+		first := read + 1
+		for len(snips) < ImportBatchSize {
+			var s Snip
+			err := dec.Decode(&s)
+			if err == io.EOF {
+				done = true
+				break
+			}
+			read++
+			if err != nil {
+				return imported, fmt.Errorf("snip %d: %w", read, err)
+			}
+			snips = append(snips, s)
+		}
+
+		batch := 0
+		err := database.WithTx(st.Conn, func() error {
+			batch = 0
+			for n, s := range snips {
+				ok, err := st.importSnip(s)
+				if err != nil {
+					return fmt.Errorf("snip %d %s: %w", first+n, s.UUID, err)
+				}
+				if ok {
+					batch++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return imported, err
+		}
+		imported += batch
+	}
+	return imported, nil
+}
@@ -0,0 +1,217 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"sort"
+	"unicode"
+)
+
+// DefaultFuzzyDistance is the default maximum edit distance of similar terms
+const DefaultFuzzyDistance = 2
+
+// FuzzyTermWeight is the scoring weight of a search term matched only by similar indexed terms, relative to an exact match
+var FuzzyTermWeight = 0.8
+
+// FindSimilarTerms is a wrapper around Store.FindSimilarTerms using the default store
+func FindSimilarTerms(stem string, maxDistance int) ([]string, error) {
+	return defaultStore().FindSimilarTerms(stem, maxDistance)
+}
+
+// FindSimilarTerms returns distinct indexed terms within maxDistance edits of stem, excluding stem itself, closest first
+func (st *Store) FindSimilarTerms(stem string, maxDistance int) ([]string, error) {
+	var similar []string
+	distances := make(map[string]int)
+
+	stmt, err := st.Conn.Prepare(`SELECT DISTINCT term FROM snip_index`)
+	if err != nil {
+		return similar, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return similar, err
+		}
+		if !hasRow {
+			break
+		}
+		var term string
+		err = stmt.Scan(&term)
+		if err != nil {
+			return similar, err
+		}
+		if term == stem {
+			continue
+		}
+		d := levenshtein(stem, term)
+		if d <= maxDistance {
+			similar = append(similar, term)
+			distances[term] = d
+		}
+	}
+
+	sort.Slice(similar, func(i, j int) bool {
+		if distances[similar[i]] == distances[similar[j]] {
+			return similar[i] < similar[j]
+		}
+		return distances[similar[i]] < distances[similar[j]]
+	})
+	return similar, nil
+}
+
+// SearchIndexTermFuzzy is a wrapper around Store.SearchIndexTermFuzzy using the default store
+func SearchIndexTermFuzzy(terms []string, requireAll bool, maxDistance int) (map[uuid.UUID][]SearchCount, error) {
+	return defaultStore().SearchIndexTermFuzzy(terms, requireAll, maxDistance)
+}
+
+// SearchIndexTermFuzzy behaves like SearchIndexTerm, but a term whose stem has no exact match in the index
+// is replaced by indexed terms within maxDistance edits of the stem
+func (st *Store) SearchIndexTermFuzzy(terms []string, requireAll bool, maxDistance int) (map[uuid.UUID][]SearchCount, error) {
+	var searchResults = make(map[uuid.UUID][]SearchCount, 0)
+
+	if len(terms) <= 0 {
+		return searchResults, fmt.Errorf("refusing to search for empty string")
+	}
+	err := st.checkIndexLanguage()
+	if err != nil {
+		return searchResults, err
+	}
+	err = st.checkIndexWordSymbols()
+	if err != nil {
+		return searchResults, err
+	}
+
+	for _, term := range terms {
+		term = cleanTerm(term)
+		termStemmed, err := stemTerm(term)
+		if err != nil {
+			return searchResults, err
+		}
+		matches, err := st.searchIndexExact(searchResults, term, termStemmed)
+		if err != nil {
+			return searchResults, err
+		}
+		if matches > 0 {
+			continue
+		}
+
+		similar, err := st.FindSimilarTerms(termStemmed, maxDistance)
+		if err != nil {
+			return searchResults, err
+		}
+		log.Debug().Str("termStemmed", termStemmed).Strs("similar", similar).Msg("expanding term to similar terms")
+		for _, stem := range similar {
+			_, err = st.searchIndexStem(searchResults, term, stem, levenshtein(termStemmed, stem))
+			if err != nil {
+				return searchResults, err
+			}
+		}
+	}
+
+	if requireAll {
+		return pruneIncomplete(searchResults, terms), nil
+	}
+
+	return searchResults, nil
+}
+
+// levenshtein returns the number of single character insertions, deletions, or substitutions required to change a into b
+func levenshtein(a string, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	// only the previous row of the distance matrix is required
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j] + 1
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+			if prev[j-1]+cost < curr[j] {
+				curr[j] = prev[j-1] + cost
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// FuzzyMatch returns the indexes of the candidates containing the runes of query in order, ignoring case, such as
+// wpwr for Wikipedia - Wren. Candidates are ordered by the shortest span containing the match, then by where the span
+// begins, then by their order in candidates. An empty query matches every candidate.
+func FuzzyMatch(query string, candidates []string) []int {
+	q := []rune(query)
+	for i, r := range q {
+		q[i] = unicode.ToLower(r)
+	}
+
+	type match struct {
+		index int
+		start int
+		span  int
+	}
+	var matches []match
+	for i, c := range candidates {
+		start, span, ok := fuzzySpan(q, []rune(c))
+		if ok {
+			matches = append(matches, match{index: i, start: start, span: span})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].span != matches[j].span {
+			return matches[i].span < matches[j].span
+		}
+		return matches[i].start < matches[j].start
+	})
+
+	indexes := make([]int, 0, len(matches))
+	for _, m := range matches {
+		indexes = append(indexes, m.index)
+	}
+	return indexes
+}
+
+// fuzzySpan returns the start and length of the shortest run of candidate containing the lowercase runes of query
+// in order, and whether there is one
+func fuzzySpan(query []rune, candidate []rune) (int, int, bool) {
+	if len(query) == 0 {
+		return 0, 0, true
+	}
+	bestStart, bestSpan := 0, 0
+	found := false
+	for start := range candidate {
+		if unicode.ToLower(candidate[start]) != query[0] {
+			continue
+		}
+		// matching each rune at its first occurrence gives the shortest span from this start
+		q := 1
+		end := start + 1
+		for ; end < len(candidate) && q < len(query); end++ {
+			if unicode.ToLower(candidate[end]) == query[q] {
+				q++
+			}
+		}
+		if q < len(query) {
+			// no later start can match all of the query either
+			break
+		}
+		if !found || end-start < bestSpan {
+			bestStart, bestSpan = start, end-start
+			found = true
+		}
+	}
+	return bestStart, bestSpan, found
+}
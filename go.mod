@@ -4,15 +4,16 @@ go 1.20
 
 require (
 	github.com/bvinc/go-sqlite-lite v0.6.1
+	github.com/fatih/color v1.15.0
 	github.com/google/uuid v1.3.0
 	github.com/kljensen/snowball v0.8.0
+	github.com/mattn/go-isatty v0.0.17
+	github.com/rivo/uniseg v0.4.4
 	github.com/rs/zerolog v1.29.1
+	golang.org/x/text v0.9.0
 )
 
 require (
-	github.com/fatih/color v1.15.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.17 // indirect
-	github.com/rivo/uniseg v0.4.4 // indirect
 	golang.org/x/sys v0.6.0 // indirect
 )
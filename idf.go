@@ -0,0 +1,150 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"math"
+	"strings"
+)
+
+// DocumentFrequency is a wrapper around Store.DocumentFrequency using the default store
+func DocumentFrequency(stem string) (int, error) {
+	return defaultStore().DocumentFrequency(stem)
+}
+
+// DocumentFrequency returns the number of indexed snips containing the stem
+func (st *Store) DocumentFrequency(stem string) (int, error) {
+	return st.countIndex(`SELECT count(DISTINCT uuid) FROM snip_index WHERE term = ?`, stem)
+}
+
+// termDocumentFrequency returns the number of snips containing term, matched as searchIndexExact does: by stem for
+// snips indexed with stemming, and by the lowercased term for snips indexed verbatim
+func (st *Store) termDocumentFrequency(term string, stem string) (int, error) {
+	return st.countIndex(`SELECT count(DISTINCT i.uuid) FROM snip_index i LEFT JOIN snip_index_meta m ON m.uuid = i.uuid
+		WHERE (i.term = ? AND coalesce(m.stemmed, 1) != 0) OR (i.term = ? AND m.stemmed = 0)`, stem, strings.ToLower(term))
+}
+
+// TotalDocuments is a wrapper around Store.TotalDocuments using the default store
+func TotalDocuments() (int, error) {
+	return defaultStore().TotalDocuments()
+}
+
+// TotalDocuments returns the number of indexed snips
+func (st *Store) TotalDocuments() (int, error) {
+	return st.countIndex(`SELECT count(DISTINCT uuid) FROM snip_index`)
+}
+
+// countIndex returns the single count selected by query
+func (st *Store) countIndex(query string, args ...interface{}) (int, error) {
+	var count int
+	stmt, err := st.Conn.Prepare(query, args...)
+	if err != nil {
+		return count, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return count, err
+	}
+	if !hasRow {
+		return count, fmt.Errorf("count returned zero rows")
+	}
+	err = stmt.Scan(&count)
+	return count, err
+}
+
+// IDF returns the inverse document frequency of a term found in documentFrequency of totalDocuments snips. It is
+// smoothed to stay positive, so that a term found in every snip still contributes a little.
+func IDF(documentFrequency int, totalDocuments int) float64 {
+	df := float64(documentFrequency)
+	return math.Log(1 + (float64(totalDocuments)-df+0.5)/(df+0.5))
+}
+
+// TermIDF is a wrapper around Store.TermIDF using the default store
+func TermIDF(terms []string) (map[string]float64, error) {
+	return defaultStore().TermIDF(terms)
+}
+
+// TermIDF returns the inverse document frequency of each search term, keyed by the cleaned term as reported in
+// SearchCount.Term. A snip contains the term when it is indexed by its stem, or by the lowercased term verbatim.
+func (st *Store) TermIDF(terms []string) (map[string]float64, error) {
+	idf := make(map[string]float64)
+	total, err := st.TotalDocuments()
+	if err != nil {
+		return idf, err
+	}
+	for _, term := range terms {
+		term = cleanTerm(term)
+		stem, err := stemTerm(term)
+		if err != nil {
+			return idf, err
+		}
+		df, err := st.termDocumentFrequency(term, stem)
+		if err != nil {
+			return idf, err
+		}
+		idf[term] = IDF(df, total)
+	}
+	return idf, nil
+}
+
+// ScoreCountsIDF is a wrapper around Store.ScoreCountsIDF using the default store
+func ScoreCountsIDF(id uuid.UUID, terms []string, counts []SearchCount, weights ScoreWeights, idf map[string]float64) (float64, error) {
+	return defaultStore().ScoreCountsIDF(id, terms, counts, weights, idf)
+}
+
+// ScoreCountsIDF returns a floating point score for search result validity like ScoreCountsWeighted, except that each
+// matched term contributes to coverage in proportion to its inverse document frequency from idf, as returned by
+// TermIDF. Rare terms then count for more than common ones. Terms missing from idf are weighted as 1.
+func (st *Store) ScoreCountsIDF(id uuid.UUID, terms []string, counts []SearchCount, weights ScoreWeights, idf map[string]float64) (float64, error) {
+	if weights.Coverage < 0 || weights.Prominence < 0 {
+		return 0, fmt.Errorf("score weights must not be negative")
+	}
+	totalWeight := weights.Coverage + weights.Prominence
+	if totalWeight == 0 {
+		return 0, fmt.Errorf("at least one score weight must be greater than zero")
+	}
+	termWeight := func(term string) float64 {
+		if w, ok := idf[term]; ok {
+			return w
+		}
+		return 1
+	}
+
+	var possible float64
+	for _, term := range terms {
+		possible += termWeight(cleanTerm(term))
+	}
+	var matched float64
+	fuzzyTerms := make(map[string]bool)
+	for _, c := range counts {
+		if c.Distance == 0 {
+			matched += termWeight(c.Term)
+			continue
+		}
+		// a term matched only by similar terms counts once at reduced weight
+		if !fuzzyTerms[c.Term] {
+			fuzzyTerms[c.Term] = true
+			matched += FuzzyTermWeight * termWeight(c.Term)
+		}
+	}
+	var matchTermsRatio float64
+	if possible != 0 {
+		matchTermsRatio = matched / possible
+	}
+
+	var matchProminence float64
+	indexedTerms, err := st.CumulativeTermsCount(id)
+	if err != nil {
+		return 0, err
+	}
+	if indexedTerms != 0 {
+		matchProminence = float64(len(terms)) / float64(indexedTerms)
+	}
+	log.Debug().Float64("matchTermsRatio", matchTermsRatio).Msg("scoring")
+	log.Debug().Float64("matchProminence", matchProminence).Msg("scoring")
+
+	return (weights.Coverage*matchTermsRatio + weights.Prominence*matchProminence) / totalWeight, nil
+}
@@ -0,0 +1,110 @@
+package snip
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNotText is returned when a file does not contain valid UTF-8 text
+var ErrNotText = errors.New("file does not contain valid UTF-8 text")
+
+// FindImportFiles returns the regular files within root, optionally descending into subdirectories.
+// When exts is not empty, only files with one of the listed extensions are returned.
+func FindImportFiles(root string, recursive bool, exts []string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if len(exts) > 0 && !hasExtension(p, exts) {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// hasExtension reports whether the file extension of p matches one of exts, ignoring case and leading dots
+func hasExtension(p string, exts []string) bool {
+	ext := strings.TrimPrefix(filepath.Ext(p), ".")
+	for _, e := range exts {
+		if strings.EqualFold(ext, strings.TrimPrefix(strings.TrimSpace(e), ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// NameFromFilename returns the base name of the file at p without its extension
+func NameFromFilename(p string) string {
+	base := filepath.Base(p)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	if name == "" {
+		// dotfiles consist entirely of an extension
+		return base
+	}
+	return name
+}
+
+// ImportFile is a wrapper around Store.ImportFile using the default store
+func ImportFile(p string) (Snip, error) {
+	return defaultStore().ImportFile(p)
+}
+
+// isText reports whether data is valid UTF-8 without null bytes, treating anything else as binary content
+func isText(data []byte) bool {
+	return utf8.Valid(data) && !strings.ContainsRune(string(data), 0)
+}
+
+// ImportFile creates, inserts, and indexes a snip named after the file with its contents as data. Indexing is skipped
+// when the store has NoIndex set. Files larger than MaxDataSize return a DataSizeError without being read.
+func (st *Store) ImportFile(p string) (Snip, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return Snip{}, err
+	}
+	if err := CheckDataSize(int(info.Size())); err != nil {
+		return Snip{}, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return Snip{}, err
+	}
+	if !isText(data) {
+		return Snip{}, ErrNotText
+	}
+
+	s := New()
+	s.Name = NameFromFilename(p)
+	s.Data = string(data)
+
+	err = st.InsertSnip(s)
+	if err != nil {
+		return Snip{}, err
+	}
+	if st.NoIndex {
+		return s, nil
+	}
+	err = st.Index(&s)
+	if err != nil {
+		return Snip{}, err
+	}
+	return s, nil
+}
@@ -0,0 +1,109 @@
+package snip
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultLanguage is the default stemming language
+const DefaultLanguage = "english"
+
+// Languages are the stemming languages supported
+var Languages = []string{"english", "french", "hungarian", "norwegian", "russian", "spanish", "swedish"}
+
+// UnavailableLanguages are languages that may be expected but have no stemmer, with the reason given to the user
+var UnavailableLanguages = map[string]string{
+	"german": "the snowball stemmer has no german stemmer",
+}
+
+// Language is the stemming language used to index and search, which must match the language the index was built with
+var Language = DefaultLanguage
+
+// indexLanguageKey is the snip_setting key recording the stemming language of the index
+const indexLanguageKey = "index_language"
+
+// IndexLanguageError indicates that the index was built with a stemming language other than Language
+type IndexLanguageError struct {
+	Index    string
+	Language string
+}
+
+func (e *IndexLanguageError) Error() string {
+	return fmt.Sprintf("index was built with %s stemming and must be rebuilt to use %s", e.Index, e.Language)
+}
+
+// CheckLanguage returns an error if lang is not a supported stemming language
+func CheckLanguage(lang string) error {
+	for _, l := range Languages {
+		if l == lang {
+			return nil
+		}
+	}
+	if reason, ok := UnavailableLanguages[lang]; ok {
+		return fmt.Errorf("unsupported language %s, %s, use one of: %s", lang, reason, strings.Join(Languages, ", "))
+	}
+	return fmt.Errorf("unsupported language %s, use one of: %s", lang, strings.Join(Languages, ", "))
+}
+
+// IndexLanguage is a wrapper around Store.IndexLanguage using the default store
+func IndexLanguage() (string, error) {
+	return defaultStore().IndexLanguage()
+}
+
+// IndexLanguage returns the stemming language the index was built with, or an empty string if nothing is indexed.
+// Indexes built before the language was recorded use DefaultLanguage.
+func (st *Store) IndexLanguage() (string, error) {
+	var lang string
+	stmt, err := st.Conn.Prepare(`SELECT value FROM snip_setting WHERE key = ?`, indexLanguageKey)
+	if err != nil {
+		return lang, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return lang, err
+	}
+	if hasRow {
+		err = stmt.Scan(&lang)
+		return lang, err
+	}
+
+	empty, err := st.indexIsEmpty()
+	if err != nil || empty {
+		return lang, err
+	}
+	return DefaultLanguage, nil
+}
+
+// indexIsEmpty returns true if no terms are indexed
+func (st *Store) indexIsEmpty() (bool, error) {
+	stmt, err := st.Conn.Prepare(`SELECT 1 FROM snip_index LIMIT 1`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return false, err
+	}
+	return !hasRow, nil
+}
+
+// checkIndexLanguage returns an IndexLanguageError if the index was built with a language other than Language
+func (st *Store) checkIndexLanguage() error {
+	lang, err := st.IndexLanguage()
+	if err != nil {
+		return err
+	}
+	if lang != "" && lang != Language {
+		return &IndexLanguageError{Index: lang, Language: Language}
+	}
+	return nil
+}
+
+// recordIndexLanguage stores Language as the language of the index, which must first be checked by checkIndexLanguage
+func (st *Store) recordIndexLanguage() error {
+	return st.Conn.Exec(`INSERT OR REPLACE INTO snip_setting (key, value) VALUES (?, ?)`, indexLanguageKey, Language)
+}
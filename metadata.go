@@ -0,0 +1,171 @@
+package snip
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"unicode"
+)
+
+// ErrMetaNotFound indicates that a snip has no metadata with the requested key
+var ErrMetaNotFound = errors.New("metadata key not found")
+
+// ValidateMetaKey returns an error if key is empty, or contains whitespace or =, which separates keys from values
+func ValidateMetaKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("metadata key must not be empty")
+	}
+	if strings.ContainsRune(key, '=') || strings.IndexFunc(key, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("metadata key %q must not contain whitespace or =", key)
+	}
+	return nil
+}
+
+// SetMeta is a wrapper around Store.SetMeta using the default store
+func (s *Snip) SetMeta(key string, value string) error {
+	return defaultStore().SetMeta(s, key, value)
+}
+
+// SetMeta sets the metadata key of the snip to value, replacing any previous value
+func (st *Store) SetMeta(s *Snip, key string, value string) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	if err := ValidateMetaKey(key); err != nil {
+		return err
+	}
+	exists, err := st.SnipExists(s.UUID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("could not locate snip %s", s.UUID)
+	}
+	return st.Conn.Exec(`INSERT OR REPLACE INTO snip_meta (snip_uuid, key, value) VALUES (?, ?, ?)`, s.UUID.String(), key, value)
+}
+
+// GetMeta is a wrapper around Store.GetMeta using the default store
+func (s *Snip) GetMeta(key string) (string, error) {
+	return defaultStore().GetMeta(s, key)
+}
+
+// GetMeta returns the value of the metadata key of the snip, or ErrMetaNotFound if it is not set
+func (st *Store) GetMeta(s *Snip, key string) (string, error) {
+	var value string
+	stmt, err := st.Conn.Prepare(`SELECT value FROM snip_meta WHERE snip_uuid = ? AND key = ?`, s.UUID.String(), key)
+	if err != nil {
+		return value, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return value, err
+	}
+	if !hasRow {
+		return value, ErrMetaNotFound
+	}
+	err = stmt.Scan(&value)
+	return value, err
+}
+
+// Meta is a wrapper around Store.Meta using the default store
+func (s *Snip) Meta() (map[string]string, error) {
+	return defaultStore().Meta(s)
+}
+
+// Meta returns every metadata key of the snip with its value
+func (st *Store) Meta(s *Snip) (map[string]string, error) {
+	meta := make(map[string]string)
+	stmt, err := st.Conn.Prepare(`SELECT key, value FROM snip_meta WHERE snip_uuid = ?`, s.UUID.String())
+	if err != nil {
+		return meta, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return meta, err
+		}
+		if !hasRow {
+			break
+		}
+		var key, value string
+		err = stmt.Scan(&key, &value)
+		if err != nil {
+			return meta, err
+		}
+		meta[key] = value
+	}
+	return meta, nil
+}
+
+// RemoveMeta is a wrapper around Store.RemoveMeta using the default store
+func (s *Snip) RemoveMeta(key string) error {
+	return defaultStore().RemoveMeta(s, key)
+}
+
+// RemoveMeta removes the metadata key from the snip, returning ErrMetaNotFound if it is not set
+func (st *Store) RemoveMeta(s *Snip, key string) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	err := st.Conn.Exec(`DELETE FROM snip_meta WHERE snip_uuid = ? AND key = ?`, s.UUID.String(), key)
+	if err != nil {
+		return err
+	}
+	if st.Conn.Changes() == 0 {
+		return ErrMetaNotFound
+	}
+	return nil
+}
+
+// SnipsWithMeta is a wrapper around Store.SnipsWithMeta using the default store
+func SnipsWithMeta(meta map[string]string) ([]uuid.UUID, error) {
+	return defaultStore().SnipsWithMeta(meta)
+}
+
+// SnipsWithMeta returns the ids of snips having every key of meta set to its value, ordered by uuid
+func (st *Store) SnipsWithMeta(meta map[string]string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	// each snip matching a pair is counted once, as keys are unique per snip
+	var conditions []string
+	var args []interface{}
+	for key, value := range meta {
+		conditions = append(conditions, `(key = ? AND value = ?)`)
+		args = append(args, key, value)
+	}
+	if len(conditions) == 0 {
+		return ids, fmt.Errorf("no metadata to match")
+	}
+	args = append(args, len(meta))
+	stmt, err := st.Conn.Prepare(`SELECT snip_uuid FROM snip_meta WHERE `+strings.Join(conditions, " OR ")+
+		` GROUP BY snip_uuid HAVING count() = ? ORDER BY snip_uuid`, args...)
+	if err != nil {
+		return ids, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return ids, err
+		}
+		if !hasRow {
+			break
+		}
+		var idStr string
+		err = stmt.Scan(&idStr)
+		if err != nil {
+			return ids, err
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
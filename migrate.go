@@ -0,0 +1,175 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/ryanfrishkorn/snip/database"
+	"time"
+)
+
+// Migration is a numbered change to the database schema. Migrations are applied in order of Version, each at most
+// once, and must succeed on databases created before versions were recorded, which may already contain the change.
+type Migration struct {
+	Version     int
+	Description string
+	Apply       func(st *Store) error
+}
+
+// Migrations are the changes that build the current schema, in order of Version. New changes to the schema must be
+// appended with the next version rather than editing a migration that has been released.
+var Migrations = []Migration{
+	{1, "create snip and attachment tables", func(st *Store) error {
+		err := st.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip(uuid TEXT, timestamp TEXT, name TEXT, data TEXT)`)
+		if err != nil {
+			return err
+		}
+		return st.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip_attachment(uuid TEXT, snip_uuid TEXT, timestamp TEXT, name TEXT, data BLOB, size INTEGER, mime TEXT)`)
+	}},
+	{2, "add attachment mime type", func(st *Store) error {
+		return st.addColumnIfMissing("snip_attachment", "mime", "TEXT")
+	}},
+	{3, "add snip accessed timestamp", func(st *Store) error {
+		return st.addColumnIfMissing("snip", "accessed", "TEXT")
+	}},
+	{4, "add snip favorite flag", func(st *Store) error {
+		return st.addColumnIfMissing("snip", "favorite", "INTEGER NOT NULL DEFAULT 0")
+	}},
+	{5, "create index tables", func(st *Store) error {
+		err := st.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip_index(term TEXT, uuid TEXT, count INTEGER, positions TEXT)`)
+		if err != nil {
+			return err
+		}
+		return st.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip_index_meta(uuid TEXT PRIMARY KEY, data_hash TEXT)`)
+	}},
+	{6, "add index stemmed flag", func(st *Store) error {
+		return st.addColumnIfMissing("snip_index_meta", "stemmed", "INTEGER NOT NULL DEFAULT 1")
+	}},
+	{7, "create revision table", func(st *Store) error {
+		return st.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip_revision(snip_uuid TEXT, revision INTEGER, timestamp TEXT, name TEXT, data TEXT, saved TEXT)`)
+	}},
+	{8, "create setting table", func(st *Store) error {
+		return st.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip_setting(key TEXT PRIMARY KEY, value TEXT)`)
+	}},
+	{9, "create snip meta table", func(st *Store) error {
+		return st.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip_meta(snip_uuid TEXT, key TEXT, value TEXT, PRIMARY KEY (snip_uuid, key))`)
+	}},
+	{10, "create trash tables", func(st *Store) error {
+		tables := []string{
+			`CREATE TABLE IF NOT EXISTS snip_trash(trash_id INTEGER PRIMARY KEY, uuid TEXT, timestamp TEXT, name TEXT, data TEXT, favorite INTEGER NOT NULL DEFAULT 0, accessed TEXT, stemmed INTEGER NOT NULL DEFAULT 1, trashed TEXT)`,
+			`CREATE TABLE IF NOT EXISTS snip_attachment_trash(trash_id INTEGER, uuid TEXT, snip_uuid TEXT, timestamp TEXT, name TEXT, data BLOB, size INTEGER, mime TEXT)`,
+			`CREATE TABLE IF NOT EXISTS snip_revision_trash(trash_id INTEGER, snip_uuid TEXT, revision INTEGER, timestamp TEXT, name TEXT, data TEXT, saved TEXT)`,
+			`CREATE TABLE IF NOT EXISTS snip_meta_trash(trash_id INTEGER, snip_uuid TEXT, key TEXT, value TEXT)`,
+		}
+		for _, table := range tables {
+			if err := st.Conn.Exec(table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+}
+
+// LatestSchemaVersion returns the version of the last migration, which Migrate brings a database up to
+func LatestSchemaVersion() int {
+	if len(Migrations) == 0 {
+		return 0
+	}
+	return Migrations[len(Migrations)-1].Version
+}
+
+// SchemaVersionError indicates that the database was migrated by a newer release than this one
+type SchemaVersionError struct {
+	Version   int
+	Supported int
+}
+
+func (e *SchemaVersionError) Error() string {
+	return fmt.Sprintf("database schema version %d is newer than the supported version %d", e.Version, e.Supported)
+}
+
+// SchemaVersion is a wrapper around Store.SchemaVersion using the default store
+func SchemaVersion() (int, error) {
+	return defaultStore().SchemaVersion()
+}
+
+// SchemaVersion returns the version of the last migration applied to the database, or zero if none are recorded
+func (st *Store) SchemaVersion() (int, error) {
+	var version int
+	stmt, err := st.Conn.Prepare(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`)
+	if err != nil {
+		return version, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil || !hasRow {
+		return version, err
+	}
+
+	stmt2, err := st.Conn.Prepare(`SELECT IFNULL(MAX(version), 0) FROM schema_version`)
+	if err != nil {
+		return version, err
+	}
+	defer stmt2.Close()
+
+	_, err = stmt2.Step()
+	if err != nil {
+		return version, err
+	}
+	err = stmt2.Scan(&version)
+	return version, err
+}
+
+// IsSnipDatabase reports whether the database has the snip table, which every release of snip creates
+func (st *Store) IsSnipDatabase() (bool, error) {
+	stmt, err := st.Conn.Prepare(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'snip'`)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	return stmt.Step()
+}
+
+// Migrate is a wrapper around Store.Migrate using the default store
+func Migrate() error {
+	return defaultStore().Migrate()
+}
+
+// Migrate applies the Migrations newer than the schema version of the database, recording each version in the
+// schema_version table within the same transaction as its change. A SchemaVersionError is returned if the database
+// is newer than the latest migration known.
+func (st *Store) Migrate() error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	err := st.Conn.Exec(`CREATE TABLE IF NOT EXISTS schema_version(version INTEGER PRIMARY KEY, description TEXT, applied TEXT)`)
+	if err != nil {
+		return err
+	}
+
+	current, err := st.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > LatestSchemaVersion() {
+		return &SchemaVersionError{Version: current, Supported: LatestSchemaVersion()}
+	}
+
+	for _, m := range Migrations {
+		if m.Version <= current {
+			continue
+		}
+		err = database.WithTx(st.Conn, func() error {
+			if err := m.Apply(st); err != nil {
+				return err
+			}
+			return st.Conn.Exec(`INSERT INTO schema_version (version, description, applied) VALUES (?, ?, ?)`,
+				m.Version, m.Description, time.Now().Format(time.RFC3339Nano))
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		log.Debug().Int("version", m.Version).Str("description", m.Description).Msg("applied migration")
+	}
+	return nil
+}
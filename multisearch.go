@@ -0,0 +1,73 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+)
+
+// SearchFieldWeights are the score weights of a match in each field searched by SearchMulti. A match in the name is a
+// stronger signal than one in the data.
+var SearchFieldWeights = map[string]float64{"data": 1, "name": 2, "uuid": 1}
+
+// SearchMulti is a wrapper around Store.SearchMulti using the default store
+func SearchMulti(term string, fields []string) ([]SearchScore, error) {
+	return defaultStore().SearchMulti(term, fields)
+}
+
+// SearchMulti searches each of the fields data, name, and uuid for term as SearchDataTerm, SearchNameTerm, and
+// SearchUUID do, returning each matching snip once. The score of a snip is the sum of SearchFieldWeights of the fields
+// it matched divided by the sum for all fields searched, so a snip matching every field scores 1. Results are ordered
+// by highest score, then by uuid.
+func (st *Store) SearchMulti(term string, fields []string) ([]SearchScore, error) {
+	var scores []SearchScore
+	if term == "" {
+		return scores, fmt.Errorf("refusing to search for empty string")
+	}
+	if len(fields) == 0 {
+		return scores, fmt.Errorf("no fields to search")
+	}
+
+	matched := make(map[uuid.UUID]float64)
+	searched := make(map[string]bool)
+	var total float64
+	for _, field := range fields {
+		weight, ok := SearchFieldWeights[field]
+		if !ok {
+			return scores, fmt.Errorf("unsupported search field %s, use data, name, or uuid", field)
+		}
+		if searched[field] {
+			continue
+		}
+		searched[field] = true
+		total += weight
+
+		var results []Snip
+		var err error
+		switch field {
+		case "data":
+			results, err = st.SearchDataTerm(term)
+		case "name":
+			results, err = st.SearchNameTerm(term)
+		case "uuid":
+			results, err = st.SearchUUID(term)
+		}
+		if err != nil {
+			return scores, err
+		}
+		for _, s := range results {
+			matched[s.UUID] += weight
+		}
+	}
+
+	for id, weight := range matched {
+		scores = append(scores, SearchScore{UUID: id, Score: weight / total})
+	}
+	sort.Slice(scores, func(i int, j int) bool {
+		if scores[i].Score == scores[j].Score {
+			return scores[i].UUID.String() < scores[j].UUID.String()
+		}
+		return scores[i].Score > scores[j].Score
+	})
+	return scores, nil
+}
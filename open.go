@@ -0,0 +1,72 @@
+package snip
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ErrNoOpener indicates that no tool for opening files with the default application was found
+var ErrNoOpener = errors.New("no opener found")
+
+// openCommand is an external tool that opens the file given as its final argument with the default application
+type openCommand struct {
+	Name  string
+	Args  []string
+	Waits bool // the tool exits only after the viewer is closed, rather than detaching from it
+}
+
+// openCommands lists candidate opener tools in order of preference for each platform
+var openCommands = map[string][]openCommand{
+	"darwin": {
+		{Name: "open", Args: []string{"-W"}, Waits: true},
+	},
+	"linux": {
+		{Name: "xdg-open"},
+	},
+	"windows": {
+		// the empty argument is the window title, which start would otherwise take from a quoted path
+		{Name: "cmd", Args: []string{"/c", "start", ""}},
+	},
+}
+
+// OpenWithDefault opens path with the default application of the platform, returning once the opener exits.
+// Some openers detach from the viewer and exit immediately, see OpenerWaits.
+func OpenWithDefault(path string) error {
+	return openWith(openCommands[runtime.GOOS], path)
+}
+
+// OpenerWaits reports whether the opener used by OpenWithDefault exits only after the viewer is closed.
+// When it does not, the file must remain in place for the viewer to read.
+func OpenerWaits() bool {
+	for _, c := range openCommands[runtime.GOOS] {
+		if _, err := exec.LookPath(c.Name); err == nil {
+			return c.Waits
+		}
+	}
+	return false
+}
+
+// openWith runs the first candidate command found in the path on the file
+func openWith(candidates []openCommand, path string) error {
+	var names []string
+	for _, c := range candidates {
+		names = append(names, c.Name)
+		toolPath, err := exec.LookPath(c.Name)
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(toolPath, append(append([]string{}, c.Args...), path)...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %v: %s", c.Name, err, strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%w, opening files is not supported on %s", ErrNoOpener, runtime.GOOS)
+	}
+	return fmt.Errorf("%w, looked for: %s", ErrNoOpener, strings.Join(names, ", "))
+}
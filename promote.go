@@ -0,0 +1,81 @@
+package snip
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ryanfrishkorn/snip/database"
+	"unicode/utf8"
+)
+
+// ErrNotUTF8 indicates that data is not valid UTF-8 text
+var ErrNotUTF8 = errors.New("data is not valid UTF-8")
+
+// PromoteAttachment is a wrapper around Store.PromoteAttachment using the default store
+func PromoteAttachment(id uuid.UUID, removeOriginal bool) (Snip, error) {
+	return defaultStore().PromoteAttachment(id, removeOriginal)
+}
+
+// PromoteAttachment creates and indexes a new snip named after the attachment, with its contents as data. With
+// removeOriginal the attachment is removed in the same transaction. Contents that are not valid UTF-8 are refused with
+// ErrNotUTF8, and contents larger than MaxDataSize with a DataSizeError.
+func (st *Store) PromoteAttachment(id uuid.UUID, removeOriginal bool) (Snip, error) {
+	return st.promoteAttachment(id, removeOriginal, false)
+}
+
+// PromoteAttachmentForce is a wrapper around Store.PromoteAttachmentForce using the default store
+func PromoteAttachmentForce(id uuid.UUID, removeOriginal bool) (Snip, error) {
+	return defaultStore().PromoteAttachmentForce(id, removeOriginal)
+}
+
+// PromoteAttachmentForce behaves like PromoteAttachment, but contents that are not valid UTF-8 are stored as they are
+func (st *Store) PromoteAttachmentForce(id uuid.UUID, removeOriginal bool) (Snip, error) {
+	return st.promoteAttachment(id, removeOriginal, true)
+}
+
+// promoteAttachment creates a snip from the attachment, refusing contents that are not valid UTF-8 unless forced
+func (st *Store) promoteAttachment(id uuid.UUID, removeOriginal bool, force bool) (Snip, error) {
+	if err := st.checkWritable(); err != nil {
+		return Snip{}, err
+	}
+	// the recorded size avoids reading large data that would be refused
+	a, err := st.GetAttachmentMetadata(id)
+	if err != nil {
+		return Snip{}, err
+	}
+	if err := CheckDataSize(a.Size); err != nil {
+		return Snip{}, err
+	}
+	a, err = st.GetAttachmentFromUUID(id.String())
+	if err != nil {
+		return Snip{}, err
+	}
+	if err := CheckDataSize(len(a.Data)); err != nil {
+		return Snip{}, err
+	}
+	if !force && !utf8.Valid(a.Data) {
+		return Snip{}, fmt.Errorf("attachment %s: %w", id, ErrNotUTF8)
+	}
+
+	s := New()
+	s.Name = a.Name
+	s.Data = string(a.Data)
+	err = database.WithTx(st.Conn, func() error {
+		err := st.InsertSnip(s)
+		if err != nil {
+			return err
+		}
+		err = st.Index(&s)
+		if err != nil {
+			return err
+		}
+		if removeOriginal {
+			return st.RemoveAttachment(a.UUID)
+		}
+		return nil
+	})
+	if err != nil {
+		return Snip{}, err
+	}
+	return s, nil
+}
@@ -0,0 +1,78 @@
+package snip
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// accessedLayout is a fixed width RFC3339 layout, so that accessed times stored in UTC sort as text
+const accessedLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// DefaultRecent is the default number of snips listed by Recent
+const DefaultRecent = 10
+
+// RecentSnip is a snip with the time it was last accessed
+type RecentSnip struct {
+	Snip
+	Accessed time.Time
+}
+
+// TouchSnip is a wrapper around Store.TouchSnip using the default store
+func TouchSnip(id uuid.UUID) error {
+	return defaultStore().TouchSnip(id)
+}
+
+// TouchSnip records the current time as the last access of the snip. It is not called by reads such as GetFromUUID,
+// which would otherwise all write to the database, so callers touch only snips a user has viewed.
+func (st *Store) TouchSnip(id uuid.UUID) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	return st.Conn.Exec(`UPDATE snip SET accessed = ? WHERE uuid = ?`, time.Now().UTC().Format(accessedLayout), id.String())
+}
+
+// Recent is a wrapper around Store.Recent using the default store
+func Recent(limit int) ([]RecentSnip, error) {
+	return defaultStore().Recent(limit)
+}
+
+// Recent returns up to limit snips ordered by most recently accessed, excluding snips never accessed.
+// A limit of 0 returns all accessed snips.
+func (st *Store) Recent(limit int) ([]RecentSnip, error) {
+	var results []RecentSnip
+	if limit == 0 {
+		limit = -1 // no limit in sqlite
+	}
+	stmt, err := st.Conn.Prepare(`SELECT uuid, accessed FROM snip WHERE accessed IS NOT NULL ORDER BY accessed DESC LIMIT ?`, limit)
+	if err != nil {
+		return results, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return results, err
+		}
+		if !hasRow {
+			break
+		}
+
+		var idStr string
+		var accessedStr string
+		err = stmt.Scan(&idStr, &accessedStr)
+		if err != nil {
+			return results, err
+		}
+		accessed, err := time.Parse(time.RFC3339Nano, accessedStr)
+		if err != nil {
+			return results, err
+		}
+		s, err := st.GetFromUUID(idStr)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, RecentSnip{Snip: s, Accessed: accessed})
+	}
+	return results, nil
+}
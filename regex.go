@@ -0,0 +1,112 @@
+package snip
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxRegexLength is the maximum length in bytes of a regular expression pattern. Go regular expressions run in time
+// linear to the input, so the pattern size is what bounds the cost of a search.
+const MaxRegexLength = 1024
+
+// LineMatch is a line of data containing matches of a regular expression
+type LineMatch struct {
+	Line    int      `json:"line"` // line number counting from one
+	Text    string   `json:"text"`
+	Matches []string `json:"matches"`
+}
+
+// CompileRegex compiles pattern, refusing empty patterns and those longer than MaxRegexLength
+func CompileRegex(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("refusing to search for empty pattern")
+	}
+	if len(pattern) > MaxRegexLength {
+		return nil, fmt.Errorf("pattern of %d bytes exceeds maximum of %d", len(pattern), MaxRegexLength)
+	}
+	return regexp.Compile(pattern)
+}
+
+// SearchDataRegex is a wrapper around Store.SearchDataRegex using the default store
+func SearchDataRegex(ctx context.Context, pattern string) ([]Snip, error) {
+	return defaultStore().SearchDataRegex(ctx, pattern)
+}
+
+// SearchDataRegex returns a slice of Snips whose data matches the regular expression pattern. Cancelling ctx stops the
+// search between snips, returning the error of ctx.
+func (st *Store) SearchDataRegex(ctx context.Context, pattern string) ([]Snip, error) {
+	var searchResult []Snip
+	re, err := CompileRegex(pattern)
+	if err != nil {
+		return searchResult, err
+	}
+
+	// sqlite has no regular expressions, so data is compared as rows are retrieved
+	stmt, err := st.Conn.Prepare(`SELECT uuid, data from snip`)
+	if err != nil {
+		return searchResult, err
+	}
+	defer stmt.Close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return searchResult, err
+		}
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return searchResult, err
+		}
+		if !hasRow {
+			break
+		}
+
+		var idStr string
+		var data string
+		err = stmt.Scan(&idStr, &data)
+		if err != nil {
+			return searchResult, err
+		}
+		if !re.MatchString(data) {
+			continue
+		}
+
+		s, err := st.GetFromUUID(idStr)
+		if err != nil {
+			return searchResult, err
+		}
+		searchResult = append(searchResult, s)
+	}
+
+	return searchResult, nil
+}
+
+// MatchLines returns each line of data on which a match of re begins, in order.
+// A match spanning lines is reported on its first line.
+func MatchLines(re *regexp.Regexp, data string) []LineMatch {
+	var matches []LineMatch
+	line := 1
+	lineStart := 0 // offset of the start of the current line
+	for _, loc := range re.FindAllStringIndex(data, -1) {
+		// advance to the line containing the start of the match
+		for {
+			next := strings.IndexByte(data[lineStart:], '\n')
+			if next < 0 || lineStart+next >= loc[0] {
+				break
+			}
+			lineStart += next + 1
+			line++
+		}
+		if len(matches) == 0 || matches[len(matches)-1].Line != line {
+			lineEnd := strings.IndexByte(data[lineStart:], '\n')
+			if lineEnd < 0 {
+				lineEnd = len(data) - lineStart
+			}
+			matches = append(matches, LineMatch{Line: line, Text: data[lineStart : lineStart+lineEnd]})
+		}
+		last := &matches[len(matches)-1]
+		last.Matches = append(last.Matches, data[loc[0]:loc[1]])
+	}
+	return matches
+}
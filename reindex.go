@@ -0,0 +1,222 @@
+package snip
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ryanfrishkorn/snip/database"
+	"runtime"
+	"sync"
+)
+
+// IndexWorkers is the number of worker goroutines ReindexAll uses to analyze snips
+var IndexWorkers = runtime.NumCPU()
+
+// indexJob is a snip to be analyzed, and whether its terms are stemmed
+type indexJob struct {
+	snip    Snip
+	stemmed bool
+}
+
+// indexResult is the outcome of analyzing the terms of a snip
+type indexResult struct {
+	indexJob
+	termsPositions map[string][]int
+	err            error
+}
+
+// ReindexAll is a wrapper around Store.ReindexAll using the default store
+func ReindexAll(ctx context.Context, progress func(done int, total int)) error {
+	return defaultStore().ReindexAll(ctx, progress)
+}
+
+// ReindexAll drops the search index and indexes every snip with IndexWorkers workers. If progress is not nil,
+// it is called after each snip is processed, allowing callers to render progress however they choose. If ctx is
+// cancelled, the snips indexed so far remain and the rest are indexed by an incremental IndexSnips.
+func (st *Store) ReindexAll(ctx context.Context, progress func(done int, total int)) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	ids, err := st.GetAllSnipIDs()
+	if err != nil {
+		return err
+	}
+	err = st.DropIndex()
+	if err != nil {
+		return err
+	}
+	_, err = st.IndexSnips(ctx, ids, IndexWorkers, false, progress)
+	return err
+}
+
+// UnindexedSnips is a wrapper around Store.UnindexedSnips using the default store
+func UnindexedSnips() ([]uuid.UUID, error) {
+	return defaultStore().UnindexedSnips()
+}
+
+// UnindexedSnips returns the ids of snips without any terms in the index, or whose index was dropped by DropIndex and
+// not yet rebuilt, ordered by name. Snips recorded as indexed without terms, such as those without words in their
+// data, are not included.
+func (st *Store) UnindexedSnips() ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	// DropIndex keeps the stemming mode of each snip, clearing only its hash
+	stmt, err := st.Conn.Prepare(`SELECT uuid FROM snip
+		WHERE uuid IN (SELECT uuid FROM snip_index_meta WHERE data_hash IS NULL)
+			OR (uuid NOT IN (SELECT uuid FROM snip_index) AND uuid NOT IN (SELECT uuid FROM snip_index_meta))
+		ORDER BY name, uuid`)
+	if err != nil {
+		return ids, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return ids, err
+		}
+		if !hasRow {
+			break
+		}
+		var idStr string
+		err = stmt.Scan(&idStr)
+		if err != nil {
+			return ids, err
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// IndexSnips is a wrapper around Store.IndexSnips using the default store
+func IndexSnips(ctx context.Context, ids []uuid.UUID, workers int, incremental bool, progress func(done int, total int)) (int, error) {
+	return defaultStore().IndexSnips(ctx, ids, workers, incremental, progress)
+}
+
+// IndexSnips reindexes the snips with the supplied ids, analyzing data with the number of worker goroutines specified.
+// Database access is serialized on the calling goroutine since the connection is shared. When incremental is true,
+// snips unchanged since last indexed are skipped. If progress is not nil, it is called after each snip is processed.
+// The number of snips indexed is returned. Cancelling ctx stops indexing between snips, returning the error of ctx,
+// and the index of each snip is either complete or unchanged.
+func (st *Store) IndexSnips(ctx context.Context, ids []uuid.UUID, workers int, incremental bool, progress func(done int, total int)) (int, error) {
+	if err := st.checkWritable(); err != nil {
+		return 0, err
+	}
+	if workers < 1 {
+		return 0, fmt.Errorf("number of workers must be at least 1")
+	}
+
+	jobs := make(chan indexJob)
+	results := make(chan indexResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				termsPositions, err := job.snip.analyzeTerms(job.stemmed)
+				results <- indexResult{indexJob: job, termsPositions: termsPositions, err: err}
+			}
+		}()
+	}
+	// stop releases the workers, discarding any results still in flight
+	stop := func() {
+		close(jobs)
+		go func() {
+			wg.Wait()
+			close(results)
+		}()
+		for range results {
+		}
+	}
+
+	var (
+		indexed  int
+		done     int
+		inFlight int
+		next     int
+		pending  *indexJob
+	)
+	for done < len(ids) {
+		if err := ctx.Err(); err != nil {
+			stop()
+			return indexed, err
+		}
+		// load the next snip that requires indexing
+		for pending == nil && next < len(ids) {
+			s, err := st.GetFromUUID(ids[next].String())
+			next++
+			if err != nil {
+				stop()
+				return indexed, err
+			}
+			if incremental {
+				current, err := st.IndexCurrent(&s)
+				if err != nil {
+					stop()
+					return indexed, err
+				}
+				if current {
+					done++
+					if progress != nil {
+						progress(done, len(ids))
+					}
+					continue
+				}
+			}
+			stemmed, err := st.Stemmed(&s)
+			if err != nil {
+				stop()
+				return indexed, err
+			}
+			pending = &indexJob{snip: s, stemmed: stemmed}
+		}
+		if pending == nil && inFlight == 0 {
+			break
+		}
+
+		// a nil channel is never selected, so only results are received once all snips are dispatched
+		var send chan indexJob
+		var job indexJob
+		if pending != nil {
+			send = jobs
+			job = *pending
+		}
+		select {
+		case send <- job:
+			pending = nil
+			inFlight++
+		case r := <-results:
+			inFlight--
+			if r.err != nil {
+				stop()
+				return indexed, r.err
+			}
+			// terms of previous data must not remain in the index, and are only removed along with writing the new
+			// terms, so that a failure leaves the previous index of the snip in place
+			err := database.WithTx(st.Conn, func() error {
+				err := st.removeIndex(&r.snip)
+				if err != nil {
+					return err
+				}
+				return st.writeIndex(&r.snip, r.termsPositions, r.stemmed)
+			})
+			if err != nil {
+				stop()
+				return indexed, err
+			}
+			indexed++
+			done++
+			if progress != nil {
+				progress(done, len(ids))
+			}
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	return indexed, nil
+}
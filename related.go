@@ -0,0 +1,76 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+)
+
+// DefaultRelated is the default number of snips returned by RelatedSnips
+const DefaultRelated = 10
+
+// RelatedTerms is the number of most frequent terms of a snip compared by RelatedSnips
+var RelatedTerms = 20
+
+// RelatedSnips is a wrapper around Store.RelatedSnips using the default store
+func RelatedSnips(id uuid.UUID, limit int) ([]SearchScore, error) {
+	return defaultStore().RelatedSnips(id, limit)
+}
+
+// RelatedSnips returns up to limit other snips sharing the most frequent indexed terms of the snip, ordered by the
+// ratio of those terms they contain. Snips sharing the same number of terms are ordered by the total count of the
+// shared terms. A limit of 0 returns all snips sharing any term.
+func (st *Store) RelatedSnips(id uuid.UUID, limit int) ([]SearchScore, error) {
+	var scores []SearchScore
+	exists, err := st.SnipExists(id)
+	if err != nil {
+		return scores, err
+	}
+	if !exists {
+		return scores, fmt.Errorf("snip %s does not exist", id)
+	}
+
+	top, err := st.TopTerms(&Snip{UUID: id}, RelatedTerms)
+	if err != nil {
+		return scores, err
+	}
+	if len(top) == 0 {
+		return scores, nil
+	}
+	var terms []string
+	for _, t := range top {
+		terms = append(terms, t.Term)
+	}
+
+	results, err := st.SearchIndexTerm(terms, false)
+	if err != nil {
+		return scores, err
+	}
+	delete(results, id)
+
+	totals := make(map[uuid.UUID]int)
+	for key, counts := range results {
+		// terms of a verbatim snip may share a stem, matching the same indexed term more than once
+		shared := make(map[string]bool)
+		for _, c := range counts {
+			shared[c.Term] = true
+			totals[key] += c.Count
+		}
+		score := float64(len(shared)) / float64(len(terms))
+		scores = append(scores, SearchScore{UUID: key, Score: score, SearchCounts: counts})
+	}
+
+	sort.Slice(scores, func(i int, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		if totals[scores[i].UUID] != totals[scores[j].UUID] {
+			return totals[scores[i].UUID] > totals[scores[j].UUID]
+		}
+		return scores[i].UUID.String() < scores[j].UUID.String()
+	})
+	if limit > 0 && len(scores) > limit {
+		scores = scores[:limit]
+	}
+	return scores, nil
+}
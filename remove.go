@@ -0,0 +1,61 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/ryanfrishkorn/snip/database"
+	"sort"
+)
+
+// RemoveBySearch is a wrapper around Store.RemoveBySearch using the default store
+func RemoveBySearch(terms []string, dryRun bool) ([]Snip, error) {
+	return defaultStore().RemoveBySearch(terms, dryRun)
+}
+
+// RemoveBySearch removes every snip whose index contains all of the terms, as found by SearchIndexTerm, and returns
+// them ordered by name. With dryRun the snips are only returned, otherwise all are removed in a single transaction.
+func (st *Store) RemoveBySearch(terms []string, dryRun bool) ([]Snip, error) {
+	var matches []Snip
+	if !dryRun {
+		if err := st.checkWritable(); err != nil {
+			return matches, err
+		}
+	}
+	if len(terms) == 0 {
+		return matches, fmt.Errorf("refusing to search for empty string")
+	}
+
+	results, err := st.SearchIndexTerm(terms, true)
+	if err != nil {
+		return matches, err
+	}
+	for id := range results {
+		s, err := st.GetFromUUID(id.String())
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, s)
+	}
+	sort.Slice(matches, func(i int, j int) bool {
+		if matches[i].Name != matches[j].Name {
+			return matches[i].Name < matches[j].Name
+		}
+		return matches[i].UUID.String() < matches[j].UUID.String()
+	})
+	if dryRun || len(matches) == 0 {
+		return matches, nil
+	}
+
+	err = database.WithTx(st.Conn, func() error {
+		for _, s := range matches {
+			err := st.Remove(s.UUID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
@@ -0,0 +1,71 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ryanfrishkorn/snip/database"
+)
+
+// RenameResult is a change of name made, or previewed, by RenameByPattern
+type RenameResult struct {
+	UUID    uuid.UUID `json:"uuid"`
+	OldName string    `json:"old_name"`
+	NewName string    `json:"new_name"`
+}
+
+// RenameByPattern is a wrapper around Store.RenameByPattern using the default store
+func RenameByPattern(pattern string, repl string, dryRun bool) ([]RenameResult, error) {
+	return defaultStore().RenameByPattern(pattern, repl, dryRun)
+}
+
+// RenameByPattern replaces matches of the regular expression pattern in the names of all snips with repl, which may
+// refer to capture groups as in regexp.Regexp.ReplaceAllString. Names left unchanged are not included in the results.
+// With dryRun the changes are only returned, otherwise all are applied in a single transaction.
+// No snip is renamed if any replacement produces an empty name.
+func (st *Store) RenameByPattern(pattern string, repl string, dryRun bool) ([]RenameResult, error) {
+	var results []RenameResult
+	if !dryRun {
+		if err := st.checkWritable(); err != nil {
+			return results, err
+		}
+	}
+	re, err := CompileRegex(pattern)
+	if err != nil {
+		return results, err
+	}
+
+	snips, err := st.List(0)
+	if err != nil {
+		return results, err
+	}
+	var renamed []Snip
+	for _, s := range snips {
+		newName := re.ReplaceAllString(s.Name, repl)
+		if newName == s.Name {
+			continue
+		}
+		if newName == "" {
+			return nil, fmt.Errorf("renaming %s %q would produce an empty name", s.UUID, s.Name)
+		}
+		results = append(results, RenameResult{UUID: s.UUID, OldName: s.Name, NewName: newName})
+		s.Name = newName
+		renamed = append(renamed, s)
+	}
+	if dryRun || len(renamed) == 0 {
+		return results, nil
+	}
+
+	err = database.WithTx(st.Conn, func() error {
+		for idx := range renamed {
+			err := st.Update(&renamed[idx])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
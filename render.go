@@ -0,0 +1,109 @@
+package snip
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// shebangLanguages maps interpreters found in a shebang line to a code fence language
+var shebangLanguages = map[string]string{
+	"bash":    "bash",
+	"node":    "javascript",
+	"perl":    "perl",
+	"python":  "python",
+	"python3": "python",
+	"ruby":    "ruby",
+	"sh":      "sh",
+	"zsh":     "zsh",
+}
+
+// DetectLanguage returns a code fence language for data, or an empty string for plain text
+func DetectLanguage(data string) string {
+	if !strings.HasPrefix(data, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(data, "\n")
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	// handle the form: #!/usr/bin/env python3
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	return shebangLanguages[interpreter]
+}
+
+// codeFence returns a fence long enough to not be terminated by backticks within data
+func codeFence(data string) string {
+	fence := "```"
+	for strings.Contains(data, fence) {
+		fence += "`"
+	}
+	return fence
+}
+
+// writeFenced writes data to b as a fenced code block with a detected language
+func writeFenced(b *strings.Builder, data string) {
+	fence := codeFence(data)
+	fmt.Fprintf(b, "%s%s\n", fence, DetectLanguage(data))
+	b.WriteString(data)
+	if !strings.HasSuffix(data, "\n") {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, "%s\n", fence)
+}
+
+// RenderMarkdown returns a Markdown document representing the snip and its attachments
+func (s *Snip) RenderMarkdown() (string, error) {
+	var b strings.Builder
+
+	name := s.Name
+	if name == "" {
+		name = s.UUID.String()
+	}
+	fmt.Fprintf(&b, "# %s\n\n", name)
+	fmt.Fprintf(&b, "_%s_\n\n", s.Timestamp.Format(time.RFC3339Nano))
+
+	writeFenced(&b, s.Data)
+
+	if len(s.Attachments) > 0 {
+		b.WriteString("\n## Attachments\n\n")
+		for _, a := range s.Attachments {
+			fmt.Fprintf(&b, "- %s (%d bytes)\n", a.Name, a.Size)
+		}
+	}
+	return b.String(), nil
+}
+
+// RenderGist returns the snip name, a separator, and the fenced data formatted for pasting. Text attachments of at
+// most maxInlineAttachmentBytes are inlined as additional fenced sections, while larger or binary attachments are
+// listed by name and size. A limit of zero inlines no attachments.
+func (s *Snip) RenderGist(maxInlineAttachmentBytes int) (string, error) {
+	if maxInlineAttachmentBytes < 0 {
+		return "", errors.New("maximum inline attachment size must not be negative")
+	}
+	var b strings.Builder
+
+	name := s.Name
+	if name == "" {
+		name = s.UUID.String()
+	}
+	fmt.Fprintf(&b, "%s\n%s\n\n", name, strings.Repeat("=", utf8.RuneCountInString(name)))
+	writeFenced(&b, s.Data)
+
+	for _, a := range s.Attachments {
+		if maxInlineAttachmentBytes > 0 && a.Size <= maxInlineAttachmentBytes && len(a.Data) == a.Size && isText(a.Data) {
+			fmt.Fprintf(&b, "\n%s (%d bytes)\n", a.Name, a.Size)
+			writeFenced(&b, string(a.Data))
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s (%d bytes, not inlined)\n", a.Name, a.Size)
+	}
+	return b.String(), nil
+}
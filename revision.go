@@ -0,0 +1,169 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// DefaultMaxRevisions is the default number of revisions retained for each snip
+const DefaultMaxRevisions = 10
+
+// MaxRevisions is the number of revisions retained for each snip, zero disables revisions
+var MaxRevisions = DefaultMaxRevisions
+
+// Revision represents a prior version of a snip, and when it was replaced
+type Revision struct {
+	SnipUUID  uuid.UUID
+	Revision  int
+	Timestamp time.Time
+	Name      string
+	Data      string
+	Saved     time.Time
+}
+
+// SaveRevision is a wrapper around Store.SaveRevision using the default store
+func (s *Snip) SaveRevision() error {
+	return defaultStore().SaveRevision(s)
+}
+
+// SaveRevision stores the current database version of the snip as a new revision, discarding the oldest beyond MaxRevisions
+func (st *Store) SaveRevision(s *Snip) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	if MaxRevisions == 0 {
+		return nil
+	}
+	// the receiver may already hold modifications, so read the stored version
+	stored, err := st.GetFromUUID(s.UUID.String())
+	if err != nil {
+		return err
+	}
+
+	latest, err := st.latestRevision(s.UUID)
+	if err != nil {
+		return err
+	}
+	err = st.Conn.Exec(`INSERT INTO snip_revision (snip_uuid, revision, timestamp, name, data, saved) VALUES (?, ?, ?, ?, ?, ?)`,
+		stored.UUID.String(), latest+1, stored.Timestamp.Format(time.RFC3339Nano), stored.Name, stored.Data, time.Now().Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+
+	// prune revisions beyond the cap
+	return st.Conn.Exec(`DELETE FROM snip_revision WHERE snip_uuid = ? AND revision <= ?`, s.UUID.String(), latest+1-MaxRevisions)
+}
+
+// latestRevision returns the highest revision number stored for a snip, or zero if none exist
+func (st *Store) latestRevision(id uuid.UUID) (int, error) {
+	var latest int
+	stmt, err := st.Conn.Prepare(`SELECT coalesce(max(revision), 0) FROM snip_revision WHERE snip_uuid = ?`, id.String())
+	if err != nil {
+		return latest, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return latest, err
+	}
+	if !hasRow {
+		return latest, nil
+	}
+	err = stmt.Scan(&latest)
+	if err != nil {
+		return latest, err
+	}
+	return latest, nil
+}
+
+// GetRevisions is a wrapper around Store.GetRevisions using the default store
+func GetRevisions(id uuid.UUID) ([]Revision, error) {
+	return defaultStore().GetRevisions(id)
+}
+
+// GetRevisions returns all stored revisions of a snip, oldest first
+func (st *Store) GetRevisions(id uuid.UUID) ([]Revision, error) {
+	var revisions []Revision
+	stmt, err := st.Conn.Prepare(`SELECT revision, timestamp, name, data, saved FROM snip_revision WHERE snip_uuid = ? ORDER BY revision`, id.String())
+	if err != nil {
+		return revisions, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return revisions, err
+		}
+		if !hasRow {
+			break
+		}
+		var (
+			timestamp string
+			saved     string
+			r         Revision
+		)
+		err = stmt.Scan(&r.Revision, &timestamp, &r.Name, &r.Data, &saved)
+		if err != nil {
+			return revisions, err
+		}
+		r.SnipUUID = id
+		r.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return revisions, err
+		}
+		r.Saved, err = time.Parse(time.RFC3339Nano, saved)
+		if err != nil {
+			return revisions, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, nil
+}
+
+// GetRevision is a wrapper around Store.GetRevision using the default store
+func GetRevision(id uuid.UUID, revision int) (Revision, error) {
+	return defaultStore().GetRevision(id, revision)
+}
+
+// GetRevision returns a single stored revision of a snip
+func (st *Store) GetRevision(id uuid.UUID, revision int) (Revision, error) {
+	revisions, err := st.GetRevisions(id)
+	if err != nil {
+		return Revision{}, err
+	}
+	for _, r := range revisions {
+		if r.Revision == revision {
+			return r, nil
+		}
+	}
+	return Revision{}, fmt.Errorf("revision %d of snip %s does not exist", revision, id)
+}
+
+// Restore is a wrapper around Store.Restore using the default store
+func (s *Snip) Restore(revision int) error {
+	return defaultStore().Restore(s, revision)
+}
+
+// Restore replaces the snip fields with those of a stored revision and reindexes it.
+// The version being replaced is saved as a new revision, so a restore can itself be undone.
+func (st *Store) Restore(s *Snip, revision int) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	r, err := st.GetRevision(s.UUID, revision)
+	if err != nil {
+		return err
+	}
+	s.Data = r.Data
+	s.Name = r.Name
+	s.Timestamp = r.Timestamp
+	err = st.Update(s)
+	if err != nil {
+		return err
+	}
+	// terms of the replaced data must not remain in the index
+	return st.Reindex(s)
+}
@@ -8,7 +8,12 @@ import (
 	"github.com/rivo/uniseg"
 	"github.com/rs/zerolog/log"
 	"github.com/ryanfrishkorn/snip/database"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"io"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,9 +23,10 @@ import (
 
 // SearchCount contains info about a search term frequency from the index
 type SearchCount struct {
-	Term  string
-	Stem  string
-	Count int
+	Term     string `json:"term"`
+	Stem     string `json:"stem"`
+	Count    int    `json:"count"`
+	Distance int    `json:"distance"` // edit distance between the stem of Term and Stem, zero for exact matches
 }
 
 type SearchResult struct {
@@ -35,41 +41,179 @@ type SearchScore struct {
 }
 
 type TermContext struct {
-	Before      []string
-	BeforeStart int
-	Term        string
-	After       []string
-	AfterEnd    int
+	Before      []string `json:"before"`
+	BeforeStart int      `json:"before_start"`
+	Term        string   `json:"term"`
+	After       []string `json:"after"`
+	AfterEnd    int      `json:"after_end"`
+}
+
+// DefaultMaxDataSize is the default maximum number of bytes allowed in the data of a snip
+const DefaultMaxDataSize = 10 * 1024 * 1024
+
+// MaxDataSize is the maximum number of bytes allowed in the data of a snip, zero disables the check
+var MaxDataSize = DefaultMaxDataSize
+
+// DataSizeError indicates that data exceeds the configured maximum size
+type DataSizeError struct {
+	Max int
+}
+
+func (e *DataSizeError) Error() string {
+	return fmt.Sprintf("input exceeds maximum size of %d bytes; use attachments for large binary data", e.Max)
+}
+
+// CheckDataSize returns a DataSizeError if size exceeds MaxDataSize
+func CheckDataSize(size int) error {
+	if MaxDataSize > 0 && size > MaxDataSize {
+		return &DataSizeError{Max: MaxDataSize}
+	}
+	return nil
+}
+
+// AmbiguousUUIDError indicates that a partial uuid matches more than one snip
+type AmbiguousUUIDError struct {
+	Search  string
+	Matches []Snip
+}
+
+func (e *AmbiguousUUIDError) Error() string {
+	return fmt.Sprintf("uuid %s matches %d snips", e.Search, len(e.Matches))
+}
+
+// AmbiguousNameError indicates that a name is shared by more than one snip
+type AmbiguousNameError struct {
+	Name    string
+	Matches []Snip
+}
+
+func (e *AmbiguousNameError) Error() string {
+	return fmt.Sprintf("name %s matches %d snips", e.Name, len(e.Matches))
+}
+
+// NoStem indexes snips not yet indexed by their lowercased words verbatim instead of stems, which suits code and identifiers
+var NoStem = false
+
+// stemTerm returns the stem of a single word, and may be replaced for testing
+var stemTerm = func(word string) (string, error) {
+	return snowball.Stem(word, Language, true)
 }
 
 // Snip represents a snippet of data with additional metadata
 type Snip struct {
-	Attachments []Attachment
-	Data        string
-	Timestamp   time.Time
-	Name        string
-	UUID        uuid.UUID
+	Attachments []Attachment `json:"attachments"`
+	Data        string       `json:"data"`
+	Favorite    bool         `json:"favorite"`
+	Timestamp   time.Time    `json:"timestamp"`
+	Name        string       `json:"name"`
+	UUID        uuid.UUID    `json:"uuid"`
+	// Metadata is filled in by exports, and is otherwise read with Meta
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
-// Attach adds files associated with a snip
+// Attach is a wrapper around Store.Attach using the default store
 func (s *Snip) Attach(name string, data []byte) error {
+	return defaultStore().Attach(s, name, data)
+}
+
+// Attach adds files associated with a snip
+func (st *Store) Attach(s *Snip, name string, data []byte) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
 	// build and insert attachment
 	a := NewAttachment()
 	a.Data = data
 	a.Name = name
 	a.SnipUUID = s.UUID
+	a.MIME = DetectMIME(data)
+
+	return st.InsertAttachment(a)
+}
+
+// AttachFile is a wrapper around Store.AttachFile using the default store
+func (s *Snip) AttachFile(filename string) (int64, error) {
+	return defaultStore().AttachFile(s, filename)
+}
 
-	stmt, err := database.Conn.Prepare(`INSERT INTO snip_attachment (uuid, snip_uuid, timestamp, name, data, size) VALUES (?, ?, ?, ?, ?, ?)`)
+// AttachFile adds a local file as an attachment, streaming large files into the database in chunks.
+// The number of bytes attached is returned.
+func (st *Store) AttachFile(s *Snip, filename string) (int64, error) {
+	if err := st.checkWritable(); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(filename)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	name := filepath.Base(filename)
+
+	// small files use the simple in-memory path
+	if info.Size() < int64(AttachmentStreamThreshold) {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return 0, err
+		}
+		err = st.Attach(s, name, data)
+		if err != nil {
+			return 0, err
+		}
+		return int64(len(data)), nil
 	}
-	defer stmt.Close()
 
-	err = stmt.Exec(a.UUID.String(), a.SnipUUID.String(), a.Timestamp.Format(time.RFC3339Nano), a.Name, a.Data, len(a.Data))
+	f, err := os.Open(filename)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	return nil
+	defer f.Close()
+
+	// detect type from the head of the file before copying
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+
+	a := NewAttachment()
+	a.Name = name
+	a.SnipUUID = s.UUID
+	a.MIME = DetectMIME(head[:n])
+
+	var written int64
+	err = database.WithTx(st.Conn, func() error {
+		// the file is copied from the start on each attempt
+		_, err := f.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		// allocate the blob, then fill it incrementally
+		err = st.Conn.Exec(`INSERT INTO snip_attachment (uuid, snip_uuid, timestamp, name, data, size, mime) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			a.UUID.String(), a.SnipUUID.String(), a.Timestamp.Format(time.RFC3339Nano), a.Name, sqlite3.ZeroBlob(info.Size()), info.Size(), a.MIME)
+		if err != nil {
+			return err
+		}
+		blob, err := st.Conn.BlobIO("main", "snip_attachment", "data", st.Conn.LastInsertRowID(), true)
+		if err != nil {
+			return err
+		}
+		written, err = io.CopyBuffer(blob, f, make([]byte, attachmentChunkSize))
+		if err != nil {
+			blob.Close()
+			return err
+		}
+		err = blob.Close()
+		if err != nil {
+			return err
+		}
+		if written != info.Size() {
+			return fmt.Errorf("expected to write %d bytes, wrote %d", info.Size(), written)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return written, nil
 }
 
 // CountWords returns an integer estimating the number of words in data
@@ -77,18 +221,68 @@ func (s *Snip) CountWords() int {
 	return len(SplitWords(s.Data))
 }
 
-// GatherContext returns the surrounding words matching the given term
+// CountLines returns the number of newline characters in data, as counted by wc, so a final line without a newline
+// is not counted
+func (s *Snip) CountLines() int {
+	return strings.Count(s.Data, "\n")
+}
+
+// CountBytes returns the length of data in bytes
+func (s *Snip) CountBytes() int {
+	return len(s.Data)
+}
+
+// AttachmentsTotalSize returns the sum of the recorded sizes of all attachments of the snip
+func (s *Snip) AttachmentsTotalSize() int {
+	var total int
+	for _, a := range s.Attachments {
+		total += a.Size
+	}
+	return total
+}
+
+// GatherContext is a wrapper around Store.GatherContext using the default store
 func (s *Snip) GatherContext(term string, adjacent int) ([]TermContext, error) {
+	return defaultStore().GatherContext(s, term, adjacent)
+}
+
+// GatherContext returns the surrounding words matching the given term
+func (st *Store) GatherContext(s *Snip, term string, adjacent int) ([]TermContext, error) {
+	if adjacent < 0 {
+		return nil, fmt.Errorf("number of adjacent words must not be negative")
+	}
+	term = cleanTerm(term)
+	termStemmed, err := stemTerm(term)
+	if err != nil {
+		return nil, err
+	}
+	// snips indexed without stemming store the lowercased word
+	stemmed, err := st.Stemmed(s)
+	if err != nil {
+		return nil, err
+	}
+	if !stemmed {
+		termStemmed = strings.ToLower(term)
+	}
+	return st.GatherIndexedContext(s, termStemmed, adjacent)
+}
+
+// GatherIndexedContext is a wrapper around Store.GatherIndexedContext using the default store
+func (s *Snip) GatherIndexedContext(indexed string, adjacent int) ([]TermContext, error) {
+	return defaultStore().GatherIndexedContext(s, indexed, adjacent)
+}
+
+// GatherIndexedContext returns the surrounding words of each position of a term exactly as indexed, such as a stem
+// reported in SearchCount.Stem, without stemming it again
+func (st *Store) GatherIndexedContext(s *Snip, indexed string, adjacent int) ([]TermContext, error) {
 	var (
 		ctxAll []TermContext
 		words  []string
-		stems  []string
 	)
-	termStemmed, err := snowball.Stem(term, "english", true)
-	if err != nil {
-		return ctxAll, err
+	if adjacent < 0 {
+		return ctxAll, fmt.Errorf("number of adjacent words must not be negative")
 	}
-	positions, err := s.GetPositions(termStemmed)
+	positions, err := st.GetPositions(s, indexed)
 	if err != nil {
 		return ctxAll, err
 	}
@@ -112,20 +306,15 @@ func (s *Snip) GatherContext(term string, adjacent int) ([]TermContext, error) {
 	}
 	log.Debug().Any("positions", positionsSplitInt).Msg("positions")
 
-	// build split words and corresponding stems
 	words = SplitWords(s.Data)
-	for _, word := range words {
-		// apparently we don't need to use DownCase here since the stemmer does so
-		stem, err := snowball.Stem(word, "english", true)
-		if err != nil {
-			return ctxAll, err
-		}
-		stems = append(stems, stem)
-	}
 
 	// iterate through all positions
 	for _, position := range positionsSplitInt {
 		var ctx TermContext
+		// positions recorded by an index built from other words must not be followed
+		if position < 0 || position >= len(words) {
+			return ctxAll, fmt.Errorf("position %d of %s is outside the %d words of snip %s, the index must be rebuilt", position, indexed, len(words), s.UUID)
+		}
 		// establish either the amount of terms requested (adjacent) or the maximum we can satisfy
 		// attempt to find words before term
 		start := position - adjacent
@@ -135,10 +324,8 @@ func (s *Snip) GatherContext(term string, adjacent int) ([]TermContext, error) {
 		}
 		// log.Debug().Int("start", start).Msg("number")
 		// log.Debug().Int("position", position).Msg("GatherContextNew")
+		ctx.BeforeStart = start + 1 // add one to reflect word count, not element index
 		for i := start; i < position; i++ {
-			if i == start {
-				ctx.BeforeStart = i + 1 // add one to reflect word count, not element index
-			}
 			// log.Debug().Msg("ITERATION")
 			ctx.Before = append(ctx.Before, words[i])
 			// log.Debug().Str("words[i]", words[i]).Msg("added word to before")
@@ -153,7 +340,7 @@ func (s *Snip) GatherContext(term string, adjacent int) ([]TermContext, error) {
 		if lastElement >= len(words)-1 {
 			lastElement = len(words) - 1
 		}
-		ctx.AfterEnd = lastElement
+		ctx.AfterEnd = lastElement + 1
 		for i := position + 1; i <= lastElement; i++ {
 			// log.Debug().Int("i", i).Msg("counter")
 			ctx.After = append(ctx.After, words[i])
@@ -166,8 +353,16 @@ func (s *Snip) GatherContext(term string, adjacent int) ([]TermContext, error) {
 	return ctxAll, nil
 }
 
-// GenerateName returns a clean string derived from processing the data field
+// DefaultNameWords is the default number of words used to generate a name from data
+const DefaultNameWords = 5
+
+// GenerateName returns a clean string of up to wordCount words derived from processing the data field.
+// All words are used if the data contains fewer, and data without words produces an empty string.
 func (s *Snip) GenerateName(wordCount int) string {
+	// a negative count would otherwise match every word
+	if wordCount < 1 {
+		return ""
+	}
 	data := FlattenString(s.Data)
 	// FIXME by allowing additional sensible characters such as `:`
 	pattern := regexp.MustCompile(`\w+`)
@@ -200,6 +395,12 @@ func StripPunctuation(words []string) []string {
 	return words
 }
 
+// cleanTerm strips punctuation from a search term, as indexed words are split at word boundaries that leave none, so
+// that data. matches data
+func cleanTerm(term string) string {
+	return StripPunctuation([]string{term})[0]
+}
+
 // DownCase returns a slice of strings that have been cased down
 func DownCase(words []string) []string {
 	var output []string
@@ -209,79 +410,240 @@ func DownCase(words []string) []string {
 	return output
 }
 
-// Index stems all data and writes it to a search table
+// Index is a wrapper around Store.Index using the default store
 func (s *Snip) Index() error {
+	return defaultStore().Index(s)
+}
+
+// Index stems all data and writes it to a search table
+func (st *Store) Index(s *Snip) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	stemmed, err := st.Stemmed(s)
+	if err != nil {
+		return err
+	}
+	termsPositions, err := s.analyzeTerms(stemmed)
+	if err != nil {
+		return err
+	}
+	return st.writeIndex(s, termsPositions, stemmed)
+}
+
+// analyzeTerms returns the word positions of each term in the data, where terms are stemmed words unless stemmed
+// is false, and does not access the database
+func (s *Snip) analyzeTerms(stemmed bool) (map[string][]int, error) {
 	// TODO: remove stop words from dict
 	dataCleaned := SplitWords(s.Data)
 	dataCleaned = DownCase(dataCleaned)
 	var dataStemmed []string
 	for _, word := range dataCleaned {
-		stem, err := snowball.Stem(word, "english", true)
+		if !stemmed {
+			dataStemmed = append(dataStemmed, word)
+			continue
+		}
+		stem, err := stemTerm(word)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		dataStemmed = append(dataStemmed, stem)
 	}
 	// confirm equal length of split words and stemmed words
 	if len(dataCleaned) != len(dataStemmed) {
-		return fmt.Errorf("expected len(dataCleaned) %d to equal len(dataStemmed) %d", len(dataCleaned), len(dataStemmed))
+		return nil, fmt.Errorf("expected len(dataCleaned) %d to equal len(dataStemmed) %d", len(dataCleaned), len(dataStemmed))
 	}
 
-	// build terms and counts
-	terms := make(map[string]int, 0)
+	// build terms and positions, where the count of a term is the number of its positions
 	termsPositions := make(map[string][]int, 0)
-	for _, term := range dataStemmed {
-		// determine if term has already been processed
-		_, ok := terms[term]
-		if ok {
-			// skip
-			continue
-		}
+	for idx, term := range dataStemmed {
+		termsPositions[term] = append(termsPositions[term], idx)
+	}
+	return termsPositions, nil
+}
 
-		// count occurrences
-		var count int
-		var positions []int
-		for idx, t := range dataStemmed {
-			if term == t {
-				count++
-				positions = append(positions, idx)
-			}
-		}
-		terms[term] = count
-		// log.Debug().Str("term", term).Int("count", count).Msg("indexing stem")
-		termsPositions[term] = positions
-		// log.Debug().Str("term", term).Any("positions", positions).Msg("indexing positions")
+// writeIndex writes the counts and positions of terms to the search table, recording whether the terms are stemmed
+func (st *Store) writeIndex(s *Snip, termsPositions map[string][]int, stemmed bool) error {
+	// terms stemmed in different languages cannot be searched together
+	err := st.checkIndexLanguage()
+	if err != nil {
+		return err
+	}
+	// positions refer to words split with the word symbols setting of the index
+	err = st.checkIndexWordSymbols()
+	if err != nil {
+		return err
 	}
-	for term, count := range terms {
-		err := s.SetIndexTermCount(term, count)
+	for term, positions := range termsPositions {
+		err := st.SetIndexTermCount(s, term, len(positions))
 		if err != nil {
 			return err
 		}
 	}
 	for term, positions := range termsPositions {
-		err := s.SetPositions(term, positions)
+		err := st.SetPositions(s, term, positions)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	// record the indexed content so unchanged snips can be skipped by incremental indexing
+	err = st.Conn.Exec(`INSERT OR REPLACE INTO snip_index_meta (uuid, data_hash, stemmed) VALUES (?, ?, ?)`, s.UUID.String(), HashData([]byte(s.Data)), stemmed)
+	if err != nil {
+		return err
+	}
+
+	err = st.recordIndexLanguage()
+	if err != nil {
+		return err
+	}
+	return st.recordIndexWordSymbols()
 }
 
-// Rename updates the name field of a snip
+// Reindex is a wrapper around Store.Reindex using the default store
+func (s *Snip) Reindex() error {
+	return defaultStore().Reindex(s)
+}
+
+// Reindex removes all index entries of the snip and indexes its current data
+func (st *Store) Reindex(s *Snip) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	err := st.removeIndex(s)
+	if err != nil {
+		return err
+	}
+	return st.Index(s)
+}
+
+// removeIndex deletes all index entries of the snip
+func (st *Store) removeIndex(s *Snip) error {
+	return st.Conn.Exec(`DELETE FROM snip_index WHERE uuid = ?`, s.UUID.String())
+}
+
+// IndexCurrent is a wrapper around Store.IndexCurrent using the default store
+func (s *Snip) IndexCurrent() (bool, error) {
+	return defaultStore().IndexCurrent(s)
+}
+
+// IndexCurrent reports whether the snip data is unchanged since it was last indexed
+func (st *Store) IndexCurrent(s *Snip) (bool, error) {
+	stmt, err := st.Conn.Prepare(`SELECT data_hash FROM snip_index_meta WHERE uuid = ?`, s.UUID.String())
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return false, err
+	}
+	if !hasRow {
+		return false, nil
+	}
+	var hash string
+	err = stmt.Scan(&hash)
+	if err != nil {
+		return false, err
+	}
+	return hash == HashData([]byte(s.Data)), nil
+}
+
+// Stemmed is a wrapper around Store.Stemmed using the default store
+func (s *Snip) Stemmed() (bool, error) {
+	return defaultStore().Stemmed(s)
+}
+
+// Stemmed reports whether the snip is indexed by stemmed terms, or by lowercased words verbatim.
+// Snips not yet indexed use the opposite of NoStem.
+func (st *Store) Stemmed(s *Snip) (bool, error) {
+	stmt, err := st.Conn.Prepare(`SELECT stemmed FROM snip_index_meta WHERE uuid = ?`, s.UUID.String())
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return false, err
+	}
+	if !hasRow {
+		return !NoStem, nil
+	}
+	var stemmed bool
+	err = stmt.Scan(&stemmed)
+	if err != nil {
+		return false, err
+	}
+	return stemmed, nil
+}
+
+// SetStemmed is a wrapper around Store.SetStemmed using the default store
+func (s *Snip) SetStemmed(stemmed bool) error {
+	return defaultStore().SetStemmed(s, stemmed)
+}
+
+// SetStemmed changes whether the snip is indexed by stemmed terms, and reindexes it
+func (st *Store) SetStemmed(s *Snip, stemmed bool) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	err := st.removeIndex(s)
+	if err != nil {
+		return err
+	}
+	termsPositions, err := s.analyzeTerms(stemmed)
+	if err != nil {
+		return err
+	}
+	return st.writeIndex(s, termsPositions, stemmed)
+}
+
+// Rename is a wrapper around Store.Rename using the default store
 func (s *Snip) Rename(newName string) error {
+	return defaultStore().Rename(s, newName)
+}
+
+// Rename updates the name field of a snip
+func (st *Store) Rename(s *Snip, newName string) error {
 	s.Name = newName
-	err := s.Update()
+	err := st.Update(s)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetPositions gets the position indicators for a given term
+// SetFavorite is a wrapper around Store.SetFavorite using the default store
+func SetFavorite(id uuid.UUID, fav bool) error {
+	return defaultStore().SetFavorite(id, fav)
+}
+
+// SetFavorite marks or unmarks the snip as a favorite
+func (st *Store) SetFavorite(id uuid.UUID, fav bool) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	err := st.Conn.Exec(`UPDATE snip SET favorite = ? WHERE uuid = ?`, fav, id.String())
+	if err != nil {
+		return err
+	}
+	if st.Conn.Changes() == 0 {
+		return fmt.Errorf("no snip with uuid %s", id)
+	}
+	return nil
+}
+
+// GetPositions is a wrapper around Store.GetPositions using the default store
 func (s *Snip) GetPositions(term string) (string, error) {
+	return defaultStore().GetPositions(s, term)
+}
+
+// GetPositions gets the position indicators for a given term
+func (st *Store) GetPositions(s *Snip, term string) (string, error) {
 	var positions string
-	stmt, err := database.Conn.Prepare(`SELECT positions FROM snip_index WHERE term = ? AND uuid = ?`)
+	stmt, err := st.Conn.Prepare(`SELECT positions FROM snip_index WHERE term = ? AND uuid = ?`)
 	if err != nil {
 		return positions, err
 	}
@@ -306,15 +668,23 @@ func (s *Snip) GetPositions(term string) (string, error) {
 	return positions, nil
 }
 
-// SetPositions writes the word positions of a given term
+// SetPositions is a wrapper around Store.SetPositions using the default store
 func (s *Snip) SetPositions(term string, positions []int) error {
+	return defaultStore().SetPositions(s, term, positions)
+}
+
+// SetPositions writes the word positions of a given term
+func (st *Store) SetPositions(s *Snip, term string, positions []int) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
 	// join positions into a string
 	var positionsStr []string
 	for _, p := range positions {
 		positionsStr = append(positionsStr, strconv.Itoa(p))
 	}
 	positionsJoined := strings.Join(positionsStr, ",")
-	stmt, err := database.Conn.Prepare(`UPDATE snip_index SET positions = ? WHERE term = ? AND uuid = ?`)
+	stmt, err := st.Conn.Prepare(`UPDATE snip_index SET positions = ? WHERE term = ? AND uuid = ?`)
 	if err != nil {
 		return err
 	}
@@ -327,9 +697,17 @@ func (s *Snip) SetPositions(term string, positions []int) error {
 	return nil
 }
 
-// SetIndexTermCount inserts or updates the count of a term indexed
+// SetIndexTermCount is a wrapper around Store.SetIndexTermCount using the default store
 func (s *Snip) SetIndexTermCount(term string, count int) error {
-	countCurrent, err := GetIndexTermCount(term, s.UUID)
+	return defaultStore().SetIndexTermCount(s, term, count)
+}
+
+// SetIndexTermCount inserts or updates the count of a term indexed
+func (st *Store) SetIndexTermCount(s *Snip, term string, count int) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	countCurrent, err := st.GetIndexTermCount(term, s.UUID)
 	if err != nil {
 		return err
 	}
@@ -337,20 +715,20 @@ func (s *Snip) SetIndexTermCount(term string, count int) error {
 	var stmt *sqlite3.Stmt
 	if countCurrent != 0 {
 		// remove current count and replace with new count
-		stmt, err = database.Conn.Prepare(`UPDATE snip_index SET count = ? WHERE term = ? AND uuid = ?`)
+		stmt, err = st.Conn.Prepare(`UPDATE snip_index SET count = ? WHERE term = ? AND uuid = ?`)
 		if err != nil {
 			return err
 		}
-		err = stmt.Exec(count, term, s.UUID.String())
+		err = database.ExecStmt(st.Conn, stmt, count, term, s.UUID.String())
 		if err != nil {
 			return err
 		}
 	} else {
-		stmt, err = database.Conn.Prepare(`INSERT INTO snip_index (term, uuid, count) VALUES (?, ?, ?)`)
+		stmt, err = st.Conn.Prepare(`INSERT INTO snip_index (term, uuid, count) VALUES (?, ?, ?)`)
 		if err != nil {
 			return err
 		}
-		err = stmt.Exec(term, s.UUID.String(), count)
+		err = database.ExecStmt(st.Conn, stmt, term, s.UUID.String(), count)
 		if err != nil {
 			return err
 		}
@@ -359,38 +737,147 @@ func (s *Snip) SetIndexTermCount(term string, count int) error {
 	return nil
 }
 
-// Update writes all fields, overwriting existing snip data
-func (s *Snip) Update() error {
-	// verify that current record is present and unique
-	stmt, err := database.Conn.Prepare(`SELECT count() FROM snip where uuid = ?`, s.UUID.String())
+// TopTerms is a wrapper around Store.TopTerms using the default store
+func (s *Snip) TopTerms(n int) ([]SearchCount, error) {
+	return defaultStore().TopTerms(s, n)
+}
+
+// TopTerms returns the most frequent indexed terms of the snip in descending order, zero returns all terms
+func (st *Store) TopTerms(s *Snip, n int) ([]SearchCount, error) {
+	var results []SearchCount
+	var stmt *sqlite3.Stmt
+	var err error
+
+	if n != 0 {
+		stmt, err = st.Conn.Prepare(`SELECT term, count FROM snip_index WHERE uuid = ? ORDER BY count DESC, term ASC LIMIT ?`, s.UUID.String(), n)
+	} else {
+		stmt, err = st.Conn.Prepare(`SELECT term, count FROM snip_index WHERE uuid = ? ORDER BY count DESC, term ASC`, s.UUID.String())
+	}
 	if err != nil {
-		return err
+		return results, err
 	}
 	defer stmt.Close()
 
-	// update
-	err = stmt.Exec()
-	if err != nil {
-		return err
-	}
-	count := 0
 	for {
 		hasRow, err := stmt.Step()
 		if err != nil {
-			return err
+			return results, err
 		}
 		if !hasRow {
 			break
 		}
-		count++
-
-		var n int
-		err = stmt.Scan(&n)
+		var term string
+		var count int
+		err = stmt.Scan(&term, &count)
 		if err != nil {
-			return fmt.Errorf("error scanning for value during update preliminary check")
+			return results, err
 		}
-		if n != 1 {
-			return fmt.Errorf("count query returned more than one row, meaning uuid may have duplicate entries")
+		// the index only stores stems
+		results = append(results, SearchCount{Term: term, Stem: term, Count: count})
+	}
+	return results, nil
+}
+
+// AllIndexedTerms is a wrapper around Store.AllIndexedTerms using the default store
+func AllIndexedTerms() ([]SearchCount, error) {
+	return defaultStore().AllIndexedTerms()
+}
+
+// AllIndexedTerms returns each distinct indexed term with its total count across all snips in descending order
+func (st *Store) AllIndexedTerms() ([]SearchCount, error) {
+	return st.IndexedTermsPrefix("", 0)
+}
+
+// IndexedTermsPrefix is a wrapper around Store.IndexedTermsPrefix using the default store
+func IndexedTermsPrefix(prefix string, n int) ([]SearchCount, error) {
+	return defaultStore().IndexedTermsPrefix(prefix, n)
+}
+
+// IndexedTermsPrefix returns the n most frequent distinct indexed terms beginning with prefix, with their total counts
+// across all snips in descending order. The prefix is lowercased to match the index, and zero returns all terms.
+func (st *Store) IndexedTermsPrefix(prefix string, n int) ([]SearchCount, error) {
+	var results []SearchCount
+	if n == 0 {
+		n = -1 // no limit in sqlite
+	}
+	stmt, err := st.Conn.Prepare(`SELECT term, sum(count) AS total FROM snip_index WHERE substr(term, 1, length(?1)) = ?1
+		GROUP BY term ORDER BY total DESC, term ASC LIMIT ?2`, strings.ToLower(prefix), n)
+	if err != nil {
+		return results, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return results, err
+		}
+		if !hasRow {
+			break
+		}
+		var term string
+		var count int
+		err = stmt.Scan(&term, &count)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, SearchCount{Term: term, Stem: term, Count: count})
+	}
+	return results, nil
+}
+
+// Update is a wrapper around Store.Update using the default store
+func (s *Snip) Update() error {
+	return defaultStore().Update(s)
+}
+
+// Update writes all fields, overwriting existing snip data
+func (st *Store) Update(s *Snip) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	// preserve the stored version in the same transaction as its replacement
+	return database.WithTx(st.Conn, func() error {
+		err := st.SaveRevision(s)
+		if err != nil {
+			return err
+		}
+		return st.replace(s)
+	})
+}
+
+// replace overwrites the stored data, timestamp, and name of s, which must be present exactly once
+func (st *Store) replace(s *Snip) error {
+	// verify that current record is present and unique
+	stmt, err := st.Conn.Prepare(`SELECT count() FROM snip where uuid = ?`, s.UUID.String())
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	// update
+	err = stmt.Exec()
+	if err != nil {
+		return err
+	}
+	count := 0
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return err
+		}
+		if !hasRow {
+			break
+		}
+		count++
+
+		var n int
+		err = stmt.Scan(&n)
+		if err != nil {
+			return fmt.Errorf("error scanning for value during update preliminary check")
+		}
+		if n != 1 {
+			return fmt.Errorf("count query returned more than one row, meaning uuid may have duplicate entries")
 		}
 	}
 
@@ -400,43 +887,73 @@ func (s *Snip) Update() error {
 
 	// FIXME handle attachments
 	// update the record
-	stmt2, err := database.Conn.Prepare(`UPDATE snip SET (data, timestamp, name) = (?, ?, ?) WHERE uuid = ?`)
+	stmt2, err := st.Conn.Prepare(`UPDATE snip SET (data, timestamp, name) = (?, ?, ?) WHERE uuid = ?`)
 	if err != nil {
 		return err
 	}
 	defer stmt2.Close()
 
-	err = stmt2.Exec(s.Data, s.Timestamp.Format(time.RFC3339Nano), s.Name, s.UUID.String())
+	err = database.ExecStmt(st.Conn, stmt2, s.Data, s.Timestamp.Format(time.RFC3339Nano), s.Name, s.UUID.String())
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// CreateNewDatabase creates a new sqlite3 database
+// CreateNewDatabase is a wrapper around Store.CreateNewDatabase using the default store
 func CreateNewDatabase() error {
-	// build schema
-	err := database.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip(uuid TEXT, timestamp TEXT, name TEXT, data TEXT)`)
-	if err != nil {
-		return err
-	}
-	err = database.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip_attachment(uuid TEXT, snip_uuid TEXT, timestamp TEXT, name TEXT, data BLOB, size INTEGER)`)
+	return defaultStore().CreateNewDatabase()
+}
+
+// CreateNewDatabase creates a new sqlite3 database, or brings the schema of an existing one up to date, by applying
+// any pending Migrations
+func (st *Store) CreateNewDatabase() error {
+	return st.Migrate()
+}
+
+// addColumnIfMissing adds a column to a table that was created by an older schema
+func (st *Store) addColumnIfMissing(table string, column string, columnType string) error {
+	stmt, err := st.Conn.Prepare(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
 	if err != nil {
 		return err
 	}
-	err = database.Conn.Exec(`CREATE TABLE IF NOT EXISTS snip_index(term TEXT, uuid TEXT, count INTEGER, positions TEXT)`)
-	if err != nil {
-		return err
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return err
+		}
+		if !hasRow {
+			break
+		}
+		var (
+			cid  int
+			name string
+		)
+		err = stmt.Scan(&cid, &name)
+		if err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
 	}
 
-	return nil
+	log.Debug().Str("table", table).Str("column", column).Msg("adding missing column")
+	return st.Conn.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, columnType))
 }
 
-// CumulativeTermsCount returns a total of all occurrences of all known terms in a document's search index
+// CumulativeTermsCount is a wrapper around Store.CumulativeTermsCount using the default store
 func CumulativeTermsCount(id uuid.UUID) (int, error) {
+	return defaultStore().CumulativeTermsCount(id)
+}
+
+// CumulativeTermsCount returns a total of all occurrences of all known terms in a document's search index
+func (st *Store) CumulativeTermsCount(id uuid.UUID) (int, error) {
 	var count int
 
-	stmt, err := database.Conn.Prepare(`SELECT sum(count) from snip_index where uuid = ?`)
+	stmt, err := st.Conn.Prepare(`SELECT sum(count) from snip_index where uuid = ?`)
 	if err != nil {
 		return count, err
 	}
@@ -462,42 +979,117 @@ func CumulativeTermsCount(id uuid.UUID) (int, error) {
 	return count, nil
 }
 
-// Remove removes a snip from the database
+// Remove is a wrapper around Store.Remove using the default store
 func Remove(id uuid.UUID) error {
-	// remove associated attachments
-	attachments, err := GetAttachments(id)
-	if err != nil {
+	return defaultStore().Remove(id)
+}
+
+// Remove removes a snip from the database, moving it to the trash first when SoftDelete is set. The snip is copied to
+// the trash and deleted in a single transaction, so it is never lost or left in both places.
+func (st *Store) Remove(id uuid.UUID) error {
+	if err := st.checkWritable(); err != nil {
 		return err
 	}
-	for _, a := range attachments {
-		err = RemoveAttachment(a.UUID)
-		if err != nil {
-			return err
+	return database.WithTx(st.Conn, func() error {
+		if SoftDelete {
+			if err := st.trash(id); err != nil {
+				return err
+			}
+		}
+		// attachments, index entries, revisions, and metadata are removed so they do not reference a missing snip,
+		// without reading attachment data into memory
+		deletes := []string{
+			`DELETE FROM snip_attachment WHERE snip_uuid = ?`,
+			`DELETE FROM snip_index WHERE uuid = ?`,
+			`DELETE FROM snip_index_meta WHERE uuid = ?`,
+			`DELETE FROM snip_revision WHERE snip_uuid = ?`,
+			`DELETE FROM snip_meta WHERE snip_uuid = ?`,
+			`DELETE FROM snip WHERE uuid = ?`,
+		}
+		for _, d := range deletes {
+			err := st.Conn.Exec(d, id.String())
+			if err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+}
+
+// ChangeUUID is a wrapper around Store.ChangeUUID using the default store
+func ChangeUUID(oldID uuid.UUID, newID uuid.UUID) error {
+	return defaultStore().ChangeUUID(oldID, newID)
+}
+
+// ChangeUUID assigns a new uuid to a snip, updating its attachments, index entries, revisions, and metadata in a single
+// transaction
+func (st *Store) ChangeUUID(oldID uuid.UUID, newID uuid.UUID) error {
+	if err := st.checkWritable(); err != nil {
+		return err
 	}
-	// remove
-	stmt, err := database.Conn.Prepare(`DELETE from snip WHERE uuid = ?`, id.String())
+	exists, err := st.SnipExists(oldID)
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
-	err = stmt.Exec()
+	if !exists {
+		return fmt.Errorf("could not locate snip %s", oldID)
+	}
+	exists, err = st.SnipExists(newID)
 	if err != nil {
 		return err
 	}
-	return nil
+	if exists {
+		return fmt.Errorf("snip %s already exists", newID)
+	}
+
+	return database.WithTx(st.Conn, func() error {
+		updates := []string{
+			`UPDATE snip SET uuid = ? WHERE uuid = ?`,
+			`UPDATE snip_attachment SET snip_uuid = ? WHERE snip_uuid = ?`,
+			`UPDATE snip_index SET uuid = ? WHERE uuid = ?`,
+			`UPDATE snip_index_meta SET uuid = ? WHERE uuid = ?`,
+			`UPDATE snip_revision SET snip_uuid = ? WHERE snip_uuid = ?`,
+			`UPDATE snip_meta SET snip_uuid = ? WHERE snip_uuid = ?`,
+		}
+		for _, update := range updates {
+			err := st.Conn.Exec(update, newID.String(), oldID.String())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-// DropIndex drops the search index from the database
+// DropIndex is a wrapper around Store.DropIndex using the default store
 func DropIndex() error {
-	stmt, err := database.Conn.Prepare(`DELETE FROM snip_index`)
+	return defaultStore().DropIndex()
+}
+
+// DropIndex drops the search index from the database
+func (st *Store) DropIndex() error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	stmt, err := st.Conn.Prepare(`DELETE FROM snip_index`)
 	if err != nil {
 		return err
 	}
+	defer stmt.Close()
 	err = stmt.Exec()
 	if err != nil {
 		return err
 	}
+	// the stemming mode of each snip outlives the index, while hashes are cleared so that every snip is reindexed
+	err = st.Conn.Exec(`UPDATE snip_index_meta SET data_hash = NULL`)
+	if err != nil {
+		return err
+	}
+	// a rebuilt index adopts the current language and word symbols setting
+	err = st.Conn.Exec(`DELETE FROM snip_setting WHERE key IN (?, ?)`, indexLanguageKey, indexWordSymbolsKey)
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -513,11 +1105,35 @@ func FlattenString(input string) string {
 	return dataSummary
 }
 
-// GetAllSnipIDs returns a slice of all known snip uuids
+// SplitLines returns the lines of data, where a final newline does not begin another line
+func SplitLines(data string) []string {
+	if data == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+}
+
+// FoldString returns a string with compatibility decomposition applied, accents removed, and case lowered
+func FoldString(input string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	output, _, err := transform.String(t, input)
+	if err != nil {
+		// fall back to case folding only
+		output = input
+	}
+	return strings.ToLower(output)
+}
+
+// GetAllSnipIDs is a wrapper around Store.GetAllSnipIDs using the default store
 func GetAllSnipIDs() ([]uuid.UUID, error) {
+	return defaultStore().GetAllSnipIDs()
+}
+
+// GetAllSnipIDs returns a slice of all known snip uuids
+func (st *Store) GetAllSnipIDs() ([]uuid.UUID, error) {
 	var snipIDs []uuid.UUID
 
-	stmt, err := database.Conn.Prepare(`SELECT uuid from snip`)
+	stmt, err := st.Conn.Prepare(`SELECT uuid from snip`)
 	if err != nil {
 		return snipIDs, err
 	}
@@ -550,17 +1166,22 @@ func GetAllSnipIDs() ([]uuid.UUID, error) {
 	return snipIDs, nil
 }
 
-// GetAttachments returns a slice of Attachment associated with the supplied snip uuid
+// GetAttachments is a wrapper around Store.GetAttachments using the default store
 func GetAttachments(searchUUID uuid.UUID) ([]Attachment, error) {
+	return defaultStore().GetAttachments(searchUUID)
+}
+
+// GetAttachments returns a slice of Attachment associated with the supplied snip uuid
+func (st *Store) GetAttachments(searchUUID uuid.UUID) ([]Attachment, error) {
 	var attachments []Attachment
 
-	ids, err := GetAttachmentsUUID(searchUUID)
+	ids, err := st.GetAttachmentsUUID(searchUUID)
 	if err != nil {
 		return attachments, err
 	}
 
 	for _, id := range ids {
-		a, err := GetAttachmentFromUUID(id.String())
+		a, err := st.GetAttachmentFromUUID(id.String())
 		if err != nil {
 			return attachments, err
 		}
@@ -569,11 +1190,16 @@ func GetAttachments(searchUUID uuid.UUID) ([]Attachment, error) {
 	return attachments, nil
 }
 
-// GetAttachmentsAll returns a slice of uuids for all attachments in the system
+// GetAttachmentsAll is a wrapper around Store.GetAttachmentsAll using the default store
 func GetAttachmentsAll() ([]uuid.UUID, error) {
+	return defaultStore().GetAttachmentsAll()
+}
+
+// GetAttachmentsAll returns a slice of uuids for all attachments in the system
+func (st *Store) GetAttachmentsAll() ([]uuid.UUID, error) {
 	var attachmentIDs []uuid.UUID
 
-	stmt, err := database.Conn.Prepare(`SELECT uuid from snip_attachment`)
+	stmt, err := st.Conn.Prepare(`SELECT uuid from snip_attachment`)
 	if err != nil {
 		return attachmentIDs, err
 	}
@@ -606,11 +1232,16 @@ func GetAttachmentsAll() ([]uuid.UUID, error) {
 	return attachmentIDs, nil
 }
 
-// GetAttachmentsUUID returns a slice of attachment uuids associated with supplied snip uuid
+// GetAttachmentsUUID is a wrapper around Store.GetAttachmentsUUID using the default store
 func GetAttachmentsUUID(snipUUID uuid.UUID) ([]uuid.UUID, error) {
+	return defaultStore().GetAttachmentsUUID(snipUUID)
+}
+
+// GetAttachmentsUUID returns a slice of attachment uuids associated with supplied snip uuid
+func (st *Store) GetAttachmentsUUID(snipUUID uuid.UUID) ([]uuid.UUID, error) {
 	var results []uuid.UUID
 
-	stmt, err := database.Conn.Prepare(`SELECT uuid FROM snip_attachment WHERE snip_uuid = ?`)
+	stmt, err := st.Conn.Prepare(`SELECT uuid FROM snip_attachment WHERE snip_uuid = ?`)
 	if err != nil {
 		return results, err
 	}
@@ -646,8 +1277,29 @@ func GetAttachmentsUUID(snipUUID uuid.UUID) ([]uuid.UUID, error) {
 	return results, nil
 }
 
-// GetFromUUID retrieves a single Snip by its unique identifier
+// GetFromUUID is a wrapper around Store.GetFromUUID using the default store
 func GetFromUUID(searchUUID string) (Snip, error) {
+	return defaultStore().GetFromUUID(searchUUID)
+}
+
+// GetFromUUID retrieves a single Snip by its unique identifier
+func (st *Store) GetFromUUID(searchUUID string) (Snip, error) {
+	s, err := st.getFromUUID(searchUUID)
+	if err != nil {
+		return s, err
+	}
+
+	// gather attachments
+	s.Attachments, err = st.GetAttachments(s.UUID)
+	if err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+// getFromUUID retrieves a single Snip by its unique identifier without reading its attachments
+func (st *Store) getFromUUID(searchUUID string) (Snip, error) {
 	s := Snip{}
 
 	// determine exact or partial matching
@@ -667,10 +1319,10 @@ func GetFromUUID(searchUUID string) (Snip, error) {
 
 	var stmt *sqlite3.Stmt
 	if exactMatch {
-		stmt, err = database.Conn.Prepare(`SELECT uuid, data, timestamp, name FROM snip WHERE uuid = ?`, searchUUID)
+		stmt, err = st.Conn.Prepare(`SELECT uuid, data, timestamp, name, favorite FROM snip WHERE uuid = ?`, searchUUID)
 	} else {
 		searchUUIDFuzzy := "%" + searchUUID + "%"
-		stmt, err = database.Conn.Prepare(`SELECT uuid, data, timestamp, name FROM snip WHERE uuid LIKE ?`, searchUUIDFuzzy)
+		stmt, err = st.Conn.Prepare(`SELECT uuid, data, timestamp, name, favorite FROM snip WHERE uuid LIKE ?`, searchUUIDFuzzy)
 	}
 	if err != nil {
 		return s, err
@@ -681,7 +1333,8 @@ func GetFromUUID(searchUUID string) (Snip, error) {
 		return s, err
 	}
 
-	resultCount := 0
+	// gather all matches so that ambiguous results can be reported
+	var matches []Snip
 	for {
 		hasRow, err := stmt.Step()
 		if err != nil {
@@ -690,21 +1343,19 @@ func GetFromUUID(searchUUID string) (Snip, error) {
 		if !hasRow {
 			break
 		}
-		resultCount++
-		// enforce only one result to avoid ambiguous behavior
-		if resultCount > 1 {
-			return s, fmt.Errorf("database search returned multiple results")
-		}
+		s = Snip{}
 
 		var data string
 		var id string
 		var timestamp string
 		var name string
-		err = stmt.Scan(&id, &data, &timestamp, &name)
+		var favorite bool
+		err = stmt.Scan(&id, &data, &timestamp, &name, &favorite)
 		if err != nil {
 			return s, err
 		}
 		s.Data = data
+		s.Favorite = favorite
 		s.UUID, err = uuid.Parse(id)
 		if err != nil {
 			return s, fmt.Errorf("error parsing uuid string into struct")
@@ -714,25 +1365,111 @@ func GetFromUUID(searchUUID string) (Snip, error) {
 		if err != nil {
 			return s, err
 		}
+		matches = append(matches, s)
 	}
-	if resultCount == 0 {
+	if len(matches) == 0 {
 		return s, fmt.Errorf("database search returned zero results")
 	}
+	// enforce only one result to avoid ambiguous behavior
+	if len(matches) > 1 {
+		for idx := range matches {
+			// only identifying fields are useful for disambiguation
+			matches[idx].Data = ""
+		}
+		return Snip{}, &AmbiguousUUIDError{Search: searchUUID, Matches: matches}
+	}
 
-	// gather attachments
-	s.Attachments, err = GetAttachments(s.UUID)
+	return s, nil
+}
+
+// NamePrefix marks a snip reference as a name instead of a uuid
+const NamePrefix = "name:"
+
+// ResolveSnip is a wrapper around Store.ResolveSnip using the default store
+func ResolveSnip(ref string) (Snip, error) {
+	return defaultStore().ResolveSnip(ref)
+}
+
+// ResolveSnip returns the Snip identified by ref, which may be a full uuid, a partial uuid,
+// or a name preceded by NamePrefix
+func (st *Store) ResolveSnip(ref string) (Snip, error) {
+	if strings.HasPrefix(ref, NamePrefix) {
+		return st.GetFromName(strings.TrimPrefix(ref, NamePrefix))
+	}
+	return st.GetFromUUID(ref)
+}
+
+// GetFromName is a wrapper around Store.GetFromName using the default store
+func GetFromName(name string) (Snip, error) {
+	return defaultStore().GetFromName(name)
+}
+
+// GetFromName returns a Snip with the exact name, and errors if the name is not unique
+func (st *Store) GetFromName(name string) (Snip, error) {
+	ids, err := st.GetUUIDsFromName(name)
 	if err != nil {
-		return s, err
+		return Snip{}, err
+	}
+	switch len(ids) {
+	case 0:
+		return Snip{}, fmt.Errorf("no snip with name %s", name)
+	case 1:
+		return st.GetFromUUID(ids[0].String())
 	}
 
-	return s, nil
+	var matches []Snip
+	for _, id := range ids {
+		matches = append(matches, Snip{UUID: id, Name: name})
+	}
+	return Snip{}, &AmbiguousNameError{Name: name, Matches: matches}
 }
 
-// GetIndexTermCount returns the index count for a term matching id
+// GetUUIDsFromName is a wrapper around Store.GetUUIDsFromName using the default store
+func GetUUIDsFromName(name string) ([]uuid.UUID, error) {
+	return defaultStore().GetUUIDsFromName(name)
+}
+
+// GetUUIDsFromName returns the uuids of all snips with the exact name
+func (st *Store) GetUUIDsFromName(name string) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	stmt, err := st.Conn.Prepare(`SELECT uuid FROM snip WHERE name = ? ORDER BY timestamp`, name)
+	if err != nil {
+		return ids, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return ids, err
+		}
+		if !hasRow {
+			break
+		}
+		var idStr string
+		err = stmt.Scan(&idStr)
+		if err != nil {
+			return ids, err
+		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GetIndexTermCount is a wrapper around Store.GetIndexTermCount using the default store
 func GetIndexTermCount(term string, id uuid.UUID) (int, error) {
+	return defaultStore().GetIndexTermCount(term, id)
+}
+
+// GetIndexTermCount returns the index count for a term matching id
+func (st *Store) GetIndexTermCount(term string, id uuid.UUID) (int, error) {
 	var matches = 0
 	// return zero if nothing matches (which should not be present in database)
-	stmt, err := database.Conn.Prepare(`SELECT count from snip_index WHERE term = ? AND uuid = ?`)
+	stmt, err := st.Conn.Prepare(`SELECT count from snip_index WHERE term = ? AND uuid = ?`)
 	if err != nil {
 		return matches, err
 	}
@@ -756,50 +1493,96 @@ func GetIndexTermCount(term string, id uuid.UUID) (int, error) {
 	return matches, nil
 }
 
-// InsertSnip adds a new Snip to the database
+// InsertSnip is a wrapper around Store.InsertSnip using the default store
 func InsertSnip(s Snip) error {
-	stmt, err := database.Conn.Prepare(`INSERT INTO snip VALUES (?, ?, ?, ?)`)
+	return defaultStore().InsertSnip(s)
+}
+
+// InsertSnip adds a new Snip to the database
+func (st *Store) InsertSnip(s Snip) error {
+	if err := st.checkWritable(); err != nil {
+		return err
+	}
+	err := CheckDataSize(len(s.Data))
+	if err != nil {
+		return err
+	}
+
+	stmt, err := st.Conn.Prepare(`INSERT INTO snip (uuid, timestamp, name, data, favorite) VALUES (?, ?, ?, ?, ?)`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	// reference
-	err = stmt.Exec(s.UUID.String(), s.Timestamp.Format(time.RFC3339Nano), s.Name, s.Data)
+	err = database.ExecStmt(st.Conn, stmt, s.UUID.String(), s.Timestamp.Format(time.RFC3339Nano), s.Name, s.Data, s.Favorite)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// WordSymbols are the characters retained within words when KeepWordSymbols is enabled
+const WordSymbols = "._-/"
+
+// KeepWordSymbols retains words containing WordSymbols, such as versions, identifiers, and paths, as single words.
+// The index records this setting, and must be rebuilt after it is changed.
+var KeepWordSymbols = false
+
 // IsWord determines if a string is a valid word using unicode functions
 func IsWord(word string) bool {
-	for _, c := range word {
-		if !unicode.IsLetter(c) && !unicode.IsDigit(c) {
-			return false
+	for idx, c := range word {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			continue
+		}
+		// symbols are only allowed between letters or digits
+		if KeepWordSymbols && strings.ContainsRune(WordSymbols, c) && idx > 0 && idx < len(word)-1 {
+			continue
 		}
+		return false
 	}
 	return true
 }
 
-// List returns a slice of all Snips in the database
+// List is a wrapper around Store.List using the default store
 func List(limit int) ([]Snip, error) {
+	return defaultStore().List(limit)
+}
+
+// List returns a slice of all Snips in the database
+func (st *Store) List(limit int) ([]Snip, error) {
 	var results []Snip
 	var stmt *sqlite3.Stmt
 	var err error
 
 	if limit != 0 {
-		stmt, err = database.Conn.Prepare(`SELECT uuid, timestamp, name, data from snip LIMIT ?`, limit)
+		stmt, err = st.Conn.Prepare(`SELECT uuid, timestamp, name, data, favorite from snip LIMIT ?`, limit)
 		if err != nil {
 			return results, err
 		}
 	} else {
-		stmt, err = database.Conn.Prepare(`SELECT uuid, timestamp, name, data from snip`)
+		stmt, err = st.Conn.Prepare(`SELECT uuid, timestamp, name, data, favorite from snip`)
 		if err != nil {
 			return results, err
 		}
 	}
 	defer stmt.Close()
+	return scanSnips(stmt)
+}
+
+// ListNames is a wrapper around Store.ListNames using the default store
+func ListNames() ([]Snip, error) {
+	return defaultStore().ListNames()
+}
+
+// ListNames returns a Snip with only the UUID and Name set for every snip, without reading data
+func (st *Store) ListNames() ([]Snip, error) {
+	var results []Snip
+	stmt, err := st.Conn.Prepare(`SELECT uuid, name FROM snip`)
+	if err != nil {
+		return results, err
+	}
+	defer stmt.Close()
 
 	for {
 		hasRow, err := stmt.Step()
@@ -809,38 +1592,72 @@ func List(limit int) ([]Snip, error) {
 		if !hasRow {
 			break
 		}
-
-		var idStr string
-		var timestampStr string
-		var name string
-		var data string
-
-		err = stmt.Scan(&idStr, &timestampStr, &name, &data)
+		var idStr, name string
+		err = stmt.Scan(&idStr, &name)
 		if err != nil {
-			break
+			return results, err
 		}
-
 		id, err := uuid.Parse(idStr)
 		if err != nil {
 			return results, err
 		}
+		results = append(results, Snip{UUID: id, Name: name})
+	}
+	return results, nil
+}
 
-		timestamp, err := time.Parse(time.RFC3339Nano, timestampStr)
+// scanSnips returns a Snip without attachments for each row of stmt, which selects uuid, timestamp, name, data, and favorite
+func scanSnips(stmt *sqlite3.Stmt) ([]Snip, error) {
+	var results []Snip
+	for {
+		hasRow, err := stmt.Step()
 		if err != nil {
 			return results, err
 		}
-		// construct item
-		s := Snip{
-			UUID:      id,
-			Timestamp: timestamp,
-			Name:      name,
-			Data:      data,
+		if !hasRow {
+			break
+		}
+		s, err := scanSnip(stmt)
+		if err != nil {
+			return results, err
 		}
 		results = append(results, s)
 	}
 	return results, nil
 }
 
+// scanSnip returns a Snip without attachments from the current row of stmt, as selected for scanSnips
+func scanSnip(stmt *sqlite3.Stmt) (Snip, error) {
+	var idStr string
+	var timestampStr string
+	var name string
+	var data string
+	var favorite bool
+
+	err := stmt.Scan(&idStr, &timestampStr, &name, &data, &favorite)
+	if err != nil {
+		return Snip{}, err
+	}
+
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return Snip{}, err
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, timestampStr)
+	if err != nil {
+		return Snip{}, err
+	}
+	// construct item
+	return Snip{
+		UUID:      id,
+		Timestamp: timestamp,
+		Name:      name,
+		Data:      data,
+		Favorite:  favorite,
+	}, nil
+}
+
 // New returns a new snippet and generates a new UUID for it
 func New() Snip {
 	return Snip{
@@ -851,38 +1668,67 @@ func New() Snip {
 	}
 }
 
-// ScoreCounts returns a floating point score for search result validity
+// ScoreWeights determines the relative contribution of each component to a search score
+type ScoreWeights struct {
+	Coverage   float64 // ratio of search terms matched
+	Prominence float64 // ratio of search terms to all indexed terms of the document
+}
+
+// DefaultScoreWeights weighs term coverage and prominence equally
+var DefaultScoreWeights = ScoreWeights{Coverage: 1, Prominence: 1}
+
+// ScoreCounts is a wrapper around Store.ScoreCounts using the default store
 func ScoreCounts(id uuid.UUID, terms []string, counts []SearchCount) (float64, error) {
-	var matchTermsRatio float64
-	var matchProminence float64
-	// calculate the ratio of matching terms to search terms
-	matchTermsRatio = float64(len(counts)) / float64(len(terms))
+	return defaultStore().ScoreCounts(id, terms, counts)
+}
 
-	// calculate the ratio representing the prominence of the search term is within the document itself
-	// add all the counts for all terms in the index matching this uuid
-	indexedTerms, err := CumulativeTermsCount(id)
-	if err != nil {
-		return 0, err
-	}
-	if indexedTerms != 0 {
-		matchProminence = float64(len(terms)) / float64(indexedTerms)
-	}
-	log.Debug().Float64("matchTermsRatio", matchTermsRatio).Msg("scoring")
-	log.Debug().Float64("matchProminence", matchProminence).Msg("scoring")
+// ScoreCounts returns a floating point score for search result validity using DefaultScoreWeights
+func (st *Store) ScoreCounts(id uuid.UUID, terms []string, counts []SearchCount) (float64, error) {
+	return st.ScoreCountsWeighted(id, terms, counts, DefaultScoreWeights)
+}
 
-	return (matchTermsRatio + matchProminence) / 2.0, nil
+// ScoreCountsWeighted is a wrapper around Store.ScoreCountsWeighted using the default store
+func ScoreCountsWeighted(id uuid.UUID, terms []string, counts []SearchCount, weights ScoreWeights) (float64, error) {
+	return defaultStore().ScoreCountsWeighted(id, terms, counts, weights)
 }
 
-// SearchDataTerm returns a slice of Snips whose data matches supplied terms
+// ScoreCountsWeighted returns a floating point score for search result validity, as the weighted average of its components
+func (st *Store) ScoreCountsWeighted(id uuid.UUID, terms []string, counts []SearchCount, weights ScoreWeights) (float64, error) {
+	return st.ScoreCountsIDF(id, terms, counts, weights, nil)
+}
+
+// SearchDataTerm is a wrapper around Store.SearchDataTerm using the default store
 func SearchDataTerm(term string) ([]Snip, error) {
+	return defaultStore().SearchDataTerm(term)
+}
+
+// SearchDataTerm returns a slice of Snips whose data matches supplied terms
+func (st *Store) SearchDataTerm(term string) ([]Snip, error) {
+	return st.SearchDataTermLimit(term, 0, 0)
+}
+
+// SearchDataTermLimit is a wrapper around Store.SearchDataTermLimit using the default store
+func SearchDataTermLimit(term string, offset int, limit int) ([]Snip, error) {
+	return defaultStore().SearchDataTermLimit(term, offset, limit)
+}
+
+// SearchDataTermLimit returns up to limit Snips whose data matches supplied terms, skipping the first offset matches in
+// order of creation. A limit of 0 returns all matches. Only the returned snips are loaded from the database.
+func (st *Store) SearchDataTermLimit(term string, offset int, limit int) ([]Snip, error) {
 	var searchResult []Snip
 	if term == "" {
 		return searchResult, fmt.Errorf("refusing to search for empty string")
 	}
+	if offset < 0 || limit < 0 {
+		return searchResult, fmt.Errorf("offset and limit must not be negative")
+	}
+	if limit == 0 {
+		limit = -1 // no limit in sqlite
+	}
 
 	// modify term for fuzziness
 	termFuzzy := "%" + term + "%"
-	stmt, err := database.Conn.Prepare(`SELECT uuid from snip where data LIKE ?`, termFuzzy)
+	stmt, err := st.Conn.Prepare(`SELECT uuid from snip where data LIKE ? ORDER BY timestamp, uuid LIMIT ? OFFSET ?`, termFuzzy, limit, offset)
 	if err != nil {
 		return searchResult, err
 	}
@@ -904,7 +1750,7 @@ func SearchDataTerm(term string) ([]Snip, error) {
 			break
 		}
 
-		s, err := GetFromUUID(idStr)
+		s, err := st.GetFromUUID(idStr)
 		if err != nil {
 			return searchResult, err
 		}
@@ -914,98 +1760,198 @@ func SearchDataTerm(term string) ([]Snip, error) {
 	return searchResult, nil
 }
 
-// SearchIndexTerm searches the index and returns results matching the given term
+// SearchDataTermFold is a wrapper around Store.SearchDataTermFold using the default store
+func SearchDataTermFold(term string) ([]Snip, error) {
+	return defaultStore().SearchDataTermFold(term)
+}
+
+// SearchDataTermFold returns a slice of Snips whose data contains the term, ignoring case and accents
+func (st *Store) SearchDataTermFold(term string) ([]Snip, error) {
+	var searchResult []Snip
+	if term == "" {
+		return searchResult, fmt.Errorf("refusing to search for empty string")
+	}
+	termFolded := FoldString(term)
+
+	// sqlite cannot fold unicode, so data is compared after retrieval
+	stmt, err := st.Conn.Prepare(`SELECT uuid, data from snip`)
+	if err != nil {
+		return searchResult, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return searchResult, err
+		}
+		if !hasRow {
+			break
+		}
+
+		var idStr string
+		var data string
+		err = stmt.Scan(&idStr, &data)
+		if err != nil {
+			return searchResult, err
+		}
+		if !strings.Contains(FoldString(data), termFolded) {
+			continue
+		}
+
+		s, err := st.GetFromUUID(idStr)
+		if err != nil {
+			return searchResult, err
+		}
+		searchResult = append(searchResult, s)
+	}
+
+	return searchResult, nil
+}
+
+// SearchIndexTerm is a wrapper around Store.SearchIndexTerm using the default store
 func SearchIndexTerm(terms []string, requireAll bool) (map[uuid.UUID][]SearchCount, error) {
+	return defaultStore().SearchIndexTerm(terms, requireAll)
+}
+
+// SearchIndexTerm searches the index and returns results matching the given term
+func (st *Store) SearchIndexTerm(terms []string, requireAll bool) (map[uuid.UUID][]SearchCount, error) {
 	var searchResults = make(map[uuid.UUID][]SearchCount, 0)
 
 	if len(terms) <= 0 {
 		return searchResults, fmt.Errorf("refusing to search for empty string")
 	}
+	err := st.checkIndexLanguage()
+	if err != nil {
+		return searchResults, err
+	}
+	err = st.checkIndexWordSymbols()
+	if err != nil {
+		return searchResults, err
+	}
 
 	for _, term := range terms {
+		term = cleanTerm(term)
 		// stem the term
-		termStemmed, err := snowball.Stem(term, "english", true)
+		termStemmed, err := stemTerm(term)
+		if err != nil {
+			return searchResults, err
+		}
 		log.Debug().Str("termStemmed", termStemmed).Msg("term stemmed")
 
-		stmt, err := database.Conn.Prepare(`SELECT uuid, count FROM snip_index WHERE term = ?`, termStemmed)
+		_, err = st.searchIndexExact(searchResults, term, termStemmed)
 		if err != nil {
 			return searchResults, err
 		}
-		// defer stmt.Close()
+	}
 
-		for {
-			hasRow, err := stmt.Step()
-			if err != nil {
-				stmt.Close()
-				return searchResults, err
-			}
-			if !hasRow {
-				break
-			}
+	if requireAll {
+		return pruneIncomplete(searchResults, terms), nil
+	}
 
-			var (
-				idStr string
-				count int
-			)
-			err = stmt.Scan(&idStr, &count)
-			if err != nil {
-				stmt.Close()
-				return searchResults, err
-			}
-			id, err := uuid.Parse(idStr)
-			if err != nil {
-				stmt.Close()
-				return searchResults, err
-			}
-			result := SearchCount{
-				Term:  term,
-				Stem:  termStemmed,
-				Count: count,
-			}
-			searchResults[id] = append(searchResults[id], result)
+	return searchResults, nil
+}
+
+// searchIndexExact adds index matches of a search term to results and returns the number of matches. Snips indexed
+// with stemming match stem, while snips indexed verbatim match the lowercased term.
+func (st *Store) searchIndexExact(results map[uuid.UUID][]SearchCount, term string, stem string) (int, error) {
+	stmt, err := st.Conn.Prepare(`SELECT i.uuid, i.count, i.term FROM snip_index i LEFT JOIN snip_index_meta m ON m.uuid = i.uuid
+		WHERE (i.term = ? AND coalesce(m.stemmed, 1) != 0) OR (i.term = ? AND m.stemmed = 0)`, stem, strings.ToLower(term))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	return collectIndexMatches(results, stmt, term, 0)
+}
+
+// searchIndexStem adds index matches of stem to results, attributed to the search term, and returns the number of matches
+func (st *Store) searchIndexStem(results map[uuid.UUID][]SearchCount, term string, stem string, distance int) (int, error) {
+	stmt, err := st.Conn.Prepare(`SELECT uuid, count, term FROM snip_index WHERE term = ?`, stem)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	return collectIndexMatches(results, stmt, term, distance)
+}
+
+// collectIndexMatches adds the uuid, count, and indexed term of each row of stmt to results, attributed to the search term
+func collectIndexMatches(results map[uuid.UUID][]SearchCount, stmt *sqlite3.Stmt, term string, distance int) (int, error) {
+	matches := 0
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return matches, err
+		}
+		if !hasRow {
+			break
+		}
+
+		var (
+			idStr   string
+			count   int
+			indexed string
+		)
+		err = stmt.Scan(&idStr, &count, &indexed)
+		if err != nil {
+			return matches, err
 		}
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return matches, err
+		}
+		result := SearchCount{
+			Term:     term,
+			Stem:     indexed,
+			Count:    count,
+			Distance: distance,
+		}
+		results[id] = append(results[id], result)
+		matches++
 	}
+	return matches, nil
+}
 
-	if requireAll {
-		// prune results that do not contain all supplied terms
-		searchResultsPruned := make(map[uuid.UUID][]SearchCount, 0)
-		for id, result := range searchResults {
-			// check each id
-			var termsCollected []string
-			for _, item := range result {
-				// check if term is in collected
-				if !func() bool {
-					for _, t := range termsCollected {
-						if t == item.Term {
-							return true
-						}
+// pruneIncomplete returns only the results that contain all supplied terms
+func pruneIncomplete(searchResults map[uuid.UUID][]SearchCount, terms []string) map[uuid.UUID][]SearchCount {
+	searchResultsPruned := make(map[uuid.UUID][]SearchCount, 0)
+	for id, result := range searchResults {
+		// check each id
+		var termsCollected []string
+		for _, item := range result {
+			// check if term is in collected
+			if !func() bool {
+				for _, t := range termsCollected {
+					if t == item.Term {
+						return true
 					}
-					return false
-				}() {
-					termsCollected = append(termsCollected, item.Term)
 				}
-			}
-			// keep this id
-			if len(termsCollected) == len(terms) {
-				searchResultsPruned[id] = result
+				return false
+			}() {
+				termsCollected = append(termsCollected, item.Term)
 			}
 		}
-
-		return searchResultsPruned, nil
+		// keep this id
+		if len(termsCollected) == len(terms) {
+			searchResultsPruned[id] = result
+		}
 	}
+	return searchResultsPruned
+}
 
-	return searchResults, nil
+// SearchUUID is a wrapper around Store.SearchUUID using the default store
+func SearchUUID(term string) ([]Snip, error) {
+	return defaultStore().SearchUUID(term)
 }
 
 // SearchUUID returns a slice of Snips with uuids matching partial search term
-func SearchUUID(term string) ([]Snip, error) {
+func (st *Store) SearchUUID(term string) ([]Snip, error) {
 	var searchResult []Snip
 	if term == "" {
 		return searchResult, fmt.Errorf("refusing to search for empty string")
 	}
 
 	termFuzzy := "%" + term + "%"
-	stmt, err := database.Conn.Prepare(`SELECT uuid from snip where uuid LIKE ?`, termFuzzy)
+	stmt, err := st.Conn.Prepare(`SELECT uuid from snip where uuid LIKE ?`, termFuzzy)
 	if err != nil {
 		return searchResult, err
 	}
@@ -1026,7 +1972,49 @@ func SearchUUID(term string) ([]Snip, error) {
 			// TODO scrutinize this
 			break
 		}
-		s, err := GetFromUUID(idStr)
+		s, err := st.GetFromUUID(idStr)
+		if err != nil {
+			return searchResult, err
+		}
+		searchResult = append(searchResult, s)
+	}
+	return searchResult, nil
+}
+
+// SearchNameTerm is a wrapper around Store.SearchNameTerm using the default store
+func SearchNameTerm(term string) ([]Snip, error) {
+	return defaultStore().SearchNameTerm(term)
+}
+
+// SearchNameTerm returns a slice of Snips whose name contains the term, ignoring case for ASCII characters
+func (st *Store) SearchNameTerm(term string) ([]Snip, error) {
+	var searchResult []Snip
+	if term == "" {
+		return searchResult, fmt.Errorf("refusing to search for empty string")
+	}
+
+	termFuzzy := "%" + term + "%"
+	stmt, err := st.Conn.Prepare(`SELECT uuid FROM snip WHERE name LIKE ?`, termFuzzy)
+	if err != nil {
+		return searchResult, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return searchResult, err
+		}
+		if !hasRow {
+			break
+		}
+
+		var idStr string
+		err = stmt.Scan(&idStr)
+		if err != nil {
+			return searchResult, err
+		}
+		s, err := st.GetFromUUID(idStr)
 		if err != nil {
 			return searchResult, err
 		}
@@ -1044,13 +2032,69 @@ func ShortenUUID(id uuid.UUID) []string {
 	return idSplit
 }
 
+// SnipExists is a wrapper around Store.SnipExists using the default store
+func SnipExists(id uuid.UUID) (bool, error) {
+	return defaultStore().SnipExists(id)
+}
+
+// SnipExists reports whether a snip with the exact uuid is present in the database
+func (st *Store) SnipExists(id uuid.UUID) (bool, error) {
+	stmt, err := st.Conn.Prepare(`SELECT count() FROM snip WHERE uuid = ?`, id.String())
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return false, err
+	}
+	if !hasRow {
+		return false, fmt.Errorf("count query returned zero rows")
+	}
+	var count int
+	err = stmt.Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// HighlightTerms returns data with each word sharing a stem with any of terms wrapped by the highlight function.
+// All other characters, including whitespace and punctuation, are preserved.
+func HighlightTerms(data string, terms []string, highlight func(a ...interface{}) string) (string, error) {
+	stems := make(map[string]bool)
+	for _, term := range terms {
+		stem, err := stemTerm(strings.ToLower(term))
+		if err != nil {
+			return "", err
+		}
+		stems[stem] = true
+	}
+
+	var b strings.Builder
+	for _, word := range wordSegments(data) {
+		if !IsWord(word) {
+			b.WriteString(word)
+			continue
+		}
+		stem, err := stemTerm(strings.ToLower(word))
+		if err != nil {
+			return "", err
+		}
+		if stems[stem] {
+			b.WriteString(highlight(word))
+		} else {
+			b.WriteString(word)
+		}
+	}
+	return b.String(), nil
+}
+
 // SplitWords splits words using unicode standard splitting functions
 func SplitWords(data string) []string {
-	var word string
 	var output []string
-	state := -1
-	for len(data) > 0 {
-		word, data, state = uniseg.FirstWordInString(data, state)
+	for _, word := range wordSegments(data) {
 		if IsWord(word) {
 			output = append(output, word)
 		}
@@ -1059,9 +2103,42 @@ func SplitWords(data string) []string {
 	return output
 }
 
-// WriteAttachment writes the attached file to the current working directory
+// wordSegments splits data at unicode word boundaries, keeping all characters. When KeepWordSymbols is enabled,
+// words separated by a single symbol such as path/to/file are joined into one segment.
+func wordSegments(data string) []string {
+	var segments []string
+	var word string
+	state := -1
+	for len(data) > 0 {
+		word, data, state = uniseg.FirstWordInString(data, state)
+		segments = append(segments, word)
+	}
+	if !KeepWordSymbols {
+		return segments
+	}
+
+	var joined []string
+	for i := 0; i < len(segments); i++ {
+		n := len(joined)
+		if n > 0 && i+1 < len(segments) && len(segments[i]) == 1 && strings.Contains(WordSymbols, segments[i]) &&
+			IsWord(joined[n-1]) && IsWord(segments[i+1]) {
+			joined[n-1] += segments[i] + segments[i+1]
+			i++
+			continue
+		}
+		joined = append(joined, segments[i])
+	}
+	return joined
+}
+
+// WriteAttachment is a wrapper around Store.WriteAttachment using the default store
 func WriteAttachment(id uuid.UUID, outfile string, forceWrite bool) (int, error) {
-	a, err := GetAttachmentFromUUID(id.String())
+	return defaultStore().WriteAttachment(id, outfile, forceWrite)
+}
+
+// WriteAttachment writes the attached file to the current working directory
+func (st *Store) WriteAttachment(id uuid.UUID, outfile string, forceWrite bool) (int, error) {
+	a, err := st.GetAttachmentMetadata(id)
 	if err != nil {
 		log.Debug().Err(err).Str("uuid", id.String()).Msg("error obtaining attachment from id")
 		return 0, err
@@ -1079,6 +2156,23 @@ func WriteAttachment(id uuid.UUID, outfile string, forceWrite bool) (int, error)
 		log.Debug().Err(err).Msg("error opening new file for writing")
 		return 0, err
 	}
+	defer f.Close()
+
+	// large attachments are copied in chunks to avoid holding the entire blob in memory
+	if a.Size >= AttachmentStreamThreshold {
+		bytesWritten, err := st.StreamAttachment(id, f)
+		if err != nil {
+			log.Debug().Err(err).Str("filename", a.Name).Msg("error attempting to stream data to file")
+			return 0, err
+		}
+		return int(bytesWritten), nil
+	}
+
+	a, err = st.GetAttachmentFromUUID(id.String())
+	if err != nil {
+		log.Debug().Err(err).Str("uuid", id.String()).Msg("error obtaining attachment from id")
+		return 0, err
+	}
 	bytesWritten, err := f.Write(a.Data)
 	if err != nil {
 		log.Debug().Err(err).Str("filename", a.Name).Msg("error attempting to write data to file")
@@ -1,15 +1,23 @@
 package snip
 
 import (
+	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/base64"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/bvinc/go-sqlite-lite/sqlite3"
 	"github.com/google/uuid"
 	"github.com/ryanfrishkorn/snip/database"
 	"io"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -20,6 +28,21 @@ var UUIDTest = uuid.New()
 var DataTest = "this is VeRy UnIQu3 sample data, and stemming is good for searching"
 var NameTest = "Test Snip of the Century"
 
+// newTestStore returns a Store backed by a new in-memory database, closed when the test finishes
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	conn, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	st := NewStore(conn)
+	if err := st.CreateNewDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
 // AddDataCSV adds data to the test database
 func AddDataCSV() error {
 	// TODO check for exising database, we must create it from scratch
@@ -225,6 +248,25 @@ func TestSnipGenerateName(t *testing.T) {
 	if strings.Compare(expected, modified) != 0 {
 		t.Errorf(`expected string "%s", got "%s"`, expected, modified)
 	}
+
+	tests := []struct {
+		data      string
+		wordCount int
+		expected  string
+	}{
+		{"two words", 10, "two words"},
+		{"", 5, ""},
+		{"  \n\t ", 5, ""},
+		{" -- leading and trailing -- ", 5, "leading and trailing"},
+		{"some data", 0, ""},
+		{"some data", -1, ""},
+	}
+	for _, test := range tests {
+		s.Data = test.data
+		if name := s.GenerateName(test.wordCount); name != test.expected {
+			t.Errorf("expected %q for %q with %d words, got %q", test.expected, test.data, test.wordCount, name)
+		}
+	}
 }
 
 func TestSnipUpdate(t *testing.T) {
@@ -356,3 +398,4042 @@ func TestUnicodeSplit(t *testing.T) {
 		}
 	}
 }
+
+func TestExportImport(t *testing.T) {
+	s := New()
+	s.Name = "export test"
+	s.Data = DataTest
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// binary attachment data must survive the round trip exactly
+	var blob []byte
+	for i := 0; i < 256; i++ {
+		blob = append(blob, byte(i))
+	}
+	err = s.Attach("blob.bin", blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetMeta("source", "stackoverflow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = ExportAll(&buf)
+	if err != nil {
+		t.Fatalf("ExportAll returned error: %v", err)
+	}
+
+	err = Remove(s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := ImportAll(&buf)
+	if err != nil {
+		t.Fatalf("ImportAll returned error: %v", err)
+	}
+	// cleanup - leave it the way you found it
+	defer func() {
+		err := Remove(s.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+	if count != 1 {
+		t.Errorf("expected 1 imported snip, got %d", count)
+	}
+
+	c, err := GetFromUUID(s.UUID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != s.Name || c.Data != s.Data {
+		t.Errorf("imported snip does not match original")
+	}
+	if len(c.Attachments) != 1 {
+		t.Fatalf("expected 1 attachment, got %d", len(c.Attachments))
+	}
+	if !bytes.Equal(c.Attachments[0].Data, blob) {
+		t.Errorf("attachment data did not round-trip exactly")
+	}
+	meta, err := c.Meta()
+	if err != nil || !reflect.DeepEqual(meta, map[string]string{"source": "stackoverflow"}) {
+		t.Errorf("metadata did not round-trip, got %v: %v", meta, err)
+	}
+}
+
+func TestSnipRenderMarkdown(t *testing.T) {
+	s := New()
+	s.Name = NameTest
+	s.Data = "#!/usr/bin/env python3\nprint(\"```\")\n"
+	s.Attachments = []Attachment{{Name: "file.txt", Size: 42}}
+
+	md, err := s.RenderMarkdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"# " + NameTest + "\n",
+		"````python\n",
+		"\n````\n",
+		"- file.txt (42 bytes)\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(md, e) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", e, md)
+		}
+	}
+}
+
+func TestDetectMIME(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n")
+	if mime := DetectMIME(png); mime != "image/png" {
+		t.Errorf("expected image/png, got %s", mime)
+	}
+
+	a := Attachment{MIME: DetectMIME([]byte(DataTest))}
+	if a.MediaType() != "text/plain" {
+		t.Errorf("expected text/plain media type, got %s", a.MediaType())
+	}
+}
+
+func TestAttachFileStream(t *testing.T) {
+	// force the streaming path for a small file
+	threshold := AttachmentStreamThreshold
+	AttachmentStreamThreshold = 16
+	defer func() {
+		AttachmentStreamThreshold = threshold
+	}()
+
+	dir := t.TempDir()
+	var blob []byte
+	for i := 0; i < 200*1024; i++ {
+		blob = append(blob, byte(i%251))
+	}
+	infile := dir + "/blob.bin"
+	err := os.WriteFile(infile, blob, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := GetFromUUID(UUIDTest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := s.AttachFile(infile)
+	if err != nil {
+		t.Fatalf("AttachFile returned error: %v", err)
+	}
+	if size != int64(len(blob)) {
+		t.Errorf("expected %d bytes attached, got %d", len(blob), size)
+	}
+
+	ids, err := GetAttachmentsUUID(s.UUID)
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("expected one attachment, got %d: %v", len(ids), err)
+	}
+	defer func() {
+		err := RemoveAttachment(ids[0])
+		if err != nil {
+			t.Fatalf("removing attachment returned error: %v", err)
+		}
+	}()
+
+	outfile := dir + "/blob.out"
+	written, err := WriteAttachment(ids[0], outfile, false)
+	if err != nil {
+		t.Fatalf("WriteAttachment returned error: %v", err)
+	}
+	if written != len(blob) {
+		t.Errorf("expected %d bytes written, got %d", len(blob), written)
+	}
+	data, err := os.ReadFile(outfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, blob) {
+		t.Errorf("streamed attachment data does not match original")
+	}
+}
+
+func TestCheckDataSize(t *testing.T) {
+	max := MaxDataSize
+	defer func() {
+		MaxDataSize = max
+	}()
+
+	MaxDataSize = 10
+	if err := CheckDataSize(10); err != nil {
+		t.Errorf("expected nil error at limit, got %v", err)
+	}
+	var sizeErr *DataSizeError
+	if err := CheckDataSize(11); !errors.As(err, &sizeErr) {
+		t.Errorf("expected DataSizeError above limit, got %v", err)
+	}
+
+	s := New()
+	s.Data = "this data is too large"
+	if err := InsertSnip(s); !errors.As(err, &sizeErr) {
+		t.Errorf("expected InsertSnip to return DataSizeError, got %v", err)
+	}
+
+	// zero disables the check
+	MaxDataSize = 0
+	if err := CheckDataSize(1 << 30); err != nil {
+		t.Errorf("expected nil error with limit disabled, got %v", err)
+	}
+}
+
+func TestCopyToClipboard(t *testing.T) {
+	outfile := t.TempDir() + "/clipboard"
+	fake := []clipboardCommand{
+		{Name: "snip-nonexistent-clipboard"},
+		{Name: "sh", Args: []string{"-c", "cat > " + outfile}},
+	}
+	err := copyWith(fake, []byte(DataTest))
+	if err != nil {
+		t.Fatalf("copyWith returned error: %v", err)
+	}
+	data, err := os.ReadFile(outfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != DataTest {
+		t.Errorf("expected clipboard data %q, got %q", DataTest, data)
+	}
+
+	// missing tools should be named in the error
+	err = copyWith(fake[:1], []byte(DataTest))
+	if err == nil || !strings.Contains(err.Error(), "snip-nonexistent-clipboard") {
+		t.Errorf("expected error naming missing tool, got %v", err)
+	}
+}
+
+func TestSearchIndexTermStemError(t *testing.T) {
+	stem := stemTerm
+	defer func() {
+		stemTerm = stem
+	}()
+	stemErr := errors.New("stemmer failure")
+	stemTerm = func(word string) (string, error) {
+		return "", stemErr
+	}
+
+	_, err := SearchIndexTerm([]string{"searching"}, true)
+	if !errors.Is(err, stemErr) {
+		t.Errorf("expected stemmer error to propagate, got %v", err)
+	}
+}
+
+func TestSearchDataTermFold(t *testing.T) {
+	if folded := FoldString("Café CRÈME ﬁne"); folded != "cafe creme fine" {
+		t.Errorf(`expected "cafe creme fine", got "%s"`, folded)
+	}
+
+	s := New()
+	s.Name = "fold test"
+	s.Data = "A visit to the Café Crème"
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := Remove(s.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+
+	results, err := SearchDataTerm("cafe creme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected LIKE search to not fold accents, got %d results", len(results))
+	}
+
+	results, err = SearchDataTermFold("cafe creme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].UUID != s.UUID {
+		t.Errorf("expected folded search to match snip %s, got %d results", s.UUID, len(results))
+	}
+}
+
+func TestSnipTopTerms(t *testing.T) {
+	s := New()
+	s.Name = "terms test"
+	s.Data = "Searching searched search data data stemming"
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := Remove(s.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+	err = s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	terms, err := s.TopTerms(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []SearchCount{
+		{Term: "search", Stem: "search", Count: 3},
+		{Term: "data", Stem: "data", Count: 2},
+	}
+	if len(terms) != len(expected) {
+		t.Fatalf("expected %d terms, got %d: %v", len(expected), len(terms), terms)
+	}
+	for idx, e := range expected {
+		if terms[idx] != e {
+			t.Errorf("expected %v, got %v", e, terms[idx])
+		}
+	}
+
+	all, err := s.TopTerms(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Errorf("expected 3 terms, got %d", len(all))
+	}
+}
+
+func TestFindDuplicateSnips(t *testing.T) {
+	var ids []uuid.UUID
+	for i := 0; i < 2; i++ {
+		s := New()
+		s.Name = "duplicate test"
+		s.Data = "this duplicate data is only used by TestFindDuplicateSnips"
+		s.Timestamp = s.Timestamp.Add(time.Duration(i) * time.Hour)
+		err := InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.UUID)
+	}
+	defer func() {
+		for _, id := range ids {
+			err := Remove(id)
+			if err != nil {
+				t.Fatalf("delete function returned error: %v", err)
+			}
+		}
+	}()
+
+	duplicates, err := FindDuplicateSnips()
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, ok := duplicates[HashData([]byte("this duplicate data is only used by TestFindDuplicateSnips"))]
+	if !ok {
+		t.Fatalf("expected duplicate group for inserted snips, got %v", duplicates)
+	}
+	if len(group) != 2 || group[0] != ids[0] || group[1] != ids[1] {
+		t.Errorf("expected group ordered oldest to newest %v, got %v", ids, group)
+	}
+}
+
+func TestHighlightTerms(t *testing.T) {
+	data := "Running runs, but the runner ran.\nRun!"
+	expected := "[Running] [runs], but the runner ran.\n[Run]!"
+	mark := func(a ...interface{}) string {
+		return fmt.Sprintf("[%s]", a...)
+	}
+	highlighted, err := HighlightTerms(data, []string{"run"}, mark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if highlighted != expected {
+		t.Errorf(`expected "%s", got "%s"`, expected, highlighted)
+	}
+}
+
+func TestSplitWordsSymbols(t *testing.T) {
+	data := "Install v1.2.3, set snake_case and path/to/file or foo-bar. _private end."
+	expected := []string{"Install", "set", "and", "path", "to", "file", "or", "foo", "bar", "end"}
+	if words := SplitWords(data); strings.Join(words, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v, got %v", expected, words)
+	}
+
+	KeepWordSymbols = true
+	defer func() {
+		KeepWordSymbols = false
+	}()
+	expected = []string{"Install", "v1.2.3", "set", "snake_case", "and", "path/to/file", "or", "foo-bar", "end"}
+	if words := SplitWords(data); strings.Join(words, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v, got %v", expected, words)
+	}
+	for _, word := range []string{"v1.2.3", "snake_case", "path/to/file"} {
+		if !IsWord(word) {
+			t.Errorf("expected %s to be a word", word)
+		}
+	}
+	for _, word := range []string{"_private", "trailing.", "/", "a b"} {
+		if IsWord(word) {
+			t.Errorf("expected %s not to be a word", word)
+		}
+	}
+
+	mark := func(a ...interface{}) string {
+		return fmt.Sprintf("[%s]", a...)
+	}
+	highlighted, err := HighlightTerms("open path/to/file.", []string{"path/to/file"}, mark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if highlighted != "open [path/to/file]." {
+		t.Errorf("expected joined word highlighted, got %q", highlighted)
+	}
+
+	// a separate store, as the index records the word symbols setting it was built with
+	st := newTestStore(t)
+	s := New()
+	s.Name = "word symbols"
+	s.Data = data
+	err = st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Index(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, term := range []string{"v1.2.3", "snake_case", "path/to/file"} {
+		results, err := st.SearchIndexTerm([]string{term}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := results[s.UUID]; !ok {
+			t.Errorf("expected search for %s to find snip %s", term, s.UUID)
+		}
+	}
+}
+
+func TestBase64(t *testing.T) {
+	data := make([]byte, 200)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	var buf bytes.Buffer
+	err := EncodeBase64(&buf, data)
+	if err != nil {
+		t.Fatalf("EncodeBase64 returned error: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) < 2 {
+		t.Errorf("expected encoded output wrapped into multiple lines, got %d", len(lines))
+	}
+	for _, line := range lines {
+		if len(line) > base64LineLength {
+			t.Errorf("expected lines of at most %d characters, got %d", base64LineLength, len(line))
+		}
+	}
+
+	decoded, err := DecodeBase64(buf.Bytes())
+	if err != nil {
+		t.Fatalf("DecodeBase64 returned error: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Errorf("expected decoded data to match original")
+	}
+
+	// the final line is ended whether or not it is full
+	expectedEncoded := map[int]string{
+		0:  "",
+		57: base64.StdEncoding.EncodeToString(data[:57]) + "\n",
+		58: base64.StdEncoding.EncodeToString(data[:57]) + "\n" + base64.StdEncoding.EncodeToString(data[57:58]) + "\n",
+	}
+	for size, expected := range expectedEncoded {
+		buf.Reset()
+		err = EncodeBase64(&buf, data[:size])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != expected {
+			t.Errorf("expected %q encoding %d bytes, got %q", expected, size, buf.String())
+		}
+	}
+
+	_, err = DecodeBase64([]byte("not base64!"))
+	if err == nil {
+		t.Errorf("expected error decoding invalid base64")
+	}
+}
+
+func TestMoveAttachment(t *testing.T) {
+	src, err := GetFromUUID(UUIDTest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := New()
+	dest.Name = "move attachment test"
+	dest.Data = "destination for TestMoveAttachment"
+	err = InsertSnip(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := Remove(dest.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+
+	err = src.Attach("move.txt", []byte("attachment to be moved"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := GetAttachmentsUUID(src.UUID)
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("expected one attachment, got %d: %v", len(ids), err)
+	}
+
+	// destination must exist
+	err = MoveAttachment(ids[0], uuid.New())
+	if err == nil {
+		t.Errorf("expected error moving attachment to missing snip")
+	}
+	// attachment must exist
+	err = MoveAttachment(uuid.New(), dest.UUID)
+	if err == nil {
+		t.Errorf("expected error moving missing attachment")
+	}
+
+	err = MoveAttachment(ids[0], dest.UUID)
+	if err != nil {
+		t.Fatalf("MoveAttachment returned error: %v", err)
+	}
+	a, err := GetAttachmentMetadata(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.SnipUUID != dest.UUID {
+		t.Errorf("expected attachment owned by %s, got %s", dest.UUID, a.SnipUUID)
+	}
+	remaining, err := GetAttachmentsUUID(src.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("expected no attachments on source snip, got %d", len(remaining))
+	}
+}
+
+func TestRenameAttachment(t *testing.T) {
+	s, err := GetFromUUID(UUIDTest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Attach("misnamed.txt", []byte("attachment to be renamed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := GetAttachmentsUUID(s.UUID)
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("expected one attachment, got %d: %v", len(ids), err)
+	}
+	defer func() {
+		err := RemoveAttachment(ids[0])
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+
+	err = RenameAttachment(ids[0], "")
+	if err == nil {
+		t.Errorf("expected error renaming attachment to empty string")
+	}
+	err = RenameAttachment(uuid.New(), "missing.txt")
+	if err == nil {
+		t.Errorf("expected error renaming missing attachment")
+	}
+
+	err = RenameAttachment(ids[0], "renamed.txt")
+	if err != nil {
+		t.Fatalf("RenameAttachment returned error: %v", err)
+	}
+	a, err := GetAttachmentMetadata(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "renamed.txt" {
+		t.Errorf("expected name renamed.txt, got %s", a.Name)
+	}
+}
+
+func TestReplaceAttachment(t *testing.T) {
+	s, err := GetFromUUID(UUIDTest.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Attach("changing.txt", []byte("original attachment data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := GetAttachmentsUUID(s.UUID)
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("expected one attachment, got %d: %v", len(ids), err)
+	}
+	defer func() {
+		err := RemoveAttachment(ids[0])
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+
+	err = ReplaceAttachment(uuid.New(), []byte("missing"))
+	if err == nil {
+		t.Errorf("expected error replacing missing attachment")
+	}
+
+	replacement := []byte("\x89PNG\r\n\x1a\n replacement")
+	err = ReplaceAttachment(ids[0], replacement)
+	if err != nil {
+		t.Fatalf("ReplaceAttachment returned error: %v", err)
+	}
+	a, err := GetAttachmentFromUUID(ids[0].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "changing.txt" || a.SnipUUID != s.UUID {
+		t.Errorf("expected name and snip kept, got %s %s", a.Name, a.SnipUUID)
+	}
+	if string(a.Data) != string(replacement) || a.Size != len(replacement) {
+		t.Errorf("expected replaced data of %d bytes, got %q (%d bytes recorded)", len(replacement), a.Data, a.Size)
+	}
+	if a.MIME != "image/png" {
+		t.Errorf("expected MIME type detected from replaced data, got %s", a.MIME)
+	}
+}
+
+func TestNameFromFilename(t *testing.T) {
+	tests := map[string]string{
+		"notes.txt":          "notes",
+		"/tmp/dir/README.md": "README",
+		"archive.tar.gz":     "archive.tar",
+		".bashrc":            ".bashrc",
+		"plain":              "plain",
+	}
+	for p, expected := range tests {
+		if name := NameFromFilename(p); name != expected {
+			t.Errorf("expected name %s for %s, got %s", expected, p, name)
+		}
+	}
+}
+
+func TestImportFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"notes.md":        []byte("markdown notes for TestImportFiles"),
+		"todo.txt":        []byte("text todo for TestImportFiles"),
+		"image.png":       {0x89, 'P', 'N', 'G', 0x00, 0xff},
+		"sub/nested.txt":  []byte("nested text for TestImportFiles"),
+		"sub/binary.txt":  {0xff, 0xfe, 0x00},
+		"sub/ignored.log": []byte("not matched by extension"),
+	}
+	err := os.Mkdir(dir+"/sub", 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range files {
+		err = os.WriteFile(dir+"/"+name, data, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := FindImportFiles(dir, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 3 {
+		t.Errorf("expected 3 files without recursion, got %d: %v", len(found), found)
+	}
+	found, err = FindImportFiles(dir, true, []string{".md", "txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 4 {
+		t.Fatalf("expected 4 files matching extensions, got %d: %v", len(found), found)
+	}
+
+	var imported []Snip
+	defer func() {
+		for _, s := range imported {
+			err := Remove(s.UUID)
+			if err != nil {
+				t.Fatalf("delete function returned error: %v", err)
+			}
+		}
+	}()
+	for _, f := range found {
+		s, err := ImportFile(f)
+		if strings.HasSuffix(f, "binary.txt") {
+			if !errors.Is(err, ErrNotText) {
+				t.Errorf("expected ErrNotText for %s, got %v", f, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("ImportFile returned error for %s: %v", f, err)
+		}
+		imported = append(imported, s)
+	}
+
+	s, err := GetFromUUID(imported[0].UUID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "notes" || s.Data != string(files["notes.md"]) {
+		t.Errorf("unexpected imported snip name %q data %q", s.Name, s.Data)
+	}
+}
+
+func TestGetFromUUIDAmbiguous(t *testing.T) {
+	var ids []uuid.UUID
+	for _, id := range []string{"abcdef00-0000-4000-8000-000000000001", "abcdef00-0000-4000-8000-000000000002"} {
+		s := New()
+		s.UUID = uuid.MustParse(id)
+		s.Name = "ambiguous " + id[len(id)-1:]
+		s.Data = "data for TestGetFromUUIDAmbiguous"
+		err := InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.UUID)
+	}
+	defer func() {
+		for _, id := range ids {
+			err := Remove(id)
+			if err != nil {
+				t.Fatalf("delete function returned error: %v", err)
+			}
+		}
+	}()
+
+	_, err := GetFromUUID("abcdef00-0000")
+	var ambiguous *AmbiguousUUIDError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("expected AmbiguousUUIDError, got %v", err)
+	}
+	if len(ambiguous.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %d", len(ambiguous.Matches))
+	}
+	for idx, m := range ambiguous.Matches {
+		if m.UUID != ids[idx] || m.Name == "" {
+			t.Errorf("unexpected match %s %q", m.UUID, m.Name)
+		}
+	}
+
+	// an exact uuid still resolves
+	s, err := GetFromUUID("abcdef00-0000-4000-8000-000000000002")
+	if err != nil || s.UUID != ids[1] {
+		t.Errorf("expected %s, got %s: %v", ids[1], s.UUID, err)
+	}
+}
+
+func TestSnipRevisions(t *testing.T) {
+	maxRevisions := MaxRevisions
+	MaxRevisions = 2
+	defer func() {
+		MaxRevisions = maxRevisions
+	}()
+
+	s := New()
+	s.Name = "revision 0"
+	s.Data = "original data for TestSnipRevisions"
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := Remove(s.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+
+	for i := 1; i <= 3; i++ {
+		s.Name = fmt.Sprintf("revision %d", i)
+		s.Data = fmt.Sprintf("changed data %d for TestSnipRevisions", i)
+		err = s.Update()
+		if err != nil {
+			t.Fatalf("Update returned error: %v", err)
+		}
+	}
+
+	// only the most recent revisions are retained
+	revisions, err := GetRevisions(s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(revisions))
+	}
+	if revisions[0].Revision != 2 || revisions[0].Name != "revision 1" {
+		t.Errorf("expected revision 2 named %q, got %d %q", "revision 1", revisions[0].Revision, revisions[0].Name)
+	}
+
+	err = s.Restore(2)
+	if err != nil {
+		t.Fatalf("Restore returned error: %v", err)
+	}
+	restored, err := GetFromUUID(s.UUID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Name != "revision 1" || restored.Data != "changed data 1 for TestSnipRevisions" {
+		t.Errorf("unexpected restored snip name %q data %q", restored.Name, restored.Data)
+	}
+
+	// the index reflects restored data only
+	count, err := GetIndexTermCount("3", s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected replaced term to be removed from index, got count %d", count)
+	}
+
+	err = s.Restore(1)
+	if err == nil {
+		t.Errorf("expected error restoring pruned revision")
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"bird", "bird", 0},
+		{"bird", "brid", 2},
+		{"bird", "birds", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+		{"café", "cafe", 1},
+	}
+	for _, test := range tests {
+		d := levenshtein(test.a, test.b)
+		if d != test.expected {
+			t.Errorf("levenshtein(%q, %q) expected %d, got %d", test.a, test.b, test.expected, d)
+		}
+	}
+}
+
+func TestSearchIndexTermFuzzy(t *testing.T) {
+	s := New()
+	s.Name = "fuzzy test"
+	s.Data = "the quixotic zebrafish swims"
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := Remove(s.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+	err = s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	similar, err := FindSimilarTerms("zebrafich", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(similar) == 0 || similar[0] != "zebrafish" {
+		t.Fatalf("expected zebrafish as closest similar term, got %v", similar)
+	}
+
+	exact, err := SearchIndexTerm([]string{"zebrafich"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exact[s.UUID]) != 0 {
+		t.Errorf("expected no exact results for misspelled term")
+	}
+
+	results, err := SearchIndexTermFuzzy([]string{"zebrafich"}, true, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts, ok := results[s.UUID]
+	if !ok {
+		t.Fatalf("expected fuzzy result for misspelled term")
+	}
+	if counts[0].Stem != "zebrafish" || counts[0].Distance != 1 {
+		t.Errorf("expected match on zebrafish at distance 1, got %+v", counts[0])
+	}
+
+	// fuzzy matches score lower than the equivalent exact match
+	fuzzyScore, err := ScoreCounts(s.UUID, []string{"zebrafich"}, counts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = SearchIndexTerm([]string{"zebrafish"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exactScore, err := ScoreCounts(s.UUID, []string{"zebrafish"}, results[s.UUID])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fuzzyScore >= exactScore {
+		t.Errorf("expected fuzzy score %f to be lower than exact score %f", fuzzyScore, exactScore)
+	}
+}
+
+func TestScoreCountsWeighted(t *testing.T) {
+	s := New()
+	s.Name = "score weights test"
+	s.Data = "alpha beta gamma delta"
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := Remove(s.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+	err = s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one of two terms matched gives coverage 0.5, and two terms of four indexed gives prominence 0.5
+	// one of one term matched gives coverage 1.0, and one term of four indexed gives prominence 0.25
+	two := []string{"alpha", "omega"}
+	one := []string{"alpha"}
+	counts := []SearchCount{{Term: "alpha", Stem: "alpha", Count: 1}}
+	tests := []struct {
+		terms    []string
+		weights  ScoreWeights
+		expected float64
+	}{
+		{two, ScoreWeights{Coverage: 1, Prominence: 1}, 0.5},
+		{one, ScoreWeights{Coverage: 1, Prominence: 1}, 0.625},
+		{one, ScoreWeights{Coverage: 3, Prominence: 1}, 0.8125},
+		{one, ScoreWeights{Coverage: 1, Prominence: 0}, 1.0},
+		{one, ScoreWeights{Coverage: 0, Prominence: 2}, 0.25},
+	}
+	for _, test := range tests {
+		score, err := ScoreCountsWeighted(s.UUID, test.terms, counts, test.weights)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(score-test.expected) > 1e-9 {
+			t.Errorf("weights %+v terms %v expected score %f, got %f", test.weights, test.terms, test.expected, score)
+		}
+	}
+
+	// the default wrapper weighs components equally
+	score, err := ScoreCounts(s.UUID, one, counts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(score-0.625) > 1e-9 {
+		t.Errorf("expected default score 0.625, got %f", score)
+	}
+
+	_, err = ScoreCountsWeighted(s.UUID, one, counts, ScoreWeights{})
+	if err == nil {
+		t.Errorf("expected error for zero weights")
+	}
+	_, err = ScoreCountsWeighted(s.UUID, one, counts, ScoreWeights{Coverage: -1, Prominence: 2})
+	if err == nil {
+		t.Errorf("expected error for negative weight")
+	}
+}
+
+func TestGetFromName(t *testing.T) {
+	var ids []uuid.UUID
+	for _, name := range []string{"unique name test", "shared name test", "shared name test"} {
+		s := New()
+		s.Name = name
+		s.Data = "data for TestGetFromName"
+		err := InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.UUID)
+	}
+	defer func() {
+		for _, id := range ids {
+			err := Remove(id)
+			if err != nil {
+				t.Fatalf("delete function returned error: %v", err)
+			}
+		}
+	}()
+
+	s, err := GetFromName("unique name test")
+	if err != nil {
+		t.Fatalf("GetFromName returned error: %v", err)
+	}
+	if s.UUID != ids[0] {
+		t.Errorf("expected %s, got %s", ids[0], s.UUID)
+	}
+
+	_, err = GetFromName("shared name test")
+	var ambiguous *AmbiguousNameError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("expected AmbiguousNameError, got %v", err)
+	}
+	if len(ambiguous.Matches) != 2 {
+		t.Errorf("expected 2 matches, got %d", len(ambiguous.Matches))
+	}
+
+	_, err = GetFromName("missing name test")
+	if err == nil {
+		t.Errorf("expected error for missing name")
+	}
+
+	duplicates, err := FindDuplicateNames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(duplicates["shared name test"]) != 2 {
+		t.Errorf("expected 2 snips sharing name, got %v", duplicates)
+	}
+	if _, ok := duplicates["unique name test"]; ok {
+		t.Errorf("expected unique name to be absent from duplicates")
+	}
+}
+
+func TestResolveSnip(t *testing.T) {
+	s := New()
+	s.Name = "resolve test"
+	s.Data = "data for TestResolveSnip"
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := Remove(s.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+
+	for _, ref := range []string{s.UUID.String(), s.UUID.String()[:13], "name:resolve test"} {
+		r, err := ResolveSnip(ref)
+		if err != nil {
+			t.Errorf("ResolveSnip(%q) returned error: %v", ref, err)
+			continue
+		}
+		if r.UUID != s.UUID {
+			t.Errorf("ResolveSnip(%q) expected %s, got %s", ref, s.UUID, r.UUID)
+		}
+	}
+
+	_, err = ResolveSnip("name:resolve")
+	if err == nil {
+		t.Errorf("expected error for partial name")
+	}
+}
+
+func TestSnipIndexCurrent(t *testing.T) {
+	s := New()
+	s.Name = "index current test"
+	s.Data = "original indexcurrent words"
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := Remove(s.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+
+	current, err := s.IndexCurrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current {
+		t.Errorf("expected snip that was never indexed to not be current")
+	}
+
+	err = s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err = s.IndexCurrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current {
+		t.Errorf("expected snip to be current after indexing")
+	}
+
+	s.Data = "replacement indexcurrent words"
+	err = s.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err = s.IndexCurrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current {
+		t.Errorf("expected snip to be stale after data changed")
+	}
+
+	// reindexing removes terms that no longer appear in the data
+	err = s.Reindex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, err := GetIndexTermCount("origin", s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected stale term to be removed, got count %d", count)
+	}
+	current, err = s.IndexCurrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !current {
+		t.Errorf("expected snip to be current after reindexing")
+	}
+}
+
+// indexRows returns the index entries of a snip in a comparable form
+func indexRows(t *testing.T, id uuid.UUID) []string {
+	t.Helper()
+	stmt, err := database.Conn.Prepare(`SELECT term, count, positions FROM snip_index WHERE uuid = ? ORDER BY term`, id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	var rows []string
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hasRow {
+			break
+		}
+		var term, positions string
+		var count int
+		err = stmt.Scan(&term, &count, &positions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, fmt.Sprintf("%s %d %s", term, count, positions))
+	}
+	return rows
+}
+
+func TestIndexSnips(t *testing.T) {
+	var ids []uuid.UUID
+	serial := make(map[uuid.UUID][]string)
+	for i := 0; i < 8; i++ {
+		s := New()
+		s.Name = fmt.Sprintf("parallel index test %d", i)
+		s.Data = strings.Repeat(fmt.Sprintf("the parallel worker %d indexes running words ", i), i+1)
+		err := InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.UUID)
+		err = s.Index()
+		if err != nil {
+			t.Fatal(err)
+		}
+		serial[s.UUID] = indexRows(t, s.UUID)
+		// leave stale entries that must be replaced
+		err = s.SetIndexTermCount("stale", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, id := range ids {
+			err := Remove(id)
+			if err != nil {
+				t.Fatalf("delete function returned error: %v", err)
+			}
+		}
+	}()
+
+	var calls int
+	indexed, err := IndexSnips(context.Background(), ids, 3, false, func(done int, total int) {
+		calls++
+		if done != calls || total != len(ids) {
+			t.Errorf("unexpected progress %d/%d on call %d", done, total, calls)
+		}
+	})
+	if err != nil {
+		t.Fatalf("IndexSnips returned error: %v", err)
+	}
+	if indexed != len(ids) || calls != len(ids) {
+		t.Errorf("expected %d indexed with progress for each, got %d indexed and %d calls", len(ids), indexed, calls)
+	}
+	for _, id := range ids {
+		rows := indexRows(t, id)
+		if strings.Join(rows, "\n") != strings.Join(serial[id], "\n") {
+			t.Errorf("parallel index of %s differs from serial index:\n%v\n%v", id, rows, serial[id])
+		}
+	}
+
+	// nothing changed since indexing
+	indexed, err = IndexSnips(context.Background(), ids, 2, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexed != 0 {
+		t.Errorf("expected incremental index to skip all snips, indexed %d", indexed)
+	}
+
+	_, err = IndexSnips(context.Background(), ids, 0, false, nil)
+	if err == nil {
+		t.Errorf("expected error for zero workers")
+	}
+}
+
+func TestIndexSnipsFailureKeepsIndex(t *testing.T) {
+	defer func() {
+		Language = DefaultLanguage
+	}()
+	st := newTestStore(t)
+
+	s := New()
+	s.Data = "the running workers"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Index(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// writing the new terms fails after the previous terms would have been removed
+	Language = "french"
+	var langErr *IndexLanguageError
+	if _, err = st.IndexSnips(context.Background(), []uuid.UUID{s.UUID}, 1, false, nil); !errors.As(err, &langErr) {
+		t.Fatalf("expected IndexLanguageError, got %v", err)
+	}
+	Language = DefaultLanguage
+	results, err := st.SearchIndexTerm([]string{"running"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := results[s.UUID]; !ok {
+		t.Errorf("expected the previous index of the snip kept after a failure, got %v", results)
+	}
+}
+
+func TestStoreIsolation(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "isolated store"
+	s.Data = "data written to a separate database"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatalf("InsertSnip returned error: %v", err)
+	}
+	err = st.Index(&s)
+	if err != nil {
+		t.Fatalf("Index returned error: %v", err)
+	}
+
+	got, err := st.GetFromUUID(s.UUID.String())
+	if err != nil {
+		t.Fatalf("GetFromUUID returned error: %v", err)
+	}
+	if got.Name != s.Name || got.Data != s.Data {
+		t.Errorf("expected %q %q, got %q %q", s.Name, s.Data, got.Name, got.Data)
+	}
+
+	// the default store must not see snips of another connection
+	exists, err := SnipExists(s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Errorf("snip %s inserted into separate store is visible in default store", s.UUID)
+	}
+}
+
+func TestVerifyDatabase(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "verify"
+	s.Data = "consistent data"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Index(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Attach(&s, "valid.txt", []byte("attachment"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := st.VerifyDatabase()
+	if err != nil {
+		t.Fatalf("VerifyDatabase returned error: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	orphan := uuid.New()
+	statements := []string{
+		fmt.Sprintf(`INSERT INTO snip_attachment (uuid, snip_uuid, timestamp, name, data, size) VALUES ('%s', '%s', '%s', 'orphan', x'00', 1)`, uuid.New(), orphan, time.Now().Format(time.RFC3339Nano)),
+		fmt.Sprintf(`INSERT INTO snip_index (term, uuid, count, positions) VALUES ('orphan', '%s', 1, '0')`, orphan),
+		`UPDATE snip_attachment SET size = 1 WHERE name = 'valid.txt'`,
+		`UPDATE snip SET timestamp = 'invalid'`,
+	}
+	for _, statement := range statements {
+		err = st.Conn.Exec(statement)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	problems, err = st.VerifyDatabase()
+	if err != nil {
+		t.Fatalf("VerifyDatabase returned error: %v", err)
+	}
+	expected := []string{"snip_attachment", "snip_index", "snip_attachment", "snip"}
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %d: %v", len(expected), len(problems), problems)
+	}
+	for idx, p := range problems {
+		if p.Table != expected[idx] {
+			t.Errorf("expected problem %d in table %s, got %s: %s", idx, expected[idx], p.Table, p.Description)
+		}
+	}
+	if problems[1].UUID != orphan.String() {
+		t.Errorf("expected orphaned index uuid %s, got %s", orphan, problems[1].UUID)
+	}
+}
+
+func TestChangeUUID(t *testing.T) {
+	s := New()
+	s.Name = "change uuid"
+	s.Data = "original data to be moved"
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Attach("moved.txt", []byte("attachment follows the snip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// create a revision
+	s.Data = "modified data to be moved"
+	err = s.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Reindex()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newID := uuid.New()
+	defer func() {
+		err := Remove(newID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+
+	// the new uuid must not already exist
+	err = ChangeUUID(s.UUID, UUIDTest)
+	if err == nil {
+		t.Errorf("expected error changing to an existing uuid")
+	}
+	err = ChangeUUID(uuid.New(), newID)
+	if err == nil {
+		t.Errorf("expected error changing uuid of missing snip")
+	}
+
+	err = ChangeUUID(s.UUID, newID)
+	if err != nil {
+		t.Fatalf("ChangeUUID returned error: %v", err)
+	}
+	exists, err := SnipExists(s.UUID)
+	if err != nil || exists {
+		t.Errorf("expected old uuid to be absent, exists: %v err: %v", exists, err)
+	}
+	moved, err := GetFromUUID(newID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Data != s.Data {
+		t.Errorf("expected data %q, got %q", s.Data, moved.Data)
+	}
+	attachments, err := GetAttachmentsUUID(newID)
+	if err != nil || len(attachments) != 1 {
+		t.Errorf("expected one attachment on new uuid, got %d: %v", len(attachments), err)
+	}
+	revisions, err := GetRevisions(newID)
+	if err != nil || len(revisions) != 1 {
+		t.Errorf("expected one revision on new uuid, got %d: %v", len(revisions), err)
+	}
+	current, err := moved.IndexCurrent()
+	if err != nil || !current {
+		t.Errorf("expected index of new uuid to be current, got %v: %v", current, err)
+	}
+	if len(indexRows(t, s.UUID)) != 0 || len(indexRows(t, newID)) == 0 {
+		t.Errorf("expected index entries to move to the new uuid")
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	s := New()
+	s.UUID = uuid.MustParse("99bc71c7-573c-403d-a560-996bde675030")
+	s.Name = "template test"
+	s.Timestamp = time.Date(2023, 6, 30, 2, 43, 28, 0, time.UTC)
+
+	output, err := RenderTemplate("{{.UUID}}\t{{.Name}}", s)
+	if err != nil {
+		t.Fatalf("RenderTemplate returned error: %v", err)
+	}
+	if output != "99bc71c7-573c-403d-a560-996bde675030\ttemplate test" {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	presets := map[string]string{
+		"short": "99bc71c7 template test",
+		"long":  "99bc71c7-573c-403d-a560-996bde675030 2023-06-30 02:43:28 template test",
+	}
+	for name, expected := range presets {
+		output, err = RenderTemplate(TemplatePresets[name], s)
+		if err != nil {
+			t.Fatalf("preset %s returned error: %v", name, err)
+		}
+		if output != expected {
+			t.Errorf("expected preset %s to produce %q, got %q", name, expected, output)
+		}
+	}
+
+	result := ScoredSnip{Snip: s, Score: 0.5, SearchCounts: []SearchCount{{Stem: "templat", Count: 2}}}
+	output, err = RenderTemplate(`{{short .UUID}} {{.Score}}{{range .SearchCounts}} {{.Stem}}:{{.Count}}{{end}}`, result)
+	if err != nil {
+		t.Fatalf("RenderTemplate returned error: %v", err)
+	}
+	if output != "99bc71c7 0.5 templat:2" {
+		t.Errorf("unexpected output %q", output)
+	}
+
+	_, err = RenderTemplate("{{.Missing}}", s)
+	if err == nil {
+		t.Errorf("expected error for missing field")
+	}
+	_, err = RenderTemplate("{{.Name", s)
+	if err == nil {
+		t.Errorf("expected error for invalid template")
+	}
+}
+
+func TestStoreReadOnly(t *testing.T) {
+	filename := t.TempDir() + "/readonly.sqlite3"
+	conn, err := sqlite3.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := NewStore(conn)
+	err = st.CreateNewDatabase()
+	if err != nil {
+		t.Fatalf("CreateNewDatabase returned error: %v", err)
+	}
+	s := New()
+	s.Name = "read-only store"
+	s.Data = "data that must not change"
+	err = st.InsertSnip(s)
+	if err != nil {
+		t.Fatalf("InsertSnip returned error: %v", err)
+	}
+	conn.Close()
+
+	conn, err = sqlite3.Open(filename, sqlite3.OPEN_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	st = &Store{Conn: conn, ReadOnly: true}
+
+	got, err := st.GetFromUUID(s.UUID.String())
+	if err != nil {
+		t.Fatalf("GetFromUUID returned error: %v", err)
+	}
+	if got.Data != s.Data {
+		t.Errorf("expected %q, got %q", s.Data, got.Data)
+	}
+
+	writes := map[string]func() error{
+		"InsertSnip": func() error { return st.InsertSnip(New()) },
+		"Update":     func() error { return st.Update(&got) },
+		"Remove":     func() error { return st.Remove(got.UUID) },
+		"Attach":     func() error { return st.Attach(&got, "file.txt", []byte("data")) },
+		"Index":      func() error { return st.Index(&got) },
+		"TouchSnip":  func() error { return st.TouchSnip(got.UUID) },
+	}
+	for name, write := range writes {
+		err = write()
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s: expected ErrReadOnly, got %v", name, err)
+		}
+	}
+}
+
+func TestReindexAll(t *testing.T) {
+	st := newTestStore(t)
+
+	var snips []Snip
+	for i := 0; i < 3; i++ {
+		s := New()
+		s.Data = fmt.Sprintf("reindex every snip %d", i)
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// stale entries must not survive the rebuild
+		err = st.SetIndexTermCount(&s, "stale", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snips = append(snips, s)
+	}
+
+	var calls int
+	err := st.ReindexAll(context.Background(), func(done int, total int) {
+		calls++
+		if done != calls || total != len(snips) {
+			t.Errorf("unexpected progress %d/%d on call %d", done, total, calls)
+		}
+	})
+	if err != nil {
+		t.Fatalf("ReindexAll returned error: %v", err)
+	}
+	if calls != len(snips) {
+		t.Errorf("expected %d progress calls, got %d", len(snips), calls)
+	}
+	for _, s := range snips {
+		current, err := st.IndexCurrent(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !current {
+			t.Errorf("expected snip %s to be indexed", s.UUID)
+		}
+	}
+	results, err := st.SearchIndexTerm([]string{"stale"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected stale terms to be removed, got %v", results)
+	}
+
+	// a nil progress is allowed
+	err = st.ReindexAll(context.Background(), nil)
+	if err != nil {
+		t.Errorf("ReindexAll returned error with nil progress: %v", err)
+	}
+}
+
+func TestDiffSnips(t *testing.T) {
+	a := New()
+	a.Name = "before"
+	a.Data = "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven\ntwelve\n"
+	b := a
+	b.UUID = uuid.New()
+	b.Name = "after"
+	b.Data = "zero\none\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nNINE\nten\neleven\ntwelve\n"
+
+	expected := fmt.Sprintf(`--- %s before
++++ %s after
+@@ -1,3 +1,4 @@
++zero
+ one
+ two
+ three
+@@ -6,7 +7,7 @@
+ six
+ seven
+ eight
+-nine
++NINE
+ ten
+ eleven
+ twelve
+`, a.UUID, b.UUID)
+	if output := DiffSnips(a, b); output != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	// changes separated by few unchanged lines share a hunk
+	b.Data = "one\ntwo\nTHREE\nfour\nfive\nsix\nSEVEN\n"
+	a.Data = "one\ntwo\nthree\nfour\nfive\nsix\nseven\n"
+	expected = fmt.Sprintf(`--- %s before
++++ %s after
+@@ -1,7 +1,7 @@
+ one
+ two
+-three
++THREE
+ four
+ five
+ six
+-seven
++SEVEN
+`, a.UUID, b.UUID)
+	if output := DiffSnips(a, b); output != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	if output := DiffSnips(a, a); output != "" {
+		t.Errorf("expected no difference for identical data, got %q", output)
+	}
+}
+
+func TestOpenWith(t *testing.T) {
+	outfile := t.TempDir() + "/opened"
+	fake := []openCommand{
+		{Name: "snip-nonexistent-opener"},
+		{Name: "sh", Args: []string{"-c", `cp "$1" ` + outfile, "sh"}},
+	}
+	infile := t.TempDir() + "/file.txt"
+	err := os.WriteFile(infile, []byte(DataTest), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = openWith(fake, infile)
+	if err != nil {
+		t.Fatalf("openWith returned error: %v", err)
+	}
+	data, err := os.ReadFile(outfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != DataTest {
+		t.Errorf("expected opened file data %q, got %q", DataTest, data)
+	}
+
+	err = openWith(fake[:1], infile)
+	if !errors.Is(err, ErrNoOpener) || !strings.Contains(err.Error(), "snip-nonexistent-opener") {
+		t.Errorf("expected ErrNoOpener naming missing tool, got %v", err)
+	}
+}
+
+func TestAttachmentsTotalSize(t *testing.T) {
+	st := newTestStore(t)
+
+	total, err := st.AttachmentsTotalSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 {
+		t.Errorf("expected zero total without attachments, got %d", total)
+	}
+
+	s := New()
+	s.Data = "snip with attachments"
+	err = st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{"first", "second attachment"} {
+		err = st.Attach(&s, "file.txt", []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := len("first") + len("second attachment")
+
+	total, err = st.AttachmentsTotalSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != expected {
+		t.Errorf("expected total %d, got %d", expected, total)
+	}
+	s, err = st.GetFromUUID(s.UUID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.AttachmentsTotalSize() != expected {
+		t.Errorf("expected snip total %d, got %d", expected, s.AttachmentsTotalSize())
+	}
+}
+
+func TestIndexLanguage(t *testing.T) {
+	defer func() {
+		Language = DefaultLanguage
+	}()
+	st := newTestStore(t)
+
+	lang, err := st.IndexLanguage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lang != "" {
+		t.Errorf("expected no language before indexing, got %q", lang)
+	}
+
+	Language = "french"
+	s := New()
+	s.Data = "les chevaux mangent"
+	err = st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Index(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := st.SearchIndexTerm([]string{"cheval"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Errorf("expected french stem to match, got %v", results)
+	}
+
+	// the index must be rebuilt before another language is used
+	Language = "english"
+	_, err = st.SearchIndexTerm([]string{"chevaux"}, true)
+	var langErr *IndexLanguageError
+	if !errors.As(err, &langErr) || langErr.Index != "french" || langErr.Language != "english" {
+		t.Errorf("expected IndexLanguageError from search, got %v", err)
+	}
+	err = st.Index(&s)
+	if !errors.As(err, &langErr) {
+		t.Errorf("expected IndexLanguageError from index, got %v", err)
+	}
+	err = st.ReindexAll(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lang, err = st.IndexLanguage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lang != "english" {
+		t.Errorf("expected rebuilt index language english, got %q", lang)
+	}
+
+	// indexes built before the language was recorded are english
+	err = st.Conn.Exec(`DELETE FROM snip_setting`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lang, err = st.IndexLanguage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lang != DefaultLanguage {
+		t.Errorf("expected %s for unrecorded language, got %q", DefaultLanguage, lang)
+	}
+
+	if CheckLanguage("klingon") == nil {
+		t.Errorf("expected error for unsupported language")
+	}
+	if err = CheckLanguage("german"); err == nil || !strings.Contains(err.Error(), "snowball") {
+		t.Errorf("expected error explaining that german has no stemmer, got %v", err)
+	}
+}
+
+func TestIndexWordSymbols(t *testing.T) {
+	defer func() {
+		KeepWordSymbols = false
+	}()
+	st := newTestStore(t)
+
+	if _, indexed, err := st.IndexWordSymbols(); err != nil || indexed {
+		t.Errorf("expected no setting before indexing, got %v %v", indexed, err)
+	}
+
+	s := New()
+	s.Data = "a/b/c/d/e/f/g/h/i/j target"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Index(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep, indexed, err := st.IndexWordSymbols()
+	if err != nil || !indexed || keep {
+		t.Errorf("expected index recorded without word symbols, got %v %v %v", keep, indexed, err)
+	}
+
+	// the words split at search time differ from those indexed, so the index must be rebuilt
+	KeepWordSymbols = true
+	_, err = st.SearchIndexTerm([]string{"target"}, true)
+	var symbolsErr *IndexWordSymbolsError
+	if !errors.As(err, &symbolsErr) || symbolsErr.Index || !symbolsErr.Keep {
+		t.Errorf("expected IndexWordSymbolsError from search, got %v", err)
+	}
+	_, err = st.SearchIndexTermFuzzy([]string{"target"}, true, 1)
+	if !errors.As(err, &symbolsErr) {
+		t.Errorf("expected IndexWordSymbolsError from fuzzy search, got %v", err)
+	}
+	if err = st.Index(&s); !errors.As(err, &symbolsErr) {
+		t.Errorf("expected IndexWordSymbolsError from index, got %v", err)
+	}
+	// positions beyond the words split from the data are refused rather than followed
+	if _, err = st.GatherIndexedContext(&s, "target", 2); err == nil {
+		t.Errorf("expected error gathering context at a position beyond the words")
+	}
+
+	err = st.ReindexAll(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep, indexed, err = st.IndexWordSymbols()
+	if err != nil || !indexed || !keep {
+		t.Errorf("expected rebuilt index recorded with word symbols, got %v %v %v", keep, indexed, err)
+	}
+	results, err := st.SearchIndexTerm([]string{"target"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results[s.UUID]) != 1 {
+		t.Fatalf("expected snip found after rebuilding, got %v", results)
+	}
+	ctx, err := st.GatherIndexedContext(&s, results[s.UUID][0].Stem, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx) != 1 || ctx[0].Term != "target" || strings.Join(ctx[0].Before, " ") != "a/b/c/d/e/f/g/h/i/j" {
+		t.Errorf("expected context of target after the joined path, got %+v", ctx)
+	}
+
+	// indexes built before the setting was recorded did not keep word symbols
+	err = st.Conn.Exec(`DELETE FROM snip_setting WHERE key = ?`, indexWordSymbolsKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = st.SearchIndexTerm([]string{"target"}, true); !errors.As(err, &symbolsErr) {
+		t.Errorf("expected IndexWordSymbolsError for unrecorded setting, got %v", err)
+	}
+}
+
+func TestIndexNoStem(t *testing.T) {
+	st := newTestStore(t)
+
+	stemmed := New()
+	stemmed.Data = "Configure the parser"
+	err := st.InsertSnip(stemmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Index(&stemmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	NoStem = true
+	defer func() {
+		NoStem = false
+	}()
+	verbatim := New()
+	verbatim.Data = "parseConfiguration configuration"
+	err = st.InsertSnip(verbatim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Index(&verbatim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	NoStem = false
+
+	search := func(term string) map[uuid.UUID][]SearchCount {
+		results, err := st.SearchIndexTerm([]string{term}, false)
+		if err != nil {
+			t.Fatalf("SearchIndexTerm(%s) returned error: %v", term, err)
+		}
+		return results
+	}
+	check := func(term string, expected ...uuid.UUID) {
+		results := search(term)
+		if len(results) != len(expected) {
+			t.Errorf("search for %s expected %d results, got %d: %v", term, len(expected), len(results), results)
+		}
+		for _, id := range expected {
+			if _, ok := results[id]; !ok {
+				t.Errorf("search for %s expected result %s", term, id)
+			}
+		}
+	}
+
+	// the stemmed snip matches any word sharing the stem, the verbatim snip only the exact word
+	check("configuration", stemmed.UUID, verbatim.UUID)
+	check("configured", stemmed.UUID)
+	check("ParseConfiguration", verbatim.UUID)
+	check("parsers", stemmed.UUID)
+
+	// modes are retained when the index is rebuilt
+	err = st.ReindexAll(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mode, err := st.Stemmed(&verbatim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode {
+		t.Errorf("expected snip %s to remain indexed without stemming", verbatim.UUID)
+	}
+	check("configured", stemmed.UUID)
+
+	// contexts are found by the lowercased word
+	ctx, err := st.GatherContext(&verbatim, "CONFIGURATION", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx) != 1 || ctx[0].Term != "configuration" {
+		t.Errorf("unexpected context for verbatim snip: %+v", ctx)
+	}
+
+	err = st.SetStemmed(&verbatim, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("configured", stemmed.UUID, verbatim.UUID)
+}
+
+func TestSearchDataRegex(t *testing.T) {
+	s := New()
+	s.Name = "regex test"
+	s.Data = "first line\nversion v1.2.3 and v10.0.1\nlast line"
+	err := InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := Remove(s.UUID)
+		if err != nil {
+			t.Fatalf("delete function returned error: %v", err)
+		}
+	}()
+
+	results, err := SearchDataRegex(context.Background(), `v\d+\.\d+\.\d+ and v10`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].UUID != s.UUID {
+		t.Fatalf("expected only snip %s, got %v", s.UUID, results)
+	}
+
+	re := regexp.MustCompile(`v\d+\.\d+\.\d+|line`)
+	expected := []LineMatch{
+		{Line: 1, Text: "first line", Matches: []string{"line"}},
+		{Line: 2, Text: "version v1.2.3 and v10.0.1", Matches: []string{"v1.2.3", "v10.0.1"}},
+		{Line: 3, Text: "last line", Matches: []string{"line"}},
+	}
+	if matches := MatchLines(re, s.Data); !reflect.DeepEqual(matches, expected) {
+		t.Errorf("expected %+v, got %+v", expected, matches)
+	}
+
+	_, err = SearchDataRegex(context.Background(), "(")
+	if err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+	_, err = SearchDataRegex(context.Background(), strings.Repeat("a", MaxRegexLength+1))
+	if err == nil {
+		t.Errorf("expected error for pattern exceeding maximum length")
+	}
+}
+
+func TestRecent(t *testing.T) {
+	st := newTestStore(t)
+
+	var snips []Snip
+	for i := 0; i < 3; i++ {
+		s := New()
+		s.Name = fmt.Sprintf("recent %d", i)
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snips = append(snips, s)
+	}
+	// the last snip is never accessed
+	for _, s := range []Snip{snips[1], snips[0]} {
+		err := st.TouchSnip(s.UUID)
+		if err != nil {
+			t.Fatalf("TouchSnip returned error: %v", err)
+		}
+	}
+
+	recent, err := st.Recent(0)
+	if err != nil {
+		t.Fatalf("Recent returned error: %v", err)
+	}
+	if len(recent) != 2 || recent[0].UUID != snips[0].UUID || recent[1].UUID != snips[1].UUID {
+		t.Fatalf("expected snips 0 and 1 by most recent access, got %+v", recent)
+	}
+	if recent[0].Accessed.Before(recent[1].Accessed) || time.Since(recent[0].Accessed) > time.Minute {
+		t.Errorf("unexpected access times %v and %v", recent[0].Accessed, recent[1].Accessed)
+	}
+
+	recent, err = st.Recent(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0].UUID != snips[0].UUID {
+		t.Errorf("expected only the most recent snip, got %+v", recent)
+	}
+}
+
+func TestSetFavorite(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "favorite"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fav := range []bool{true, false} {
+		err = st.SetFavorite(s.UUID, fav)
+		if err != nil {
+			t.Fatalf("SetFavorite returned error: %v", err)
+		}
+		got, err := st.GetFromUUID(s.UUID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Favorite != fav {
+			t.Errorf("expected favorite %t, got %t", fav, got.Favorite)
+		}
+		all, err := st.List(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(all) != 1 || all[0].Favorite != fav {
+			t.Errorf("expected listed favorite %t, got %+v", fav, all)
+		}
+	}
+
+	// favorites are kept by inserts, such as when importing
+	imported := New()
+	imported.Favorite = true
+	err = st.InsertSnip(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.GetFromUUID(imported.UUID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Favorite {
+		t.Errorf("expected inserted snip to be a favorite")
+	}
+
+	err = st.SetFavorite(uuid.New(), true)
+	if err == nil {
+		t.Errorf("expected error for missing snip")
+	}
+}
+
+func TestListNames(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "named"
+	s.Data = "data left unread"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := st.ListNames()
+	if err != nil {
+		t.Fatalf("ListNames returned error: %v", err)
+	}
+	if len(names) != 1 || names[0].UUID != s.UUID || names[0].Name != s.Name || names[0].Data != "" {
+		t.Errorf("expected only the uuid and name of %s, got %+v", s.UUID, names)
+	}
+}
+
+func TestListBetween(t *testing.T) {
+	st := newTestStore(t)
+
+	// snips are created in the local zone, while bounds may be given in any zone
+	zone := time.FixedZone("UTC-7", -7*60*60)
+	base := time.Date(2023, 6, 16, 12, 0, 0, 0, time.Local)
+	var snips []Snip
+	for i, ts := range []time.Time{base, base.Add(time.Hour), base.Add(2 * time.Hour)} {
+		s := New()
+		s.Name = fmt.Sprintf("between %d", i)
+		s.Timestamp = ts
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snips = append(snips, s)
+	}
+
+	tests := []struct {
+		start    time.Time
+		end      time.Time
+		expected []Snip
+	}{
+		{time.Time{}, time.Time{}, snips},
+		{base.Add(time.Hour), time.Time{}, snips[1:]},
+		{time.Time{}, base.Add(time.Hour), snips[:1]},
+		{base.Add(30 * time.Minute).In(zone), base.Add(90 * time.Minute).UTC(), snips[1:2]},
+	}
+	for _, tt := range tests {
+		results, err := st.ListBetween(tt.start, tt.end)
+		if err != nil {
+			t.Fatalf("ListBetween returned error: %v", err)
+		}
+		ids, err := st.UUIDsBetween(tt.start, tt.end)
+		if err != nil {
+			t.Fatalf("UUIDsBetween returned error: %v", err)
+		}
+		if len(results) != len(tt.expected) || len(ids) != len(tt.expected) {
+			t.Errorf("between %v and %v expected %d snips, got %d and %d ids", tt.start, tt.end, len(tt.expected), len(results), len(ids))
+			continue
+		}
+		for i := range results {
+			if results[i].UUID != tt.expected[i].UUID {
+				t.Errorf("between %v and %v expected %s at %d, got %s", tt.start, tt.end, tt.expected[i].Name, i, results[i].Name)
+			}
+			if ids[i] != tt.expected[i].UUID {
+				t.Errorf("between %v and %v expected id of %s at %d, got %s", tt.start, tt.end, tt.expected[i].Name, i, ids[i])
+			}
+		}
+	}
+}
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Date(2023, 6, 16, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"2023-06-01T08:30:00-07:00": time.Date(2023, 6, 1, 15, 30, 0, 0, time.UTC),
+		"2023-06-01":                time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC),
+		"90m":                       now.Add(-90 * time.Minute),
+		"7d":                        now.Add(-7 * 24 * time.Hour),
+		"2w":                        now.Add(-14 * 24 * time.Hour),
+	}
+	for value, expected := range tests {
+		got, err := ParseTimeBound(value, now)
+		if err != nil {
+			t.Errorf("ParseTimeBound(%s) returned error: %v", value, err)
+			continue
+		}
+		if !got.Equal(expected) {
+			t.Errorf("ParseTimeBound(%s) expected %v, got %v", value, expected, got)
+		}
+	}
+
+	for _, value := range []string{"", "yesterday", "-7d", "7x", "2023-13-01"} {
+		_, err := ParseTimeBound(value, now)
+		if err == nil {
+			t.Errorf("ParseTimeBound(%q) expected error", value)
+		}
+	}
+}
+
+func TestRenameByPattern(t *testing.T) {
+	st := newTestStore(t)
+
+	names := []string{"notes 2023-06", "notes 2024-01", "draft"}
+	ids := make(map[string]uuid.UUID)
+	for _, name := range names {
+		s := New()
+		s.Name = name
+		s.Data = "data of " + name
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = s.UUID
+	}
+
+	expected := []RenameResult{
+		{UUID: ids["notes 2023-06"], OldName: "notes 2023-06", NewName: "2023/06 notes"},
+		{UUID: ids["notes 2024-01"], OldName: "notes 2024-01", NewName: "2024/01 notes"},
+	}
+	for _, dryRun := range []bool{true, false} {
+		results, err := st.RenameByPattern(`^notes (\d+)-(\d+)$`, "$1/$2 notes", dryRun)
+		if err != nil {
+			t.Fatalf("RenameByPattern returned error: %v", err)
+		}
+		if !reflect.DeepEqual(results, expected) {
+			t.Errorf("expected %+v, got %+v", expected, results)
+		}
+		s, err := st.GetFromUUID(ids["notes 2023-06"].String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (dryRun && s.Name != "notes 2023-06") || (!dryRun && s.Name != "2023/06 notes") {
+			t.Errorf("unexpected name %q after rename with dry run %t", s.Name, dryRun)
+		}
+	}
+
+	// an empty name fails before any snip is renamed
+	_, err := st.RenameByPattern(`^.*$`, "", false)
+	if err == nil {
+		t.Errorf("expected error for replacement producing an empty name")
+	}
+	s, err := st.GetFromUUID(ids["draft"].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "draft" {
+		t.Errorf("expected name to be unchanged, got %q", s.Name)
+	}
+}
+
+func TestClone(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "original"
+	s.Data = "parseConfiguration reads the settings"
+	s.Timestamp = time.Now().Add(-24 * time.Hour)
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.SetStemmed(&s, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Attach(&s, "settings.txt", []byte("key=value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := st.Clone(&s)
+	if err != nil {
+		t.Fatalf("Clone returned error: %v", err)
+	}
+	got, err := st.GetFromUUID(clone.UUID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UUID == s.UUID || got.Name != "original (copy)" || got.Data != s.Data || !got.Timestamp.After(s.Timestamp) {
+		t.Errorf("unexpected clone %+v", got)
+	}
+	if len(got.Attachments) != 1 {
+		t.Fatalf("expected 1 attachment, got %d", len(got.Attachments))
+	}
+	original, err := st.GetAttachments(s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := got.Attachments[0]
+	if len(original) != 1 || a.UUID == original[0].UUID || a.Name != "settings.txt" || string(a.Data) != "key=value" || a.Size != 9 {
+		t.Errorf("unexpected cloned attachment %+v", a)
+	}
+
+	// the clone is indexed without stemming like the original
+	results, err := st.SearchIndexTerm([]string{"parseconfiguration"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := results[clone.UUID]; !ok || len(results) != 2 {
+		t.Errorf("expected original and clone in results, got %v", results)
+	}
+	stemmed, err := st.Stemmed(&clone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stemmed {
+		t.Errorf("expected clone to be indexed without stemming")
+	}
+}
+
+func TestContextWindows(t *testing.T) {
+	// words of "one two cat three cat four five six cat" with one word of context
+	contexts := []TermContext{
+		{Before: []string{"two"}, BeforeStart: 2, Term: "cat", After: []string{"three"}, AfterEnd: 4},
+		{Before: []string{"three"}, BeforeStart: 4, Term: "cat", After: []string{"four"}, AfterEnd: 6},
+		{Before: []string{"six"}, BeforeStart: 8, Term: "cat", AfterEnd: 9},
+	}
+
+	windows := ContextWindows(contexts, false)
+	if len(windows) != 3 {
+		t.Fatalf("expected a window for each context, got %+v", windows)
+	}
+	if !reflect.DeepEqual(windows[0], ContextWindow{Start: 2, End: 4, Words: []string{"two", "cat", "three"}, Terms: []int{1}}) {
+		t.Errorf("unexpected window %+v", windows[0])
+	}
+
+	expected := []ContextWindow{
+		{Start: 2, End: 6, Words: []string{"two", "cat", "three", "cat", "four"}, Terms: []int{1, 3}},
+		{Start: 8, End: 9, Words: []string{"six", "cat"}, Terms: []int{1}},
+	}
+	if merged := ContextWindows(contexts, true); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %+v, got %+v", expected, merged)
+	}
+
+	// adjoining windows merge, and contexts of other terms are ordered by position
+	contexts = append(contexts, TermContext{Before: []string{"four"}, BeforeStart: 6, Term: "five", After: []string{"six"}, AfterEnd: 8})
+	expected = []ContextWindow{
+		{Start: 2, End: 9, Words: []string{"two", "cat", "three", "cat", "four", "five", "six", "cat"}, Terms: []int{1, 3, 5, 7}},
+	}
+	if merged := ContextWindows(contexts, true); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected %+v, got %+v", expected, merged)
+	}
+}
+
+func TestSearchDataTermLimit(t *testing.T) {
+	st := newTestStore(t)
+
+	// inserted newest first, so that pages follow creation rather than insertion
+	base := time.Now()
+	ids := make([]uuid.UUID, 5)
+	for i := len(ids) - 1; i >= 0; i-- {
+		s := New()
+		s.Data = fmt.Sprintf("limited match %d", i)
+		s.Timestamp = base.Add(time.Duration(i) * time.Minute)
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = s.UUID
+	}
+
+	tests := []struct {
+		offset   int
+		limit    int
+		expected []uuid.UUID
+	}{
+		{0, 0, ids},
+		{0, 2, ids[:2]},
+		{3, 0, ids[3:]},
+		{1, 2, ids[1:3]},
+		{10, 1, nil},
+	}
+	for _, tt := range tests {
+		results, err := st.SearchDataTermLimit("limited", tt.offset, tt.limit)
+		if err != nil {
+			t.Fatalf("SearchDataTermLimit returned error: %v", err)
+		}
+		var got []uuid.UUID
+		for _, s := range results {
+			got = append(got, s.UUID)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("offset %d limit %d expected %v, got %v", tt.offset, tt.limit, tt.expected, got)
+		}
+	}
+
+	_, err := st.SearchDataTermLimit("limited", 0, -1)
+	if err == nil {
+		t.Errorf("expected error for negative limit")
+	}
+}
+
+func TestExportImportJSONL(t *testing.T) {
+	src := newTestStore(t)
+	var originals []Snip
+	for idx, data := range []string{"first line\nof data", "second snip"} {
+		s := New()
+		s.Name = fmt.Sprintf("jsonl %d", idx)
+		s.Data = data
+		s.Favorite = idx == 1
+		err := src.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		originals = append(originals, s)
+	}
+	err := src.Attach(&originals[0], "notes.bin", []byte{0, 1, 2, 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{"source": "stackoverflow", "lang": "go"} {
+		err = src.SetMeta(&originals[1], key, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err = src.ExportJSONL(context.Background(), &buf)
+	if err != nil {
+		t.Fatalf("ExportJSONL returned error: %v", err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(originals) {
+		t.Fatalf("expected %d lines, got %d", len(originals), lines)
+	}
+	exported := buf.String()
+
+	// commit in batches smaller than the number of snips
+	defer func(size int) { ImportBatchSize = size }(ImportBatchSize)
+	ImportBatchSize = 1
+	dst := newTestStore(t)
+	count, err := dst.ImportJSONL(strings.NewReader(exported))
+	if err != nil {
+		t.Fatalf("ImportJSONL returned error: %v", err)
+	}
+	if count != len(originals) {
+		t.Errorf("expected %d imported snips, got %d", len(originals), count)
+	}
+	for _, s := range originals {
+		got, err := dst.GetFromUUID(s.UUID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != s.Name || got.Data != s.Data || got.Favorite != s.Favorite {
+			t.Errorf("imported snip %+v does not match original %+v", got, s)
+		}
+	}
+	got, err := dst.GetFromUUID(originals[0].UUID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Attachments) != 1 || !bytes.Equal(got.Attachments[0].Data, []byte{0, 1, 2, 255}) {
+		t.Errorf("attachment did not round-trip exactly: %+v", got.Attachments)
+	}
+	for idx, expected := range []map[string]string{{}, {"source": "stackoverflow", "lang": "go"}} {
+		meta, err := dst.Meta(&originals[idx])
+		if err != nil || !reflect.DeepEqual(meta, expected) {
+			t.Errorf("metadata of %s did not round-trip, got %v: %v", originals[idx].Name, meta, err)
+		}
+	}
+
+	// existing snips are skipped
+	count, err = dst.ImportJSONL(strings.NewReader(exported))
+	if err != nil || count != 0 {
+		t.Errorf("expected re-import to skip all snips, got %d: %v", count, err)
+	}
+
+	// committed batches remain when a later line is invalid
+	third := newTestStore(t)
+	count, err = third.ImportJSONL(strings.NewReader(exported + "{not json\n"))
+	if err == nil || count != len(originals) {
+		t.Errorf("expected error after %d imported snips, got %d: %v", len(originals), count, err)
+	}
+}
+
+func TestVerifyAttachment(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "verify attachment"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Attach(&s, "data.bin", []byte{0, 1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := st.GetAttachmentsUUID(s.UUID)
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("expected 1 attachment, got %v: %v", ids, err)
+	}
+
+	ok, err := st.VerifyAttachment(ids[0])
+	if err != nil || !ok {
+		t.Errorf("expected attachment to verify, got %v: %v", ok, err)
+	}
+	err = st.Conn.Exec(`UPDATE snip_attachment SET size = 5 WHERE uuid = ?`, ids[0].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, err = st.VerifyAttachment(ids[0])
+	if err != nil || ok {
+		t.Errorf("expected size mismatch, got %v: %v", ok, err)
+	}
+	_, err = st.VerifyAttachment(uuid.New())
+	if err == nil {
+		t.Errorf("expected error verifying missing attachment")
+	}
+}
+
+func TestRelatedSnips(t *testing.T) {
+	st := newTestStore(t)
+
+	var snips []Snip
+	for _, data := range []string{
+		"wren sparrow finch feathers",
+		"wren sparrow finch nests",
+		"wren sparrow gardens",
+		"compilers parse grammars",
+	} {
+		s := New()
+		s.Name = data
+		s.Data = data
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = st.Index(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snips = append(snips, s)
+	}
+
+	scores, err := st.RelatedSnips(snips[0].UUID, 0)
+	if err != nil {
+		t.Fatalf("RelatedSnips returned error: %v", err)
+	}
+	if len(scores) != 2 || scores[0].UUID != snips[1].UUID || scores[1].UUID != snips[2].UUID {
+		t.Fatalf("expected snips sharing terms ordered by overlap, got %+v", scores)
+	}
+	if scores[0].Score != 0.75 || scores[1].Score != 0.5 {
+		t.Errorf("expected scores 0.75 and 0.5, got %f and %f", scores[0].Score, scores[1].Score)
+	}
+
+	scores, err = st.RelatedSnips(snips[0].UUID, 1)
+	if err != nil || len(scores) != 1 {
+		t.Errorf("expected 1 related snip with limit, got %d: %v", len(scores), err)
+	}
+	_, err = st.RelatedSnips(uuid.New(), 0)
+	if err == nil {
+		t.Errorf("expected error for missing snip")
+	}
+}
+
+func TestAppend(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "running notes"
+	s.Data = "first entry"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Index(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Attach(&s, "kept.txt", []byte("attachment"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = st.Append(&s, "second entry\n")
+	if err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+	err = st.Append(&s, "third entry")
+	if err != nil {
+		t.Fatalf("Append returned error: %v", err)
+	}
+	got, err := st.GetFromUUID(s.UUID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "first entry\nsecond entry\nthird entry"
+	if got.Data != expected || s.Data != expected {
+		t.Errorf("expected data %q, got %q", expected, got.Data)
+	}
+	if len(got.Attachments) != 1 {
+		t.Errorf("expected attachment to be kept, got %d", len(got.Attachments))
+	}
+	results, err := st.SearchIndexTerm([]string{"third"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := results[s.UUID]; !ok {
+		t.Errorf("expected appended data to be indexed")
+	}
+
+	defer func(size int) { MaxDataSize = size }(MaxDataSize)
+	MaxDataSize = len(expected) + 1
+	err = st.Append(&s, "too long")
+	var sizeErr *DataSizeError
+	if !errors.As(err, &sizeErr) {
+		t.Errorf("expected DataSizeError, got %v", err)
+	}
+	if s.Data != expected {
+		t.Errorf("expected data to be unchanged after error, got %q", s.Data)
+	}
+}
+
+func TestIndexedTermsPrefix(t *testing.T) {
+	st := newTestStore(t)
+
+	for _, data := range []string{"compile compile config", "compile cache"} {
+		s := New()
+		s.Data = data
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = st.Index(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := st.AllIndexedTerms()
+	if err != nil {
+		t.Fatalf("AllIndexedTerms returned error: %v", err)
+	}
+	expected := []SearchCount{
+		{Term: "compil", Stem: "compil", Count: 3},
+		{Term: "cach", Stem: "cach", Count: 1},
+		{Term: "config", Stem: "config", Count: 1},
+	}
+	if !reflect.DeepEqual(all, expected) {
+		t.Errorf("expected %+v, got %+v", expected, all)
+	}
+
+	prefixed, err := st.IndexedTermsPrefix("CO", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(prefixed, []SearchCount{expected[0], expected[2]}) {
+		t.Errorf("expected terms beginning with co, got %+v", prefixed)
+	}
+	limited, err := st.IndexedTermsPrefix("c", 1)
+	if err != nil || len(limited) != 1 || limited[0].Term != "compil" {
+		t.Errorf("expected only the most frequent term, got %+v: %v", limited, err)
+	}
+}
+
+func TestExportSnip(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "notes/today"
+	s.Data = "archived data"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"image.png", "image.png"} {
+		err = st.Attach(&s, name, []byte(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "archive")
+	err = st.ExportSnip(s.UUID, dir)
+	if err != nil {
+		t.Fatalf("ExportSnip returned error: %v", err)
+	}
+	expected := map[string]string{
+		"notes_today.txt": "archived data",
+		"image.png":       "image.png",
+		"image-1.png":     "image.png",
+	}
+	for name, content := range expected {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != content {
+			t.Errorf("expected %s to contain %q, got %q: %v", name, content, data, err)
+		}
+	}
+
+	// existing files are kept
+	err = st.ExportSnip(s.UUID, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes_today-1.txt")); err != nil {
+		t.Errorf("expected counter to be appended on collision: %v", err)
+	}
+
+	err = st.Attach(&s, "..", []byte("escape"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.ExportSnip(s.UUID, dir)
+	if err == nil {
+		t.Errorf("expected error writing outside of directory")
+	}
+}
+
+func TestGatherDataContext(t *testing.T) {
+	s := New()
+	// v1.4.2 and -> are not words, so are not counted in word positions
+	s.Data = "Upgrade to v1.4.2 before migrating, then UPGRADE again -> done"
+
+	tests := []struct {
+		term     string
+		adjacent int
+		expected []TermContext
+	}{
+		{"1.4", 1, []TermContext{{Before: []string{"to"}, BeforeStart: 2, Term: "v1.4.2", After: []string{"before"}, AfterEnd: 4}}},
+		{"upgrade", 1, []TermContext{
+			{BeforeStart: 1, Term: "Upgrade", After: []string{"to"}, AfterEnd: 2},
+			{Before: []string{"then"}, BeforeStart: 5, Term: "UPGRADE", After: []string{"again"}, AfterEnd: 7},
+		}},
+		{"->", 1, []TermContext{{Before: []string{"again"}, BeforeStart: 7, Term: "->", After: []string{"done"}, AfterEnd: 9}}},
+		{"migrating, then", 0, []TermContext{{BeforeStart: 4, Term: "migrating, then", AfterEnd: 4}}},
+		{"absent", 1, nil},
+	}
+	for _, tt := range tests {
+		ctx, err := s.GatherDataContext(tt.term, tt.adjacent)
+		if err != nil {
+			t.Fatalf("GatherDataContext returned error for %q: %v", tt.term, err)
+		}
+		if !reflect.DeepEqual(ctx, tt.expected) {
+			t.Errorf("term %q: expected %+v, got %+v", tt.term, tt.expected, ctx)
+		}
+	}
+
+	_, err := s.GatherDataContext("", 1)
+	if err == nil {
+		t.Errorf("expected error for empty term")
+	}
+}
+
+func TestRetryLocked(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "locked.sqlite3")
+	conn, err := sqlite3.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	st := NewStore(conn)
+	err = st.CreateNewDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fail immediately rather than waiting for the lock, as when a burst outlasts the busy timeout
+	conn.BusyTimeout(0)
+
+	other, err := sqlite3.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	defer func(attempts int, delay time.Duration) {
+		database.RetryAttempts = attempts
+		database.RetryDelay = delay
+	}(database.RetryAttempts, database.RetryDelay)
+	database.RetryAttempts = 2
+	database.RetryDelay = time.Millisecond
+
+	// the write lock of the other connection outlasts the retries
+	err = other.Exec(`BEGIN IMMEDIATE`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New()
+	s.Name = "retry"
+	s.Data = "written once the lock clears"
+	err = st.InsertSnip(s)
+	if !database.IsBusy(err) {
+		t.Fatalf("expected busy error after retries, got %v", err)
+	}
+
+	// the lock is released during the retries
+	database.RetryAttempts = 10
+	database.RetryDelay = 10 * time.Millisecond
+	released := make(chan error)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		released <- other.Commit()
+	}()
+	err = st.InsertSnip(s)
+	if err != nil {
+		t.Fatalf("expected insert to succeed once the lock cleared, got %v", err)
+	}
+	if err := <-released; err != nil {
+		t.Fatal(err)
+	}
+	got, err := st.GetFromUUID(s.UUID.String())
+	if err != nil || got.Data != s.Data {
+		t.Errorf("expected inserted snip, got %+v: %v", got, err)
+	}
+}
+
+func TestWithTxRetry(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "locked.sqlite3")
+	conn, err := sqlite3.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	st := NewStore(conn)
+	err = st.CreateNewDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.BusyTimeout(0)
+
+	other, err := sqlite3.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	other.BusyTimeout(0)
+
+	defer func(attempts int, delay time.Duration) {
+		database.RetryAttempts = attempts
+		database.RetryDelay = delay
+	}(database.RetryAttempts, database.RetryDelay)
+	database.RetryAttempts = 10
+	database.RetryDelay = 10 * time.Millisecond
+
+	s := New()
+	s.Name = "retry"
+	s.Data = "original"
+	err = st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a busy error within the transaction rolls back the work done so far and runs the whole transaction again
+	attempts := 0
+	err = database.WithTx(conn, func() error {
+		attempts++
+		err := conn.Exec(`INSERT INTO snip_meta (snip_uuid, key, value) VALUES (?, 'attempt', ?)`, s.UUID.String(), attempts)
+		if err != nil {
+			return err
+		}
+		if attempts == 1 {
+			// the other connection cannot write while this transaction holds the lock
+			return other.Exec(`INSERT INTO snip_meta (snip_uuid, key, value) VALUES (?, 'other', '')`, s.UUID.String())
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected transaction to succeed when run again, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected transaction run twice, got %d", attempts)
+	}
+	meta, err := st.Meta(&s)
+	if err != nil || !reflect.DeepEqual(meta, map[string]string{"attempt": "2"}) {
+		t.Errorf("expected only the second attempt committed, got %v: %v", meta, err)
+	}
+
+	// updates save the revision in the same transaction, which waits for the lock of the other connection
+	err = other.Exec(`BEGIN IMMEDIATE`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	released := make(chan error)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		released <- other.Commit()
+	}()
+	s.Data = "updated once the lock clears"
+	err = st.Update(&s)
+	if err != nil {
+		t.Fatalf("expected update to succeed once the lock cleared, got %v", err)
+	}
+	if err := <-released; err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := st.GetRevisions(s.UUID)
+	if err != nil || len(revisions) != 1 || revisions[0].Data != "original" {
+		t.Errorf("expected one revision of the original data, got %+v: %v", revisions, err)
+	}
+
+	// a transaction within another that fails undoes only its own changes
+	err = database.WithTx(conn, func() error {
+		err := conn.Exec(`INSERT INTO snip_meta (snip_uuid, key, value) VALUES (?, 'outer', '')`, s.UUID.String())
+		if err != nil {
+			return err
+		}
+		err = database.WithTx(conn, func() error {
+			err := conn.Exec(`INSERT INTO snip_meta (snip_uuid, key, value) VALUES (?, 'inner', '')`, s.UUID.String())
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("inner failure")
+		})
+		if err == nil {
+			return fmt.Errorf("expected inner failure")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err = st.Meta(&s)
+	if err != nil || !reflect.DeepEqual(meta, map[string]string{"attempt": "2", "outer": ""}) {
+		t.Errorf("expected only the inner transaction rolled back, got %v: %v", meta, err)
+	}
+
+	// a transaction within another joins it, and is rolled back with it
+	err = database.WithTx(conn, func() error {
+		err := database.WithTx(conn, func() error {
+			return conn.Exec(`DELETE FROM snip_meta WHERE snip_uuid = ?`, s.UUID.String())
+		})
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("outer failure")
+	})
+	if err == nil {
+		t.Fatalf("expected outer failure")
+	}
+	meta, err = st.Meta(&s)
+	if err != nil || len(meta) != 2 {
+		t.Errorf("expected inner transaction rolled back with the outer, got %v: %v", meta, err)
+	}
+}
+
+func TestSearchNameTerm(t *testing.T) {
+	st := newTestStore(t)
+
+	named := New()
+	named.Name = "Wikipedia - Wren"
+	named.Data = "a small bird"
+	other := New()
+	other.Name = "unrelated"
+	other.Data = "wren appears only in the data"
+	for _, s := range []Snip{named, other} {
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := st.SearchNameTerm("WREN")
+	if err != nil {
+		t.Fatalf("SearchNameTerm returned error: %v", err)
+	}
+	if len(results) != 1 || results[0].UUID != named.UUID {
+		t.Errorf("expected only %s, got %v", named.UUID, results)
+	}
+
+	_, err = st.SearchNameTerm("")
+	if err == nil {
+		t.Errorf("expected error searching for empty string")
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	candidates := []string{"Wikipedia - Wren", "wren", "shopping list", "Awkward Wren"}
+	tests := []struct {
+		query    string
+		expected []int
+	}{
+		{"", []int{0, 1, 2, 3}},
+		{"WREN", []int{1, 3, 0}},
+		{"wpwr", []int{0}},
+		{"wkwr", []int{3, 0}},
+		{"sl", []int{2}},
+		{"xyz", []int{}},
+	}
+	for _, tt := range tests {
+		got := FuzzyMatch(tt.query, candidates)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("query %q expected %v, got %v", tt.query, tt.expected, got)
+		}
+	}
+}
+
+func TestWriteAllAttachments(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Data = "snip with attachments"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{"one.txt": "one", "two.txt": "two"} {
+		err = st.Attach(&s, name, []byte(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "attachments")
+	written, err := st.WriteAllAttachments(s.UUID, dir, false)
+	if err != nil || written != 2 {
+		t.Fatalf("expected 2 attachments written, got %d: %v", written, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "two.txt"))
+	if err != nil || string(data) != "two" {
+		t.Errorf("expected attachment data two, got %q: %v", data, err)
+	}
+
+	// existing files are kept unless forced
+	err = os.WriteFile(filepath.Join(dir, "one.txt"), []byte("local"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed []string
+	written, err = st.WriteAllAttachmentsReport(s.UUID, dir, false, func(a Attachment, path string, bytesWritten int, err error) {
+		if err != nil {
+			failed = append(failed, a.Name)
+		}
+	})
+	if err == nil || written != 0 || len(failed) != 2 {
+		t.Errorf("expected both attachments refused, got %d written, failed %v: %v", written, failed, err)
+	}
+	written, err = st.WriteAllAttachments(s.UUID, dir, true)
+	if err != nil || written != 2 {
+		t.Fatalf("expected 2 attachments forced, got %d: %v", written, err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "one.txt"))
+	if err != nil || string(data) != "one" {
+		t.Errorf("expected overwritten attachment data one, got %q: %v", data, err)
+	}
+}
+
+func TestCountAll(t *testing.T) {
+	st := newTestStore(t)
+
+	first := New()
+	first.Data = "one two\nthree\n"
+	second := New()
+	second.Data = "no final newline"
+	for _, s := range []Snip{first, second} {
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if c := first.Counts(); c != (Counts{Lines: 2, Words: 3, Bytes: 14}) {
+		t.Errorf("unexpected counts of first snip %+v", c)
+	}
+	if c := second.Counts(); c != (Counts{Lines: 0, Words: 3, Bytes: 16}) {
+		t.Errorf("unexpected counts of second snip %+v", c)
+	}
+
+	total, counted, err := st.CountAll()
+	if err != nil {
+		t.Fatalf("CountAll returned error: %v", err)
+	}
+	if counted != 2 || total != (Counts{Lines: 2, Words: 6, Bytes: 30}) {
+		t.Errorf("unexpected total %+v of %d snips", total, counted)
+	}
+}
+
+func TestResolveAttachmentUUID(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"one.txt", "two.txt"} {
+		err = st.Attach(&s, name, []byte(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids, err := st.GetAttachmentsUUID(s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := st.ResolveAttachmentUUID(ids[0].String()[:8])
+	if err != nil || id != ids[0] {
+		t.Errorf("expected %s from partial uuid, got %s: %v", ids[0], id, err)
+	}
+	// every uuid contains a hyphen
+	_, err = st.ResolveAttachmentUUID("-")
+	if err == nil {
+		t.Errorf("expected error resolving ambiguous uuid")
+	}
+
+	a, err := st.GetAttachmentMetadataFromUUID(ids[1].String()[:8])
+	if err != nil || a.UUID != ids[1] || a.Size != len("two.txt") || a.Data != nil {
+		t.Errorf("expected metadata of %s without data, got %+v: %v", ids[1], a, err)
+	}
+	if _, err = st.GetAttachmentMetadataFromUUID("-"); err == nil {
+		t.Errorf("expected error getting metadata of ambiguous uuid")
+	}
+}
+
+func TestStreamAttachment(t *testing.T) {
+	st := newTestStore(t)
+
+	data := make([]byte, 3*attachmentChunkSize+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	s := New()
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Attach(&s, "large.bin", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := st.GetAttachmentsUUID(s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw bytes.Buffer
+	n, err := st.StreamAttachment(ids[0], &raw)
+	if err != nil || n != int64(len(data)) || !bytes.Equal(raw.Bytes(), data) {
+		t.Errorf("expected %d bytes streamed unchanged, got %d: %v", len(data), n, err)
+	}
+
+	// streamed base64 matches encoding all of the data at once
+	var expected, encoded bytes.Buffer
+	err = EncodeBase64(&expected, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc := NewBase64Writer(&encoded)
+	_, err = st.StreamAttachment(ids[0], enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = enc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded.String() != expected.String() {
+		t.Errorf("expected streamed base64 to match EncodeBase64")
+	}
+}
+
+func TestGetAttachmentOwner(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "owner"
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Attach(&s, "owned.txt", []byte("owned"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := st.GetAttachmentsUUID(s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner, err := st.GetAttachmentOwner(ids[0])
+	if err != nil || owner.UUID != s.UUID || owner.Name != "owner" {
+		t.Errorf("expected owner %s, got %s: %v", s.UUID, owner.UUID, err)
+	}
+
+	orphan := NewAttachment()
+	orphan.SnipUUID = uuid.New()
+	orphan.Name = "orphan.txt"
+	orphan.Data = []byte("orphan")
+	orphan.Size = len(orphan.Data)
+	err = st.InsertAttachment(orphan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = st.GetAttachmentOwner(orphan.UUID)
+	var orphaned *OrphanedAttachmentError
+	if !errors.As(err, &orphaned) || orphaned.SnipUUID != orphan.SnipUUID {
+		t.Errorf("expected OrphanedAttachmentError, got %v", err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	s := New()
+	s.Data = "ssh {{.user}}@{{.host}}"
+
+	expanded, err := s.Expand(map[string]string{"host": "server1", "user": "admin"})
+	if err != nil || expanded != "ssh admin@server1" {
+		t.Errorf("expected ssh admin@server1, got %q: %v", expanded, err)
+	}
+	_, err = s.Expand(map[string]string{"host": "server1"})
+	if err == nil {
+		t.Errorf("expected error for placeholder without value")
+	}
+	expanded, err = s.ExpandMissingOK(map[string]string{"host": "server1"})
+	if err != nil || expanded != "ssh @server1" {
+		t.Errorf("expected ssh @server1, got %q: %v", expanded, err)
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{"the quick brown fox", 0, "the quick brown fox"},
+		{"the quick brown fox", 10, "the quick\nbrown fox"},
+		{"short\nthe quick brown fox", 15, "short\nthe quick brown\nfox"},
+		{"  indented words here", 12, "  indented\nwords here"},
+		{"a unbreakableword b", 5, "a\nunbreakableword\nb"},
+		// runes rather than bytes are counted
+		{"ça été déjà vu", 7, "ça été\ndéjà vu"},
+		{"trailing  ", 12, "trailing  "},
+	}
+	for _, tt := range tests {
+		got := WrapText(tt.input, tt.width)
+		if got != tt.expected {
+			t.Errorf("WrapText(%q, %d) expected %q, got %q", tt.input, tt.width, tt.expected, got)
+		}
+	}
+}
+
+func TestRemoveBySearch(t *testing.T) {
+	st := newTestStore(t)
+
+	var ids []uuid.UUID
+	for _, data := range []string{"obsolete draft", "obsolete notes", "current draft"} {
+		s := New()
+		s.Name = data
+		s.Data = data
+		err := st.InsertSnip(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = st.Index(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.UUID)
+	}
+
+	previewed, err := st.RemoveBySearch([]string{"obsolete"}, true)
+	if err != nil || len(previewed) != 2 || previewed[0].Name != "obsolete draft" {
+		t.Fatalf("expected two snips previewed in name order, got %v: %v", previewed, err)
+	}
+	if exists, _ := st.SnipExists(ids[0]); !exists {
+		t.Fatalf("expected previewed snip to remain")
+	}
+
+	removed, err := st.RemoveBySearch([]string{"obsolete", "draft"}, false)
+	if err != nil || len(removed) != 1 || removed[0].UUID != ids[0] {
+		t.Fatalf("expected only snip %s removed, got %v: %v", ids[0], removed, err)
+	}
+	for idx, expected := range []bool{false, true, true} {
+		exists, err := st.SnipExists(ids[idx])
+		if err != nil || exists != expected {
+			t.Errorf("expected snip %d to exist %v, got %v: %v", idx, expected, exists, err)
+		}
+	}
+
+	// each removal is restored together, most recent first
+	removed, err = st.RemoveBySearch([]string{"obsolete"}, false)
+	if err != nil || len(removed) != 1 || removed[0].UUID != ids[1] {
+		t.Fatalf("expected snip %s removed, got %v: %v", ids[1], removed, err)
+	}
+	restored, err := st.UndoRemoves(5)
+	if err != nil || len(restored) != 2 || restored[0].UUID != ids[1] || restored[1].UUID != ids[0] {
+		t.Fatalf("expected both removed snips restored, got %v: %v", restored, err)
+	}
+	for idx := range ids {
+		if exists, err := st.SnipExists(ids[idx]); err != nil || !exists {
+			t.Errorf("expected snip %d restored: %v", idx, err)
+		}
+	}
+	if _, err = st.UndoRemoves(1); !errors.Is(err, ErrTrashEmpty) {
+		t.Errorf("expected ErrTrashEmpty once everything is restored, got %v", err)
+	}
+}
+
+func TestPromoteAttachment(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	err := st.InsertSnip(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Attach(&s, "notes.txt", []byte("promoted attachment notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = st.Attach(&s, "binary.dat", []byte{0xff, 0xfe})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := st.GetAttachmentsUUID(s.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachments := make(map[string]uuid.UUID)
+	for _, id := range ids {
+		a, err := st.GetAttachmentMetadata(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attachments[a.Name] = id
+	}
+
+	promoted, err := st.PromoteAttachment(attachments["notes.txt"], true)
+	if err != nil {
+		t.Fatalf("PromoteAttachment returned error: %v", err)
+	}
+	if promoted.Name != "notes.txt" || promoted.Data != "promoted attachment notes" {
+		t.Errorf("unexpected promoted snip %q %q", promoted.Name, promoted.Data)
+	}
+	results, err := st.SearchIndexTerm([]string{"notes"}, true)
+	if err != nil || len(results[promoted.UUID]) == 0 {
+		t.Errorf("expected promoted snip to be indexed: %v", err)
+	}
+	if _, err = st.GetAttachmentMetadata(attachments["notes.txt"]); err == nil {
+		t.Errorf("expected original attachment removed")
+	}
+
+	_, err = st.PromoteAttachment(attachments["binary.dat"], false)
+	if !errors.Is(err, ErrNotUTF8) {
+		t.Errorf("expected ErrNotUTF8, got %v", err)
+	}
+	forced, err := st.PromoteAttachmentForce(attachments["binary.dat"], false)
+	if err != nil || forced.Data != "\xff\xfe" {
+		t.Errorf("expected forced promotion of data as is, got %q: %v", forced.Data, err)
+	}
+	if _, err = st.GetAttachmentMetadata(attachments["binary.dat"]); err != nil {
+		t.Errorf("expected original attachment kept: %v", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	conn, err := sqlite3.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	st := NewStore(conn)
+
+	// a database created before the accessed and favorite columns and before versions were recorded
+	err = conn.Exec(`CREATE TABLE snip(uuid TEXT, timestamp TEXT, name TEXT, data TEXT)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := st.SchemaVersion()
+	if err != nil || version != 0 {
+		t.Fatalf("expected schema version 0 before migrating, got %d: %v", version, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = st.Migrate(); err != nil {
+			t.Fatalf("Migrate returned error on run %d: %v", i+1, err)
+		}
+		version, err = st.SchemaVersion()
+		if err != nil || version != LatestSchemaVersion() {
+			t.Errorf("expected schema version %d, got %d: %v", LatestSchemaVersion(), version, err)
+		}
+	}
+
+	s := New()
+	s.Name = "migrated"
+	s.Data = "migrated data"
+	if err = st.InsertSnip(s); err != nil {
+		t.Fatalf("InsertSnip returned error after migrating: %v", err)
+	}
+	if err = st.SetFavorite(s.UUID, true); err != nil {
+		t.Errorf("expected favorite column added by migration: %v", err)
+	}
+	if err = st.recordIndexLanguage(); err != nil {
+		t.Errorf("expected setting table created by migration: %v", err)
+	}
+	if err = st.SetMeta(&s, "source", "migrated"); err != nil {
+		t.Errorf("expected meta table created by migration: %v", err)
+	}
+
+	err = conn.Exec(`INSERT INTO schema_version (version) VALUES (?)`, LatestSchemaVersion()+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versionErr *SchemaVersionError
+	if err = st.Migrate(); !errors.As(err, &versionErr) {
+		t.Errorf("expected SchemaVersionError for a newer database, got %v", err)
+	}
+}
+
+func TestSearchStripsPunctuation(t *testing.T) {
+	st := newTestStore(t)
+
+	// search terms are cleaned of the punctuation that word splitting leaves out of the index
+
+	s := New()
+	s.Data = "Back up the data. Restore (data) later!"
+	if err := st.InsertSnip(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Index(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, term := range []string{"data", "data.", "(data)", "later!"} {
+		results, err := st.SearchIndexTerm([]string{term}, true)
+		if err != nil {
+			t.Fatalf("SearchIndexTerm(%s) returned error: %v", term, err)
+		}
+		if len(results[s.UUID]) != 1 {
+			t.Errorf("expected %s to find the snip, got %v", term, results)
+			continue
+		}
+		if term != "later!" && results[s.UUID][0].Count != 2 {
+			t.Errorf("expected %s to match both occurrences, got %d", term, results[s.UUID][0].Count)
+		}
+	}
+
+	ctxAll, err := st.GatherContext(&s, "data.", 1)
+	if err != nil || len(ctxAll) != 2 {
+		t.Errorf("expected context of both occurrences of data., got %d: %v", len(ctxAll), err)
+	}
+}
+
+func TestUnindexedSnips(t *testing.T) {
+	st := newTestStore(t)
+
+	insert := func(name string, data string, index bool) Snip {
+		s := New()
+		s.Name = name
+		s.Data = data
+		if err := st.InsertSnip(s); err != nil {
+			t.Fatal(err)
+		}
+		if index {
+			if err := st.Index(&s); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return s
+	}
+	insert("indexed", "indexed words", true)
+	insert("empty", "", true)
+	second := insert("second", "added without indexing", false)
+	first := insert("first", "also added without indexing", false)
+
+	ids, err := st.UnindexedSnips()
+	if err != nil {
+		t.Fatalf("UnindexedSnips returned error: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != first.UUID || ids[1] != second.UUID {
+		t.Fatalf("expected unindexed snips first and second, got %v", ids)
+	}
+
+	_, err = st.IndexSnips(context.Background(), ids, 1, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err = st.UnindexedSnips()
+	if err != nil || len(ids) != 0 {
+		t.Errorf("expected no unindexed snips after indexing them, got %v: %v", ids, err)
+	}
+
+	// a dropped index, as left by an interrupted rebuild, leaves every snip unindexed
+	if err = st.DropIndex(); err != nil {
+		t.Fatal(err)
+	}
+	ids, err = st.UnindexedSnips()
+	if err != nil || len(ids) != 4 {
+		t.Fatalf("expected all 4 snips unindexed after dropping the index, got %v: %v", ids, err)
+	}
+	_, err = st.IndexSnips(context.Background(), ids, 1, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err = st.UnindexedSnips()
+	if err != nil || len(ids) != 0 {
+		t.Errorf("expected no unindexed snips after rebuilding the index, got %v: %v", ids, err)
+	}
+}
+
+func TestBucketScores(t *testing.T) {
+	var scores []SearchScore
+	for _, score := range []float64{0.9, 0.6, 0.2, 0.1} {
+		scores = append(scores, SearchScore{UUID: uuid.New(), Score: score})
+	}
+
+	buckets := BucketScores(scores, DefaultBucketHigh, DefaultBucketMedium)
+	if len(buckets) != 2 {
+		t.Fatalf("expected the empty medium band omitted, got %d bands", len(buckets))
+	}
+	if buckets[0].Name != "high" || len(buckets[0].Scores) != 2 || buckets[0].Scores[1].UUID != scores[1].UUID {
+		t.Errorf("expected two high scores including the threshold, got %+v", buckets[0])
+	}
+	if buckets[1].Name != "low" || len(buckets[1].Scores) != 2 {
+		t.Errorf("expected two low scores, got %+v", buckets[1])
+	}
+
+	if buckets := BucketScores(scores, 1, 0.15); len(buckets) != 2 || buckets[0].Name != "medium" || len(buckets[0].Scores) != 3 {
+		t.Errorf("expected thresholds to move scores into the medium band, got %+v", buckets)
+	}
+	if buckets := BucketScores(nil, DefaultBucketHigh, DefaultBucketMedium); len(buckets) != 0 {
+		t.Errorf("expected no bands without scores, got %+v", buckets)
+	}
+}
+
+func TestSearchMulti(t *testing.T) {
+	st := newTestStore(t)
+
+	insert := func(name string, data string) Snip {
+		s := New()
+		s.Name = name
+		s.Data = data
+		if err := st.InsertSnip(s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	inData := insert("garden birds", "a wren at the feeder")
+	inName := insert("wren notes", "nothing else")
+	inBoth := insert("wren sightings", "the wren returned")
+	insert("unrelated", "no match here")
+
+	scores, err := st.SearchMulti("wren", []string{"name", "data", "name"})
+	if err != nil {
+		t.Fatalf("SearchMulti returned error: %v", err)
+	}
+	expected := []uuid.UUID{inBoth.UUID, inName.UUID, inData.UUID}
+	if len(scores) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(scores))
+	}
+	for idx, id := range expected {
+		if scores[idx].UUID != id {
+			t.Errorf("expected result %d to be %s, got %s", idx, id, scores[idx].UUID)
+		}
+	}
+	if scores[0].Score != 1 || scores[1].Score <= scores[2].Score {
+		t.Errorf("expected name matches ranked above data matches, got %+v", scores)
+	}
+
+	if _, err = st.SearchMulti("wren", []string{"name", "body"}); err == nil {
+		t.Errorf("expected error for unsupported field")
+	}
+	if _, err = st.SearchMulti("", []string{"name"}); err == nil {
+		t.Errorf("expected error for empty term")
+	}
+}
+
+func TestImportNoIndex(t *testing.T) {
+	st := newTestStore(t)
+
+	file := filepath.Join(t.TempDir(), "wren.txt")
+	if err := os.WriteFile(file, []byte("the wren sang at dawn"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	st.NoIndex = true
+	s, err := st.ImportFile(file)
+	if err != nil {
+		t.Fatalf("ImportFile returned error: %v", err)
+	}
+
+	ids, err := st.UnindexedSnips()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != s.UUID {
+		t.Fatalf("expected the imported snip to be unindexed, got %v", ids)
+	}
+	results, err := st.SearchIndexTerm([]string{"wren"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("expected no search results before indexing, got %v", results)
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	st := newTestStore(t)
+	for _, data := range []string{"first wren", "second wren", "third wren"} {
+		s := New()
+		s.Name = data
+		s.Data = data
+		if err := st.InsertSnip(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids, err := st.UnindexedSnips()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	indexed, err := st.IndexSnips(ctx, ids, 2, false, nil)
+	if !errors.Is(err, context.Canceled) || indexed != 0 {
+		t.Errorf("expected IndexSnips to stop with context.Canceled, got %d indexed: %v", indexed, err)
+	}
+	remaining, err := st.UnindexedSnips()
+	if err != nil || len(remaining) != len(ids) {
+		t.Errorf("expected %d snips left unindexed, got %v: %v", len(ids), remaining, err)
+	}
+
+	_, err = st.SearchDataRegex(ctx, "wren")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected SearchDataRegex to stop with context.Canceled, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = st.ExportJSONL(ctx, &buf)
+	if !errors.Is(err, context.Canceled) || buf.Len() != 0 {
+		t.Errorf("expected ExportJSONL to stop with context.Canceled before writing, got %q: %v", buf.String(), err)
+	}
+}
+
+func TestMatchTermLines(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "lines"
+	s.Data = "nothing here\nthe dog was Running fast\nrerun is not run, really\n"
+	if err := st.InsertSnip(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Index(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := st.MatchTermLines(&s, []string{"run"})
+	if err != nil {
+		t.Fatalf("MatchTermLines returned error: %v", err)
+	}
+	expected := []LineMatch{
+		{Line: 2, Text: "the dog was Running fast", Matches: []string{"Running"}},
+		{Line: 3, Text: "rerun is not run, really", Matches: []string{"run"}},
+	}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %+v, got %+v", expected, lines)
+	}
+
+	// verbatim snips match only the word itself
+	if err := st.SetStemmed(&s, false); err != nil {
+		t.Fatal(err)
+	}
+	lines, err = st.MatchTermLines(&s, []string{"run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || lines[0].Line != 3 {
+		t.Errorf("expected only line 3 to match verbatim, got %+v", lines)
+	}
+}
+
+func TestSnipMeta(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "annotated"
+	s.Data = "go channels"
+	other := New()
+	other.Name = "other"
+	for _, snip := range []Snip{s, other} {
+		if err := st.InsertSnip(snip); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for key, value := range map[string]string{"source": "stackoverflow", "lang": "go"} {
+		if err := st.SetMeta(&s, key, value); err != nil {
+			t.Fatalf("SetMeta returned error: %v", err)
+		}
+	}
+	if err := st.SetMeta(&other, "lang", "go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetMeta(&s, "bad key", "value"); err == nil {
+		t.Errorf("expected an error for a key containing whitespace")
+	}
+
+	value, err := st.GetMeta(&s, "source")
+	if err != nil || value != "stackoverflow" {
+		t.Errorf("expected source stackoverflow, got %q: %v", value, err)
+	}
+	if _, err := st.GetMeta(&s, "missing"); !errors.Is(err, ErrMetaNotFound) {
+		t.Errorf("expected ErrMetaNotFound for a missing key, got %v", err)
+	}
+	meta, err := st.Meta(&s)
+	if err != nil || !reflect.DeepEqual(meta, map[string]string{"source": "stackoverflow", "lang": "go"}) {
+		t.Errorf("expected both keys of metadata, got %v: %v", meta, err)
+	}
+
+	ids, err := st.SnipsWithMeta(map[string]string{"lang": "go", "source": "stackoverflow"})
+	if err != nil || len(ids) != 1 || ids[0] != s.UUID {
+		t.Errorf("expected only the snip with both keys, got %v: %v", ids, err)
+	}
+	ids, err = st.SnipsWithMeta(map[string]string{"lang": "go"})
+	if err != nil || len(ids) != 2 {
+		t.Errorf("expected both snips with lang go, got %v: %v", ids, err)
+	}
+
+	if err := st.RemoveMeta(&s, "source"); err != nil {
+		t.Errorf("RemoveMeta returned error: %v", err)
+	}
+	if err := st.RemoveMeta(&s, "source"); !errors.Is(err, ErrMetaNotFound) {
+		t.Errorf("expected ErrMetaNotFound removing a missing key, got %v", err)
+	}
+
+	// metadata does not outlive its snip
+	if err := st.Remove(s.UUID); err != nil {
+		t.Fatal(err)
+	}
+	meta, err = st.Meta(&s)
+	if err != nil || len(meta) != 0 {
+		t.Errorf("expected no metadata after removing the snip, got %v: %v", meta, err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Name = "verbatim"
+	s.Data = "running wren"
+	if err := st.InsertSnip(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetStemmed(&s, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Attach(&s, "notes.txt", []byte("attached")); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetMeta(&s, "source", "field"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Remove(s.UUID); err != nil {
+		t.Fatalf("Remove returned error: %v", err)
+	}
+	if exists, err := st.SnipExists(s.UUID); err != nil || exists {
+		t.Fatalf("expected the removed snip to be gone, got %v: %v", exists, err)
+	}
+	trashed, err := st.ListTrash()
+	if err != nil || len(trashed) != 1 || trashed[0].UUID != s.UUID {
+		t.Fatalf("expected the snip in the trash, got %+v: %v", trashed, err)
+	}
+
+	restored, err := st.UndoRemove()
+	if err != nil {
+		t.Fatalf("UndoRemove returned error: %v", err)
+	}
+	if restored.UUID != s.UUID || restored.Data != s.Data || len(restored.Attachments) != 1 {
+		t.Errorf("expected the snip restored with its attachment, got %+v", restored)
+	}
+	if value, err := st.GetMeta(&restored, "source"); err != nil || value != "field" {
+		t.Errorf("expected metadata restored, got %q: %v", value, err)
+	}
+	// the index is rebuilt in the previous stemming mode
+	if stemmed, err := st.Stemmed(&restored); err != nil || stemmed {
+		t.Errorf("expected the snip indexed verbatim, got stemmed %v: %v", stemmed, err)
+	}
+	results, err := st.SearchIndexTerm([]string{"running"}, true)
+	if err != nil || len(results) != 1 {
+		t.Errorf("expected the restored snip found by search, got %v: %v", results, err)
+	}
+	if _, err := st.UndoRemove(); !errors.Is(err, ErrTrashEmpty) {
+		t.Errorf("expected ErrTrashEmpty, got %v", err)
+	}
+
+	if err := st.Remove(s.UUID); err != nil {
+		t.Fatal(err)
+	}
+	count, err := st.EmptyTrash()
+	if err != nil || count != 1 {
+		t.Errorf("expected one snip deleted from the trash, got %d: %v", count, err)
+	}
+	if trashed, err := st.ListTrash(); err != nil || len(trashed) != 0 {
+		t.Errorf("expected an empty trash, got %+v: %v", trashed, err)
+	}
+
+	// without soft delete nothing is kept
+	SoftDelete = false
+	defer func() { SoftDelete = true }()
+	other := New()
+	if err := st.InsertSnip(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Remove(other.UUID); err != nil {
+		t.Fatal(err)
+	}
+	if trashed, err := st.ListTrash(); err != nil || len(trashed) != 0 {
+		t.Errorf("expected nothing trashed without soft delete, got %+v: %v", trashed, err)
+	}
+}
+
+func TestSnipRenderGist(t *testing.T) {
+	s := New()
+	s.Name = NameTest
+	s.Data = "echo gist"
+	s.Attachments = []Attachment{
+		{Name: "small.txt", Data: []byte("small"), Size: 5},
+		{Name: "large.txt", Data: []byte("large text"), Size: 10},
+		{Name: "binary.bin", Data: []byte{0, 1, 2}, Size: 3},
+	}
+
+	gist, err := s.RenderGist(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NameTest + "\n" + strings.Repeat("=", len(NameTest)) + "\n\n```\necho gist\n```\n" +
+		"\nsmall.txt (5 bytes)\n```\nsmall\n```\n" +
+		"\nlarge.txt (10 bytes, not inlined)\n" +
+		"\nbinary.bin (3 bytes, not inlined)\n"
+	if gist != expected {
+		t.Errorf("expected gist:\n%s\ngot:\n%s", expected, gist)
+	}
+
+	gist, err = s.RenderGist(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(gist, "small.txt (5 bytes, not inlined)\n") {
+		t.Errorf("expected no attachments inlined with a limit of zero, got:\n%s", gist)
+	}
+	if _, err := s.RenderGist(-1); err == nil {
+		t.Errorf("expected error for a negative limit")
+	}
+}
+
+func TestScoreCountsIDF(t *testing.T) {
+	st := newTestStore(t)
+
+	// every snip mentions linux, but only one mentions kernel
+	var snips []Snip
+	for _, data := range []string{"linux kernel", "linux desktop", "linux laptop", "linux server"} {
+		s := New()
+		s.Name = data
+		s.Data = data
+		if err := st.InsertSnip(s); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Index(&s); err != nil {
+			t.Fatal(err)
+		}
+		snips = append(snips, s)
+	}
+
+	total, err := st.TotalDocuments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 {
+		t.Errorf("expected 4 documents, got %d", total)
+	}
+	df, err := st.DocumentFrequency("linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df != 4 {
+		t.Errorf("expected linux in 4 documents, got %d", df)
+	}
+	if df, err := st.DocumentFrequency("kernel"); err != nil || df != 1 {
+		t.Errorf("expected kernel in 1 document, got %d %v", df, err)
+	}
+
+	terms := []string{"linux", "kernel"}
+	idf, err := st.TermIDF(terms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idf["kernel"] <= idf["linux"] || idf["linux"] <= 0 {
+		t.Errorf("expected rare term to outweigh a positive common term, got %v", idf)
+	}
+
+	// a snip indexed verbatim counts toward the frequency of the term it contains
+	verbatim := New()
+	verbatim.Name = "running kernels"
+	verbatim.Data = "running kernels"
+	if err := st.InsertSnip(verbatim); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetStemmed(&verbatim, false); err != nil {
+		t.Fatal(err)
+	}
+	if df, err := st.DocumentFrequency("kernel"); err != nil || df != 1 {
+		t.Errorf("expected the kernel stem in 1 document, got %d %v", df, err)
+	}
+	verbatimIDF, err := st.TermIDF([]string{"kernels"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := IDF(2, 5); math.Abs(verbatimIDF["kernels"]-expected) > 1e-9 {
+		t.Errorf("expected kernels in 2 documents with idf %f, got %v", expected, verbatimIDF)
+	}
+	if err := st.Remove(verbatim.UUID); err != nil {
+		t.Fatal(err)
+	}
+	idf, err = st.TermIDF(terms)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// matching only the rare term covers more than matching only the common term
+	weights := ScoreWeights{Coverage: 1, Prominence: 0}
+	rare, err := st.ScoreCountsIDF(snips[0].UUID, terms, []SearchCount{{Term: "kernel", Stem: "kernel", Count: 1}}, weights, idf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	common, err := st.ScoreCountsIDF(snips[0].UUID, terms, []SearchCount{{Term: "linux", Stem: "linux", Count: 1}}, weights, idf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rare <= common {
+		t.Errorf("expected rare term score %f to exceed common term score %f", rare, common)
+	}
+	if math.Abs(rare+common-1) > 1e-9 {
+		t.Errorf("expected coverage of both terms to sum to 1, got %f", rare+common)
+	}
+
+	// without idf both terms are weighted as 1
+	even, err := st.ScoreCountsIDF(snips[0].UUID, terms, []SearchCount{{Term: "linux", Stem: "linux", Count: 1}}, weights, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(even-0.5) > 1e-9 {
+		t.Errorf("expected coverage 0.5 without idf, got %f", even)
+	}
+}
+
+func TestBackupDatabase(t *testing.T) {
+	countSnips := func(conn *sqlite3.Conn) int {
+		stmt, err := conn.Prepare(`SELECT count(*) FROM snip`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		if _, err := stmt.Step(); err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		if err := stmt.Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	// an existing file is replaced by the finished backup
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "backup.sqlite3")
+	if err := os.WriteFile(dest, []byte("previous backup"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.BackupDatabase(dest); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := sqlite3.Open(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if backedUp, expected := countSnips(conn), countSnips(database.Conn); backedUp != expected {
+		t.Errorf("expected %d snips in the backup, got %d", expected, backedUp)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the backup in its directory, got %d entries", len(entries))
+	}
+
+	// the open database is never overwritten
+	if err := database.BackupDatabase(DatabasePath); !errors.Is(err, database.ErrBackupIsDatabase) {
+		t.Errorf("expected ErrBackupIsDatabase, got %v", err)
+	}
+	if countSnips(database.Conn) == 0 {
+		t.Errorf("expected the open database to keep its snips")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	conn, readOnly := database.Conn, database.ReadOnly
+	defer func() {
+		database.Conn, database.ReadOnly = conn, readOnly
+	}()
+	pragma := func(name string) string {
+		stmt, err := database.Conn.Prepare(`PRAGMA ` + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		if _, err := stmt.Step(); err != nil {
+			t.Fatal(err)
+		}
+		var value string
+		if err := stmt.Scan(&value); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	p := filepath.Join(t.TempDir(), "configure.sqlite3")
+	var err error
+	database.Conn, err = sqlite3.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.ReadOnly = false
+	if err := database.Configure(); err != nil {
+		t.Fatal(err)
+	}
+	if mode := pragma("journal_mode"); mode != "wal" {
+		t.Errorf("expected wal journal mode, got %s", mode)
+	}
+	if keys := pragma("foreign_keys"); keys != "1" {
+		t.Errorf("expected foreign keys to be enabled, got %s", keys)
+	}
+	database.Conn.Close()
+
+	// a read-only connection cannot change the journal mode, but is still configured
+	database.Conn, err = sqlite3.Open(p, sqlite3.OPEN_READONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Conn.Close()
+	database.ReadOnly = true
+	if err := database.Configure(); err != nil {
+		t.Fatalf("expected read-only connection to be configured, got %v", err)
+	}
+	if keys := pragma("foreign_keys"); keys != "1" {
+		t.Errorf("expected foreign keys to be enabled read-only, got %s", keys)
+	}
+}
+
+func TestGatherIndexedContext(t *testing.T) {
+	st := newTestStore(t)
+
+	s := New()
+	s.Data = "the universities opened early"
+	if err := st.InsertSnip(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Index(&s); err != nil {
+		t.Fatal(err)
+	}
+
+	// the indexed stem univers would stem again to univ, which is not indexed
+	ctx, err := st.GatherIndexedContext(&s, "univers", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx) != 1 || ctx[0].Term != "universities" {
+		t.Fatalf("expected context of universities, got %+v", ctx)
+	}
+	if !reflect.DeepEqual(ctx[0].Before, []string{"the"}) || !reflect.DeepEqual(ctx[0].After, []string{"opened"}) {
+		t.Errorf("unexpected adjacent words %+v", ctx[0])
+	}
+}
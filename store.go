@@ -0,0 +1,39 @@
+package snip
+
+import (
+	"errors"
+	"github.com/bvinc/go-sqlite-lite/sqlite3"
+	"github.com/ryanfrishkorn/snip/database"
+)
+
+// ErrReadOnly is returned by operations that modify a store opened read-only
+var ErrReadOnly = errors.New("database opened read-only")
+
+// Store provides access to the snips of a single database connection.
+// Methods on Snip and package-level functions use the default store bound to database.Conn.
+type Store struct {
+	Conn *sqlite3.Conn
+	// ReadOnly causes write operations to return ErrReadOnly before attempting the write
+	ReadOnly bool
+	// NoIndex skips indexing the snips inserted by ImportAll, ImportJSONL, and ImportFile, which makes large imports
+	// faster. The snips are not found by index searches until indexed, such as by UnindexedSnips and IndexSnips.
+	NoIndex bool
+}
+
+// NewStore returns a Store that operates on conn
+func NewStore(conn *sqlite3.Conn) *Store {
+	return &Store{Conn: conn}
+}
+
+// defaultStore returns a Store bound to the current values of database.Conn and database.ReadOnly
+func defaultStore() *Store {
+	return &Store{Conn: database.Conn, ReadOnly: database.ReadOnly}
+}
+
+// checkWritable returns ErrReadOnly if the store must not be modified
+func (st *Store) checkWritable() error {
+	if st.ReadOnly {
+		return ErrReadOnly
+	}
+	return nil
+}
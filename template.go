@@ -0,0 +1,66 @@
+package snip
+
+import (
+	"github.com/google/uuid"
+	"strings"
+	"text/template"
+)
+
+// TemplatePresets are named templates that may be used in place of a template string
+var TemplatePresets = map[string]string{
+	"short": `{{short .UUID}} {{.Name}}`,
+	"long":  `{{.UUID}} {{.Timestamp.Format "2006-01-02 15:04:05"}} {{.Name}}`,
+}
+
+// ScoredSnip is a search result rendered by templates, where Score and SearchCounts are empty for data searches
+type ScoredSnip struct {
+	Snip
+	Score        float64
+	SearchCounts []SearchCount
+}
+
+// templateFuncs are the functions available to templates in addition to the text/template builtins
+var templateFuncs = template.FuncMap{
+	"short": func(id uuid.UUID) string {
+		return ShortenUUID(id)[0]
+	},
+}
+
+// RenderTemplate executes the text/template tmpl against data and returns the output
+func RenderTemplate(tmpl string, data any) (string, error) {
+	t, err := template.New("output").Funcs(templateFuncs).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = t.Execute(&b, data)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Expand executes the data of the snip as a text/template with vars, so that data such as ssh {{.host}} becomes
+// ssh server1 with host=server1. A placeholder without a value in vars is an error.
+func (s *Snip) Expand(vars map[string]string) (string, error) {
+	return s.expand(vars, "missingkey=error")
+}
+
+// ExpandMissingOK behaves like Expand, but placeholders without a value in vars are replaced by an empty string
+func (s *Snip) ExpandMissingOK(vars map[string]string) (string, error) {
+	return s.expand(vars, "missingkey=zero")
+}
+
+// expand executes the data of the snip as a text/template with vars and the missingkey option
+func (s *Snip) expand(vars map[string]string, missingKey string) (string, error) {
+	t, err := template.New("data").Option(missingKey).Parse(s.Data)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = t.Execute(&b, vars)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
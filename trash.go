@@ -0,0 +1,284 @@
+package snip
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/ryanfrishkorn/snip/database"
+	"time"
+)
+
+// SoftDelete causes Remove to move each snip to the trash, from which UndoRemove restores it, rather than deleting it
+var SoftDelete = true
+
+// ErrTrashEmpty indicates that there is no removed snip to restore
+var ErrTrashEmpty = errors.New("trash is empty")
+
+// TrashedSnip is a snip moved to the trash by Remove
+type TrashedSnip struct {
+	ID      int64 // identifies the removal, as a snip with the same uuid may be removed more than once
+	UUID    uuid.UUID
+	Name    string
+	Trashed time.Time
+}
+
+// trash copies the snip with its attachments, revisions, metadata, and stemming mode to the trash tables, leaving
+// Remove to delete the originals. The index is not kept, as it is rebuilt from the data when the snip is restored.
+func (st *Store) trash(id uuid.UUID) error {
+	exists, err := st.SnipExists(id)
+	if err != nil {
+		return err
+	}
+	// removing a missing snip deletes nothing, so there is nothing to keep
+	if !exists {
+		return nil
+	}
+	stemmed, err := st.Stemmed(&Snip{UUID: id})
+	if err != nil {
+		return err
+	}
+
+	err = st.Conn.Exec(`INSERT INTO snip_trash (uuid, timestamp, name, data, favorite, accessed, stemmed, trashed)
+		SELECT uuid, timestamp, name, data, favorite, accessed, ?, ? FROM snip WHERE uuid = ?`,
+		stemmed, time.Now().Format(time.RFC3339Nano), id.String())
+	if err != nil {
+		return err
+	}
+	trashID := st.Conn.LastInsertRowID()
+	copies := []string{
+		// attachment data is copied within the database rather than read into memory
+		`INSERT INTO snip_attachment_trash (trash_id, uuid, snip_uuid, timestamp, name, data, size, mime)
+			SELECT ?, uuid, snip_uuid, timestamp, name, data, size, mime FROM snip_attachment WHERE snip_uuid = ?`,
+		`INSERT INTO snip_revision_trash (trash_id, snip_uuid, revision, timestamp, name, data, saved)
+			SELECT ?, snip_uuid, revision, timestamp, name, data, saved FROM snip_revision WHERE snip_uuid = ?`,
+		`INSERT INTO snip_meta_trash (trash_id, snip_uuid, key, value)
+			SELECT ?, snip_uuid, key, value FROM snip_meta WHERE snip_uuid = ?`,
+	}
+	for _, c := range copies {
+		err = st.Conn.Exec(c, trashID, id.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTrash is a wrapper around Store.ListTrash using the default store
+func ListTrash() ([]TrashedSnip, error) {
+	return defaultStore().ListTrash()
+}
+
+// ListTrash returns the snips in the trash, most recently removed first
+func (st *Store) ListTrash() ([]TrashedSnip, error) {
+	var trashed []TrashedSnip
+	stmt, err := st.Conn.Prepare(`SELECT trash_id, uuid, name, trashed FROM snip_trash ORDER BY trash_id DESC`)
+	if err != nil {
+		return trashed, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return trashed, err
+		}
+		if !hasRow {
+			break
+		}
+		var t TrashedSnip
+		var idStr, trashedStr string
+		err = stmt.Scan(&t.ID, &idStr, &t.Name, &trashedStr)
+		if err != nil {
+			return trashed, err
+		}
+		t.UUID, err = uuid.Parse(idStr)
+		if err != nil {
+			return trashed, err
+		}
+		t.Trashed, err = time.Parse(time.RFC3339Nano, trashedStr)
+		if err != nil {
+			return trashed, err
+		}
+		trashed = append(trashed, t)
+	}
+	return trashed, nil
+}
+
+// UndoRemove is a wrapper around Store.UndoRemove using the default store
+func UndoRemove() (Snip, error) {
+	return defaultStore().UndoRemove()
+}
+
+// UndoRemove restores the most recently removed snip from the trash, returning ErrTrashEmpty if there is none
+func (st *Store) UndoRemove() (Snip, error) {
+	if err := st.checkWritable(); err != nil {
+		return Snip{}, err
+	}
+	trashed, err := st.ListTrash()
+	if err != nil {
+		return Snip{}, err
+	}
+	if len(trashed) == 0 {
+		return Snip{}, ErrTrashEmpty
+	}
+	return st.RestoreTrashed(trashed[0].ID)
+}
+
+// UndoRemoves is a wrapper around Store.UndoRemoves using the default store
+func UndoRemoves(n int) ([]Snip, error) {
+	return defaultStore().UndoRemoves(n)
+}
+
+// UndoRemoves restores up to n of the most recently removed snips from the trash in a single transaction, most recent
+// first, such as the snips removed together by RemoveBySearch. ErrTrashEmpty is returned if there are none.
+func (st *Store) UndoRemoves(n int) ([]Snip, error) {
+	if err := st.checkWritable(); err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, fmt.Errorf("number of removals to restore must be at least 1")
+	}
+	trashed, err := st.ListTrash()
+	if err != nil {
+		return nil, err
+	}
+	if len(trashed) == 0 {
+		return nil, ErrTrashEmpty
+	}
+	if n > len(trashed) {
+		n = len(trashed)
+	}
+
+	var restored []Snip
+	err = database.WithTx(st.Conn, func() error {
+		restored = nil
+		for _, t := range trashed[:n] {
+			s, err := st.RestoreTrashed(t.ID)
+			if err != nil {
+				return err
+			}
+			restored = append(restored, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// RestoreTrashed is a wrapper around Store.RestoreTrashed using the default store
+func RestoreTrashed(trashID int64) (Snip, error) {
+	return defaultStore().RestoreTrashed(trashID)
+}
+
+// RestoreTrashed moves the removed snip identified by trashID back from the trash with its attachments, revisions,
+// and metadata, indexing it in its previous stemming mode. A snip with the same uuid must not exist.
+func (st *Store) RestoreTrashed(trashID int64) (Snip, error) {
+	if err := st.checkWritable(); err != nil {
+		return Snip{}, err
+	}
+	stmt, err := st.Conn.Prepare(`SELECT uuid, stemmed FROM snip_trash WHERE trash_id = ?`, trashID)
+	if err != nil {
+		return Snip{}, err
+	}
+	hasRow, err := stmt.Step()
+	if err != nil {
+		stmt.Close()
+		return Snip{}, err
+	}
+	if !hasRow {
+		stmt.Close()
+		return Snip{}, fmt.Errorf("could not locate removal %d in trash", trashID)
+	}
+	var idStr string
+	var stemmed bool
+	err = stmt.Scan(&idStr, &stemmed)
+	stmt.Close()
+	if err != nil {
+		return Snip{}, err
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return Snip{}, err
+	}
+	exists, err := st.SnipExists(id)
+	if err != nil {
+		return Snip{}, err
+	}
+	if exists {
+		return Snip{}, fmt.Errorf("snip %s already exists", id)
+	}
+
+	err = database.WithTx(st.Conn, func() error {
+		restores := []string{
+			`INSERT INTO snip (uuid, timestamp, name, data, favorite, accessed)
+				SELECT uuid, timestamp, name, data, favorite, accessed FROM snip_trash WHERE trash_id = ?`,
+			`INSERT INTO snip_attachment (uuid, snip_uuid, timestamp, name, data, size, mime)
+				SELECT uuid, snip_uuid, timestamp, name, data, size, mime FROM snip_attachment_trash WHERE trash_id = ?`,
+			`INSERT INTO snip_revision (snip_uuid, revision, timestamp, name, data, saved)
+				SELECT snip_uuid, revision, timestamp, name, data, saved FROM snip_revision_trash WHERE trash_id = ?`,
+			`INSERT OR REPLACE INTO snip_meta (snip_uuid, key, value)
+				SELECT snip_uuid, key, value FROM snip_meta_trash WHERE trash_id = ?`,
+		}
+		for _, r := range restores {
+			if err := st.Conn.Exec(r, trashID); err != nil {
+				return err
+			}
+		}
+		if err := st.deleteTrash(trashID); err != nil {
+			return err
+		}
+		s, err := st.GetFromUUID(id.String())
+		if err != nil {
+			return err
+		}
+		termsPositions, err := s.analyzeTerms(stemmed)
+		if err != nil {
+			return err
+		}
+		return st.writeIndex(&s, termsPositions, stemmed)
+	})
+	if err != nil {
+		return Snip{}, err
+	}
+	return st.GetFromUUID(id.String())
+}
+
+// deleteTrash permanently deletes the removal identified by trashID from the trash tables
+func (st *Store) deleteTrash(trashID int64) error {
+	for _, table := range []string{"snip_trash", "snip_attachment_trash", "snip_revision_trash", "snip_meta_trash"} {
+		if err := st.Conn.Exec(`DELETE FROM `+table+` WHERE trash_id = ?`, trashID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EmptyTrash is a wrapper around Store.EmptyTrash using the default store
+func EmptyTrash() (int, error) {
+	return defaultStore().EmptyTrash()
+}
+
+// EmptyTrash permanently deletes every snip in the trash, returning the number deleted
+func (st *Store) EmptyTrash() (int, error) {
+	if err := st.checkWritable(); err != nil {
+		return 0, err
+	}
+	trashed, err := st.ListTrash()
+	if err != nil {
+		return 0, err
+	}
+	err = database.WithTx(st.Conn, func() error {
+		for _, t := range trashed {
+			if err := st.deleteTrash(t.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(trashed), nil
+}
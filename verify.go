@@ -0,0 +1,161 @@
+package snip
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Problem describes an inconsistency found in the database
+type Problem struct {
+	Table       string `json:"table"`
+	UUID        string `json:"uuid"`
+	Description string `json:"description"`
+}
+
+// VerifyDatabase is a wrapper around Store.VerifyDatabase using the default store
+func VerifyDatabase() ([]Problem, error) {
+	return defaultStore().VerifyDatabase()
+}
+
+// VerifyDatabase checks references between tables, attachment sizes, and timestamps, returning each problem found
+func (st *Store) VerifyDatabase() ([]Problem, error) {
+	var problems []Problem
+
+	// attachments and index entries must belong to an existing snip
+	found, err := st.queryProblems(`SELECT a.uuid, a.snip_uuid FROM snip_attachment a LEFT JOIN snip s ON s.uuid = a.snip_uuid WHERE s.uuid IS NULL`,
+		"snip_attachment", "references missing snip %s")
+	if err != nil {
+		return problems, err
+	}
+	problems = append(problems, found...)
+
+	found, err = st.queryProblems(`SELECT i.uuid, count() FROM snip_index i LEFT JOIN snip s ON s.uuid = i.uuid WHERE s.uuid IS NULL GROUP BY i.uuid`,
+		"snip_index", "%s terms indexed for missing snip")
+	if err != nil {
+		return problems, err
+	}
+	problems = append(problems, found...)
+
+	found, err = st.queryProblems(`SELECT m.snip_uuid, m.key FROM snip_meta m LEFT JOIN snip s ON s.uuid = m.snip_uuid WHERE s.uuid IS NULL`,
+		"snip_meta", "key %s set for missing snip")
+	if err != nil {
+		return problems, err
+	}
+	problems = append(problems, found...)
+
+	// data is cast so that length counts bytes regardless of the stored type
+	found, err = st.queryProblems(`SELECT uuid, size || ' bytes recorded, ' || length(CAST(data AS BLOB)) || ' bytes of data' FROM snip_attachment WHERE CAST(size AS INTEGER) != length(CAST(data AS BLOB))`,
+		"snip_attachment", "size does not match data: %s")
+	if err != nil {
+		return problems, err
+	}
+	problems = append(problems, found...)
+
+	timestampColumns := []struct {
+		table  string
+		id     string
+		column string
+	}{
+		{"snip", "uuid", "timestamp"},
+		{"snip_attachment", "uuid", "timestamp"},
+		{"snip_revision", "snip_uuid", "timestamp"},
+		{"snip_revision", "snip_uuid", "saved"},
+	}
+	for _, c := range timestampColumns {
+		found, err = st.verifyTimestamps(c.table, c.id, c.column)
+		if err != nil {
+			return problems, err
+		}
+		problems = append(problems, found...)
+	}
+
+	return problems, nil
+}
+
+// VerifyAttachment is a wrapper around Store.VerifyAttachment using the default store
+func VerifyAttachment(id uuid.UUID) (bool, error) {
+	return defaultStore().VerifyAttachment(id)
+}
+
+// VerifyAttachment reports whether the recorded size of the attachment matches the length of its data. The length is
+// computed by sqlite, so the data is not read into memory. No checksum is stored with attachments, so data of the
+// recorded size is not otherwise checked.
+func (st *Store) VerifyAttachment(id uuid.UUID) (bool, error) {
+	stmt, err := st.Conn.Prepare(`SELECT CAST(size AS INTEGER) = length(CAST(data AS BLOB)) FROM snip_attachment WHERE uuid = ?`, id.String())
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return false, err
+	}
+	if !hasRow {
+		return false, fmt.Errorf("attachment %s does not exist", id)
+	}
+	var match bool
+	err = stmt.Scan(&match)
+	if err != nil {
+		return false, err
+	}
+	return match, nil
+}
+
+// queryProblems returns a Problem for each row of query, which selects an id and a detail formatted into the description
+func (st *Store) queryProblems(query string, table string, description string) ([]Problem, error) {
+	var problems []Problem
+	stmt, err := st.Conn.Prepare(query)
+	if err != nil {
+		return problems, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return problems, err
+		}
+		if !hasRow {
+			break
+		}
+		var id, detail string
+		err = stmt.Scan(&id, &detail)
+		if err != nil {
+			return problems, err
+		}
+		problems = append(problems, Problem{Table: table, UUID: id, Description: fmt.Sprintf(description, detail)})
+	}
+	return problems, nil
+}
+
+// verifyTimestamps returns a Problem for each value of column in table that does not parse as RFC3339Nano
+func (st *Store) verifyTimestamps(table string, id string, column string) ([]Problem, error) {
+	var problems []Problem
+	stmt, err := st.Conn.Prepare(fmt.Sprintf(`SELECT %s, %s FROM %s`, id, column, table))
+	if err != nil {
+		return problems, err
+	}
+	defer stmt.Close()
+
+	for {
+		hasRow, err := stmt.Step()
+		if err != nil {
+			return problems, err
+		}
+		if !hasRow {
+			break
+		}
+		var idStr, timestamp string
+		err = stmt.Scan(&idStr, &timestamp)
+		if err != nil {
+			return problems, err
+		}
+		_, err = time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			problems = append(problems, Problem{Table: table, UUID: idStr, Description: fmt.Sprintf("%s %q is not a valid timestamp", column, timestamp)})
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,73 @@
+package snip
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// indexWordSymbolsKey is the snip_setting key recording whether the index was built with KeepWordSymbols
+const indexWordSymbolsKey = "index_word_symbols"
+
+// IndexWordSymbolsError indicates that the index was built with a KeepWordSymbols setting other than the current one,
+// so that indexed word positions do not refer to the words split from snip data
+type IndexWordSymbolsError struct {
+	Index bool
+	Keep  bool
+}
+
+func (e *IndexWordSymbolsError) Error() string {
+	return fmt.Sprintf("index was built with word symbols kept %t and must be rebuilt to keep them %t", e.Index, e.Keep)
+}
+
+// IndexWordSymbols is a wrapper around Store.IndexWordSymbols using the default store
+func IndexWordSymbols() (bool, bool, error) {
+	return defaultStore().IndexWordSymbols()
+}
+
+// IndexWordSymbols returns whether the index was built keeping word symbols, and false for the second value if
+// nothing is indexed. Indexes built before the setting was recorded did not keep word symbols.
+func (st *Store) IndexWordSymbols() (bool, bool, error) {
+	stmt, err := st.Conn.Prepare(`SELECT value FROM snip_setting WHERE key = ?`, indexWordSymbolsKey)
+	if err != nil {
+		return false, false, err
+	}
+	defer stmt.Close()
+
+	hasRow, err := stmt.Step()
+	if err != nil {
+		return false, false, err
+	}
+	if hasRow {
+		var value string
+		err = stmt.Scan(&value)
+		if err != nil {
+			return false, false, err
+		}
+		keep, err := strconv.ParseBool(value)
+		return keep, true, err
+	}
+
+	empty, err := st.indexIsEmpty()
+	if err != nil || empty {
+		return false, false, err
+	}
+	return false, true, nil
+}
+
+// checkIndexWordSymbols returns an IndexWordSymbolsError if the index was built with another KeepWordSymbols setting
+func (st *Store) checkIndexWordSymbols() error {
+	keep, indexed, err := st.IndexWordSymbols()
+	if err != nil {
+		return err
+	}
+	if indexed && keep != KeepWordSymbols {
+		return &IndexWordSymbolsError{Index: keep, Keep: KeepWordSymbols}
+	}
+	return nil
+}
+
+// recordIndexWordSymbols stores KeepWordSymbols as the setting of the index, which must first be checked by
+// checkIndexWordSymbols
+func (st *Store) recordIndexWordSymbols() error {
+	return st.Conn.Exec(`INSERT OR REPLACE INTO snip_setting (key, value) VALUES (?, ?)`, indexWordSymbolsKey, strconv.FormatBool(KeepWordSymbols))
+}
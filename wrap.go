@@ -0,0 +1,61 @@
+package snip
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// WrapText breaks lines of s longer than width runes at spaces, keeping existing line breaks. Words longer than width
+// are placed on a line of their own rather than split. Spaces at a break are removed, while indentation and spacing
+// elsewhere are kept. A width of 0 or less returns s unchanged.
+func WrapText(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = wrapLine(line, width)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// wrapLine breaks a single line at spaces so that each part is at most width runes where possible
+func wrapLine(line string, width int) string {
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	rest := line
+	for rest != "" {
+		// each word is preceded by the spaces separating it from the previous word
+		trimmed := strings.TrimLeft(rest, " \t")
+		space := rest[:len(rest)-len(trimmed)]
+		end := strings.IndexAny(trimmed, " \t")
+		if end < 0 {
+			end = len(trimmed)
+		}
+		word := trimmed[:end]
+		rest = trimmed[end:]
+		if word == "" {
+			// trailing spaces are kept only if they fit
+			if col+utf8.RuneCountInString(space) <= width {
+				b.WriteString(space)
+			}
+			break
+		}
+
+		spaceLen := utf8.RuneCountInString(space)
+		wordLen := utf8.RuneCountInString(word)
+		if col > 0 && col+spaceLen+wordLen > width {
+			b.WriteString("\n")
+			col = 0
+			space = ""
+			spaceLen = 0
+		}
+		b.WriteString(space)
+		b.WriteString(word)
+		col += spaceLen + wordLen
+	}
+	return b.String()
+}